@@ -0,0 +1,86 @@
+// Command cdc-handler runs a canal of its own and converts its row changes
+// with the plugin's EventHandler, printing each event to stdout. Stop it with
+// Ctrl-C.
+//
+//	go run ./example/cdc-handler -addr 127.0.0.1:3306 -user root -password secret -database demo
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	mysqlstream "github.com/le-vlad/benthos-mysql-plugin/lib"
+)
+
+func main() {
+	var (
+		addr     = flag.String("addr", "127.0.0.1:3306", "MySQL host:port")
+		user     = flag.String("user", "root", "replication user")
+		password = flag.String("password", "", "replication password")
+		database = flag.String("database", "", "database to stream")
+		flavor   = flag.String("flavor", "mysql", "server flavor, mysql or mariadb")
+	)
+	flag.Parse()
+
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr = *addr
+	cfg.User = *user
+	cfg.Password = *password
+	cfg.Flavor = *flavor
+	cfg.Dump.ExecutionPath = ""
+	if *database != "" {
+		cfg.IncludeTableRegex = []string{*database + "\\..*"}
+	}
+
+	c, err := canal.NewCanal(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	h, err := mysqlstream.NewEventHandler(c,
+		mysqlstream.WithAddr(*addr),
+		mysqlstream.WithDatabase(*database),
+		mysqlstream.WithFlavor(*flavor),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	c.SetEventHandler(h)
+
+	pos, err := c.GetMasterPos()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() { errs <- c.RunFrom(pos) }()
+
+	for {
+		select {
+		case msg := <-h.Messages():
+			data, _ := json.Marshal(msg.Data)
+			fmt.Printf("%s %s %s\n", msg.Event, msg.Table, data)
+		case err := <-errs:
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Command cdc-print streams row changes from a MySQL database and prints each
+// event to stdout, without a Benthos config. Stop it with Ctrl-C.
+//
+//	go run ./examples/cdc-print -addr 127.0.0.1:3306 -user root -password secret -database demo
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	mysqlstream "github.com/le-vlad/benthos-mysql-plugin/lib"
+)
+
+func main() {
+	var (
+		addr     = flag.String("addr", "127.0.0.1:3306", "MySQL host:port")
+		user     = flag.String("user", "root", "replication user")
+		password = flag.String("password", "", "replication password")
+		database = flag.String("database", "", "database to stream")
+		flavor   = flag.String("flavor", "mysql", "server flavor, mysql or mariadb")
+		tables   = flag.String("tables", "", "comma separated tables to stream, all tables when empty")
+		snapshot = flag.Bool("snapshot", false, "snapshot the tables before streaming")
+	)
+	flag.Parse()
+
+	opts := []mysqlstream.Option{
+		mysqlstream.WithAddr(*addr),
+		mysqlstream.WithUser(*user),
+		mysqlstream.WithPassword(*password),
+		mysqlstream.WithDatabase(*database),
+		mysqlstream.WithFlavor(*flavor),
+		mysqlstream.WithStreamSnapshot(*snapshot),
+	}
+	if *tables != "" {
+		opts = append(opts, mysqlstream.WithTables(strings.Split(*tables, ",")...))
+	}
+
+	input, err := mysqlstream.NewMysqlStreamInput(opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := input.Connect(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for {
+		msg, ack, err := input.Read(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			break
+		}
+		printMessage(msg)
+		_ = ack(ctx, nil)
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := input.Close(closeCtx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printMessage(msg *service.Message) {
+	body, _ := msg.AsBytes()
+	table, _ := msg.MetaGet("table")
+	event, _ := msg.MetaGet("event")
+	fmt.Printf("%s %s %s\n", event, table, body)
+}
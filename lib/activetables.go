@@ -0,0 +1,77 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// activeTables tracks the tables that changes have been captured from.
+type activeTables struct {
+	mu     sync.RWMutex
+	tables map[string]struct{}
+}
+
+// StreamedTables returns the db.table names of every table that row changes
+// have been captured from, in sorted order. Tables are added when their first
+// row is emitted and removed when they are dropped.
+func (m *MysqlStreamInput) StreamedTables() []string {
+	m.active.mu.RLock()
+	defer m.active.mu.RUnlock()
+
+	tables := make([]string, 0, len(m.active.tables))
+	for table := range m.active.tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func (m *MysqlStreamInput) markTableActive(table *schema.Table) {
+	key := table.String()
+
+	m.active.mu.RLock()
+	_, ok := m.active.tables[key]
+	m.active.mu.RUnlock()
+	if ok {
+		return
+	}
+
+	m.active.mu.Lock()
+	m.active.tables[key] = struct{}{}
+	m.active.mu.Unlock()
+
+	m.metrics.tableActive.Set(1, key)
+	m.logger.Infof("Capturing changes from table %s", key)
+}
+
+func (m *MysqlStreamInput) markTableInactive(key string) {
+	m.active.mu.Lock()
+	_, ok := m.active.tables[key]
+	delete(m.active.tables, key)
+	m.active.mu.Unlock()
+
+	if ok {
+		m.metrics.tableActive.Set(0, key)
+		m.logger.Infof("Stopped capturing changes from dropped table %s", key)
+	}
+}
+
+// OnTableChanged is called by canal after a DDL statement touches a table, and
+// removes the table from the active set when it no longer exists, streams it
+// with positional columns when its new schema cannot be resolved, or updates
+// it in schema_cache otherwise.
+func (m *MysqlStreamInput) OnTableChanged(header *replication.EventHeader, db string, table string) error {
+	resolved, err := m.canal.GetTable(db, table)
+	if errors.Is(err, schema.ErrTableNotExist) {
+		m.markTableInactive(db + "." + table)
+	}
+	if err == nil {
+		m.refreshCachedSchema(resolved)
+	}
+	m.degradeSchema(m.canal, db, table, err)
+	return nil
+}
@@ -0,0 +1,96 @@
+package mongodb_stream_benthos
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	auroraModeAuto = "auto"
+	auroraModeOn   = "on"
+	auroraModeOff  = "off"
+)
+
+// isAurora reports whether the server is Aurora MySQL, the only flavor that
+// defines aurora_version.
+func isAurora(conn *client.Conn) bool {
+	_, err := conn.Execute("SELECT @@aurora_version")
+	return err == nil
+}
+
+// checkAurora warns when the binlog retention of an Aurora cluster is shorter
+// than aurora_min_binlog_retention. Aurora purges binlog files as soon as no
+// replica reads them unless a retention is configured with
+// mysql.rds_set_configuration, so a stream that is down for a while cannot
+// resume where it stopped.
+func (m *MysqlStreamInput) checkAurora(conn *client.Conn) {
+	switch m.auroraMode {
+	case auroraModeOff:
+		return
+	case auroraModeAuto:
+		if !isAurora(conn) {
+			return
+		}
+	}
+
+	retention, ok, err := binlogRetention(conn)
+	switch {
+	case err != nil:
+		m.logger.Warnf("Failed to read the binlog retention of the Aurora cluster: %v", err)
+	case !ok:
+		m.logger.Warnf("The Aurora cluster has no binlog retention configured, so binlog files are purged as soon as possible and the stream cannot resume after being down; set one with CALL mysql.rds_set_configuration('binlog retention hours', %d)", hoursCeil(m.auroraMinBinlogRetention))
+	case retention < m.auroraMinBinlogRetention:
+		m.logger.Warnf("The binlog retention of the Aurora cluster is %v, shorter than aurora_min_binlog_retention of %v, so the stream cannot resume after being down for longer", retention, m.auroraMinBinlogRetention)
+	}
+}
+
+// binlogRetention returns the binlog retention hours reported by
+// mysql.rds_show_configuration, which is NULL when none is configured.
+func binlogRetention(conn *client.Conn) (time.Duration, bool, error) {
+	var value string
+	var found bool
+	var queryErr error
+	// The procedure returns its rows followed by the status of the CALL.
+	if _, err := conn.ExecuteMultiple("CALL mysql.rds_show_configuration", func(res *mysql.Result, err error) {
+		if err != nil {
+			queryErr = err
+			return
+		}
+		if res == nil || res.Resultset == nil {
+			return
+		}
+		for i := 0; i < res.RowNumber(); i++ {
+			name, _ := res.GetString(i, 0)
+			if !strings.EqualFold(name, "binlog retention hours") {
+				continue
+			}
+			if isNull, _ := res.IsNull(i, 1); isNull {
+				return
+			}
+			value, _ = res.GetString(i, 1)
+			found = true
+		}
+	}); err != nil {
+		return 0, false, err
+	}
+	if queryErr != nil {
+		return 0, false, queryErr
+	}
+	if !found {
+		return 0, false, nil
+	}
+
+	hours, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(hours * float64(time.Hour)), true, nil
+}
+
+func hoursCeil(d time.Duration) int {
+	return int((d + time.Hour - 1) / time.Hour)
+}
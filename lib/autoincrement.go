@@ -0,0 +1,31 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// autoIncrementColumn returns the position of the AUTO_INCREMENT column of a
+// table, of which MySQL allows at most one.
+func autoIncrementColumn(table *schema.Table) (int, bool) {
+	for i, col := range table.Columns {
+		if col.IsAuto {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// autoIncrementID returns the value allocated to the AUTO_INCREMENT column by
+// an insert read from the binlog, for the auto_increment_id metadata field.
+// Snapshot rows and other changes have none.
+func autoIncrementID(e *canal.RowsEvent, row []any) string {
+	if e.Action != canal.InsertAction || e.Header == nil {
+		return ""
+	}
+	i, ok := autoIncrementColumn(e.Table)
+	if !ok || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	return keyValue(row[i])
+}
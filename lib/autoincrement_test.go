@@ -0,0 +1,46 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestAutoIncrementID(t *testing.T) {
+	auto := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id", IsAuto: true}, {Name: "sku"}},
+		PKColumns: []int{0},
+	}
+	manual := &schema.Table{
+		Schema:    "shop",
+		Name:      "skus",
+		Columns:   []schema.TableColumn{{Name: "sku"}, {Name: "name"}},
+		PKColumns: []int{0},
+	}
+	header := &replication.EventHeader{Timestamp: 1, LogPos: 100}
+
+	tests := []struct {
+		name  string
+		event *canal.RowsEvent
+		row   []any
+		want  string
+	}{
+		{name: "insert", event: &canal.RowsEvent{Table: auto, Action: canal.InsertAction, Header: header}, row: []any{uint64(42), "A-1"}, want: "42"},
+		{name: "update", event: &canal.RowsEvent{Table: auto, Action: canal.UpdateAction, Header: header}, row: []any{uint64(42), "A-1"}},
+		{name: "delete", event: &canal.RowsEvent{Table: auto, Action: canal.DeleteAction, Header: header}, row: []any{uint64(42), "A-1"}},
+		{name: "snapshot row", event: &canal.RowsEvent{Table: auto, Action: canal.InsertAction}, row: []any{uint64(42), "A-1"}},
+		{name: "no auto increment column", event: &canal.RowsEvent{Table: manual, Action: canal.InsertAction, Header: header}, row: []any{"A-1", "pen"}},
+		{name: "null value", event: &canal.RowsEvent{Table: auto, Action: canal.InsertAction, Header: header}, row: []any{nil, "A-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoIncrementID(tt.event, tt.row); got != tt.want {
+				t.Errorf("autoIncrementID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
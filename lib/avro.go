@@ -0,0 +1,456 @@
+package mongodb_stream_benthos
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/linkedin/goavro/v2"
+	"github.com/shopspring/decimal"
+)
+
+const outputFormatAvro = "avro"
+
+var (
+	avroInvalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	decimalRawType       = regexp.MustCompile(`\((\d+)(?:,(\d+))?\)`)
+)
+
+// avroField describes how a single column is represented in a table's Avro
+// record.
+type avroField struct {
+	name     string
+	column   string
+	typeName string
+	schema   any
+	convert  func(any) (any, error)
+
+	// labels marks ENUM and SET columns, whose values are converted to
+	// their labels before convert.
+	labels bool
+}
+
+type avroCodec struct {
+	codec    *goavro.Codec
+	fields   []avroField
+	schemaID int
+
+	// table is the table the codec was last looked up for and fingerprint
+	// identifies its column definitions, so that the schema is only derived
+	// again when they change.
+	table       *schema.Table
+	fingerprint string
+}
+
+// avroEncoder derives an Avro schema per table from its column metadata and
+// encodes rows with it. When a schema registry is configured the schema is
+// registered once per table version and messages use the Confluent wire
+// format.
+type avroEncoder struct {
+	registryURL    string
+	client         *http.Client
+	tinyint1AsBool bool
+
+	mu     sync.Mutex
+	codecs map[string]*avroCodec
+}
+
+func newAvroEncoder(registryURL string) *avroEncoder {
+	return &avroEncoder{
+		registryURL: strings.TrimSuffix(registryURL, "/"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		codecs:      map[string]*avroCodec{},
+	}
+}
+
+// Encode encodes row changes as Avro and messages not tied to a table, such
+// as lifecycle events, as JSON.
+func (a *avroEncoder) Encode(ctx context.Context, msg StreamMessage) ([]byte, error) {
+	if msg.table == nil {
+		return jsonEncoder{}.Encode(ctx, msg)
+	}
+	return a.encode(ctx, msg.table, msg.Data)
+}
+
+func (a *avroEncoder) structured(msg StreamMessage) (any, bool) {
+	if msg.table == nil {
+		return jsonBody(msg), true
+	}
+	return nil, false
+}
+
+func (a *avroEncoder) encode(ctx context.Context, table *schema.Table, data map[string]any) ([]byte, error) {
+	c, err := a.codecFor(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]any, len(c.fields))
+	for i, f := range c.fields {
+		v := data[f.column]
+		if v == nil {
+			record[f.name] = nil
+			continue
+		}
+		if f.labels {
+			// The value lists of ENUM and SET columns are not part of the
+			// schema, so the codec may have been built before an ALTER
+			// changed them and labels are looked up in the current table.
+			v = convertData(table.Columns[i], v)
+		}
+		native, err := f.convert(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", f.column, err)
+		}
+		record[f.name] = goavro.Union(f.typeName, native)
+	}
+
+	var buf []byte
+	if a.registryURL != "" {
+		buf = make([]byte, 5)
+		binary.BigEndian.PutUint32(buf[1:], uint32(c.schemaID))
+	}
+	return c.codec.BinaryFromNative(buf, record)
+}
+
+// codecFor returns the codec of a table, keyed by its schema qualified name
+// and rebuilt whenever its column definitions change.
+func (a *avroEncoder) codecFor(ctx context.Context, table *schema.Table) (*avroCodec, error) {
+	key := table.Schema + "." + table.Name
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cached, ok := a.codecs[key]
+	if ok && cached.table == table {
+		return cached, nil
+	}
+	fingerprint := avroFingerprint(table)
+	if ok && cached.fingerprint == fingerprint {
+		cached.table = table
+		return cached, nil
+	}
+
+	fields := avroFields(table, a.tinyint1AsBool)
+	fieldSchemas := make([]any, 0, len(fields))
+	for _, f := range fields {
+		fieldSchemas = append(fieldSchemas, map[string]any{
+			"name":    f.name,
+			"type":    []any{"null", f.schema},
+			"default": nil,
+		})
+	}
+	schemaJSON, err := json.Marshal(map[string]any{
+		"type":      "record",
+		"name":      avroName(table.Name),
+		"namespace": avroName(table.Schema),
+		"fields":    fieldSchemas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := goavro.NewCodec(string(schemaJSON))
+	if err != nil {
+		return nil, fmt.Errorf("building avro schema for %s: %w", table, err)
+	}
+	c := &avroCodec{codec: codec, fields: fields, table: table, fingerprint: fingerprint}
+	if a.registryURL != "" {
+		if c.schemaID, err = a.register(ctx, avroSubject(table), string(schemaJSON)); err != nil {
+			return nil, err
+		}
+	}
+	a.codecs[key] = c
+	return c, nil
+}
+
+// avroFingerprint identifies the column definitions an Avro schema is derived
+// from.
+func avroFingerprint(table *schema.Table) string {
+	var b strings.Builder
+	for _, col := range table.Columns {
+		fmt.Fprintf(&b, "%s\x00%d\x00%s\x00%t\x00", col.Name, col.Type, col.RawType, col.IsUnsigned)
+	}
+	return b.String()
+}
+
+// avroSubject returns the Schema Registry subject of a table, qualified by its
+// schema so that tables of the same name in different schemas do not share
+// one.
+func avroSubject(table *schema.Table) string {
+	return table.Schema + "." + table.Name + "-value"
+}
+
+// register adds the schema to the registry under subject and returns its id.
+func (a *avroEncoder) register(ctx context.Context, subject, schemaJSON string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schemaJSON})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/subjects/%s/versions", a.registryURL, subject), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("registering schema for subject %s: unexpected status %s", subject, res.Status)
+	}
+
+	var out struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, err
+	}
+	return out.ID, nil
+}
+
+func avroName(name string) string {
+	name = avroInvalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func avroFields(table *schema.Table, tinyint1AsBool bool) []avroField {
+	fields := make([]avroField, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		f := avroField{name: avroName(col.Name), column: col.Name}
+
+		if tinyint1AsBool && isTinyint1(col) {
+			f.typeName, f.schema, f.convert = "boolean", "boolean", avroBoolean
+			fields = append(fields, f)
+			continue
+		}
+
+		switch {
+		case col.Type == schema.TYPE_NUMBER && col.IsUnsigned && strings.HasPrefix(col.RawType, "bigint"):
+			// Unsigned BIGINT values above the range of a long are kept
+			// exact as decimals.
+			f.typeName = "bytes.decimal"
+			f.schema = map[string]any{
+				"type":        "bytes",
+				"logicalType": "decimal",
+				"precision":   20,
+				"scale":       0,
+			}
+			f.convert = avroDecimal
+			fields = append(fields, f)
+			continue
+		}
+
+		switch col.Type {
+		case schema.TYPE_NUMBER, schema.TYPE_MEDIUM_INT, schema.TYPE_BIT:
+			f.typeName, f.schema, f.convert = "long", "long", avroLong
+		case schema.TYPE_FLOAT:
+			f.typeName, f.schema, f.convert = "double", "double", avroDouble
+		case schema.TYPE_DECIMAL:
+			precision, scale := decimalPrecisionScale(col.RawType)
+			f.typeName = "bytes.decimal"
+			f.schema = map[string]any{
+				"type":        "bytes",
+				"logicalType": "decimal",
+				"precision":   precision,
+				"scale":       scale,
+			}
+			f.convert = avroDecimal
+		case schema.TYPE_DATETIME, schema.TYPE_TIMESTAMP:
+			f.typeName = "long.timestamp-micros"
+			f.schema = map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+			f.convert = avroTimestamp
+		case schema.TYPE_DATE:
+			f.typeName = "int.date"
+			f.schema = map[string]any{"type": "int", "logicalType": "date"}
+			f.convert = avroDate
+		case schema.TYPE_BINARY, schema.TYPE_POINT:
+			f.typeName, f.schema, f.convert = "bytes", "bytes", avroBytes
+		case schema.TYPE_ENUM, schema.TYPE_SET:
+			f.typeName, f.schema, f.convert = "string", "string", avroString
+			f.labels = true
+		case schema.TYPE_JSON:
+			f.typeName, f.schema, f.convert = "string", "string", avroJSON
+		default:
+			f.typeName, f.schema, f.convert = "string", "string", avroString
+		}
+
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func decimalPrecisionScale(rawType string) (int, int) {
+	precision, scale := 10, 0
+	if m := decimalRawType.FindStringSubmatch(rawType); m != nil {
+		precision, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			scale, _ = strconv.Atoi(m[2])
+		}
+	}
+	return precision, scale
+}
+
+func avroLong(v any) (any, error) {
+	switch v := v.(type) {
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d out of range of long", v)
+		}
+		return int64(v), nil
+	case string:
+		if v == "\x01" {
+			return int64(1), nil
+		}
+		if v == "\x00" {
+			return int64(0), nil
+		}
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return nil, fmt.Errorf("cannot encode %T as long", v)
+}
+
+func avroBoolean(v any) (any, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as boolean", v)
+}
+
+func avroDouble(v any) (any, error) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return nil, fmt.Errorf("cannot encode %T as double", v)
+}
+
+func avroDecimal(v any) (any, error) {
+	var s string
+	switch v := v.(type) {
+	case decimal.Decimal:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	case int, int8, int16, int32, uint, uint8, uint16, uint32:
+		s = fmt.Sprint(v)
+	default:
+		return nil, fmt.Errorf("cannot encode %T as decimal", v)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	return r, nil
+}
+
+func avroTimestamp(v any) (any, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		// Values converted by the to_epoch_millis column transform.
+		return time.UnixMilli(v), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			// Values converted by temporal_output rfc3339.
+			return t, nil
+		}
+		return time.ParseInLocation(mysql.TimeFormat, v, time.Local)
+	}
+	return nil, fmt.Errorf("cannot encode %T as timestamp", v)
+}
+
+func avroDate(v any) (any, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.UnixMilli(v).UTC(), nil
+	case string:
+		return time.Parse(mysqlDateFormat, v)
+	}
+	return nil, fmt.Errorf("cannot encode %T as date", v)
+}
+
+func avroBytes(v any) (any, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as bytes", v)
+}
+
+func avroString(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func avroJSON(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,199 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestAvroLong(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      any
+		want    any
+		wantErr bool
+	}{
+		{name: "int8", in: int8(-3), want: int64(-3)},
+		{name: "uint32", in: uint32(math.MaxUint32), want: int64(math.MaxUint32)},
+		{name: "int64 min", in: int64(math.MinInt64), want: int64(math.MinInt64)},
+		{name: "uint64 max long", in: uint64(math.MaxInt64), want: int64(math.MaxInt64)},
+		{name: "uint64 overflow", in: uint64(math.MaxInt64) + 1, wantErr: true},
+		{name: "uint64 max", in: uint64(math.MaxUint64), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := avroLong(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("avroLong(%v) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("avroLong(%v): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("avroLong(%v) = %v (%T), want %v", tt.in, got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvroDecimal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{name: "string", in: "12.345", want: "2469/200"},
+		{name: "uint64 max", in: uint64(math.MaxUint64), want: "18446744073709551615"},
+		{name: "int64", in: int64(-42), want: "-42"},
+		{name: "uint32", in: uint32(7), want: "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := avroDecimal(tt.in)
+			if err != nil {
+				t.Fatalf("avroDecimal(%v): %v", tt.in, err)
+			}
+			r, ok := got.(*big.Rat)
+			if !ok {
+				t.Fatalf("avroDecimal(%v) = %T, want *big.Rat", tt.in, got)
+			}
+			if r.RatString() != tt.want {
+				t.Errorf("avroDecimal(%v) = %s, want %s", tt.in, r.RatString(), tt.want)
+			}
+		})
+	}
+}
+
+func avroTestTable(db, name string, cols ...schema.TableColumn) *schema.Table {
+	return &schema.Table{Schema: db, Name: name, Columns: cols}
+}
+
+func TestAvroUnsignedBigint(t *testing.T) {
+	table := avroTestTable("shop", "orders",
+		schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER, RawType: "bigint(20) unsigned", IsUnsigned: true},
+	)
+	a := newAvroEncoder("")
+	body, err := a.encode(context.Background(), table, map[string]any{"id": uint64(math.MaxUint64)})
+	if err != nil {
+		t.Fatalf("encoding unsigned bigint: %v", err)
+	}
+	c, err := a.codecFor(context.Background(), table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, _, err := c.codec.NativeFromBinary(body)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	got := native.(map[string]any)["id"].(map[string]any)["bytes.decimal"].(*big.Rat)
+	if got.RatString() != "18446744073709551615" {
+		t.Errorf("id = %s, want 18446744073709551615", got.RatString())
+	}
+}
+
+func TestAvroCodecRegistry(t *testing.T) {
+	var mu sync.Mutex
+	var subjects []string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		subjects = append(subjects, r.URL.Path)
+		id := len(subjects)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]int{"id": id})
+	}))
+	defer registry.Close()
+
+	a := newAvroEncoder(registry.URL)
+	ctx := context.Background()
+	id := schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int(11)"}
+	shop := avroTestTable("shop", "users", id)
+	crm := avroTestTable("crm", "users", id)
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.codecFor(ctx, shop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A table reloaded with the same columns keeps its codec.
+	reloaded := avroTestTable("shop", "users", id)
+	if _, err := a.codecFor(ctx, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.codecFor(ctx, crm); err != nil {
+		t.Fatal(err)
+	}
+	// A column change registers the new schema.
+	altered := avroTestTable("shop", "users", id, schema.TableColumn{Name: "name", Type: schema.TYPE_STRING, RawType: "varchar(32)"})
+	c, err := a.codecFor(ctx, altered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.fields) != 2 {
+		t.Errorf("altered codec has %d fields, want 2", len(c.fields))
+	}
+
+	want := []string{
+		"/subjects/shop.users-value/versions",
+		"/subjects/crm.users-value/versions",
+		"/subjects/shop.users-value/versions",
+	}
+	if len(subjects) != len(want) {
+		t.Fatalf("registered subjects %v, want %v", subjects, want)
+	}
+	for i := range want {
+		if subjects[i] != want[i] {
+			t.Errorf("subject %d = %s, want %s", i, subjects[i], want[i])
+		}
+	}
+}
+
+func TestAvroEnumLabelsAfterAlter(t *testing.T) {
+	status := func(labels ...string) *schema.Table {
+		// The raw type is the same before and after the ALTER, so that the
+		// altered table keeps the codec built for the original one.
+		return avroTestTable("shop", "orders",
+			schema.TableColumn{Name: "status", Type: schema.TYPE_ENUM, RawType: "enum", EnumValues: labels},
+		)
+	}
+	a := newAvroEncoder("")
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		table *schema.Table
+		want  string
+	}{
+		{name: "before the alter", table: status("new", "paid"), want: "paid"},
+		{name: "after the alter", table: status("new", "shipped", "paid"), want: "shipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := a.encode(ctx, tt.table, map[string]any{"status": int64(2)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := a.codecFor(ctx, tt.table)
+			if err != nil {
+				t.Fatal(err)
+			}
+			native, _, err := c.codec.NativeFromBinary(body)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			got := native.(map[string]any)["status"].(map[string]any)["string"]
+			if got != tt.want {
+				t.Errorf("status = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+const (
+	bufferFullBlock      = "block"
+	bufferFullDropOldest = "drop_oldest"
+	bufferFullError      = "error"
+)
+
+var errBufferFull = errors.New("stream buffer is full")
+
+// bufferStats tracks the most messages buffered at once, for the high water
+// mark gauge.
+type bufferStats struct {
+	mu        sync.Mutex
+	highWater int
+}
+
+// send pushes a message onto the stream, applying the configured
+// on_buffer_full policy when the buffer has no room left. The message records
+// the synced position as the one streaming can resume from once it is acked,
+// or the position a resnapshot running alongside the stream started at.
+// Changes to the tables of such a resnapshot are held back until it
+// completes, see holdForResnapshot.
+func (m *MysqlStreamInput) send(msg StreamMessage) error {
+	if m.holdForResnapshot(msg) {
+		return nil
+	}
+	msg = m.prepare(msg)
+	switch m.onBufferFull {
+	case bufferFullDropOldest:
+		for {
+			select {
+			case m.stream <- msg:
+				m.recordBufferDepth()
+				return nil
+			default:
+			}
+			select {
+			case <-m.stream:
+				m.metrics.messagesDropped.Incr(1)
+			default:
+			}
+		}
+	case bufferFullError:
+		select {
+		case m.stream <- msg:
+			m.recordBufferDepth()
+			return nil
+		default:
+			return errBufferFull
+		}
+	default:
+		select {
+		case m.stream <- msg:
+			m.recordBufferDepth()
+			return nil
+		default:
+		}
+		blocked := time.Now()
+		err := m.sendBlocking(msg)
+		m.metrics.bufferBlocked.Timing(time.Since(blocked).Nanoseconds())
+		return err
+	}
+}
+
+// sendUnlocked sends msg like send, for goroutines that send in-band messages
+// without holding the event lock.
+func (m *MysqlStreamInput) sendUnlocked(msg StreamMessage) error {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	return m.send(msg)
+}
+
+// prepare records where msg was read from and the position streaming can
+// resume from once it is acked.
+func (m *MysqlStreamInput) prepare(msg StreamMessage) StreamMessage {
+	msg.resume = m.syncedPosition
+	if m.resnapshotting != nil {
+		msg.resume = m.resnapshotting.position
+	}
+	msg.sourceHost = m.addr
+	if m.includeServerIdentity {
+		msg.serverUUID = m.serverUUID
+	}
+	msg.source = m.sourceInfo
+	msg.sentAt = time.Now()
+	return msg
+}
+
+// sendBlocking pushes a prepared message onto the stream, waiting for room
+// in the buffer until the input is closed.
+func (m *MysqlStreamInput) sendBlocking(msg StreamMessage) error {
+	select {
+	case m.stream <- msg:
+	case <-m.closed:
+		return service.ErrEndOfInput
+	}
+	m.recordBufferDepth()
+	return nil
+}
+
+// recordBufferDepth reports the number of buffered messages, and the most
+// buffered at once.
+func (m *MysqlStreamInput) recordBufferDepth() {
+	depth := len(m.stream)
+	m.metrics.bufferDepth.Set(int64(depth))
+
+	m.bufferStats.mu.Lock()
+	defer m.bufferStats.mu.Unlock()
+	if depth > m.bufferStats.highWater {
+		m.bufferStats.highWater = depth
+		m.metrics.bufferHighWater.Set(int64(depth))
+	}
+}
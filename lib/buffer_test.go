@@ -0,0 +1,67 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestCloseWithFullBuffer(t *testing.T) {
+	binlogFormat, err := mysql.BuildSimpleTextResultset([]string{"Variable_name", "Value"}, [][]any{{"binlog_format", "ROW"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSchemaServer(t, schemaServer{results: map[string]*mysql.Resultset{
+		"SHOW GLOBAL VARIABLES LIKE 'binlog_format';": binlogFormat,
+	}})
+	m := newTestInput(t, WithAddr(addr), WithDatabase("shop"), WithBufferSize(1))
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr, cfg.User = addr, "root"
+	cfg.Dump.ExecutionPath = ""
+	if m.canal, err = canal.NewCanal(cfg); err != nil {
+		t.Fatal(err)
+	}
+	h := EventHandler{m: m}
+	m.canal.SetEventHandler(h)
+
+	// The first row fills the buffer and the second waits for room under
+	// the event lock, as it does while nothing reads.
+	insertRows(t, m, 0, 1)
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- h.OnRow(&canal.RowsEvent{
+			Table:  &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}},
+			Action: canal.InsertAction,
+			Rows:   [][]any{{int64(1)}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: 200},
+		})
+	}()
+	select {
+	case err := <-blocked:
+		t.Fatalf("OnRow returned %v with a full buffer", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- m.Close(context.Background())
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return while a row waited for buffer room")
+	}
+	if err := <-blocked; !errors.Is(err, service.ErrEndOfInput) {
+		t.Errorf("OnRow error = %v, want %v", err, service.ErrEndOfInput)
+	}
+}
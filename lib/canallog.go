@@ -0,0 +1,121 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+const (
+	canalLogLevelDebug = "debug"
+	canalLogLevelInfo  = "info"
+	canalLogLevelWarn  = "warn"
+	canalLogLevelError = "error"
+	canalLogLevelOff   = "off"
+)
+
+const (
+	canalLevelDebug = iota
+	canalLevelInfo
+	canalLevelWarn
+	canalLevelError
+	canalLevelOff
+)
+
+var canalLogLevels = map[string]int{
+	canalLogLevelDebug: canalLevelDebug,
+	canalLogLevelInfo:  canalLevelInfo,
+	canalLogLevelWarn:  canalLevelWarn,
+	canalLogLevelError: canalLevelError,
+	canalLogLevelOff:   canalLevelOff,
+}
+
+// canalLogger forwards the replication library's log messages at or above a
+// level to the Benthos logger.
+type canalLogger struct {
+	logger *service.Logger
+	level  int
+}
+
+func newCanalLogger(logger *service.Logger, level string) *canalLogger {
+	return &canalLogger{logger: logger, level: canalLogLevels[level]}
+}
+
+func (l *canalLogger) log(level int, msg string) {
+	if level < l.level {
+		return
+	}
+	msg = "canal: " + strings.TrimSuffix(msg, "\n")
+	switch level {
+	case canalLevelDebug:
+		l.logger.Debug(msg)
+	case canalLevelInfo:
+		l.logger.Info(msg)
+	case canalLevelWarn:
+		l.logger.Warn(msg)
+	default:
+		l.logger.Error(msg)
+	}
+}
+
+func (l *canalLogger) Debug(args ...any) { l.log(canalLevelDebug, fmt.Sprint(args...)) }
+func (l *canalLogger) Debugf(format string, args ...any) {
+	l.log(canalLevelDebug, fmt.Sprintf(format, args...))
+}
+func (l *canalLogger) Debugln(args ...any) { l.log(canalLevelDebug, fmt.Sprintln(args...)) }
+func (l *canalLogger) Info(args ...any)    { l.log(canalLevelInfo, fmt.Sprint(args...)) }
+func (l *canalLogger) Infof(format string, args ...any) {
+	l.log(canalLevelInfo, fmt.Sprintf(format, args...))
+}
+func (l *canalLogger) Infoln(args ...any) { l.log(canalLevelInfo, fmt.Sprintln(args...)) }
+func (l *canalLogger) Print(args ...any)  { l.log(canalLevelInfo, fmt.Sprint(args...)) }
+func (l *canalLogger) Printf(format string, args ...any) {
+	l.log(canalLevelInfo, fmt.Sprintf(format, args...))
+}
+func (l *canalLogger) Println(args ...any) { l.log(canalLevelInfo, fmt.Sprintln(args...)) }
+func (l *canalLogger) Warn(args ...any)    { l.log(canalLevelWarn, fmt.Sprint(args...)) }
+func (l *canalLogger) Warnf(format string, args ...any) {
+	l.log(canalLevelWarn, fmt.Sprintf(format, args...))
+}
+func (l *canalLogger) Warnln(args ...any) { l.log(canalLevelWarn, fmt.Sprintln(args...)) }
+func (l *canalLogger) Error(args ...any)  { l.log(canalLevelError, fmt.Sprint(args...)) }
+func (l *canalLogger) Errorf(format string, args ...any) {
+	l.log(canalLevelError, fmt.Sprintf(format, args...))
+}
+func (l *canalLogger) Errorln(args ...any) { l.log(canalLevelError, fmt.Sprintln(args...)) }
+
+// Fatal and Panic messages are always logged. Fatal messages panic with a
+// canalFatal rather than exiting the process, so that the input recovers
+// from them as from a failed connect, see newCanalRecovering.
+
+// canalFatal is the panic value of a fatal canal log message.
+type canalFatal string
+
+func (l *canalLogger) Fatal(args ...any) {
+	msg := fmt.Sprint(args...)
+	l.logger.Error("canal: " + msg)
+	panic(canalFatal(msg))
+}
+
+func (l *canalLogger) Fatalf(format string, args ...any) {
+	l.Fatal(fmt.Sprintf(format, args...))
+}
+
+func (l *canalLogger) Fatalln(args ...any) {
+	l.Fatal(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l *canalLogger) Panic(args ...any) {
+	msg := fmt.Sprint(args...)
+	l.logger.Error("canal: " + msg)
+	panic(msg)
+}
+
+func (l *canalLogger) Panicf(format string, args ...any) {
+	l.Panic(fmt.Sprintf(format, args...))
+}
+
+func (l *canalLogger) Panicln(args ...any) {
+	l.Panic(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
@@ -0,0 +1,22 @@
+package mongodb_stream_benthos
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+)
+
+func TestCanalFatalIsAConnectError(t *testing.T) {
+	m := newTestInput(t)
+	cfg := canal.NewDefaultConfig()
+	cfg.Dump.ExecutionPath = ""
+	// The replication client logs a zero server id as fatal.
+	cfg.ServerID = 0
+	cfg.Logger = newCanalLogger(m.logger, canalLogLevelOff)
+
+	_, err := newCanalRecovering(cfg)
+	if err == nil || !strings.Contains(err.Error(), "server ID") {
+		t.Fatalf("newCanalRecovering error = %v, want the fatal message", err)
+	}
+}
@@ -0,0 +1,58 @@
+package mongodb_stream_benthos
+
+import (
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const caughtUpEvent = "caught_up"
+
+// captureCaughtUpTarget records the end of the binlog when streaming starts,
+// which is the position the stream has caught up at. It is captured again
+// when the stream resumes on another server, whose binlog positions are not
+// comparable.
+func (m *MysqlStreamInput) captureCaughtUpTarget(c *canal.Canal) error {
+	if !m.waitUntilCaughtUp || m.caughtUp {
+		return nil
+	}
+	if m.caughtUpTarget.Name != "" && m.caughtUpAddr == m.addr {
+		return nil
+	}
+	pos, err := c.GetMasterPos()
+	if err != nil {
+		return err
+	}
+	m.caughtUpTarget, m.caughtUpAddr = pos, m.addr
+	if m.caughtUpSince.IsZero() {
+		m.caughtUpSince = time.Now()
+	}
+	return nil
+}
+
+// checkCaughtUp emits the caught_up event once the synced position reaches
+// the captured end of the binlog. Every message sent before it was read from
+// the binlog as it was when streaming started.
+func (m *MysqlStreamInput) checkCaughtUp(pos mysql.Position) error {
+	if !m.waitUntilCaughtUp || m.caughtUp || m.caughtUpTarget.Name == "" {
+		return nil
+	}
+	if pos.Name == "" || pos.Compare(m.caughtUpTarget) < 0 {
+		return nil
+	}
+	m.caughtUp = true
+	m.recordCaughtUp()
+	took := time.Since(m.caughtUpSince)
+	m.logger.Infof("Caught up with the binlog at %s:%d after %v", pos.Name, pos.Pos, took.Round(time.Millisecond))
+
+	return m.send(StreamMessage{
+		Event: caughtUpEvent,
+		Data: map[string]any{
+			"binlog_file": pos.Name,
+			"binlog_pos":  pos.Pos,
+			"duration_ms": took.Milliseconds(),
+			"timestamp":   time.Now().Unix(),
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	beforeModeFull    = "full"
+	beforeModeChanged = "changed"
+	beforeModeNone    = "none"
+)
+
+// FieldChange is the value of a column before and after an update.
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// changedFields returns the columns whose values differ between the before
+// and after images of an update.
+func changedFields(before, after map[string]any) map[string]FieldChange {
+	changed := map[string]FieldChange{}
+	for column, v := range after {
+		if old := before[column]; !reflect.DeepEqual(old, v) {
+			changed[column] = FieldChange{Old: old, New: v}
+		}
+	}
+	return changed
+}
+
+// previousValues returns the before section of an update according to
+// before_mode: the whole before image, the before values of the changed
+// columns, or nil.
+func (m *MysqlStreamInput) previousValues(before map[string]any, changed map[string]FieldChange) map[string]any {
+	switch {
+	case before == nil:
+		return nil
+	case m.beforeMode == beforeModeFull:
+		return before
+	case m.beforeMode == beforeModeChanged:
+		previous := make(map[string]any, len(changed))
+		for column := range changed {
+			previous[column] = before[column]
+		}
+		return previous
+	}
+	return nil
+}
+
+// changedFieldNames returns the sorted, comma separated names of the changed
+// columns.
+func changedFieldNames(changed map[string]FieldChange) string {
+	names := make([]string, 0, len(changed))
+	for column := range changed {
+		names = append(names, column)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,49 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestChangedFieldsMetadata(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithChangedFields(true))
+	m.binlogFile = "mysql-bin.000001"
+	// A column named like the metadata field is kept as it is.
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id"}, {Name: "qty"}, {Name: "changed_fields"}},
+		PKColumns: []int{0},
+	}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.UpdateAction,
+		Rows:   [][]any{{int64(1), int64(2), "qty"}, {int64(1), int64(3), "qty"}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 200},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, body := readStructured(t, m)
+	if body["changed_fields"] != "qty" {
+		t.Errorf("changed_fields column = %v, want qty", body["changed_fields"])
+	}
+	if names, _ := msg.MetaGet("changed_fields"); names != "qty" {
+		t.Errorf("changed_fields metadata = %q, want qty", names)
+	}
+	values, _ := msg.MetaGet("changed_values")
+	var got map[string]map[string]any
+	if err := json.Unmarshal([]byte(values), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]any{"qty": {"old": float64(2), "new": float64(3)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed_values metadata = %v, want %v", got, want)
+	}
+}
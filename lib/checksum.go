@@ -0,0 +1,53 @@
+package mongodb_stream_benthos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"hash/crc32"
+	"sort"
+)
+
+const (
+	rowChecksumNone   = "none"
+	rowChecksumCRC32  = "crc32"
+	rowChecksumSHA256 = "sha256"
+)
+
+// rowChecksum returns the hex encoded row_checksum of a row change: a hash of
+// its column names in sorted order, each followed by a NUL byte, its JSON
+// encoded value and another NUL byte. Encoding sorts the keys of nested
+// objects, so the checksum does not depend on map ordering.
+func (m *MysqlStreamInput) rowChecksum(msg StreamMessage) (string, error) {
+	var h hash.Hash
+	switch m.rowChecksumAlgorithm {
+	case rowChecksumCRC32:
+		h = crc32.NewIEEE()
+	case rowChecksumSHA256:
+		h = sha256.New()
+	default:
+		return "", nil
+	}
+	if msg.op == "" || msg.Data == nil {
+		return "", nil
+	}
+
+	columns := make([]string, 0, len(msg.Data))
+	for column := range msg.Data {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		value, err := json.Marshal(msg.Data[column])
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(column))
+		h.Write([]byte{0})
+		h.Write(value)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,111 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// absentColumn stands in for the value of a column that a row image written
+// with binlog_row_image=MINIMAL or NOBLOB left out, as told by the columns
+// present bitmap of its rows event, so that it is not mistaken for a column
+// set to NULL. The markers are taken out of the rows by takeAbsentColumns
+// before the rows are converted, and encode as null in debug dumps.
+type absentColumn struct{}
+
+func (absentColumn) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// markAbsentColumns makes the rows events of c mark the columns their row
+// images leave out with absentColumn. Canal decodes rows events itself and
+// drops their columns present bitmaps, so the decoder it installs on its
+// binlog parser, neither of which it exposes, is wrapped. It must be called
+// before c runs, and reports whether the decoder could be wrapped. It is only
+// called when usesColumnBitmaps, so that other streams do not depend on the
+// layout of canal.
+func markAbsentColumns(c *canal.Canal) bool {
+	syncer := reflect.ValueOf(c).Elem().FieldByName("syncer")
+	if !syncer.IsValid() || syncer.Type() != reflect.TypeOf((*replication.BinlogSyncer)(nil)) || syncer.IsNil() {
+		return false
+	}
+	parser := syncer.Elem().FieldByName("parser")
+	if !parser.IsValid() || parser.Type() != reflect.TypeOf((*replication.BinlogParser)(nil)) || parser.IsNil() {
+		return false
+	}
+	decodeField := parser.Elem().FieldByName("rowsEventDecodeFunc")
+	var decode func(*replication.RowsEvent, []byte) error
+	if !decodeField.IsValid() || decodeField.Type() != reflect.TypeOf(decode) {
+		return false
+	}
+	decode = *(*func(*replication.RowsEvent, []byte) error)(unsafe.Pointer(decodeField.UnsafeAddr()))
+
+	p := (*replication.BinlogParser)(unsafe.Pointer(parser.Pointer()))
+	p.SetRowsEventDecodeFunc(func(e *replication.RowsEvent, data []byte) error {
+		var err error
+		if decode != nil {
+			err = decode(e, data)
+		} else {
+			err = e.Decode(data)
+		}
+		if err != nil {
+			return err
+		}
+		for i, skipped := range e.SkippedColumns {
+			if i >= len(e.Rows) {
+				break
+			}
+			for _, col := range skipped {
+				if col < len(e.Rows[i]) {
+					e.Rows[i][col] = absentColumn{}
+				}
+			}
+		}
+		return nil
+	})
+	return true
+}
+
+// usesColumnBitmaps reports whether a setting tells the columns row images
+// left out apart from columns set to NULL: filling minimal images, emitting
+// minimal deletes with their key only, and finding primary key changes.
+func (m *MysqlStreamInput) usesColumnBitmaps() bool {
+	return m.rowCache != nil || m.deleteMinimalBehavior == deleteMinimalPKOnly || m.splitPKChange || len(m.thinTables) > 0
+}
+
+// absentColumns holds the indexes of the columns each row image of a rows
+// event left out, or is nil when they are not known.
+type absentColumns [][]int
+
+// of returns the indexes of the columns row image i left out, and whether
+// they are known.
+func (a absentColumns) of(i int) ([]int, bool) {
+	if a == nil || i >= len(a) {
+		return nil, false
+	}
+	return a[i], true
+}
+
+// takeAbsentColumns replaces the absentColumn markers of an event with nil
+// and returns the columns each row image left out. They are not known for
+// snapshot rows, for the events of compressed transactions, which canal
+// decodes with a parser of its own, nor when the decoder of the canal was not
+// or could not be wrapped.
+func (m *MysqlStreamInput) takeAbsentColumns(e *canal.RowsEvent) absentColumns {
+	if !m.columnBitmaps || e.Header == nil || isCompressed(e.Header) {
+		return nil
+	}
+
+	absent := make(absentColumns, len(e.Rows))
+	for r, row := range e.Rows {
+		for i, v := range row {
+			if _, ok := v.(absentColumn); ok {
+				row[i] = nil
+				absent[r] = append(absent[r], i)
+			}
+		}
+	}
+	return absent
+}
@@ -0,0 +1,89 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// columnMetadata describes the storage of a column for downstream systems
+// creating target columns of the right size.
+type columnMetadata struct {
+	Type      string `json:"type"`
+	MaxLength uint64 `json:"max_length,omitempty"`
+	Precision int    `json:"precision,omitempty"`
+	Scale     int    `json:"scale,omitempty"`
+	Unsigned  bool   `json:"unsigned,omitempty"`
+	Nullable  *bool  `json:"nullable,omitempty"`
+}
+
+type columnMetadataJSON struct {
+	table *schema.Table
+	json  string
+}
+
+// lobMaxLengths are the maximum lengths in bytes of the TEXT and BLOB types,
+// which are not part of their column type.
+var lobMaxLengths = map[string]uint64{
+	"tinytext": 1<<8 - 1, "text": 1<<16 - 1, "mediumtext": 1<<24 - 1, "longtext": 1<<32 - 1,
+	"tinyblob": 1<<8 - 1, "blob": 1<<16 - 1, "mediumblob": 1<<24 - 1, "longblob": 1<<32 - 1,
+}
+
+// columnMetadataOf derives the metadata of a column from its type: the
+// declared length of CHAR, VARCHAR, BINARY and VARBINARY columns, the maximum
+// length of TEXT and BLOB columns, the precision and scale of DECIMAL columns
+// and the fractional seconds precision of DATETIME, TIMESTAMP and TIME
+// columns.
+func columnMetadataOf(col schema.TableColumn) columnMetadata {
+	raw := strings.ToLower(col.RawType)
+	meta := columnMetadata{Type: raw, Unsigned: col.IsUnsigned}
+
+	base := raw
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	switch {
+	case col.Type == schema.TYPE_DECIMAL:
+		meta.Precision, meta.Scale = decimalPrecisionScale(raw)
+	case col.Type == schema.TYPE_DATETIME, col.Type == schema.TYPE_TIMESTAMP, col.Type == schema.TYPE_TIME:
+		meta.Precision = timePrecision(raw)
+	case lobMaxLengths[base] > 0:
+		meta.MaxLength = lobMaxLengths[base]
+	case col.Type == schema.TYPE_STRING, col.Type == schema.TYPE_BINARY:
+		meta.MaxLength = uint64(col.MaxSize)
+	}
+	return meta
+}
+
+// columnMetadataFor returns the JSON encoded column metadata of a table.
+// It is cached per table and recomputed when its layout changes.
+func (m *MysqlStreamInput) columnMetadataFor(table *schema.Table) (string, error) {
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.columnMetadataCache.get(key); ok && cached.table == table {
+		return cached.json, nil
+	}
+
+	var nullable map[string]bool
+	if m.includeNullability {
+		var err error
+		if nullable, err = m.nullabilityFor(table); err != nil {
+			return "", err
+		}
+	}
+	columns := make(map[string]columnMetadata, len(table.Columns))
+	for _, col := range table.Columns {
+		meta := columnMetadataOf(col)
+		if nullable != nil {
+			isNullable := nullable[col.Name]
+			meta.Nullable = &isNullable
+		}
+		columns[col.Name] = meta
+	}
+	b, err := json.Marshal(columns)
+	if err != nil {
+		return "", err
+	}
+	m.columnMetadataCache.put(key, columnMetadataJSON{table: table, json: string(b)})
+	return string(b), nil
+}
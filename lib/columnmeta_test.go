@@ -0,0 +1,58 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestColumnMetadataOf(t *testing.T) {
+	tests := []struct {
+		name string
+		col  schema.TableColumn
+		want columnMetadata
+	}{
+		{
+			name: "varchar",
+			col:  schema.TableColumn{Type: schema.TYPE_STRING, RawType: "varchar(255)", MaxSize: 255},
+			want: columnMetadata{Type: "varchar(255)", MaxLength: 255},
+		},
+		{
+			name: "varbinary",
+			col:  schema.TableColumn{Type: schema.TYPE_BINARY, RawType: "varbinary(16)", MaxSize: 16},
+			want: columnMetadata{Type: "varbinary(16)", MaxLength: 16},
+		},
+		{
+			name: "mediumtext",
+			col:  schema.TableColumn{Type: schema.TYPE_STRING, RawType: "mediumtext"},
+			want: columnMetadata{Type: "mediumtext", MaxLength: 1<<24 - 1},
+		},
+		{
+			name: "blob",
+			col:  schema.TableColumn{Type: schema.TYPE_BINARY, RawType: "BLOB"},
+			want: columnMetadata{Type: "blob", MaxLength: 1<<16 - 1},
+		},
+		{
+			name: "decimal",
+			col:  schema.TableColumn{Type: schema.TYPE_DECIMAL, RawType: "decimal(12,4) unsigned", IsUnsigned: true},
+			want: columnMetadata{Type: "decimal(12,4) unsigned", Precision: 12, Scale: 4, Unsigned: true},
+		},
+		{
+			name: "datetime",
+			col:  schema.TableColumn{Type: schema.TYPE_DATETIME, RawType: "datetime(6)"},
+			want: columnMetadata{Type: "datetime(6)", Precision: 6},
+		},
+		{
+			name: "int",
+			col:  schema.TableColumn{Type: schema.TYPE_NUMBER, RawType: "int(11)"},
+			want: columnMetadata{Type: "int(11)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := columnMetadataOf(tt.col); got != tt.want {
+				t.Errorf("columnMetadataOf(%s) = %+v, want %+v", tt.col.RawType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// With binlog_transaction_compression enabled MySQL writes each transaction as
+// a GTID event followed by a single zstd compressed payload event, which canal
+// decompresses and hands on event by event. The events within a payload carry
+// no binlog position of their own, their end position is zero, so the
+// position of a compressed row change is taken to be the start of its
+// transaction, which is where it can be read again from, and the end of the
+// transaction is worked out from the transaction length its GTID event
+// records. Their row changes are versioned by the bytes of the transaction
+// after its GTID event, one per change, see compressedVersion.
+
+// isCompressed reports whether an event was part of a compressed transaction
+// payload.
+func isCompressed(header *replication.EventHeader) bool {
+	return header != nil && header.LogPos == 0
+}
+
+// trackSyncedPosition records the end of the last transaction with a known
+// position. Canal records the zero position of the events within a compressed
+// transaction as its own synced position, so the position is also recorded
+// for the health, offset and position checks, which run on other goroutines.
+func (m *MysqlStreamInput) trackSyncedPosition(pos mysql.Position) {
+	if pos.Pos == 0 {
+		return
+	}
+	m.syncedPosition = pos
+	m.health.mu.Lock()
+	m.health.synced = pos
+	m.health.mu.Unlock()
+}
+
+// handledPosition returns the position recorded by trackSyncedPosition. It is
+// safe to call from any goroutine.
+func (m *MysqlStreamInput) handledPosition() mysql.Position {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	return m.health.synced
+}
+
+// trackTransactionStart records where the transaction of a GTID event starts,
+// which is the end of the previous one, and where it ends when the event
+// records the transaction length, as MySQL 8.0.2 and later do.
+func (m *MysqlStreamInput) trackTransactionStart(header *replication.EventHeader, gtidEvent mysql.BinlogGTIDEvent) {
+	m.txEnd, m.txVersion = mysql.Position{}, mysql.Position{}
+	if header == nil || header.LogPos < header.EventSize || header.LogPos == 0 {
+		return
+	}
+	start := header.LogPos - header.EventSize
+	m.trackSyncedPosition(mysql.Position{Name: m.binlogFile, Pos: start})
+	if e, ok := gtidEvent.(*replication.GTIDEvent); ok && e.TransactionLength > 0 {
+		m.txEnd = mysql.Position{Name: m.binlogFile, Pos: start + uint32(e.TransactionLength)}
+		m.txVersion = mysql.Position{Name: m.binlogFile, Pos: header.LogPos + 1}
+	}
+}
+
+// compressedVersion returns the version of the next row change of a
+// compressed transaction. Its changes are given the positions after its GTID
+// event in turn, which no other change of the stream is versioned by: those
+// of earlier transactions end before it starts and those of later ones after
+// it ends. A transaction with more changes than bytes after its GTID event
+// would run past its end, so its last changes share the version of its end.
+// The count restarts whenever the transaction is read again, so the versions
+// are the same after a reconnect or restart. Without a transaction length the
+// changes share the version of the start of the transaction, their position.
+func (m *MysqlStreamInput) compressedVersion(position mysql.Position) uint64 {
+	if m.txVersion.Pos == 0 {
+		return globalSeq(position, 0, 1)
+	}
+	version := globalSeq(m.txVersion, 0, 1)
+	if m.txVersion.Pos < m.txEnd.Pos {
+		m.txVersion.Pos++
+	}
+	return version
+}
+
+// countRowsEvent updates the compressed and uncompressed rows event metrics.
+func (m *MysqlStreamInput) countRowsEvent(header *replication.EventHeader) {
+	if header == nil {
+		return
+	}
+	if isCompressed(header) {
+		m.metrics.rowsEventsCompressed.Incr(1)
+	} else {
+		m.metrics.rowsEventsUncompressed.Incr(1)
+	}
+}
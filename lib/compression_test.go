@@ -0,0 +1,117 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// compressedTx feeds m a compressed transaction inserting id, starting at
+// start and length bytes long, from a server that does not log GTIDs.
+func compressedTx(t *testing.T, m *MysqlStreamInput, table *schema.Table, start, length uint32, id int64) {
+	t.Helper()
+	gtid := &replication.EventHeader{Timestamp: 1, EventType: replication.ANONYMOUS_GTID_EVENT, LogPos: start + 79, EventSize: 79}
+	if err := m.OnGTID(gtid, &replication.GTIDEvent{SID: make([]byte, 16), TransactionLength: uint64(length)}); err != nil {
+		t.Fatal(err)
+	}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{id}},
+		Header: &replication.EventHeader{Timestamp: 1, EventType: replication.WRITE_ROWS_EVENTv2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Canal hands on the zero position of the XID event within the payload.
+	xid := &replication.EventHeader{Timestamp: 1, EventType: replication.XID_EVENT}
+	if err := m.OnXID(xid, mysql.Position{Name: m.binlogFile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.OnPosSynced(xid, mysql.Position{Name: m.binlogFile}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedTransactionsAdvancePosition(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithPositionCache("cache", "position"), WithPositionFlush(0, 1))
+	m.binlogFile = "mysql-bin.000001"
+	m.trackSyncedPosition(mysql.Position{Name: "mysql-bin.000001", Pos: 1000})
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	compressedTx(t, m, table, 1000, 300, 1)
+	compressedTx(t, m, table, 1300, 400, 2)
+
+	wantKeys := []string{"mysql-bin.000001:1000/1.0|shop.orders|1|c", "mysql-bin.000001:1300/1.0|shop.orders|2|c"}
+	for _, want := range wantKeys {
+		msg, ack, err := m.Read(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, _ := msg.MetaGet("idempotency_key"); key != want {
+			t.Errorf("idempotency_key = %s, want %s", key, want)
+		}
+		if err := ack(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := mysql.Position{Name: "mysql-bin.000001", Pos: 1700}
+	if m.syncedPosition != want || m.handledPosition() != want {
+		t.Errorf("synced position = %v, handled %v, want %v", m.syncedPosition, m.handledPosition(), want)
+	}
+	// The second change is resumed from the start of its transaction, which
+	// is the end of the first.
+	stored, ok := cache.storedPosition(t, "position")
+	if !ok || stored.BinlogFile != "mysql-bin.000001" || stored.BinlogPos != 1300 {
+		t.Errorf("stored position = %+v, want mysql-bin.000001:1300", stored)
+	}
+}
+
+func TestCompressedTransactionVersions(t *testing.T) {
+	const file = uint64(1) << 32
+	tests := []struct {
+		name   string
+		length uint32
+		want   []uint64
+	}{
+		{name: "room for every change", length: 300, want: []uint64{file | 1080, file | 1081, file | 1082}},
+		{name: "more changes than bytes", length: 81, want: []uint64{file | 1080, file | 1081, file | 1081}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"))
+			m.binlogFile = "mysql-bin.000001"
+			m.trackSyncedPosition(mysql.Position{Name: "mysql-bin.000001", Pos: 1000})
+			table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+			// Read twice, as after a reconnect, the versions are the same.
+			for read := 0; read < 2; read++ {
+				gtid := &replication.EventHeader{Timestamp: 1, EventType: replication.ANONYMOUS_GTID_EVENT, LogPos: 1079, EventSize: 79}
+				if err := m.OnGTID(gtid, &replication.GTIDEvent{SID: make([]byte, 16), TransactionLength: uint64(tt.length)}); err != nil {
+					t.Fatal(err)
+				}
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(1)}, {int64(2)}, {int64(3)}},
+					Header: &replication.EventHeader{Timestamp: 1, EventType: replication.WRITE_ROWS_EVENTv2},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i, want := range tt.want {
+					if msg := <-m.stream; msg.version != want || msg.globalSeq != 0 {
+						t.Errorf("read %d change %d version = %d, global_seq %d, want %d and none", read, i, msg.version, msg.globalSeq, want)
+					}
+				}
+				m.restartTransaction()
+			}
+		})
+	}
+}
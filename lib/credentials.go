@@ -0,0 +1,39 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+// refreshCredentials replaces the user and password with the latest values
+// from the credentials cache, if one is configured, so that rotated
+// credentials are picked up on every connect.
+func (m *MysqlStreamInput) refreshCredentials(ctx context.Context) error {
+	if m.credentialsCache == "" {
+		return nil
+	}
+	if m.resources == nil {
+		return errors.New("credentials_cache requires access to Benthos resources")
+	}
+
+	var user, password []byte
+	var cacheErr error
+	err := m.resources.AccessCache(ctx, m.credentialsCache, func(c service.Cache) {
+		if user, cacheErr = c.Get(ctx, m.credentialsUserKey); cacheErr != nil {
+			return
+		}
+		password, cacheErr = c.Get(ctx, m.credentialsPasswordKey)
+	})
+	if err != nil {
+		return err
+	}
+	if cacheErr != nil {
+		return cacheErr
+	}
+
+	m.user = string(user)
+	m.password = string(password)
+	return nil
+}
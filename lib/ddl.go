@@ -0,0 +1,213 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+const (
+	truncateEvent = "truncate"
+	ddlEvent      = "ddl"
+)
+
+// OnDDL emits truncate messages when include_truncate is enabled and schema
+// change messages when include_schema_changes is enabled for the streamed
+// tables a statement affects. Statements on temporary tables are skipped.
+// Canal calls it once for every statement of the query event that changes a
+// table, so each call handles the next of those statements.
+func (m *MysqlStreamInput) OnDDL(header *replication.EventHeader, nextPos mysql.Position, queryEvent *replication.QueryEvent) error {
+	if m.ddlParser == nil {
+		return nil
+	}
+	stmt, ok := m.nextDDLStatement(queryEvent)
+	if !ok {
+		return nil
+	}
+
+	position := mysql.Position{Name: m.binlogFile, Pos: header.LogPos}
+	if m.temporaryDDL(stmt, queryEvent) {
+		return nil
+	}
+	if t, ok := stmt.(*ast.TruncateTableStmt); ok {
+		ref := ddlTableRef(t.Table, queryEvent)
+		if !m.includeTruncate || !m.isTableStreamed(ref.schema, ref.name) {
+			return nil
+		}
+		return m.emitTruncate(header, ref, position)
+	}
+
+	if !m.includeSchemaChanges {
+		return nil
+	}
+	refs := ddlTables(stmt, queryEvent)
+	for i, ref := range refs {
+		if !m.isTableStreamed(ref.schema, ref.name) {
+			continue
+		}
+		if err := m.emitSchemaChange(header, stmt, queryEvent, ref, position, globalSeq(position, i, len(refs))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ddlStatements holds the statements of the query event canal last reported
+// that change a table, and how many of them OnDDL has handled.
+type ddlStatements struct {
+	event *replication.QueryEvent
+	stmts []ast.StmtNode
+	next  int
+}
+
+// nextDDLStatement returns the statement of queryEvent that canal reports
+// next. The query is parsed once, when canal reports its first statement, and
+// only the statements canal refreshes its table cache for are kept, matching
+// the calls canal makes.
+func (m *MysqlStreamInput) nextDDLStatement(queryEvent *replication.QueryEvent) (ast.StmtNode, bool) {
+	if m.ddlStatements.event != queryEvent {
+		m.ddlStatements = ddlStatements{event: queryEvent}
+		stmts, _, err := m.ddlParser.Parse(string(queryEvent.Query), "", "")
+		if err != nil {
+			// Canal has already parsed the statement successfully, so this is
+			// not expected, but an unparseable statement cannot change a table.
+			return nil, false
+		}
+		for _, stmt := range stmts {
+			if _, ok := stmt.(*ast.TruncateTableStmt); ok || len(ddlTables(stmt, queryEvent)) > 0 {
+				m.ddlStatements.stmts = append(m.ddlStatements.stmts, stmt)
+			}
+		}
+	}
+	if m.ddlStatements.next >= len(m.ddlStatements.stmts) {
+		return nil, false
+	}
+	stmt := m.ddlStatements.stmts[m.ddlStatements.next]
+	m.ddlStatements.next++
+	return stmt, true
+}
+
+func (m *MysqlStreamInput) emitTruncate(header *replication.EventHeader, ref tableRef, position mysql.Position) error {
+	return m.send(StreamMessage{
+		Table: ref.name,
+		Event: truncateEvent,
+		Data: map[string]any{
+			"schema":    ref.schema,
+			"table":     ref.name,
+			"timestamp": header.Timestamp,
+		},
+		op:        opTruncate,
+		snapshot:  snapshotFalse,
+		timestamp: header.Timestamp,
+		position:  position,
+		globalSeq: globalSeq(position, 0, 1),
+		database:  ref.schema,
+	})
+}
+
+// emitSchemaChange sends the statement together with the table's column
+// layout after it was applied, and a schema_version taken from the binlog
+// position of the statement, so that it increases with every change to the
+// table and is the same when the statement is streamed again after a restart. Dropped tables, including the old name
+// of a renamed table, are sent without a layout. With parse_ddl the changes
+// the statement makes are described in changes as well.
+func (m *MysqlStreamInput) emitSchemaChange(header *replication.EventHeader, stmt ast.StmtNode, queryEvent *replication.QueryEvent, ref tableRef, position mysql.Position, seq uint64) error {
+	data := map[string]any{
+		"schema":         ref.schema,
+		"table":          ref.name,
+		"query":          string(queryEvent.Query),
+		"schema_version": seq,
+		"dropped":        false,
+		"timestamp":      header.Timestamp,
+	}
+	if m.parseDDL {
+		changes, ok := ddlChanges(stmt, ref, queryEvent)
+		data["parsed"] = ok
+		if ok {
+			data["changes"] = changes
+		}
+	}
+
+	table, err := m.canal.GetTable(ref.schema, ref.name)
+	switch {
+	case errors.Is(err, schema.ErrTableNotExist):
+		data["dropped"] = true
+	case err != nil:
+		return err
+	default:
+		var nullable map[string]bool
+		if m.includeNullability {
+			if nullable, err = m.lookupNullability(table); err != nil {
+				return err
+			}
+		}
+		columns := make([]map[string]any, 0, len(table.Columns))
+		for _, col := range table.Columns {
+			column := map[string]any{
+				"name":     col.Name,
+				"type":     col.RawType,
+				"unsigned": col.IsUnsigned,
+			}
+			if nullable != nil {
+				column["nullable"] = nullable[col.Name]
+			}
+			columns = append(columns, column)
+		}
+		primaryKey := make([]string, 0, len(table.PKColumns))
+		for _, i := range table.PKColumns {
+			primaryKey = append(primaryKey, table.Columns[i].Name)
+		}
+		data["columns"] = columns
+		data["primary_key"] = primaryKey
+	}
+
+	return m.send(StreamMessage{
+		Table:     ref.name,
+		Event:     ddlEvent,
+		Data:      data,
+		position:  position,
+		globalSeq: seq,
+		database:  ref.schema,
+	})
+}
+
+// ddlTables returns the tables whose layout a statement changes, mirroring
+// the statements canal refreshes its table cache for.
+func ddlTables(stmt ast.StmtNode, queryEvent *replication.QueryEvent) []tableRef {
+	var names []*ast.TableName
+	switch t := stmt.(type) {
+	case *ast.RenameTableStmt:
+		for _, tt := range t.TableToTables {
+			names = append(names, tt.OldTable, tt.NewTable)
+		}
+	case *ast.AlterTableStmt:
+		names = append(names, t.Table)
+	case *ast.DropTableStmt:
+		names = append(names, t.Tables...)
+	case *ast.CreateTableStmt:
+		names = append(names, t.Table)
+	case *ast.CreateIndexStmt:
+		names = append(names, t.Table)
+	case *ast.DropIndexStmt:
+		names = append(names, t.Table)
+	}
+
+	refs := make([]tableRef, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, ddlTableRef(name, queryEvent))
+	}
+	return refs
+}
+
+// ddlTableRef resolves a table named in a statement, defaulting to the
+// statement's current database.
+func ddlTableRef(name *ast.TableName, queryEvent *replication.QueryEvent) tableRef {
+	db := name.Schema.String()
+	if db == "" {
+		db = string(queryEvent.Schema)
+	}
+	return tableRef{schema: db, name: name.Name.String()}
+}
@@ -0,0 +1,68 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestOnDDLHandlesEachStatementOnce(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithTruncateEvents(true))
+	m.binlogFile = "mysql-bin.000002"
+	header := &replication.EventHeader{Timestamp: 1, LogPos: 500}
+	query := &replication.QueryEvent{Schema: []byte("shop"), Query: []byte("TRUNCATE TABLE orders; TRUNCATE TABLE users")}
+
+	// Canal calls OnDDL once for each of the statements.
+	for i := 0; i < 2; i++ {
+		if err := m.OnDDL(header, mysql.Position{Name: "mysql-bin.000002", Pos: 500}, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"orders", "users"} {
+		msg, _ := readStructured(t, m)
+		if table, _ := msg.MetaGet("table"); table != want {
+			t.Errorf("truncated table = %s, want %s", table, want)
+		}
+	}
+	if n := len(m.stream); n != 0 {
+		t.Errorf("%d messages left, want 0", n)
+	}
+}
+
+func TestSchemaVersionFromPosition(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id", RawType: "int"}}, PKColumns: []int{0}}
+	alter := func(m *MysqlStreamInput, pos uint32) json.Number {
+		t.Helper()
+		query := &replication.QueryEvent{Schema: []byte("shop"), Query: []byte("ALTER TABLE orders ADD COLUMN note TEXT")}
+		if err := m.OnDDL(&replication.EventHeader{Timestamp: 1, LogPos: pos}, mysql.Position{Name: m.binlogFile, Pos: pos}, query); err != nil {
+			t.Fatal(err)
+		}
+		_, body := readStructured(t, m)
+		version, err := json.Marshal(body["schema_version"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return json.Number(version)
+	}
+	newInput := func() *MysqlStreamInput {
+		m := newTestInput(t, WithDatabase("shop"), WithSchemaChangeEvents(true))
+		m.canal = newTestCanal(t, table)
+		m.binlogFile = "mysql-bin.000002"
+		return m
+	}
+
+	m := newInput()
+	first := alter(m, 500)
+	second := alter(m, 900)
+	if first != "8589935092" || second != "8589935492" {
+		t.Errorf("schema_version = %s, %s, want 8589935092, 8589935492", first, second)
+	}
+
+	// After a restart the statement is streamed again with the same version.
+	if again := alter(newInput(), 500); again != first {
+		t.Errorf("schema_version after a restart = %s, want %s", again, first)
+	}
+}
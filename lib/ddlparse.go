@@ -0,0 +1,208 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// DDL change operations of parse_ddl.
+const (
+	ddlOpCreateTable    = "create_table"
+	ddlOpDropTable      = "drop_table"
+	ddlOpRenameTable    = "rename_table"
+	ddlOpAddColumn      = "add_column"
+	ddlOpDropColumn     = "drop_column"
+	ddlOpModifyColumn   = "modify_column"
+	ddlOpChangeColumn   = "change_column"
+	ddlOpRenameColumn   = "rename_column"
+	ddlOpAddIndex       = "add_index"
+	ddlOpDropIndex      = "drop_index"
+	ddlOpDropPrimaryKey = "drop_primary_key"
+)
+
+// ddlChanges describes what a DDL statement changes in table ref for
+// parse_ddl, as a list of operations with the columns, types and indexes
+// they affect. It returns false for statements with any part it does not
+// recognize, which are left to the raw statement.
+func ddlChanges(stmt ast.StmtNode, ref tableRef, queryEvent *replication.QueryEvent) ([]map[string]any, bool) {
+	switch t := stmt.(type) {
+	case *ast.CreateTableStmt:
+		if t.ReferTable != nil || t.Select != nil {
+			return nil, false
+		}
+		pk := primaryKeyConstraint(t.Constraints)
+		columns := make([]map[string]any, 0, len(t.Cols))
+		for _, col := range t.Cols {
+			for _, opt := range col.Options {
+				if opt.Tp == ast.ColumnOptionPrimaryKey {
+					pk = append(pk, col.Name.Name.O)
+				}
+			}
+			columns = append(columns, ddlColumn(col))
+		}
+		// Primary key columns are never nullable.
+		for _, column := range columns {
+			for _, name := range pk {
+				if column["column"] == name {
+					column["nullable"] = false
+				}
+			}
+		}
+		change := map[string]any{"op": ddlOpCreateTable, "columns": columns}
+		if pk != nil {
+			change["primary_key"] = pk
+		}
+		return []map[string]any{change}, true
+	case *ast.DropTableStmt:
+		return []map[string]any{{"op": ddlOpDropTable}}, true
+	case *ast.RenameTableStmt:
+		var changes []map[string]any
+		for _, tt := range t.TableToTables {
+			from, to := ddlTableRef(tt.OldTable, queryEvent), ddlTableRef(tt.NewTable, queryEvent)
+			if from == ref || to == ref {
+				changes = append(changes, map[string]any{"op": ddlOpRenameTable, "from": from.key(), "to": to.key()})
+			}
+		}
+		return changes, true
+	case *ast.CreateIndexStmt:
+		columns := make([]string, 0, len(t.IndexPartSpecifications))
+		for _, part := range t.IndexPartSpecifications {
+			if part.Column == nil {
+				return nil, false
+			}
+			columns = append(columns, part.Column.Name.O)
+		}
+		return []map[string]any{{
+			"op":      ddlOpAddIndex,
+			"index":   t.IndexName,
+			"columns": columns,
+			"unique":  t.KeyType == ast.IndexKeyTypeUnique,
+		}}, true
+	case *ast.DropIndexStmt:
+		return []map[string]any{{"op": ddlOpDropIndex, "index": t.IndexName}}, true
+	case *ast.AlterTableStmt:
+		changes := make([]map[string]any, 0, len(t.Specs))
+		for _, spec := range t.Specs {
+			change, ok := alterTableChange(spec, queryEvent)
+			if !ok {
+				return nil, false
+			}
+			if change != nil {
+				changes = append(changes, change)
+			}
+		}
+		return changes, true
+	}
+	return nil, false
+}
+
+// alterTableChange describes one clause of an ALTER TABLE statement, and
+// returns a nil change for clauses such as ALGORITHM and LOCK that change
+// nothing downstream.
+func alterTableChange(spec *ast.AlterTableSpec, queryEvent *replication.QueryEvent) (map[string]any, bool) {
+	switch spec.Tp {
+	case ast.AlterTableAddColumns:
+		if len(spec.NewColumns) != 1 {
+			return nil, false
+		}
+		change := ddlColumn(spec.NewColumns[0])
+		change["op"] = ddlOpAddColumn
+		addColumnPosition(change, spec.Position)
+		return change, true
+	case ast.AlterTableDropColumn:
+		return map[string]any{"op": ddlOpDropColumn, "column": spec.OldColumnName.Name.O}, true
+	case ast.AlterTableModifyColumn:
+		change := ddlColumn(spec.NewColumns[0])
+		change["op"] = ddlOpModifyColumn
+		addColumnPosition(change, spec.Position)
+		return change, true
+	case ast.AlterTableChangeColumn:
+		change := ddlColumn(spec.NewColumns[0])
+		change["op"] = ddlOpChangeColumn
+		change["new_column"] = change["column"]
+		change["column"] = spec.OldColumnName.Name.O
+		addColumnPosition(change, spec.Position)
+		return change, true
+	case ast.AlterTableRenameColumn:
+		return map[string]any{
+			"op":         ddlOpRenameColumn,
+			"column":     spec.OldColumnName.Name.O,
+			"new_column": spec.NewColumnName.Name.O,
+		}, true
+	case ast.AlterTableRenameTable:
+		return map[string]any{"op": ddlOpRenameTable, "to": ddlTableRef(spec.NewTable, queryEvent).key()}, true
+	case ast.AlterTableAddConstraint:
+		c := spec.Constraint
+		var columns []string
+		for _, key := range c.Keys {
+			if key.Column == nil {
+				return nil, false
+			}
+			columns = append(columns, key.Column.Name.O)
+		}
+		switch c.Tp {
+		case ast.ConstraintPrimaryKey:
+			return map[string]any{"op": ddlOpAddIndex, "index": "PRIMARY", "columns": columns, "unique": true, "primary": true}, true
+		case ast.ConstraintKey, ast.ConstraintIndex:
+			return map[string]any{"op": ddlOpAddIndex, "index": c.Name, "columns": columns, "unique": false}, true
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			return map[string]any{"op": ddlOpAddIndex, "index": c.Name, "columns": columns, "unique": true}, true
+		}
+		return nil, false
+	case ast.AlterTableDropIndex:
+		return map[string]any{"op": ddlOpDropIndex, "index": spec.Name}, true
+	case ast.AlterTableDropPrimaryKey:
+		return map[string]any{"op": ddlOpDropPrimaryKey}, true
+	case ast.AlterTableLock, ast.AlterTableAlgorithm:
+		return nil, true
+	}
+	return nil, false
+}
+
+// ddlColumn describes a column definition by its name, type as in
+// information_schema, such as varchar(255) or int(11) unsigned, and whether it
+// is nullable.
+func ddlColumn(col *ast.ColumnDef) map[string]any {
+	nullable := true
+	for _, opt := range col.Options {
+		switch opt.Tp {
+		case ast.ColumnOptionNotNull, ast.ColumnOptionPrimaryKey:
+			nullable = false
+		case ast.ColumnOptionNull:
+			nullable = true
+		}
+	}
+	return map[string]any{
+		"column":   col.Name.Name.O,
+		"type":     col.Tp.InfoSchemaStr(),
+		"nullable": nullable,
+	}
+}
+
+func addColumnPosition(change map[string]any, pos *ast.ColumnPosition) {
+	if pos == nil {
+		return
+	}
+	switch pos.Tp {
+	case ast.ColumnPositionFirst:
+		change["first"] = true
+	case ast.ColumnPositionAfter:
+		change["after"] = pos.RelativeColumn.Name.O
+	}
+}
+
+func primaryKeyConstraint(constraints []*ast.Constraint) []string {
+	for _, c := range constraints {
+		if c.Tp != ast.ConstraintPrimaryKey {
+			continue
+		}
+		columns := make([]string, 0, len(c.Keys))
+		for _, key := range c.Keys {
+			if key.Column != nil {
+				columns = append(columns, key.Column.Name.O)
+			}
+		}
+		return columns
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/tidb/pkg/parser"
+)
+
+func TestDDLChanges(t *testing.T) {
+	orders := tableRef{schema: "shop", name: "orders"}
+	tests := []struct {
+		name       string
+		query      string
+		want       []map[string]any
+		wantParsed bool
+	}{
+		{
+			name:  "add column",
+			query: "ALTER TABLE orders ADD COLUMN note VARCHAR(255) NOT NULL AFTER sku",
+			want: []map[string]any{
+				{"op": ddlOpAddColumn, "column": "note", "type": "varchar(255)", "nullable": false, "after": "sku"},
+			},
+			wantParsed: true,
+		},
+		{
+			name:  "add column first",
+			query: "ALTER TABLE orders ADD COLUMN tenant INT FIRST",
+			want: []map[string]any{
+				{"op": ddlOpAddColumn, "column": "tenant", "type": "int(11)", "nullable": true, "first": true},
+			},
+			wantParsed: true,
+		},
+		{
+			name:       "drop column",
+			query:      "ALTER TABLE orders DROP COLUMN note",
+			want:       []map[string]any{{"op": ddlOpDropColumn, "column": "note"}},
+			wantParsed: true,
+		},
+		{
+			name:  "modify column",
+			query: "ALTER TABLE orders MODIFY COLUMN qty BIGINT UNSIGNED NULL",
+			want: []map[string]any{
+				{"op": ddlOpModifyColumn, "column": "qty", "type": "bigint(20) unsigned", "nullable": true},
+			},
+			wantParsed: true,
+		},
+		{
+			name:  "change column",
+			query: "ALTER TABLE orders CHANGE COLUMN qty quantity INT NOT NULL",
+			want: []map[string]any{
+				{"op": ddlOpChangeColumn, "column": "qty", "new_column": "quantity", "type": "int(11)", "nullable": false},
+			},
+			wantParsed: true,
+		},
+		{
+			name:       "rename column",
+			query:      "ALTER TABLE orders RENAME COLUMN qty TO quantity",
+			want:       []map[string]any{{"op": ddlOpRenameColumn, "column": "qty", "new_column": "quantity"}},
+			wantParsed: true,
+		},
+		{
+			name:  "several clauses",
+			query: "ALTER TABLE orders DROP COLUMN note, ADD UNIQUE KEY sku_idx (sku), ALGORITHM=INPLACE",
+			want: []map[string]any{
+				{"op": ddlOpDropColumn, "column": "note"},
+				{"op": ddlOpAddIndex, "index": "sku_idx", "columns": []string{"sku"}, "unique": true},
+			},
+			wantParsed: true,
+		},
+		{
+			name:       "rename table",
+			query:      "RENAME TABLE orders TO archive.orders, users TO customers",
+			want:       []map[string]any{{"op": ddlOpRenameTable, "from": "shop.orders", "to": "archive.orders"}},
+			wantParsed: true,
+		},
+		{
+			name:  "create table",
+			query: "CREATE TABLE orders (id INT, sku VARCHAR(16), PRIMARY KEY (id))",
+			want: []map[string]any{{
+				"op": ddlOpCreateTable,
+				"columns": []map[string]any{
+					{"column": "id", "type": "int(11)", "nullable": false},
+					{"column": "sku", "type": "varchar(16)", "nullable": true},
+				},
+				"primary_key": []string{"id"},
+			}},
+			wantParsed: true,
+		},
+		{
+			name:  "unrecognized clause",
+			query: "ALTER TABLE orders ADD COLUMN note TEXT, ENGINE=InnoDB",
+		},
+		{
+			name:  "create table like",
+			query: "CREATE TABLE orders LIKE orders_template",
+		},
+	}
+	p := parser.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := p.ParseOneStmt(tt.query, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, parsed := ddlChanges(stmt, orders, &replication.QueryEvent{Schema: []byte("shop")})
+			if parsed != tt.wantParsed {
+				t.Fatalf("parsed = %v, want %v", parsed, tt.wantParsed)
+			}
+			if parsed && !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes = %v, want %v", changes, tt.want)
+			}
+		})
+	}
+}
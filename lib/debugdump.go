@@ -0,0 +1,117 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+)
+
+// debugDump appends a JSON line for every raw rows event to a file, rotating
+// it to a single backup with a .1 suffix once it exceeds maxBytes. Write
+// failures are logged and never interrupt the stream.
+type debugDump struct {
+	path     string
+	maxBytes int64
+	logger   *service.Logger
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+type debugDumpRecord struct {
+	Time       time.Time `json:"time"`
+	Schema     string    `json:"schema"`
+	Table      string    `json:"table"`
+	Action     string    `json:"action"`
+	BinlogFile string    `json:"binlog_file,omitempty"`
+	BinlogPos  uint32    `json:"binlog_pos,omitempty"`
+	Snapshot   bool      `json:"snapshot,omitempty"`
+	Rows       [][]any   `json:"rows"`
+}
+
+func newDebugDump(path string, maxBytes int64, logger *service.Logger) (*debugDump, error) {
+	d := &debugDump{path: path, maxBytes: maxBytes, logger: logger}
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *debugDump) open() error {
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	d.file, d.size = f, info.Size()
+	return nil
+}
+
+func (d *debugDump) write(e *canal.RowsEvent, binlogFile string) {
+	record := debugDumpRecord{
+		Time:     time.Now(),
+		Schema:   e.Table.Schema,
+		Table:    e.Table.Name,
+		Action:   e.Action,
+		Snapshot: e.Header == nil,
+		Rows:     e.Rows,
+	}
+	if e.Header != nil {
+		record.BinlogFile, record.BinlogPos = binlogFile, e.Header.LogPos
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		d.logger.Warnf("Failed to encode debug dump record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return
+	}
+	if d.maxBytes > 0 && d.size > 0 && d.size+int64(len(line)) > d.maxBytes {
+		if err := d.rotate(); err != nil {
+			d.logger.Warnf("Failed to rotate debug dump file: %v", err)
+			return
+		}
+	}
+	n, err := d.file.Write(line)
+	d.size += int64(n)
+	if err != nil {
+		d.logger.Warnf("Failed to write debug dump record: %v", err)
+	}
+}
+
+func (d *debugDump) rotate() error {
+	d.file.Close()
+	d.file = nil
+	renameErr := os.Rename(d.path, d.path+".1")
+	if err := d.open(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+func (d *debugDump) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
@@ -0,0 +1,81 @@
+package mongodb_stream_benthos
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestDebugDumpRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.jsonl")
+	// Records are a little over 1000 bytes, so two of them fit the limit.
+	const maxBytes = 2500
+	m := newTestInput(t, WithDebugDump(path, maxBytes))
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "note"}}}
+	note := strings.Repeat("x", 1000)
+	for pos := uint32(1); pos <= 5; pos++ {
+		m.debugDump.write(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Header: &replication.EventHeader{LogPos: pos},
+			Rows:   [][]any{{pos, note}},
+		}, "mysql-bin.000001")
+	}
+	if err := m.debugDump.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The third and fifth records rotated the file, the second rotation
+	// replacing the backup of the first.
+	for _, f := range []struct {
+		path string
+		want []uint32
+	}{
+		{path: path, want: []uint32{5}},
+		{path: path + ".1", want: []uint32{3, 4}},
+	} {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxBytes {
+			t.Errorf("%s is %d bytes, over the %d byte limit", filepath.Base(f.path), info.Size(), maxBytes)
+		}
+		if got := dumpedPositions(t, f.path); !reflect.DeepEqual(got, f.want) {
+			t.Errorf("%s holds positions %v, want %v", filepath.Base(f.path), got, f.want)
+		}
+	}
+}
+
+// dumpedPositions returns the binlog positions of the records in the debug
+// dump file at path.
+func dumpedPositions(t *testing.T, path string) []uint32 {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var positions []uint32
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record debugDumpRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, record.BinlogPos)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return positions
+}
@@ -0,0 +1,173 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"fmt"
+)
+
+// encodedMessage is a stream message encoded for delivery, or the reason it
+// is not delivered.
+type encodedMessage struct {
+	msg          StreamMessage
+	body         []byte
+	structured   any
+	checksum     string
+	truncated    []string
+	oversizedErr error
+
+	// drop is set for messages left out of the stream, such as oversized
+	// messages with on_oversized: drop.
+	drop bool
+	err  error
+	// panicked is set when encoding panicked, which fails the same way on
+	// every retry.
+	panicked bool
+}
+
+// encodeJob is a stream message read from the buffer, in the order it is
+// delivered in, and its encoding once done is closed. It is encoded with the
+// context of the Read call that queued it, so that schema registry lookups
+// end with the read.
+type encodeJob struct {
+	positionID uint64
+	msg        StreamMessage
+	ctx        context.Context
+	done       chan struct{}
+	result     encodedMessage
+}
+
+// encodePool encodes messages on encode_workers goroutines. Jobs are queued
+// in the order their messages were read from the buffer and delivered in
+// that order whichever worker finishes first, so that encoding in parallel
+// changes neither the global order of the stream nor the order of the
+// changes of a key.
+type encodePool struct {
+	jobs    chan *encodeJob
+	pending []*encodeJob
+}
+
+// startEncodePool starts the encode workers, which run until the input is
+// closed.
+func (m *MysqlStreamInput) startEncodePool() *encodePool {
+	p := &encodePool{jobs: make(chan *encodeJob, 2*m.encodeWorkers)}
+	for i := 0; i < m.encodeWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case job := <-p.jobs:
+					job.result = m.encodeMessage(job.ctx, job.msg)
+					close(job.done)
+				case <-m.closed:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a job for the workers. It never blocks, as no more jobs are
+// pending than the queue holds.
+func (p *encodePool) submit(ctx context.Context, job *encodeJob) {
+	job.ctx = ctx
+	job.done = make(chan struct{})
+	p.jobs <- job
+	p.pending = append(p.pending, job)
+}
+
+// full reports whether as many jobs are pending as the workers are allowed
+// to run ahead of delivery.
+func (p *encodePool) full() bool {
+	return len(p.pending) >= cap(p.jobs)
+}
+
+// nextEncodeJob queues the messages waiting in the buffer for the workers and
+// returns the oldest pending job, or nil when none is pending or there are no
+// encode workers.
+func (m *MysqlStreamInput) nextEncodeJob(ctx context.Context) *encodeJob {
+	if m.encodePool == nil {
+		return nil
+	}
+	m.fillEncodePool(ctx)
+	p := m.encodePool
+	if len(p.pending) == 0 {
+		return nil
+	}
+	job := p.pending[0]
+	p.pending = p.pending[1:]
+	return job
+}
+
+// fillEncodePool queues the messages waiting in the buffer for the workers,
+// without waiting for more to arrive.
+func (m *MysqlStreamInput) fillEncodePool(ctx context.Context) {
+	for !m.encodePool.full() {
+		select {
+		case streamMessage := <-m.stream:
+			m.encodePool.submit(ctx, m.receive(streamMessage))
+		default:
+			return
+		}
+	}
+}
+
+// receive records a message read from the buffer and tracks its position
+// until it is acknowledged, in buffer order.
+func (m *MysqlStreamInput) receive(streamMessage StreamMessage) *encodeJob {
+	m.recordBufferDepth()
+	streamMessage.opName = m.opName(streamMessage)
+	job := &encodeJob{msg: streamMessage}
+	if m.positions != nil {
+		job.positionID = m.positions.track(streamMessage.resume, streamMessage.progress)
+	}
+	return job
+}
+
+// encodeMessage encodes the body of a message and computes its checksum,
+// enforcing max_message_bytes. It only reads the configuration of the input,
+// so that encode workers can run it concurrently.
+func (m *MysqlStreamInput) encodeMessage(ctx context.Context, streamMessage StreamMessage) (result encodedMessage) {
+	defer m.recoverEncode(streamMessage, &result)
+
+	// Structured messages are only encoded when their size is limited or
+	// included, in which case the encoding is kept.
+	var structured bool
+	if s, ok := m.encoder.(structuredEncoder); ok && m.structuredMessages {
+		result.structured, structured = s.structured(streamMessage)
+	}
+	if !structured || m.maxMessageBytes > 0 || m.includeMessageSize {
+		var err error
+		if result.body, err = m.encode(ctx, streamMessage); err != nil && m.emitErrorsInline {
+			streamMessage = m.inlineEncodeError(streamMessage, err)
+			result.body, err = jsonEncoder{}.Encode(ctx, streamMessage)
+		}
+		if err != nil {
+			return encodedMessage{err: err}
+		}
+	}
+	result.msg = streamMessage
+
+	checksum, err := m.rowChecksum(streamMessage)
+	if err != nil {
+		return encodedMessage{err: err}
+	}
+	result.checksum = checksum
+
+	if m.maxMessageBytes > 0 && len(result.body) > m.maxMessageBytes {
+		m.metrics.messagesOversized.Incr(1)
+		switch m.onOversized {
+		case oversizedTruncate:
+			var err error
+			if result.body, result.truncated, err = m.truncate(ctx, streamMessage); err != nil {
+				m.logger.Warnf("Dropping oversized %s message from table %s: %v", streamMessage.Event, streamMessage.Table, err)
+				return encodedMessage{drop: true}
+			}
+		case oversizedError:
+			result.oversizedErr = fmt.Errorf("message of %d bytes exceeds max_message_bytes of %d", len(result.body), m.maxMessageBytes)
+		default:
+			m.logger.Warnf("Dropping oversized %s message of %d bytes from table %s", streamMessage.Event, len(result.body), streamMessage.Table)
+			return encodedMessage{drop: true}
+		}
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+type readCtxKey struct{}
+
+// slowEncoder takes longer to encode the rows with lower ids, so that encode
+// workers finish them out of order, and fails to encode rows outside of the
+// context of a Read call. Rows listed in failures fail to encode that many
+// times first.
+type slowEncoder struct {
+	mu       sync.Mutex
+	failures map[int64]int
+}
+
+func (e *slowEncoder) Encode(ctx context.Context, msg StreamMessage) ([]byte, error) {
+	if ctx.Value(readCtxKey{}) == nil {
+		return nil, errors.New("not encoded with the context of the read")
+	}
+	id, _ := msg.Data["id"].(int64)
+	e.mu.Lock()
+	fail := e.failures[id] > 0
+	if fail {
+		e.failures[id]--
+	}
+	e.mu.Unlock()
+	if fail {
+		return nil, errors.New("schema registry unavailable")
+	}
+	time.Sleep(time.Duration(8-id%8) * time.Millisecond)
+	return []byte(fmt.Sprint(id)), nil
+}
+
+// hashEncoder stands in for an expensive encoding, such as Avro with
+// checksums.
+type hashEncoder struct{}
+
+func (hashEncoder) Encode(_ context.Context, msg StreamMessage) ([]byte, error) {
+	sum := []byte(fmt.Sprint(msg.Data["id"]))
+	for i := 0; i < 2000; i++ {
+		s := sha256.Sum256(sum)
+		sum = s[:]
+	}
+	return sum, nil
+}
+
+var (
+	registerPoolEncoders sync.Once
+	testSlowEncoder      = &slowEncoder{failures: map[int64]int{}}
+)
+
+func registerEncodePoolEncoders(tb testing.TB) {
+	tb.Helper()
+	registerPoolEncoders.Do(func() {
+		err := RegisterMessageEncoder("test_slow", testSlowEncoder)
+		if err == nil {
+			err = RegisterMessageEncoder("test_hash", hashEncoder{})
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+	})
+}
+
+func insertRows(tb testing.TB, m *MysqlStreamInput, from, n int) {
+	tb.Helper()
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	for i := from; i < from+n; i++ {
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{int64(i)}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: uint32(100 * (i + 1))},
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeWorkersKeepOrder(t *testing.T) {
+	registerEncodePoolEncoders(t)
+	// Row 3 fails to encode once, and is delivered in order once it does.
+	testSlowEncoder.mu.Lock()
+	testSlowEncoder.failures[3] = 1
+	testSlowEncoder.mu.Unlock()
+
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat("test_slow"), WithEncodeWorkers(4))
+	defer m.Close(context.Background())
+	insertRows(t, m, 0, 16)
+
+	ctx := context.WithValue(context.Background(), readCtxKey{}, true)
+	var failed int
+	for want := 0; want < 16; {
+		msg, ack, err := m.Read(ctx)
+		if err != nil {
+			failed++
+			if failed > 1 {
+				t.Fatalf("Read error = %v after the failed encode was retried", err)
+			}
+			continue
+		}
+		body, err := msg.AsBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != fmt.Sprint(want) {
+			t.Fatalf("read row %s, want %d", body, want)
+		}
+		if err := ack(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+		want++
+	}
+	if failed != 1 {
+		t.Errorf("%d failed reads, want the 1 of row 3", failed)
+	}
+}
+
+func TestEncodeWorkersReadContext(t *testing.T) {
+	registerEncodePoolEncoders(t)
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat("test_slow"), WithEncodeWorkers(2))
+	defer m.Close(context.Background())
+	insertRows(t, m, 0, 4)
+
+	// The first read ends before row 0 is encoded, leaving the rows queued
+	// under its context.
+	first, cancel := context.WithCancel(context.WithValue(context.Background(), readCtxKey{}, true))
+	cancel()
+	if _, _, err := m.Read(first); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Read error = %v, want %v", err, context.Canceled)
+	}
+
+	// Rows the workers could not encode under it are encoded again under the
+	// next read.
+	ctx := context.WithValue(context.Background(), readCtxKey{}, true)
+	for want := 0; want < 4; want++ {
+		msg, ack, err := m.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := msg.AsBytes(); string(body) != fmt.Sprint(want) {
+			t.Fatalf("read row %s, want %d", body, want)
+		}
+		if err := ack(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncodeWorkers measures the throughput of an expensive encoding
+// with and without encode_workers.
+func BenchmarkEncodeWorkers(b *testing.B) {
+	registerEncodePoolEncoders(b)
+	for _, workers := range []int{0, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			m, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithOutputFormat("test_hash"),
+				WithEncodeWorkers(workers), WithBufferSize(b.N))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer m.Close(context.Background())
+			insertRows(b, m, 0, b.N)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, ack, err := m.Read(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := ack(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
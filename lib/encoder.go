@@ -0,0 +1,285 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	outputFormatDebezium    = "debezium"
+	outputFormatCloudEvents = "cloudevents"
+)
+
+// MessageEncoder encodes a stream message into a message body. Encoders
+// registered with RegisterMessageEncoder can be selected by name with
+// WithOutputFormat alongside the built in formats.
+type MessageEncoder interface {
+	Encode(ctx context.Context, msg StreamMessage) ([]byte, error)
+}
+
+// structuredEncoder is implemented by encoders whose output can be set as a
+// structured message body instead when structured_messages is enabled. It
+// returns false for messages that must be encoded.
+type structuredEncoder interface {
+	structured(msg StreamMessage) (any, bool)
+}
+
+var (
+	customEncodersMu sync.RWMutex
+	customEncoders   = map[string]MessageEncoder{}
+)
+
+// builtinOutputFormats are the output formats selectable in the config.
+var builtinOutputFormats = []string{
+	outputFormatRow, outputFormatTransaction, outputFormatAvro, outputFormatDebezium, outputFormatCloudEvents, outputFormatNDJSONBatch,
+}
+
+// RegisterMessageEncoder makes encoder selectable with WithOutputFormat by
+// name. It must be called before the inputs using it are created, and name
+// must not be taken by a built in or previously registered format. Custom
+// formats are a feature of the Go API: the output_format field of the config
+// only accepts the built in ones.
+func RegisterMessageEncoder(name string, encoder MessageEncoder) error {
+	if name == "" {
+		return errors.New("output format name is empty")
+	}
+	if encoder == nil {
+		return fmt.Errorf("output format %s has no encoder", name)
+	}
+	for _, builtin := range builtinOutputFormats {
+		if name == builtin {
+			return fmt.Errorf("output format %s is built in", name)
+		}
+	}
+
+	customEncodersMu.Lock()
+	defer customEncodersMu.Unlock()
+
+	if _, exists := customEncoders[name]; exists {
+		return fmt.Errorf("output format %s is already registered", name)
+	}
+	customEncoders[name] = encoder
+	return nil
+}
+
+func customEncoder(name string) (MessageEncoder, bool) {
+	customEncodersMu.RLock()
+	defer customEncodersMu.RUnlock()
+
+	encoder, ok := customEncoders[name]
+	return encoder, ok
+}
+
+// outputFormatNames lists the built in and registered output formats, for
+// errors naming the valid ones.
+func outputFormatNames() string {
+	customEncodersMu.RLock()
+	defer customEncodersMu.RUnlock()
+
+	names := append([]string(nil), builtinOutputFormats...)
+	custom := make([]string, 0, len(customEncoders))
+	for name := range customEncoders {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return strings.Join(append(names, custom...), ", ")
+}
+
+// Op returns the Debezium style operation code of a row change: c, u, d, r
+// for snapshot rows or t for truncates, and an empty string for other events.
+func (s StreamMessage) Op() string {
+	return s.op
+}
+
+// OpName returns the operation code emitted for a message under op_names,
+// which by default is Op for row changes and truncates and empty for other
+// events.
+func (s StreamMessage) OpName() string {
+	return s.opName
+}
+
+// Database returns the database of the table a message belongs to, or an
+// empty string for messages not tied to a table.
+func (s StreamMessage) Database() string {
+	if s.table != nil {
+		return s.table.Schema
+	}
+	return s.database
+}
+
+// Position returns the binlog position of the event a message was read from,
+// which is zero for snapshot rows and messages not read from the binlog.
+func (s StreamMessage) Position() mysql.Position {
+	return s.position
+}
+
+// Before returns the row image before an update when the output format or
+// before_mode requires it, and nil otherwise.
+func (s StreamMessage) Before() map[string]any {
+	return s.before
+}
+
+// Timestamp returns the time the event a message was read from was written to
+// the binlog, which is zero for snapshot rows and messages not read from the
+// binlog.
+func (s StreamMessage) Timestamp() time.Time {
+	if s.timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(s.timestamp), 0)
+}
+
+// jsonEncoder encodes the row values of a message as JSON.
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(_ context.Context, msg StreamMessage) ([]byte, error) {
+	return json.Marshal(jsonBody(msg))
+}
+
+func (jsonEncoder) structured(msg StreamMessage) (any, bool) {
+	return jsonBody(msg), true
+}
+
+// jsonBody returns the value a message body is encoded from in JSON output:
+// the column values, together with the _before, raw_field and _temporal_raw
+// sections when the message has them. The sections other than raw_field are
+// named with a leading underscore so that they do not replace a column.
+func jsonBody(msg StreamMessage) any {
+	if msg.Previous == nil && msg.Raw == nil && msg.TemporalRaw == nil {
+		return msg.Data
+	}
+	body := make(map[string]any, len(msg.Data)+3)
+	for column, v := range msg.Data {
+		body[column] = v
+	}
+	if msg.Previous != nil {
+		body["_before"] = msg.Previous
+	}
+	if msg.Raw != nil {
+		body[msg.rawField] = msg.Raw
+	}
+	if msg.TemporalRaw != nil {
+		body["_temporal_raw"] = msg.TemporalRaw
+	}
+	return body
+}
+
+// debeziumEncoder encodes row changes in the envelope of Debezium's MySQL
+// connector, with before and after row images, an op code and a source block
+// describing where the change was read from. Messages that are not row
+// changes are encoded as JSON.
+type debeziumEncoder struct{}
+
+func (e debeziumEncoder) Encode(_ context.Context, msg StreamMessage) ([]byte, error) {
+	body, _ := e.structured(msg)
+	return json.Marshal(body)
+}
+
+func (debeziumEncoder) structured(msg StreamMessage) (any, bool) {
+	if msg.op == "" {
+		return jsonBody(msg), true
+	}
+
+	var before, after map[string]any
+	switch msg.op {
+	case opCreate, opRead:
+		after = msg.Data
+	case opUpdate:
+		before, after = msg.before, msg.Data
+	case opDelete:
+		before = msg.Data
+	}
+
+	ts := msg.Timestamp()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	envelope := map[string]any{
+		"before": before,
+		"after":  after,
+		"source": map[string]any{
+			"connector": "mysql",
+			"ts_ms":     ts.UnixMilli(),
+			"snapshot":  msg.snapshot,
+			"db":        msg.Database(),
+			"table":     msg.Table,
+			"file":      msg.position.Name,
+			"pos":       msg.position.Pos,
+		},
+		"op":    msg.opName,
+		"ts_ms": time.Now().UnixMilli(),
+	}
+	if msg.source != nil {
+		source := envelope["source"].(map[string]any)
+		source["version"] = msg.source.Version
+		source["server_version"] = msg.source.ServerVersion
+	}
+	if msg.Raw != nil {
+		envelope[msg.rawField] = msg.Raw
+	}
+	if msg.TemporalRaw != nil {
+		envelope["_temporal_raw"] = msg.TemporalRaw
+	}
+	return envelope, true
+}
+
+// cloudEventsTypePrefix prefixes the event name of a message to form the type
+// of its CloudEvent, such as mysql.cdc.insert.
+const cloudEventsTypePrefix = "mysql.cdc."
+
+// cloudEventsEncoder encodes messages as CloudEvents v1.0 in the structured
+// JSON mode, with the JSON body of the message as the event data.
+type cloudEventsEncoder struct{}
+
+func (e cloudEventsEncoder) Encode(_ context.Context, msg StreamMessage) ([]byte, error) {
+	body, _ := e.structured(msg)
+	return json.Marshal(body)
+}
+
+func (cloudEventsEncoder) structured(msg StreamMessage) (any, bool) {
+	ts := msg.Timestamp()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	// Row changes are identified by their idempotency key, so that a change
+	// delivered again carries the same id, and other messages by the time
+	// they were created.
+	id := msg.idempotencyKey
+	if id == "" {
+		id = fmt.Sprintf("%s:%d", strings.ToLower(msg.Event), time.Now().UnixNano())
+	}
+
+	source := "mysql://" + msg.sourceHost
+	if db := msg.Database(); db != "" {
+		source += "/" + db
+		if msg.Table != "" {
+			source += "/" + msg.Table
+		}
+	}
+
+	event := map[string]any{
+		"specversion":     "1.0",
+		"type":            cloudEventsTypePrefix + strings.ToLower(msg.Event),
+		"source":          source,
+		"id":              id,
+		"time":            ts.UTC().Format(time.RFC3339Nano),
+		"datacontenttype": "application/json",
+		"data":            jsonBody(msg),
+	}
+	if msg.Table != "" {
+		event["subject"] = msg.Table
+	}
+	if msg.opName != "" {
+		event["op"] = msg.opName
+	}
+	return event, true
+}
@@ -0,0 +1,198 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+type upperEncoder struct{}
+
+func (upperEncoder) Encode(_ context.Context, msg StreamMessage) ([]byte, error) {
+	return []byte(strings.ToUpper(msg.Event)), nil
+}
+
+var registerUpperEncoder sync.Once
+
+func TestOutputFormatValidation(t *testing.T) {
+	registerUpperEncoder.Do(func() {
+		if err := RegisterMessageEncoder("test_upper", upperEncoder{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	registerTests := []struct {
+		name    string
+		format  string
+		encoder MessageEncoder
+	}{
+		{name: "built in", format: outputFormatDebezium, encoder: upperEncoder{}},
+		{name: "registered twice", format: "test_upper", encoder: upperEncoder{}},
+		{name: "no encoder", format: "test_nil"},
+		{name: "no name", encoder: upperEncoder{}},
+	}
+	for _, tt := range registerTests {
+		t.Run("register "+tt.name, func(t *testing.T) {
+			if err := RegisterMessageEncoder(tt.format, tt.encoder); err == nil {
+				t.Error("RegisterMessageEncoder succeeded")
+			}
+		})
+	}
+
+	formatTests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: outputFormatCloudEvents},
+		{format: "test_upper"},
+		{format: "test_missing", wantErr: true},
+	}
+	for _, tt := range formatTests {
+		t.Run("format "+tt.format, func(t *testing.T) {
+			_, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithOutputFormat(tt.format))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMysqlStreamInput error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "test_upper") {
+				t.Errorf("error %q does not list the registered formats", err)
+			}
+		})
+	}
+}
+
+// BenchmarkReadRow measures converting a row change and reading it as a
+// message, with and without structured_messages.
+func BenchmarkReadRow(b *testing.B) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "orders",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "customer", Type: schema.TYPE_STRING},
+			{Name: "sku", Type: schema.TYPE_STRING},
+			{Name: "qty", Type: schema.TYPE_NUMBER},
+			{Name: "price", Type: schema.TYPE_FLOAT},
+			{Name: "status", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+
+	for _, structured := range []bool{false, true} {
+		name := "encoded"
+		if structured {
+			name = "structured"
+		}
+		b.Run(name, func(b *testing.B) {
+			m, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithStructuredMessages(structured))
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(i), "ann@example.com", "A-100", int64(2), 9.99, "paid"}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: uint32(i + 1)},
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, ack, err := m.Read(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := ack(ctx, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCloudEventsEnvelope(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(outputFormatCloudEvents), WithStructuredMessages(true))
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(7)}},
+		Header: &replication.EventHeader{Timestamp: 1700000000, LogPos: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, event := readStructured(t, m)
+	key, _ := msg.MetaGet("idempotency_key")
+	want := map[string]any{
+		"specversion":     "1.0",
+		"type":            "mysql.cdc.insert",
+		"id":              key,
+		"time":            "2023-11-14T22:13:20Z",
+		"datacontenttype": "application/json",
+		"subject":         "orders",
+	}
+	for field, value := range want {
+		if event[field] != value {
+			t.Errorf("%s = %v, want %v", field, event[field], value)
+		}
+	}
+	if key == "" {
+		t.Error("row change has no idempotency_key to identify its event")
+	}
+	if source, _ := event["source"].(string); !strings.HasPrefix(source, "mysql://") || !strings.HasSuffix(source, "/shop/orders") {
+		t.Errorf("source = %v, want mysql://<host>/shop/orders", event["source"])
+	}
+	if data, _ := event["data"].(map[string]any); data["id"] != int64(7) {
+		t.Errorf("data = %v, want the row", event["data"])
+	}
+}
+
+func TestBeforeMode(t *testing.T) {
+	// The table has a column named before, which the section must not
+	// replace.
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "before"}, {Name: "qty"}}, PKColumns: []int{0}}
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: beforeModeFull, want: "map[before:draft id:1 qty:1]"},
+		{mode: beforeModeChanged, want: "map[qty:1]"},
+		{mode: beforeModeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithBeforeMode(tt.mode))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.UpdateAction,
+				Rows:   [][]any{{int64(1), "draft", int64(1)}, {int64(1), "draft", int64(2)}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 500},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, body := readStructured(t, m)
+			if body["before"] != "draft" {
+				t.Errorf("before column = %v, want draft", body["before"])
+			}
+			section, ok := body["_before"]
+			if (tt.want != "") != ok {
+				t.Fatalf("_before section = %v, want it present %v", section, tt.want != "")
+			}
+			if ok && fmt.Sprint(section) != tt.want {
+				t.Errorf("_before section = %v, want %s", section, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// enrichmentLookupTimeout bounds each lookup of a related row, which holds up
+// the stream while it runs.
+const enrichmentLookupTimeout = 5 * time.Second
+
+// Enrichment adds fields of the row a foreign key column refers to to the row
+// changes of a table. The row of TargetTable whose TargetColumn equals Column
+// is looked up over the control connection and each of its Fields is added
+// to the message, named with Prefix.
+type Enrichment struct {
+	// Table is the table whose messages are enriched, named as in
+	// significant_columns.
+	Table string
+	// Column is the foreign key column of Table.
+	Column string
+	// TargetTable is the referenced table, as db.table or as a table of the
+	// database of Table.
+	TargetTable string
+	// TargetColumn is the column of TargetTable that Column refers to.
+	TargetColumn string
+	// Fields are the columns of TargetTable added to the message.
+	Fields []string
+	// Prefix is prepended to the name of every added field. When empty it is
+	// Column without an _id suffix followed by an underscore, so that the name
+	// of customer_id enriches a message with customer_name.
+	Prefix string
+}
+
+// enrichmentPrefix returns the prefix of the fields added by an enrichment.
+func (e Enrichment) enrichmentPrefix() string {
+	if e.Prefix != "" {
+		return e.Prefix
+	}
+	return strings.TrimSuffix(e.Column, "_id") + "_"
+}
+
+// target returns the referenced table of an enrichment of a table of db.
+func (e Enrichment) target(db string) tableRef {
+	if schemaName, name, ok := strings.Cut(e.TargetTable, "."); ok {
+		return tableRef{schema: schemaName, name: name}
+	}
+	return tableRef{schema: db, name: e.TargetTable}
+}
+
+// parseEnrichments reads the enrichments list.
+func parseEnrichments(conf *service.ParsedConfig) ([]Enrichment, error) {
+	entries, err := conf.FieldObjectList("enrichments")
+	if err != nil {
+		return nil, err
+	}
+
+	enrichments := make([]Enrichment, 0, len(entries))
+	for _, entry := range entries {
+		var e Enrichment
+		if e.Table, err = entry.FieldString("table"); err != nil {
+			return nil, err
+		}
+		if e.Column, err = entry.FieldString("column"); err != nil {
+			return nil, err
+		}
+		if e.TargetTable, err = entry.FieldString("target_table"); err != nil {
+			return nil, err
+		}
+		if e.TargetColumn, err = entry.FieldString("target_column"); err != nil {
+			return nil, err
+		}
+		if e.Fields, err = entry.FieldStringList("fields"); err != nil {
+			return nil, err
+		}
+		if e.Prefix, err = entry.FieldString("prefix"); err != nil {
+			return nil, err
+		}
+		enrichments = append(enrichments, e)
+	}
+	return enrichments, nil
+}
+
+// validateEnrichments checks the configured enrichments and indexes them by
+// table.
+func validateEnrichments(enrichments []Enrichment) (map[string][]Enrichment, error) {
+	byTable := map[string][]Enrichment{}
+	for _, e := range enrichments {
+		switch {
+		case e.Table == "" || e.Column == "":
+			return nil, errors.New("enrichments require a table and column")
+		case e.TargetTable == "" || e.TargetColumn == "":
+			return nil, fmt.Errorf("enrichment of %s.%s requires a target_table and target_column", e.Table, e.Column)
+		case len(e.Fields) == 0:
+			return nil, fmt.Errorf("enrichment of %s.%s requires at least one field", e.Table, e.Column)
+		}
+		byTable[e.Table] = append(byTable[e.Table], e)
+	}
+	return byTable, nil
+}
+
+// relatedRow is a cached lookup of a related row, which is nil when no row
+// matched.
+type relatedRow map[string]any
+
+// enrich adds the fields of the rows referenced by the enriched foreign key
+// columns of a table to a message. Columns left out of the message, such as
+// by delete_minimal_behavior pk_only, are not enriched, and NULL foreign keys
+// or keys without a matching row set the added fields to null.
+func (m *MysqlStreamInput) enrich(table *schema.Table, row []any, message map[string]any) error {
+	for _, e := range m.enrichments[table.Name] {
+		if _, ok := message[e.Column]; !ok {
+			continue
+		}
+		i := table.FindColumn(e.Column)
+		if i < 0 || i >= len(row) {
+			continue
+		}
+
+		var related relatedRow
+		if row[i] != nil {
+			var err error
+			if related, err = m.relatedRow(e, e.target(table.Schema), row[i]); err != nil {
+				return err
+			}
+		}
+		prefix := e.enrichmentPrefix()
+		for _, field := range e.Fields {
+			message[prefix+field] = related[field]
+		}
+	}
+	return nil
+}
+
+func enrichmentKey(e Enrichment, target tableRef, value any) string {
+	return fmt.Sprintf("%s|%s|%s|%v", target.key(), e.TargetColumn, strings.Join(e.Fields, ","), value)
+}
+
+// relatedRow returns the fields of an enrichment from the row of its target
+// table whose target column equals value, from the enrichment cache when it
+// has been looked up before.
+func (m *MysqlStreamInput) relatedRow(e Enrichment, target tableRef, value any) (relatedRow, error) {
+	key := enrichmentKey(e, target, value)
+	if cached, ok := m.enrichmentCache.get(key); ok {
+		return cached, nil
+	}
+
+	columns := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		columns[i] = quoteIdentifier(field)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s = ? LIMIT 1",
+		strings.Join(columns, ", "), quoteIdentifier(target.schema), quoteIdentifier(target.name), quoteIdentifier(e.TargetColumn))
+
+	var related relatedRow
+	ctx, cancel := context.WithTimeout(context.Background(), enrichmentLookupTimeout)
+	defer cancel()
+	err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+		res, err := conn.Execute(query, value)
+		if err != nil {
+			return err
+		}
+		if res.RowNumber() == 0 {
+			return nil
+		}
+		related = make(relatedRow, len(e.Fields))
+		for j, field := range e.Fields {
+			v := res.Values[0][j].Value()
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			related[field] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s of %s for enrichment: %w", e.TargetColumn, target.key(), err)
+	}
+	m.enrichmentCache.put(key, related)
+	if m.enrichmentTargets[target.key()] == nil {
+		m.enrichmentTargets[target.key()] = map[string]Enrichment{}
+	}
+	m.enrichmentTargets[target.key()][e.TargetColumn+"|"+strings.Join(e.Fields, ",")] = e
+	return related, nil
+}
+
+// invalidateEnrichments drops the cached lookups of rows changed by a rows
+// event on a table referenced by an enrichment, so that later messages are
+// enriched with their new values.
+func (m *MysqlStreamInput) invalidateEnrichments(e *canal.RowsEvent) {
+	target := tableRef{schema: e.Table.Schema, name: e.Table.Name}
+	for _, enrichment := range m.enrichmentTargets[target.key()] {
+		i := e.Table.FindColumn(enrichment.TargetColumn)
+		if i < 0 {
+			continue
+		}
+		for _, row := range e.Rows {
+			if i < len(row) {
+				m.enrichmentCache.remove(enrichmentKey(enrichment, target, row[i]))
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestValidateEnrichments(t *testing.T) {
+	valid := Enrichment{Table: "orders", Column: "customer_id", TargetTable: "customers", TargetColumn: "id", Fields: []string{"name"}}
+	tests := []struct {
+		name    string
+		edit    func(*Enrichment)
+		wantErr bool
+	}{
+		{name: "valid", edit: func(*Enrichment) {}},
+		{name: "no column", edit: func(e *Enrichment) { e.Column = "" }, wantErr: true},
+		{name: "no target column", edit: func(e *Enrichment) { e.TargetColumn = "" }, wantErr: true},
+		{name: "no fields", edit: func(e *Enrichment) { e.Fields = nil }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid
+			tt.edit(&e)
+			if _, err := validateEnrichments([]Enrichment{e}); (err != nil) != tt.wantErr {
+				t.Errorf("validateEnrichments error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnrichFromCachedLookups(t *testing.T) {
+	enrichment := Enrichment{Table: "orders", Column: "customer_id", TargetTable: "customers", TargetColumn: "id", Fields: []string{"name"}}
+	m := newTestInput(t, WithDatabase("shop"), WithEnrichments([]Enrichment{enrichment}, 16), WithStructuredMessages(true))
+	orders := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id"}, {Name: "customer_id"}},
+		PKColumns: []int{0},
+	}
+	customers := &schema.Table{
+		Schema:    "shop",
+		Name:      "customers",
+		Columns:   []schema.TableColumn{{Name: "id"}, {Name: "name"}},
+		PKColumns: []int{0},
+	}
+	// Customer 7 was looked up for an earlier order.
+	target := tableRef{schema: "shop", name: "customers"}
+	key := enrichmentKey(enrichment, target, int64(7))
+	m.enrichmentCache.put(key, relatedRow{"name": "Ann"})
+	m.enrichmentTargets["shop.customers"] = map[string]Enrichment{"id|name": enrichment}
+
+	rows := func(table *schema.Table, action string, row []any) {
+		t.Helper()
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: action,
+			Rows:   [][]any{row},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows(orders, canal.InsertAction, []any{int64(1), int64(7)})
+	if _, body := readStructured(t, m); !reflect.DeepEqual(body, map[string]any{"id": int64(1), "customer_id": int64(7), "customer_name": "Ann"}) {
+		t.Errorf("enriched body = %v, want customer_name Ann", body)
+	}
+	rows(orders, canal.InsertAction, []any{int64(2), nil})
+	if _, body := readStructured(t, m); !reflect.DeepEqual(body, map[string]any{"id": int64(2), "customer_id": nil, "customer_name": nil}) {
+		t.Errorf("body with a NULL foreign key = %v, want customer_name null", body)
+	}
+
+	// A change to the customer drops its cached lookup.
+	rows(customers, canal.DeleteAction, []any{int64(7), "Ann"})
+	if _, ok := m.enrichmentCache.get(key); ok {
+		t.Error("lookup of a changed customer still cached")
+	}
+}
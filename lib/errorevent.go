@@ -0,0 +1,85 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const errorEvent = "error"
+
+// rowError handles the row at index i of a rows event that could not be
+// converted. With dead_letter_rows the row is emitted with its raw values and
+// tagged as a dead letter, with emit_errors_inline it is replaced by an error
+// message, and either way the stream continues. Otherwise the error stops the
+// stream.
+func (m *MysqlStreamInput) rowError(e *canal.RowsEvent, i int, position mysql.Position, err error) error {
+	if m.deadLetterRows {
+		m.logger.Warnf("Emitting %s row from table %s as a dead letter: %v", e.Action, e.Table, err)
+		m.metrics.rowsDeadLettered.Incr(1)
+		return m.send(deadLetterRow(e, i, position, err))
+	}
+	if !m.emitErrorsInline {
+		return err
+	}
+	m.logger.Warnf("Emitting error in place of %s row from table %s: %v", e.Action, e.Table, err)
+	return m.send(errorMessage(e.Table.Schema, e.Table.Name, e.Action, e.Header == nil, position, err))
+}
+
+// inlineEncodeError returns an error message in place of a message that could
+// not be encoded.
+func (m *MysqlStreamInput) inlineEncodeError(msg StreamMessage, err error) StreamMessage {
+	m.logger.Warnf("Emitting error in place of %s message from table %s: %v", msg.Event, msg.Table, err)
+	errMsg := errorMessage(msg.Database(), msg.Table, msg.Event, msg.op == opRead, msg.position, err)
+	errMsg.resume, errMsg.sentAt = msg.resume, msg.sentAt
+	return errMsg
+}
+
+// deadLetterRow returns the row at index i of a rows event as read from the
+// binlog or the snapshot query, without any conversion. Values are named after
+// their columns by position, and values past the columns of the schema, such as
+// those of a row written before the schema was altered, by their index.
+func deadLetterRow(e *canal.RowsEvent, i int, position mysql.Position, err error) StreamMessage {
+	data := make(map[string]any, len(e.Rows[i]))
+	for j, v := range e.Rows[i] {
+		if j < len(e.Table.Columns) {
+			data[e.Table.Columns[j].Name] = v
+		} else {
+			data[fmt.Sprintf("_%d", j)] = v
+		}
+	}
+	return StreamMessage{
+		Table:           e.Table.Name,
+		Event:           e.Action,
+		Data:            data,
+		database:        e.Table.Schema,
+		position:        position,
+		deadLetterError: err.Error(),
+	}
+}
+
+// errorMessage describes a failure to emit a change. It is routed by its
+// event rather than its table, so that errors can be handled by a separate
+// branch of the pipeline.
+func errorMessage(database, table, action string, snapshot bool, position mysql.Position, err error) StreamMessage {
+	data := map[string]any{
+		"database":  database,
+		"table":     table,
+		"action":    action,
+		"snapshot":  snapshot,
+		"error":     err.Error(),
+		"timestamp": time.Now().Unix(),
+	}
+	if position.Name != "" {
+		data["binlog_file"] = position.Name
+		data["binlog_pos"] = position.Pos
+	}
+	return StreamMessage{
+		Table:    table,
+		Event:    errorEvent,
+		Data:     data,
+		position: position,
+	}
+}
@@ -0,0 +1,74 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestDeadLetterRows(t *testing.T) {
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}, {Name: "shipped_at", Type: schema.TYPE_DATETIME}},
+		PKColumns: []int{0},
+	}
+	// The zero date cannot be converted under zero_date_behavior: error.
+	insert := &canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(1), "0000-00-00 00:00:00"}, {int64(2), "2024-01-02 03:04:05"}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 200},
+	}
+
+	t.Run("stops the stream", func(t *testing.T) {
+		m := newTestInput(t, WithDatabase("shop"), WithZeroDateBehavior(zeroDateError))
+		if err := m.OnRow(insert); err == nil {
+			t.Fatal("OnRow succeeded for an undecodable row")
+		}
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		mgr, cache := newTestResources(t)
+		m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithZeroDateBehavior(zeroDateError),
+			WithDeadLetterRows(true), WithStructuredMessages(true), WithPositionCache("cache", "position"), WithPositionFlush(0, 1))
+		m.binlogFile = "mysql-bin.000001"
+		m.syncedPosition = mysql.Position{Name: "mysql-bin.000001", Pos: 100}
+		if err := m.OnRow(insert); err != nil {
+			t.Fatal(err)
+		}
+
+		msg, body := readStructured(t, m)
+		if v, _ := msg.MetaGet("dead_letter"); v != "true" {
+			t.Errorf("dead_letter = %q, want true", v)
+		}
+		if v, _ := msg.MetaGet("dead_letter_error"); v == "" {
+			t.Error("dead letter has no dead_letter_error")
+		}
+		want := map[string]any{"id": int64(1), "shipped_at": "0000-00-00 00:00:00"}
+		for column, value := range want {
+			if body[column] != value {
+				t.Errorf("dead letter %s = %v, want the raw value %v", column, body[column], value)
+			}
+		}
+
+		// The next row of the event streams as usual.
+		next, ack, err := m.Read(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := next.MetaGet("dead_letter"); ok {
+			t.Error("row after the dead letter tagged as a dead letter")
+		}
+		if err := ack(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cache.storedPosition(t, "position"); !ok {
+			t.Error("position not stored past the dead letter")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package mongodb_stream_benthos
+
+import (
+	"strconv"
+	"time"
+)
+
+// eventTimeLayouts are the layouts timestamp_column values given as strings
+// are parsed with, the first being the way DATETIME and TIMESTAMP values are
+// read from the binlog without parse_time.
+var eventTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// eventTime returns the event_time of a row change of a table with a
+// timestamp_column: the value of that column, or the binlog timestamp of the
+// change when the value is NULL or cannot be parsed as a time.
+func (m *MysqlStreamInput) eventTime(msg StreamMessage) (time.Time, bool) {
+	if msg.table == nil || msg.op == "" {
+		return time.Time{}, false
+	}
+	column, ok := m.timestampColumns[tableRef{schema: msg.table.Schema, name: msg.table.Name}.key()]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if ms, ok := msg.Data[column].(int64); ok && m.temporalOutput == temporalUnixMillis {
+		return time.UnixMilli(ms), true
+	}
+	if v := msg.Data[column]; v != nil {
+		if t, ok := parseEventTime(v); ok {
+			return t, true
+		}
+		m.logger.Debugf("Using the binlog timestamp as event_time, timestamp_column %s of %s has value %v that is not a time", column, msg.table, v)
+	}
+	ts := msg.Timestamp()
+	return ts, !ts.IsZero()
+}
+
+// parseEventTime reads a column value as a time. Numbers are taken as unix
+// timestamps in seconds.
+func parseEventTime(v any) (time.Time, bool) {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case int64:
+		return time.Unix(v, 0), true
+	case uint64:
+		return time.Unix(int64(v), 0), true
+	case int32:
+		return time.Unix(int64(v), 0), true
+	case uint32:
+		return time.Unix(int64(v), 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second))), true
+	default:
+		return time.Time{}, false
+	}
+
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,102 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestEventTime(t *testing.T) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "orders",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "updated_at", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+	// 2023-11-14T22:13:20Z, the time the change was written to the binlog.
+	const binlogTime = 1700000000
+
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "datetime", value: "2024-05-01 10:00:00.25", want: "2024-05-01T10:00:00.25Z"},
+		{name: "rfc 3339", value: "2024-05-01T12:00:00+02:00", want: "2024-05-01T10:00:00Z"},
+		{name: "date", value: "2024-05-01", want: "2024-05-01T00:00:00Z"},
+		{name: "unix seconds", value: int64(1714557600), want: "2024-05-01T10:00:00Z"},
+		{name: "null", value: nil, want: "2023-11-14T22:13:20Z"},
+		{name: "not a time", value: "soon", want: "2023-11-14T22:13:20Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithTimestampColumns(map[string]string{"orders": "updated_at"}))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(1), tt.value}},
+				Header: &replication.EventHeader{Timestamp: binlogTime, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, _ := readStructured(t, m)
+			if got, _ := msg.MetaGet("event_time"); got != tt.want {
+				t.Errorf("event_time = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}}, PKColumns: []int{0}}
+	// 2023-11-14T22:13:20Z, the time the change was written to the binlog.
+	const binlogTime = 1700000000
+
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		header *replication.EventHeader
+		want   string
+	}{
+		{name: "binlog change", ttl: time.Hour, header: &replication.EventHeader{Timestamp: binlogTime, LogPos: 100}, want: "2023-11-14T23:13:20Z"},
+		// Expiry is relative to the event, so a change read long after it
+		// was written is delivered already expired.
+		{name: "stale change", ttl: time.Minute, header: &replication.EventHeader{Timestamp: binlogTime, LogPos: 100}, want: "2023-11-14T22:14:20Z"},
+		{name: "snapshot row", ttl: time.Hour},
+		{name: "disabled", header: &replication.EventHeader{Timestamp: binlogTime, LogPos: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithMessageTTL(tt.ttl))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(1)}},
+				Header: tt.header,
+			})
+			if err == nil && tt.header == nil {
+				// Snapshot rows are held until the next one is read.
+				err = m.flushSnapshotRow(snapshotTrue)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, _ := readStructured(t, m)
+			got, ok := msg.MetaGet("expires_at")
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("expires_at = %q (set %v), want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithMessageTTL(-time.Second)); err == nil {
+		t.Error("negative message_ttl accepted")
+	}
+}
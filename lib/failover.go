@@ -0,0 +1,43 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// candidateAddrs returns the servers to try connecting to, in order.
+func (m *MysqlStreamInput) candidateAddrs() []string {
+	if len(m.addrs) > 0 {
+		return m.addrs
+	}
+	return []string{m.addr}
+}
+
+// useAddr points the connections the input opens at addr.
+func (m *MysqlStreamInput) useAddr(addr string) {
+	if addr == m.addr {
+		return
+	}
+	m.addr = addr
+	if m.sslMode == sslModeVerifyIdentity && m.tlsConfig != nil {
+		tlsConfig := m.tlsConfig.Clone()
+		tlsConfig.ServerName = serverName(addr)
+		m.tlsConfig = tlsConfig
+		m.preferredTLSConfig = tlsConfig
+	}
+}
+
+// failoverGTIDSet returns the executed GTID set to start streaming from when
+// failing over between candidate servers is possible, or nil to stream from
+// binlog file positions.
+func (m *MysqlStreamInput) failoverGTIDSet(c *canal.Canal) mysql.GTIDSet {
+	if len(m.addrs) < 2 {
+		return nil
+	}
+	set, err := c.GetMasterGTIDSet()
+	if err != nil || set.String() == "" {
+		m.logger.Warnf("Server %s does not log GTIDs, streaming cannot fail over to other servers once started", m.addr)
+		return nil
+	}
+	return set
+}
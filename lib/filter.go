@@ -0,0 +1,87 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+type columnIndexes struct {
+	table   *schema.Table
+	indexes map[string]int
+}
+
+// parseSignificantColumns reads the significant_columns list into a lookup of
+// table name to the columns whose changes should trigger an UPDATE event.
+func parseSignificantColumns(conf *service.ParsedConfig) (map[string][]string, error) {
+	entries, err := conf.FieldObjectList("significant_columns")
+	if err != nil {
+		return nil, err
+	}
+
+	significant := map[string][]string{}
+	for _, entry := range entries {
+		table, err := entry.FieldString("table")
+		if err != nil {
+			return nil, err
+		}
+		columns, err := entry.FieldStringList("columns")
+		if err != nil {
+			return nil, err
+		}
+		significant[table] = append(significant[table], columns...)
+	}
+	return significant, nil
+}
+
+// hasSignificantChange reports whether an UPDATE modified any of the
+// significant columns configured for its table. Tables without significant
+// columns always report a change, and so do significant columns missing from
+// the table, since their changes cannot be told apart.
+func (m *MysqlStreamInput) hasSignificantChange(table *schema.Table, columnIndex map[string]int, before, after []any) bool {
+	columns, ok := m.significantColumns[tableRef{schema: table.Schema, name: table.Name}.key()]
+	if !ok {
+		return true
+	}
+
+	for _, column := range columns {
+		i, ok := columnIndex[column]
+		if !ok || i >= len(before) || i >= len(after) {
+			return true
+		}
+		if !reflect.DeepEqual(before[i], after[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNoopUpdate reports whether the before and after images of an update hold
+// the same values in every column.
+func isNoopUpdate(before, after []any) bool {
+	return reflect.DeepEqual(before, after)
+}
+
+// columnIndexFor returns the position of every column of a table by name. It
+// is cached per table and rebuilt when its layout changes, so that events on
+// wide tables do not rebuild it. Significant columns missing from the layout
+// are logged as it is rebuilt.
+func (m *MysqlStreamInput) columnIndexFor(table *schema.Table) map[string]int {
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.columnIndexCache.get(key); ok && cached.table == table {
+		return cached.indexes
+	}
+
+	indexes := make(map[string]int, len(table.Columns))
+	for i, col := range table.Columns {
+		indexes[col.Name] = i
+	}
+	for _, column := range m.significantColumns[key] {
+		if _, ok := indexes[column]; !ok {
+			m.logger.Warnf("significant_columns: table %s has no column %s, treating its updates as significant", key, column)
+		}
+	}
+	m.columnIndexCache.put(key, columnIndexes{table: table, indexes: indexes})
+	return indexes
+}
@@ -0,0 +1,85 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestDropNoopUpdates(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "status"}, {Name: "label"}}, PKColumns: []int{0}}
+	// Row 1 is written by UPDATE orders SET status = status, row 2 changes.
+	update := &canal.RowsEvent{
+		Table:  table,
+		Action: canal.UpdateAction,
+		Rows: [][]any{
+			{int64(1), "paid", []byte("gift")}, {int64(1), "paid", []byte("gift")},
+			{int64(2), "paid", nil}, {int64(2), "shipped", nil},
+		},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    []string
+	}{
+		{name: "dropped", enabled: true, want: []string{"2"}},
+		{name: "kept", want: []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithDropNoopUpdates(tt.enabled))
+			if err := m.OnRow(update); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.stream) != len(tt.want) {
+				t.Fatalf("%d updates sent, want %d", len(m.stream), len(tt.want))
+			}
+			for _, want := range tt.want {
+				if msg := <-m.stream; fmt.Sprint(msg.Data["id"]) != want {
+					t.Errorf("sent update of row %v, want %s", msg.Data["id"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSignificantColumns(t *testing.T) {
+	orders := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "qty"}, {Name: "note"}}, PKColumns: []int{0}}
+	archived := &schema.Table{Schema: "archive", Name: "orders", Columns: orders.Columns, PKColumns: []int{0}}
+
+	tests := []struct {
+		name        string
+		significant map[string][]string
+		table       *schema.Table
+		after       []any
+		wantSent    bool
+	}{
+		{name: "other column changed", significant: map[string][]string{"orders": {"qty"}}, table: orders, after: []any{int64(1), int64(2), "gift"}},
+		{name: "significant column changed", significant: map[string][]string{"orders": {"qty"}}, table: orders, after: []any{int64(1), int64(5), "note"}, wantSent: true},
+		{name: "qualified table", significant: map[string][]string{"shop.orders": {"qty"}}, table: orders, after: []any{int64(1), int64(2), "gift"}},
+		{name: "unknown column", significant: map[string][]string{"orders": {"quantity"}}, table: orders, after: []any{int64(1), int64(2), "gift"}, wantSent: true},
+		{name: "same name in another schema", significant: map[string][]string{"orders": {"qty"}}, table: archived, after: []any{int64(1), int64(2), "gift"}, wantSent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithTables("orders", "archive.orders"), WithSignificantColumns(tt.significant))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  tt.table,
+				Action: canal.UpdateAction,
+				Rows:   [][]any{{int64(1), int64(2), "note"}, tt.after},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 500},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent := len(m.stream) == 1; sent != tt.wantSent {
+				t.Errorf("update sent = %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
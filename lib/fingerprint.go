@@ -0,0 +1,47 @@
+package mongodb_stream_benthos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+type schemaFingerprint struct {
+	table       *schema.Table
+	fingerprint string
+}
+
+// schemaFingerprintFor returns a hash of the names and types of a table's
+// columns in order, and with include_nullability whether they accept NULL.
+// Fingerprints are cached per table and recomputed when its layout is
+// refreshed.
+func (m *MysqlStreamInput) schemaFingerprintFor(table *schema.Table) (string, error) {
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.fingerprintCache.get(key); ok && cached.table == table {
+		return cached.fingerprint, nil
+	}
+
+	var nullable map[string]bool
+	if m.includeNullability {
+		var err error
+		if nullable, err = m.nullabilityFor(table); err != nil {
+			return "", err
+		}
+	}
+	h := sha256.New()
+	for _, col := range table.Columns {
+		h.Write([]byte(col.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(col.RawType))
+		h.Write([]byte{0})
+		if nullable != nil && !nullable[col.Name] {
+			h.Write([]byte("NOT NULL"))
+			h.Write([]byte{0})
+		}
+	}
+	fingerprint := hex.EncodeToString(h.Sum(nil)[:8])
+
+	m.fingerprintCache.put(key, schemaFingerprint{table: table, fingerprint: fingerprint})
+	return fingerprint, nil
+}
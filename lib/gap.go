@@ -0,0 +1,87 @@
+package mongodb_stream_benthos
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	gapEvent = "gap"
+
+	gapReasonPositionDiscarded = "position_discarded"
+	gapReasonFilesSkipped      = "binlog_files_skipped"
+)
+
+// checkStartGap emits a gap event when streaming starts from a position that
+// does not continue the previous one: a persisted position discarded by
+// on_server_mismatch reset. It also records where the run must continue from
+// so that OnRotate can tell whether the server skipped part of the binlog.
+func (m *MysqlStreamInput) checkStartGap(coords mysql.Position, gtid bool) error {
+	m.gapExpected = mysql.Position{}
+	if !gtid {
+		m.gapExpected = coords
+	}
+	if m.gapFrom.Name == "" {
+		return nil
+	}
+	from := m.gapFrom
+	m.gapFrom = mysql.Position{}
+	return m.emitGap(from, coords, gapReasonPositionDiscarded)
+}
+
+// checkRotateGap emits a gap event when the binlog moves on to a file that
+// does not follow the one streamed from: the first rotate of a run must stay
+// in the file the run started in and later ones may only move to the next
+// file, as the changes of any file in between were never read.
+func (m *MysqlStreamInput) checkRotateGap(next mysql.Position) error {
+	from, maxStep := m.gapExpected, uint64(0)
+	m.gapExpected = mysql.Position{}
+	if from.Name == "" {
+		from, maxStep = m.syncedPosition, 1
+	}
+	if from.Name == "" || from.Name == next.Name {
+		return nil
+	}
+	fromIndex, ok := binlogIndex(from.Name)
+	if !ok {
+		return nil
+	}
+	nextIndex, ok := binlogIndex(next.Name)
+	if !ok || nextIndex <= fromIndex+maxStep {
+		return nil
+	}
+	return m.emitGap(from, next, gapReasonFilesSkipped)
+}
+
+// emitGap reports binlog events between from and to that were never read,
+// changes of which may be missing downstream until the tables are snapshotted
+// again.
+func (m *MysqlStreamInput) emitGap(from, to mysql.Position, reason string) error {
+	m.logger.Warnf("Binlog events between %s:%d and %s:%d were not read (%s), changes in between may be missing", from.Name, from.Pos, to.Name, to.Pos, reason)
+	m.metrics.binlogGaps.Incr(1)
+	return m.send(StreamMessage{
+		Event: gapEvent,
+		Data: map[string]any{
+			"from_file": from.Name,
+			"from_pos":  from.Pos,
+			"to_file":   to.Name,
+			"to_pos":    to.Pos,
+			"reason":    reason,
+			"timestamp": time.Now().Unix(),
+		},
+	})
+}
+
+// binlogIndex returns the sequence number in the extension of a binlog file
+// name, such as 12 for mysql-bin.000012.
+func binlogIndex(name string) (uint64, bool) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return 0, false
+	}
+	index, err := strconv.ParseUint(name[i+1:], 10, 64)
+	return index, err == nil
+}
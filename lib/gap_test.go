@@ -0,0 +1,84 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestGapDetection(t *testing.T) {
+	file := func(name string, pos uint32) mysql.Position {
+		return mysql.Position{Name: name, Pos: pos}
+	}
+
+	tests := []struct {
+		name string
+		// discarded is the persisted position on_server_mismatch reset
+		// discarded, if any.
+		discarded mysql.Position
+		start     mysql.Position
+		gtid      bool
+		// rotate is the first rotate of the run.
+		rotate     mysql.Position
+		wantReason string
+	}{
+		{name: "start in the same file", start: file("mysql-bin.000003", 400), rotate: file("mysql-bin.000003", 4)},
+		{name: "start file purged", start: file("mysql-bin.000003", 400), rotate: file("mysql-bin.000005", 4), wantReason: gapReasonFilesSkipped},
+		{name: "start by gtid", start: file("mysql-bin.000003", 400), gtid: true, rotate: file("mysql-bin.000005", 4)},
+		{name: "position discarded", discarded: file("mysql-bin.000009", 100), start: file("mysql-bin.000003", 400), rotate: file("mysql-bin.000003", 4), wantReason: gapReasonPositionDiscarded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t)
+			m.gapFrom = tt.discarded
+			if err := m.checkStartGap(tt.start, tt.gtid); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.checkRotateGap(tt.rotate); err != nil {
+				t.Fatal(err)
+			}
+			assertGap(t, m, tt.wantReason)
+		})
+	}
+
+	rotateTests := []struct {
+		name       string
+		synced     mysql.Position
+		rotate     mysql.Position
+		wantReason string
+	}{
+		{name: "next file", synced: file("mysql-bin.000003", 900), rotate: file("mysql-bin.000004", 4)},
+		{name: "file skipped", synced: file("mysql-bin.000003", 900), rotate: file("mysql-bin.000005", 4), wantReason: gapReasonFilesSkipped},
+		{name: "unnumbered file", synced: file("mysql-bin", 900), rotate: file("mysql-bin.000005", 4)},
+	}
+	for _, tt := range rotateTests {
+		t.Run("later rotate to "+tt.name, func(t *testing.T) {
+			m := newTestInput(t)
+			// The first rotate of the run was checked already.
+			m.syncedPosition = tt.synced
+			if err := m.checkRotateGap(tt.rotate); err != nil {
+				t.Fatal(err)
+			}
+			assertGap(t, m, tt.wantReason)
+		})
+	}
+}
+
+// assertGap checks that m sent a gap event for reason, or none when reason is
+// empty.
+func assertGap(t *testing.T, m *MysqlStreamInput, reason string) {
+	t.Helper()
+	if reason == "" {
+		if len(m.stream) != 0 {
+			t.Fatalf("sent %q, want no gap", (<-m.stream).Event)
+		}
+		return
+	}
+	if len(m.stream) != 1 {
+		t.Fatalf("sent %d messages, want a gap", len(m.stream))
+	}
+	msg := <-m.stream
+	if msg.Event != gapEvent || msg.Data["reason"] != reason {
+		t.Errorf("sent %s %v, want a gap for %s", msg.Event, msg.Data, reason)
+	}
+}
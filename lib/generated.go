@@ -0,0 +1,52 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// omittedColumn stands in for the value of a virtual generated column that
+// the server left out of a row image.
+type omittedColumn struct{}
+
+// alignGeneratedColumns restores the column alignment of row images that
+// omit virtual generated columns, which some servers and configurations do
+// not write to the binlog. Without this every column after a virtual one
+// would be paired with the value of its neighbour.
+func alignGeneratedColumns(e *canal.RowsEvent) {
+	virtual := 0
+	for _, col := range e.Table.Columns {
+		if col.IsVirtual {
+			virtual++
+		}
+	}
+	if virtual == 0 {
+		return
+	}
+
+	for i, row := range e.Rows {
+		if len(row) != len(e.Table.Columns)-virtual {
+			continue
+		}
+		aligned := make([]any, 0, len(e.Table.Columns))
+		for _, col := range e.Table.Columns {
+			if col.IsVirtual {
+				aligned = append(aligned, omittedColumn{})
+				continue
+			}
+			aligned = append(aligned, row[0])
+			row = row[1:]
+		}
+		e.Rows[i] = aligned
+	}
+}
+
+// skipColumn reports whether a column is left out of emitted messages, either
+// because it is generated and include_generated_columns is disabled or
+// because its value is not in the row image.
+func (m *MysqlStreamInput) skipColumn(col schema.TableColumn, value any) bool {
+	if _, ok := value.(omittedColumn); ok {
+		return true
+	}
+	return !m.includeGeneratedColumns && (col.IsVirtual || col.IsStored)
+}
@@ -0,0 +1,67 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestGeneratedColumns(t *testing.T) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "lines",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "qty", Type: schema.TYPE_NUMBER},
+			{Name: "total", Type: schema.TYPE_NUMBER, IsStored: true},
+			{Name: "label", Type: schema.TYPE_STRING, IsVirtual: true},
+			{Name: "note", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+
+	tests := []struct {
+		name    string
+		include bool
+		row     []any
+		want    map[string]any
+	}{
+		{
+			name: "virtual omitted from the image", include: true,
+			row:  []any{int64(1), int64(2), int64(20), "gift"},
+			want: map[string]any{"id": int64(1), "qty": int64(2), "total": int64(20), "note": "gift"},
+		},
+		{
+			name: "virtual in the image", include: true,
+			row:  []any{int64(1), int64(2), int64(20), "2 x widget", "gift"},
+			want: map[string]any{"id": int64(1), "qty": int64(2), "total": int64(20), "label": "2 x widget", "note": "gift"},
+		},
+		{
+			name: "excluded",
+			row:  []any{int64(1), int64(2), int64(20), "gift"},
+			want: map[string]any{"id": int64(1), "qty": int64(2), "note": "gift"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithGeneratedColumns(tt.include))
+			m.binlogFile = "mysql-bin.000001"
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{tt.row},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := <-m.stream
+			if !reflect.DeepEqual(msg.Data, tt.want) {
+				t.Errorf("data = %v, want %v", msg.Data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// EventHandler is a canal.EventHandler that converts the binlog events of a
+// canal into StreamMessages, the way the input does, for Go programs that run
+// a canal of their own instead of a Benthos pipeline. The input streams
+// through one as well.
+//
+// The handler only converts events: snapshots, persisted positions,
+// reconnects and the monitors started on connect remain features of the
+// input. Messages are delivered on Messages, which must be drained for the
+// handler to make progress under the default on_buffer_full policy of block.
+// Events are handled under a lock of the input, so the handler may be called
+// from any goroutine, and a panic while handling one is returned as an error.
+type EventHandler struct {
+	m *MysqlStreamInput
+}
+
+var _ canal.EventHandler = EventHandler{}
+
+// NewEventHandler creates a handler for the events of c, configured by the
+// same options as the input. WithAddr is still required and should name the
+// server c reads from, which messages report as their source, but the
+// handler never connects by itself.
+func NewEventHandler(c *canal.Canal, opts ...Option) (EventHandler, error) {
+	m, err := NewMysqlStreamInput(opts...)
+	if err != nil {
+		return EventHandler{}, err
+	}
+	m.canal = c
+	m.resolveConfiguredSchemas(c)
+	return EventHandler{m: m}, nil
+}
+
+// Messages returns the channel the messages converted from events are sent
+// on. Messages are not acknowledged, so they carry no resume position of
+// their own.
+func (h EventHandler) Messages() <-chan StreamMessage {
+	return h.m.stream
+}
+
+func (h EventHandler) OnRotate(header *replication.EventHeader, rotateEvent *replication.RotateEvent) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("rotate", header, &err)
+	return h.m.OnRotate(header, rotateEvent)
+}
+
+func (h EventHandler) OnTableChanged(header *replication.EventHeader, schema string, table string) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("table changed", header, &err)
+	return h.m.OnTableChanged(header, schema, table)
+}
+
+func (h EventHandler) OnDDL(header *replication.EventHeader, nextPos mysql.Position, queryEvent *replication.QueryEvent) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("DDL", header, &err)
+	return h.m.OnDDL(header, nextPos, queryEvent)
+}
+
+func (h EventHandler) OnRow(e *canal.RowsEvent) error {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	return h.m.OnRow(e)
+}
+
+func (h EventHandler) OnXID(header *replication.EventHeader, nextPos mysql.Position) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("XID", header, &err)
+	return h.m.OnXID(header, nextPos)
+}
+
+func (h EventHandler) OnGTID(header *replication.EventHeader, gtidEvent mysql.BinlogGTIDEvent) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("GTID", header, &err)
+	return h.m.OnGTID(header, gtidEvent)
+}
+
+func (h EventHandler) OnPosSynced(header *replication.EventHeader, pos mysql.Position, set mysql.GTIDSet, force bool) (err error) {
+	// Canal syncs its position without an event as it closes, which may
+	// happen while an event handled under the lock waits for buffer room.
+	if header == nil {
+		return nil
+	}
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("position synced", header, &err)
+	return h.m.OnPosSynced(header, pos, set, force)
+}
+
+func (h EventHandler) OnRowsQueryEvent(e *replication.RowsQueryEvent) (err error) {
+	h.m.eventMu.Lock()
+	defer h.m.eventMu.Unlock()
+	defer h.m.recoverEvent("rows query", nil, &err)
+	return h.m.OnRowsQueryEvent(e)
+}
+
+func (h EventHandler) String() string {
+	return "mysql_stream"
+}
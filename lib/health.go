@@ -0,0 +1,199 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const healthEvent = "health"
+
+// Health describes the replication state of the input, as returned by
+// MysqlStreamInput.Health and carried by health events.
+type Health struct {
+	// Connected reports whether the binlog is currently being streamed.
+	Connected bool
+	// CatchingUp reports whether the stream is behind the end of the binlog
+	// as of the last position check, and false once it has reached it.
+	CatchingUp bool
+	// FilesBehind is the number of binlog files written after the one being
+	// read as of the last position check.
+	FilesBehind int64
+	// Ready reports whether the stream is live: connected, past the snapshot
+	// and within ready_max_files_behind of the end of the binlog.
+	Ready bool
+	// Position is the binlog position of the last handled transaction.
+	Position mysql.Position
+	// ReconnectAttempts counts the failed reconnects since a stream last
+	// made progress.
+	ReconnectAttempts int
+	// LastError is the error that last stopped the stream or failed a
+	// reconnect, and LastErrorTime when it happened.
+	LastError     string
+	LastErrorTime time.Time
+}
+
+type healthState struct {
+	mu     sync.Mutex
+	health Health
+	canal  *canal.Canal
+
+	// lagChecked is set once a position check has run since the stream last
+	// connected, and caughtUp once the caught_up event has been emitted.
+	lagChecked bool
+	caughtUp   bool
+
+	// synced is the position of the last handled transaction, see
+	// trackSyncedPosition.
+	synced mysql.Position
+}
+
+// Health returns the current replication state of the input.
+func (m *MysqlStreamInput) Health() Health {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+
+	health := m.health.health
+	if m.health.canal != nil {
+		health.Position = m.health.synced
+	}
+	return health
+}
+
+// updateHealth applies fn to the replication state and, when emit is set,
+// emits a health event if emit_health_events is enabled and the state
+// changed. Events are only emitted from goroutines that never block Read, as
+// emitting waits for room in the buffer, and with the event lock held.
+func (m *MysqlStreamInput) updateHealth(emit bool, fn func(h *Health)) {
+	m.health.mu.Lock()
+	before := m.health.health
+	fn(&m.health.health)
+	m.health.health.Ready = m.isReady(m.health.health)
+	after := m.health.health
+	c := m.health.canal
+	m.health.mu.Unlock()
+
+	if after.Ready != before.Ready {
+		var ready int64
+		if after.Ready {
+			ready = 1
+		}
+		m.metrics.ready.Set(ready)
+	}
+	if !emit || !m.emitHealthEvents || before == after {
+		return
+	}
+	if c != nil {
+		after.Position = m.handledPosition()
+	}
+	m.emitHealth(after)
+}
+
+// recordConnected marks the stream as connected through c.
+func (m *MysqlStreamInput) recordConnected(c *canal.Canal) {
+	m.health.mu.Lock()
+	m.health.canal = c
+	m.health.mu.Unlock()
+
+	m.updateHealth(true, func(h *Health) {
+		h.Connected = true
+		h.ReconnectAttempts = m.reconnectAttempts
+		// The lag found before the stream stopped is stale.
+		m.health.lagChecked = false
+	})
+}
+
+// isReady reports whether the stream is live per ready_max_files_behind. The
+// stream only connects once the snapshot completes, so a connected stream is
+// past it. It is called with the health lock held.
+func (m *MysqlStreamInput) isReady(h Health) bool {
+	if !h.Connected {
+		return false
+	}
+	switch {
+	case m.health.lagChecked:
+		return h.FilesBehind <= int64(m.readyMaxFilesBehind)
+	case m.waitUntilCaughtUp:
+		return m.health.caughtUp
+	}
+	return m.positionCheckInterval <= 0
+}
+
+// recordStreamError marks the stream as stopped by err. It is called once the
+// stream stopped, without the event lock.
+func (m *MysqlStreamInput) recordStreamError(err error) {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+
+	m.health.mu.Lock()
+	if m.health.canal != nil {
+		m.health.health.Position = m.health.synced
+	}
+	m.health.canal = nil
+	m.health.mu.Unlock()
+
+	m.updateHealth(true, func(h *Health) {
+		h.Connected = false
+		h.LastError = err.Error()
+		h.LastErrorTime = time.Now()
+	})
+}
+
+// recordReconnectFailure records a failed reconnect without emitting an
+// event, as Connect must not wait for room in the buffer.
+func (m *MysqlStreamInput) recordReconnectFailure(err error, attempts int) {
+	m.updateHealth(false, func(h *Health) {
+		h.ReconnectAttempts = attempts
+		h.LastError = err.Error()
+		h.LastErrorTime = time.Now()
+	})
+}
+
+// recordLag records how far behind the end of the binlog the stream is. It is
+// called by the position monitor, without the event lock.
+func (m *MysqlStreamInput) recordLag(filesBehind int64, catchingUp bool) {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+
+	m.updateHealth(true, func(h *Health) {
+		h.FilesBehind = filesBehind
+		h.CatchingUp = catchingUp
+		m.health.lagChecked = true
+	})
+}
+
+// recordCaughtUp records that the caught_up event has been emitted.
+func (m *MysqlStreamInput) recordCaughtUp() {
+	m.updateHealth(true, func(*Health) {
+		m.health.caughtUp = true
+	})
+}
+
+// emitHealth pushes the replication state through the stream under the
+// on_buffer_full policy. It gives up if the input is closed so that a
+// shutdown never blocks on a full buffer.
+func (m *MysqlStreamInput) emitHealth(health Health) {
+	data := map[string]any{
+		"connected":          health.Connected,
+		"catching_up":        health.CatchingUp,
+		"ready":              health.Ready,
+		"files_behind":       health.FilesBehind,
+		"binlog_file":        health.Position.Name,
+		"binlog_pos":         health.Position.Pos,
+		"reconnect_attempts": health.ReconnectAttempts,
+		"timestamp":          time.Now().Unix(),
+	}
+	if health.LastError != "" {
+		data["last_error"] = health.LastError
+		data["last_error_time"] = health.LastErrorTime.Unix()
+	}
+
+	err := m.send(StreamMessage{Event: healthEvent, Data: data})
+	if err != nil && !errors.Is(err, service.ErrEndOfInput) {
+		m.logger.Warnf("Failed to send the health event: %v", err)
+	}
+}
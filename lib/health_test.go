@@ -0,0 +1,29 @@
+package mongodb_stream_benthos
+
+import "testing"
+
+func TestHealthEventsBufferFull(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{policy: bufferFullDropOldest, want: healthEvent},
+		// The event is dropped rather than failing the position monitor.
+		{policy: bufferFullError, want: "insert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			m := newTestInput(t, WithHealthEvents(true), WithBufferSize(1), WithOnBufferFull(tt.policy))
+			m.stream <- StreamMessage{Event: "insert"}
+
+			m.recordLag(2, true)
+			msg := <-m.stream
+			if msg.Event != tt.want {
+				t.Fatalf("buffered %s event, want %s", msg.Event, tt.want)
+			}
+			if msg.Event == healthEvent && msg.sourceHost != "127.0.0.1:3306" {
+				t.Errorf("source host = %q, want 127.0.0.1:3306", msg.sourceHost)
+			}
+		})
+	}
+}
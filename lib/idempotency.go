@@ -0,0 +1,112 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// idempotencyKey returns a deterministic key for the row at index i of a rows
+// event, in the form
+//
+//	<origin>|<schema>.<table>|<primary key>|<op>
+//
+// The origin is gtid:<gtid>:<event>.<row> when the server logs GTIDs, where
+// event is the index of the rows event within the transaction and row the
+// index of the row change within the rows event, <binlog file>:<end position>
+// of the rows event otherwise, or <binlog file>:<transaction start>/<event>.<row>
+// for compressed transactions, whose events have no position. Both indexes
+// count every row change read, whether or not it is emitted, so that keys do
+// not depend on which changes filters drop. Snapshot rows use
+// snapshot:<binlog file>:<pos> with the position the snapshot was taken at.
+// The primary key is the comma separated primary key values, or #<row index>
+// for tables without one.
+func (m *MysqlStreamInput) idempotencyKey(e *canal.RowsEvent, row []any, index int) string {
+	var origin string
+	switch {
+	case e.Header == nil:
+		origin = fmt.Sprintf("snapshot:%s:%d", m.snapshotPosition.Name, m.snapshotPosition.Pos)
+	case m.gtid != "":
+		origin = fmt.Sprintf("gtid:%s:%d.%d", m.gtid, m.txRowsEvents, index)
+	case isCompressed(e.Header):
+		origin = fmt.Sprintf("%s:%d/%d.%d", m.binlogFile, m.syncedPosition.Pos, m.txRowsEvents, index)
+	default:
+		origin = fmt.Sprintf("%s:%d", m.binlogFile, e.Header.LogPos)
+	}
+
+	var pk string
+	if len(e.Table.PKColumns) == 0 {
+		pk = "#" + strconv.Itoa(index)
+	} else {
+		values := make([]string, 0, len(e.Table.PKColumns))
+		for _, i := range e.Table.PKColumns {
+			if i < len(row) {
+				values = append(values, keyValue(row[i]))
+			}
+		}
+		pk = strings.Join(values, ",")
+	}
+
+	op := actionOp(e.Action)
+	if e.Header == nil {
+		op = opRead
+	}
+	return strings.Join([]string{origin, e.Table.Schema + "." + e.Table.Name, pk, op}, "|")
+}
+
+func keyValue(v any) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func (m *MysqlStreamInput) OnGTID(header *replication.EventHeader, gtidEvent mysql.BinlogGTIDEvent) error {
+	// A GTID inside a transaction starts it again after a reconnect.
+	m.restartTransaction()
+	m.beginTransaction()
+	m.trackTransactionStart(header, gtidEvent)
+	m.gtid, m.txRowsEvents = "", 0
+	if header != nil && header.EventType == replication.ANONYMOUS_GTID_EVENT {
+		// Servers that do not log GTIDs still write an anonymous GTID event
+		// for every transaction, which all carry the same GTID.
+		return nil
+	}
+	next, err := gtidEvent.GTIDNext()
+	if err != nil {
+		return err
+	}
+	m.gtid = next.String()
+
+	// MySQL 8.0.1 and later record when the transaction committed.
+	m.commitTime = time.Time{}
+	if e, ok := gtidEvent.(*replication.GTIDEvent); ok {
+		m.commitTime = e.ImmediateCommitTime()
+	}
+	return nil
+}
+
+// gtidSourceUUID returns the server_uuid of the server that originally
+// executed a transaction from its MySQL GTID, or an empty string for MariaDB
+// GTIDs, which carry a server_id instead.
+func gtidSourceUUID(gtid string) string {
+	uuid, _, ok := strings.Cut(gtid, ":")
+	if !ok {
+		return ""
+	}
+	return uuid
+}
+
+// txCommitTime returns the commit time of the current transaction, or the
+// time of the event when the binlog does not record it.
+func (m *MysqlStreamInput) txCommitTime(header *replication.EventHeader) time.Time {
+	if !m.commitTime.IsZero() {
+		return m.commitTime
+	}
+	return time.Unix(int64(header.Timestamp), 0)
+}
@@ -0,0 +1,103 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id"}, {Name: "sku"}, {Name: "qty"}},
+		PKColumns: []int{0, 1},
+	}
+	keyless := &schema.Table{Schema: "shop", Name: "log", Columns: []schema.TableColumn{{Name: "line"}}}
+	row := []any{int64(7), []byte("A-1"), int64(2)}
+
+	tests := []struct {
+		name     string
+		table    *schema.Table
+		action   string
+		header   *replication.EventHeader
+		gtid     string
+		rowsEvts int
+		index    int
+		want     string
+	}{
+		{
+			name: "gtid", table: table, action: canal.InsertAction,
+			header: &replication.EventHeader{LogPos: 500}, gtid: "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", rowsEvts: 2, index: 1,
+			want: "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:23:2.1|shop.orders|7,A-1|c",
+		},
+		{
+			name: "position", table: table, action: canal.UpdateAction,
+			header: &replication.EventHeader{LogPos: 500}, rowsEvts: 1,
+			want: "mysql-bin.000003:500|shop.orders|7,A-1|u",
+		},
+		{
+			name: "compressed", table: table, action: canal.DeleteAction,
+			header: &replication.EventHeader{}, rowsEvts: 3, index: 4,
+			want: "mysql-bin.000003:120/3.4|shop.orders|7,A-1|d",
+		},
+		{
+			name: "snapshot", table: table, action: canal.InsertAction,
+			want: "snapshot:mysql-bin.000001:4|shop.orders|7,A-1|r",
+		},
+		{
+			name: "no primary key", table: keyless, action: canal.InsertAction,
+			header: &replication.EventHeader{LogPos: 900}, index: 3,
+			want: "mysql-bin.000003:900|shop.log|#3|c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t)
+			m.binlogFile = "mysql-bin.000003"
+			m.syncedPosition = mysql.Position{Name: "mysql-bin.000003", Pos: 120}
+			m.snapshotPosition = mysql.Position{Name: "mysql-bin.000001", Pos: 4}
+			m.gtid, m.txRowsEvents = tt.gtid, tt.rowsEvts
+
+			e := &canal.RowsEvent{Table: tt.table, Action: tt.action, Header: tt.header}
+			got := m.idempotencyKey(e, row, tt.index)
+			if got != tt.want {
+				t.Errorf("idempotencyKey = %q, want %q", got, tt.want)
+			}
+			// Keys are derived, not counted, so computing one again for the
+			// same change gives the same key.
+			if again := m.idempotencyKey(e, row, tt.index); again != got {
+				t.Errorf("idempotencyKey again = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestOnRowCountsUnstreamedRowsEvents(t *testing.T) {
+	m := newTestInput(t, WithDatabase("crm"))
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}}
+
+	for i := 0; i < 2; i++ {
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{int64(i)}},
+			Header: &replication.EventHeader{LogPos: uint32(100 * (i + 1))},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.txRowsEvents != 2 {
+		t.Errorf("rows events counted = %d, want 2", m.txRowsEvents)
+	}
+	if err := m.OnXID(&replication.EventHeader{LogPos: 300}, mysql.Position{Name: "mysql-bin.000003", Pos: 300}); err != nil {
+		t.Fatal(err)
+	}
+	if m.txRowsEvents != 0 {
+		t.Errorf("rows events counted after commit = %d, want 0", m.txRowsEvents)
+	}
+}
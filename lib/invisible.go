@@ -0,0 +1,129 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// gipkColumn is the name of the generated invisible primary key MySQL 8.0.30
+// and later add to tables created without a primary key when
+// sql_generate_invisible_primary_key is enabled. It is always the first
+// column.
+const gipkColumn = "my_row_id"
+
+// invisibleColumnTimeout bounds the queries resolving invisible columns, which
+// are made while the stream is blocked.
+const invisibleColumnTimeout = 5 * time.Second
+
+type invisibleColumns struct {
+	table   *schema.Table
+	columns map[string]bool
+}
+
+// invisibleColumnsOf returns the INVISIBLE columns of a table, generated
+// invisible primary keys included, to be left out of emitted messages when
+// include_invisible_columns is disabled. It returns nil otherwise. Columns
+// are cached per table and resolved again when its layout changes. A table
+// whose invisible columns cannot be resolved is treated as having none.
+func (m *MysqlStreamInput) invisibleColumnsOf(table *schema.Table) map[string]bool {
+	if m.includeInvisibleColumns {
+		return nil
+	}
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.invisibleCache.get(key); ok && cached.table == table {
+		return cached.columns
+	}
+
+	columns := map[string]bool{}
+	ctx, cancel := context.WithTimeout(context.Background(), invisibleColumnTimeout)
+	defer cancel()
+	err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+		showGIPK(conn)
+		res, err := conn.Execute(`SELECT COLUMN_NAME FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND EXTRA LIKE '%INVISIBLE%'`, table.Schema, table.Name)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < res.RowNumber(); i++ {
+			name, _ := res.GetString(i, 0)
+			columns[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		m.logger.Warnf("Failed to resolve the invisible columns of %s, emitting all of its columns: %v", key, err)
+	}
+	m.invisibleCache.put(key, invisibleColumns{table: table, columns: columns})
+	return columns
+}
+
+// alignInvisiblePK restores the column alignment of rows events on tables
+// with a generated invisible primary key that the cached schema lacks, as
+// the key is left out of SHOW COLUMNS when
+// show_gipk_in_create_table_and_information_schema is disabled on the server
+// but always written to the binlog. The key is added as the first column and
+// the primary key of the schema, which is cached in its place.
+func (m *MysqlStreamInput) alignInvisiblePK(e *canal.RowsEvent) {
+	if len(e.Rows) == 0 || len(e.Rows[0]) != len(e.Table.Columns)+1 ||
+		len(e.Table.PKColumns) > 0 || e.Table.FindColumn(gipkColumn) >= 0 {
+		return
+	}
+	key := e.Table.String()
+	if checked, ok := m.gipkChecked.get(key); ok && checked == e.Table {
+		return
+	}
+	m.gipkChecked.put(key, e.Table)
+
+	var gipk *schema.TableColumn
+	ctx, cancel := context.WithTimeout(context.Background(), invisibleColumnTimeout)
+	defer cancel()
+	err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+		showGIPK(conn)
+		res, err := conn.Execute(`SELECT COLUMN_TYPE FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = ? AND ORDINAL_POSITION = 1`, e.Table.Schema, e.Table.Name, gipkColumn)
+		if err != nil || res.RowNumber() == 0 {
+			return err
+		}
+		columnType, _ := res.GetString(0, 0)
+		gipk = &schema.TableColumn{
+			Name:       gipkColumn,
+			Type:       schema.TYPE_NUMBER,
+			RawType:    columnType,
+			IsAuto:     true,
+			IsUnsigned: strings.Contains(columnType, "unsigned"),
+		}
+		return nil
+	})
+	if err != nil || gipk == nil {
+		if err != nil {
+			m.logger.Warnf("Failed to resolve the generated invisible primary key of %s: %v", key, err)
+		}
+		return
+	}
+
+	table := *e.Table
+	table.Columns = append([]schema.TableColumn{*gipk}, e.Table.Columns...)
+	table.PKColumns = []int{0}
+	table.UnsignedColumns = nil
+	for i, col := range table.Columns {
+		if col.IsUnsigned {
+			table.UnsignedColumns = append(table.UnsignedColumns, i)
+		}
+	}
+	m.canal.SetTableCache([]byte(table.Schema), []byte(table.Name), &table)
+	m.gipkChecked.put(key, &table)
+	m.logger.Infof("Added the generated invisible primary key %s to the schema of %s", gipkColumn, key)
+	e.Table = &table
+}
+
+// showGIPK makes generated invisible primary keys visible to the
+// information_schema queries of a connection. Servers before MySQL 8.0.30
+// have no such keys and reject the variable, which is ignored.
+func showGIPK(conn *client.Conn) {
+	_, _ = conn.Execute("SET SESSION show_gipk_in_create_table_and_information_schema = ON")
+}
@@ -0,0 +1,58 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestInvisibleColumns(t *testing.T) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "events",
+		Columns: []schema.TableColumn{
+			{Name: gipkColumn, Type: schema.TYPE_NUMBER, IsAuto: true},
+			{Name: "kind", Type: schema.TYPE_STRING},
+			{Name: "audit", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+
+	tests := []struct {
+		name    string
+		include bool
+		want    map[string]any
+	}{
+		{name: "left out", want: map[string]any{"kind": "click"}},
+		{name: "included", include: true, want: map[string]any{gipkColumn: uint64(1), "kind": "click", "audit": "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithInvisibleColumns(tt.include), WithStructuredMessages(true))
+			// The invisible columns as resolved from information_schema.
+			m.invisibleCache.put("shop.events", invisibleColumns{table: table, columns: map[string]bool{gipkColumn: true, "audit": true}})
+
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{uint64(1), "click", "x"}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, body := readStructured(t, m)
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+			// The generated invisible primary key still keys the change.
+			if key, _ := msg.MetaGet("idempotency_key"); key == "" || !strings.Contains(key, "|1|") {
+				t.Errorf("idempotency_key = %q, want it keyed by %s", key, gipkColumn)
+			}
+		})
+	}
+}
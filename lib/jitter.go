@@ -0,0 +1,109 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+const (
+	reconnectJitterNone         = "none"
+	reconnectJitterFull         = "full"
+	reconnectJitterDecorrelated = "decorrelated"
+)
+
+// reconnectBackoff computes randomized delays between reconnect attempts, so
+// that many inputs losing the same server do not reconnect in lockstep. With
+// full jitter each delay is drawn from zero up to the exponential backoff of
+// the attempt, and with decorrelated jitter from the base delay up to three
+// times the previous delay, both capped at max.
+type reconnectBackoff struct {
+	mode string
+	base time.Duration
+	max  time.Duration
+
+	mu      sync.Mutex
+	attempt int
+	prev    time.Duration
+}
+
+func newReconnectBackoff(mode string, base, max time.Duration) *reconnectBackoff {
+	return &reconnectBackoff{mode: mode, base: base, max: max}
+}
+
+// next returns the delay before the next attempt.
+func (b *reconnectBackoff) next() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var delay time.Duration
+	switch b.mode {
+	case reconnectJitterFull:
+		ceiling := b.base << uint(min(b.attempt, 30))
+		if ceiling <= 0 || ceiling > b.max {
+			ceiling = b.max
+		}
+		delay = time.Duration(rand.Int63n(int64(ceiling) + 1))
+	case reconnectJitterDecorrelated:
+		prev := max(b.prev, b.base)
+		delay = min(b.base+time.Duration(rand.Int63n(int64(3*prev-b.base)+1)), b.max)
+		b.prev = delay
+	}
+	b.attempt++
+	return delay
+}
+
+// reset starts the backoff over after a successful attempt.
+func (b *reconnectBackoff) reset() {
+	b.mu.Lock()
+	b.attempt, b.prev = 0, 0
+	b.mu.Unlock()
+}
+
+// wait sleeps for the next delay, returning early with the error of ctx.
+func (b *reconnectBackoff) wait(ctx context.Context) error {
+	delay := b.next()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jitterDialer delays each connection the replication client dials after a
+// failed one, on top of the fixed second it waits between retries of a broken
+// stream, and resets the backoff once a connection succeeds. The first redial
+// after the stream broke is not delayed, since the server may well be back.
+func (b *reconnectBackoff) jitterDialer(dial client.Dialer) client.Dialer {
+	var mu sync.Mutex
+	failed := false
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		redial := failed
+		mu.Unlock()
+
+		if redial {
+			if err := b.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		conn, err := dial(ctx, network, address)
+		mu.Lock()
+		failed = err != nil
+		mu.Unlock()
+		if err == nil {
+			b.reset()
+		}
+		return conn, err
+	}
+}
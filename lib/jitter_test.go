@@ -0,0 +1,54 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	const base, max = 100 * time.Millisecond, time.Second
+
+	tests := []struct {
+		mode string
+		// bounds returns the range the delay of attempt may fall in, given
+		// the previous delay.
+		bounds func(attempt int, prev time.Duration) (time.Duration, time.Duration)
+	}{
+		{
+			mode: reconnectJitterNone,
+			bounds: func(int, time.Duration) (time.Duration, time.Duration) {
+				return 0, 0
+			},
+		},
+		{
+			mode: reconnectJitterFull,
+			bounds: func(attempt int, _ time.Duration) (time.Duration, time.Duration) {
+				return 0, min(base<<attempt, max)
+			},
+		},
+		{
+			mode: reconnectJitterDecorrelated,
+			bounds: func(_ int, prev time.Duration) (time.Duration, time.Duration) {
+				return base, min(3*prev, max)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			b := newReconnectBackoff(tt.mode, base, max)
+			for run := 0; run < 50; run++ {
+				prev := base
+				for attempt := 0; attempt < 10; attempt++ {
+					delay := b.next()
+					lo, hi := tt.bounds(attempt, prev)
+					if delay < lo || delay > hi {
+						t.Fatalf("attempt %d delay = %v, want between %v and %v", attempt, delay, lo, hi)
+					}
+					prev = delay
+				}
+				// A successful attempt starts the backoff over.
+				b.reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// keyTemplate is a parsed key_templates entry, a sequence of literal text and
+// column references.
+type keyTemplate []keyTemplatePart
+
+type keyTemplatePart struct {
+	literal string
+	column  string
+}
+
+// parseKeyTemplate parses a template such as tenant-{tenant_id}/user-{id},
+// where {column} is replaced by the value of a column and {{ and }} stand for
+// literal braces.
+func parseKeyTemplate(template string) (keyTemplate, error) {
+	var t keyTemplate
+	var literal strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '{' && strings.HasPrefix(template[i:], "{{"),
+			c == '}' && strings.HasPrefix(template[i:], "}}"):
+			literal.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return nil, errors.New("unterminated {")
+			}
+			column := strings.TrimSpace(template[i+1 : i+end])
+			if column == "" {
+				return nil, errors.New("empty column reference")
+			}
+			if literal.Len() > 0 {
+				t = append(t, keyTemplatePart{literal: literal.String()})
+				literal.Reset()
+			}
+			t = append(t, keyTemplatePart{column: column})
+			i += end
+		case c == '}':
+			return nil, errors.New("unexpected }")
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		t = append(t, keyTemplatePart{literal: literal.String()})
+	}
+	if len(t) == 0 {
+		return nil, errors.New("empty template")
+	}
+	return t, nil
+}
+
+// check returns an error naming the first column of the template that table
+// does not have.
+func (t keyTemplate) check(table *schema.Table) error {
+	for _, part := range t {
+		if part.column != "" && table.FindColumn(part.column) < 0 {
+			return fmt.Errorf("key_templates: table %s has no column %s", table, part.column)
+		}
+	}
+	return nil
+}
+
+// messageKey returns the key of a row change from the key template of its
+// table, or an empty string for tables without one. Values are formatted as
+// read from MySQL, and NULL values as empty strings.
+func (m *MysqlStreamInput) messageKey(table *schema.Table, row []any) (string, error) {
+	t, ok := m.keyTemplates[tableRef{schema: table.Schema, name: table.Name}.key()]
+	if !ok {
+		return "", nil
+	}
+	var key strings.Builder
+	for _, part := range t {
+		if part.column == "" {
+			key.WriteString(part.literal)
+			continue
+		}
+		i := table.FindColumn(part.column)
+		if i < 0 || i >= len(row) {
+			return "", fmt.Errorf("key_templates: table %s has no column %s", table, part.column)
+		}
+		if row[i] != nil {
+			key.WriteString(keyValue(row[i]))
+		}
+	}
+	return key.String(), nil
+}
+
+// checkKeyTemplates verifies on connect that the columns referenced by key
+// templates exist. Tables that do not exist are left to on_missing_table.
+func (m *MysqlStreamInput) checkKeyTemplates(c *canal.Canal) error {
+	for key, t := range m.keyTemplates {
+		schemaName, name, _ := strings.Cut(key, ".")
+		table, err := c.GetTable(schemaName, name)
+		if err != nil {
+			continue
+		}
+		if err := t.check(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
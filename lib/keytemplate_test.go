@@ -0,0 +1,87 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "users",
+		Columns:   []schema.TableColumn{{Name: "tenant_id"}, {Name: "id"}, {Name: "email"}},
+		PKColumns: []int{0, 1},
+	}
+	row := []any{int64(3), int64(42), []byte("ann@example.com")}
+
+	tests := []struct {
+		name      string
+		template  string
+		row       []any
+		want      string
+		wantErr   bool
+		wantCheck bool
+	}{
+		{name: "composite", template: "tenant-{tenant_id}/user-{id}", row: row, want: "tenant-3/user-42"},
+		{name: "bytes", template: "{ email }", row: row, want: "ann@example.com"},
+		{name: "escaped braces", template: "{{{id}}}", row: row, want: "{42}"},
+		{name: "literal only", template: "users", row: row, want: "users"},
+		{name: "null value", template: "user-{email}", row: []any{int64(3), int64(42), nil}, want: "user-"},
+		{name: "unknown column", template: "user-{name}", row: row, wantCheck: true},
+		{name: "unterminated", template: "user-{id", wantErr: true},
+		{name: "unexpected close", template: "user-id}", wantErr: true},
+		{name: "empty reference", template: "user-{}", wantErr: true},
+		{name: "empty", template: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithKeyTemplates(map[string]string{"users": tt.template}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMysqlStreamInput error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := m.keyTemplates["shop.users"].check(table); (err != nil) != tt.wantCheck {
+				t.Fatalf("check error = %v, want error %v", err, tt.wantCheck)
+			}
+			got, err := m.messageKey(table, tt.row)
+			if (err != nil) != tt.wantCheck {
+				t.Fatalf("messageKey error = %v, want error %v", err, tt.wantCheck)
+			}
+			if got != tt.want {
+				t.Errorf("messageKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTemplateMetadata(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithKeyTemplates(map[string]string{"orders": "order-{id}"}))
+	orders := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	users := &schema.Table{Schema: "shop", Name: "users", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	for _, table := range []*schema.Table{orders, users} {
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{int64(7)}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, _ := readStructured(t, m)
+	if key, _ := msg.MetaGet("key"); key != "order-7" {
+		t.Errorf("key = %q, want order-7", key)
+	}
+	// Tables without a template carry no key.
+	msg, _ = readStructured(t, m)
+	if key, ok := msg.MetaGet("key"); ok {
+		t.Errorf("key = %q for a table without a template, want none", key)
+	}
+}
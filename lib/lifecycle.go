@@ -0,0 +1,206 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+const (
+	connectedEvent    = "connected"
+	disconnectedEvent = "disconnected"
+	offsetEvent       = "offset"
+)
+
+// stableStreamDuration is how long a stream that reads nothing must stay up
+// for its reconnect to count as successful, so that the reconnects of an
+// idle server do not add up towards max_reconnect_attempts.
+const stableStreamDuration = time.Minute
+
+// runBinlog streams the binlog until the connection fails or the canal is
+// closed. The first run starts from the position selected by start_position,
+// after the snapshot when one is enabled, or after start_gtid_set when it is
+// set, and later runs resume where the previous one stopped. In snapshot_only
+// mode the first run ends once the snapshot completes. With several
+// candidate servers the stream is followed by GTID when the server logs them,
+// so that it can resume on any of them.
+func (m *MysqlStreamInput) runBinlog(c *canal.Canal) error {
+	// A reconnect, which may follow a master switch that snapshots again,
+	// waits for a resnapshot running alongside the previous stream, and the
+	// event lock keeps the next one from starting until canal runs.
+	m.awaitResnapshot()
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+
+	m.loadCachedSchemas(c)
+	if err := m.checkKeyTemplates(c); err != nil {
+		return err
+	}
+	if err := m.emitMasterSwitch(); err != nil {
+		return err
+	}
+
+	coords := m.resumePosition
+	gtidSet := m.resumeGTID
+	if coords.Name == "" && gtidSet == nil && m.startGTIDSet != nil && !m.masterSwitchSnapshot {
+		gtidSet = m.startGTIDSet
+	} else if coords.Name == "" && gtidSet == nil {
+		var err error
+		if m.streamSnapshot || m.masterSwitchSnapshot {
+			coords, gtidSet, err = m.runSnapshot(c)
+		} else {
+			coords, gtidSet, err = m.initialPosition(c)
+		}
+		if err != nil {
+			return err
+		}
+		m.masterSwitchSnapshot = false
+		if m.snapshotOnly {
+			return errSnapshotOnlyDone
+		}
+	}
+
+	m.resolveConfiguredSchemas(c)
+	if err := m.checkStartGap(coords, gtidSet != nil); err != nil {
+		return err
+	}
+	if err := m.captureCaughtUpTarget(c); err != nil {
+		return err
+	}
+	m.recordConnected(c)
+	if err := m.emitLifecycle(connectedEvent, coords); err != nil {
+		return err
+	}
+	started := time.Now()
+	m.streamPanicked = false
+	m.streaming = true
+	m.eventMu.Unlock()
+	var err error
+	if gtidSet != nil {
+		err = c.StartFromGTID(gtidSet)
+	} else {
+		err = c.RunFrom(coords)
+	}
+	m.eventMu.Lock()
+	m.streaming = false
+
+	// Changes of the transaction the stream stopped in are read again from
+	// its start when it resumes.
+	m.txBuffer, m.txParts, m.txRowsEvents = nil, 0, 0
+	m.resumeAddr, m.resumeServerUUID = m.addr, m.serverUUID
+	if set := c.SyncedGTIDSet(); set != nil && set.String() != "" {
+		m.resumeGTID = set.Clone()
+	}
+	m.resumePosition = c.SyncedPosition()
+	if m.resumePosition.Pos == 0 || m.inTransaction {
+		// Canal records the zero position of the events within a compressed
+		// transaction, and the position a reconnect resumed at inside a
+		// transaction, resume after the last transaction with a known one.
+		m.resumePosition = m.syncedPosition
+	}
+	m.inTransaction = false
+
+	// A stream that reads no further than where it started, for example
+	// because the server accepts the connection but can no longer serve the
+	// position, counts as a failed reconnect. It is read by Read after the
+	// error of the stream is received.
+	if gtidSet != nil {
+		m.streamProgressed = m.resumeGTID != nil && m.resumeGTID.String() != gtidSet.String()
+	} else {
+		m.streamProgressed = m.resumePosition.Compare(coords) > 0
+	}
+	m.streamProgressed = m.streamProgressed || time.Since(started) >= stableStreamDuration
+
+	if lifecycleErr := m.emitLifecycle(disconnectedEvent, m.resumePosition); err == nil {
+		err = lifecycleErr
+	}
+	return err
+}
+
+// emitLifecycle pushes an in-band connection state change through the stream
+// when emit_lifecycle_events is enabled, under the on_buffer_full policy. It
+// gives up if the input is closed so that a shutdown never blocks on a full
+// buffer. It must be called with the event lock held.
+func (m *MysqlStreamInput) emitLifecycle(event string, pos mysql.Position) error {
+	if !m.emitLifecycleEvents {
+		return nil
+	}
+
+	err := m.send(StreamMessage{
+		Event: event,
+		Data: map[string]any{
+			"binlog_file": pos.Name,
+			"binlog_pos":  pos.Pos,
+			"timestamp":   time.Now().Unix(),
+		},
+	})
+	if errors.Is(err, service.ErrEndOfInput) {
+		return nil
+	}
+	return err
+}
+
+// emitOffsets periodically pushes the synced binlog position through the
+// stream under the on_buffer_full policy. The position only advances once a transaction has been handled and
+// its changes sent, so every change before the position is sent ahead of the
+// marker, while changes of the transaction being read may be sent ahead of it
+// too.
+func (m *MysqlStreamInput) emitOffsets(ctx context.Context, c *canal.Canal) {
+	ticker := time.NewTicker(m.offsetMarkerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pos := m.handledPosition()
+		if pos.Name == "" || pos.Pos == 0 {
+			continue
+		}
+		data := map[string]any{
+			"binlog_file": pos.Name,
+			"binlog_pos":  pos.Pos,
+			"timestamp":   time.Now().Unix(),
+		}
+		if set := c.SyncedGTIDSet(); set != nil {
+			data["gtid_set"] = set.String()
+		}
+
+		err := m.sendUnlocked(StreamMessage{Event: offsetEvent, Data: data})
+		if errors.Is(err, service.ErrEndOfInput) {
+			return
+		}
+		if err != nil {
+			m.logger.Warnf("Failed to send the offset marker: %v", err)
+		}
+	}
+}
+
+// OnRotate tracks the binlog file the stream is reading, as row events only
+// carry a position within the current file.
+func (m *MysqlStreamInput) OnRotate(header *replication.EventHeader, rotateEvent *replication.RotateEvent) error {
+	next := mysql.Position{Name: string(rotateEvent.NextLogName), Pos: uint32(rotateEvent.Position)}
+	if err := m.checkRotateGap(next); err != nil {
+		return err
+	}
+	if next.Name != m.binlogFile {
+		m.logger.Debugf("Binlog rotated to %s", next.Name)
+		m.binlogFile = next.Name
+	}
+	if m.inTransaction {
+		// Transactions never span binlog files, this is the replication
+		// client resuming inside the transaction after a reconnect.
+		m.logger.Debugf("Resumed inside a transaction at %s:%d, keeping the synced position at its start", next.Name, next.Pos)
+		return nil
+	}
+	m.trackSyncedPosition(next)
+	return nil
+}
@@ -0,0 +1,109 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestRotatePersistsNewBinlogFile(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithPositionCache("cache", "position"), WithPositionFlush(0, 1))
+	m.binlogFile = "mysql-bin.000001"
+	m.syncedPosition = mysql.Position{Name: "mysql-bin.000001", Pos: 4}
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	insert := func(pos uint32, id int64) {
+		t.Helper()
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{id}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(pos mysql.Position) {
+		t.Helper()
+		header := &replication.EventHeader{Timestamp: 1, LogPos: pos.Pos, EventType: replication.XID_EVENT}
+		if err := m.OnXID(header, pos); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.OnPosSynced(header, pos, nil, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliver := func() string {
+		t.Helper()
+		msg, ack, err := m.Read(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ack(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		file, _ := msg.MetaGet("binlog_file")
+		return file
+	}
+
+	insert(200, 1)
+	commit(mysql.Position{Name: "mysql-bin.000001", Pos: 300})
+	err := m.OnRotate(&replication.EventHeader{Timestamp: 1, EventType: replication.ROTATE_EVENT},
+		&replication.RotateEvent{NextLogName: []byte("mysql-bin.000002"), Position: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(150, 2)
+	commit(mysql.Position{Name: "mysql-bin.000002", Pos: 250})
+	insert(350, 3)
+
+	if got := deliver(); got != "mysql-bin.000001" {
+		t.Errorf("first change binlog_file = %s, want mysql-bin.000001", got)
+	}
+	if stored, _ := cache.storedPosition(t, "position"); stored.BinlogFile != "mysql-bin.000001" {
+		t.Errorf("stored position after the first change = %+v, want mysql-bin.000001", stored)
+	}
+
+	if got := deliver(); got != "mysql-bin.000002" {
+		t.Errorf("second change binlog_file = %s, want mysql-bin.000002", got)
+	}
+	// The third change resumes after the transaction of the second, in the
+	// file the binlog rotated to.
+	deliver()
+	stored, ok := cache.storedPosition(t, "position")
+	if !ok || stored.BinlogFile != "mysql-bin.000002" || stored.BinlogPos != 250 {
+		t.Errorf("stored position after the rotation = %+v, want mysql-bin.000002:250", stored)
+	}
+}
+
+func TestOffsetMarkerPrepared(t *testing.T) {
+	m := newTestInput(t, WithOffsetMarkers(true, time.Millisecond))
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 300}
+	m.syncedPosition = pos
+	m.trackSyncedPosition(pos)
+
+	// The canal has not connected, so it has no synced GTID set.
+	c := newTestCanal(t)
+	master := reflect.ValueOf(c).Elem().FieldByName("master")
+	reflect.NewAt(master.Type(), unsafe.Pointer(master.UnsafeAddr())).Elem().Set(reflect.New(master.Type().Elem()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.emitOffsets(ctx, c)
+
+	// The marker goes through the buffer like a change, resuming from the
+	// synced position once acked.
+	msg := <-m.stream
+	if msg.Event != offsetEvent || msg.resume != pos || msg.sourceHost != "127.0.0.1:3306" {
+		t.Errorf("marker %s resumes from %v from %q, want offset from %v from 127.0.0.1:3306", msg.Event, msg.resume, msg.sourceHost, pos)
+	}
+}
@@ -0,0 +1,105 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	masterSwitchEvent = "master_switch"
+
+	masterSwitchError      = "error"
+	masterSwitchResnapshot = "resnapshot"
+)
+
+// masterSwitch records a reconnect to a server other than the one the stream
+// stopped on, reported by a master_switch event once streaming resumes.
+type masterSwitch struct {
+	fromUUID, fromAddr string
+	toUUID, toAddr     string
+	position           mysql.Position
+	resumedBy          string
+}
+
+// checkMasterSwitch detects a reconnect to a server other than the one the
+// stream stopped on, such as after a failover, by server_uuid or by address
+// for servers without one. Binlog file positions only apply to the server
+// that wrote them, so the stream continues by GTID when it has a GTID set to
+// resume from, and otherwise fails or takes a new snapshot per
+// on_master_switch.
+func (m *MysqlStreamInput) checkMasterSwitch() error {
+	if m.resumePosition.Name == "" || m.resumeAddr == "" {
+		return nil
+	}
+	switched := m.resumeAddr != m.addr
+	if m.resumeServerUUID != "" && m.serverUUID != "" {
+		switched = m.resumeServerUUID != m.serverUUID
+	}
+	if !switched {
+		return nil
+	}
+
+	from := m.resumeAddr
+	if m.resumeServerUUID != "" {
+		from = m.resumeServerUUID
+	}
+	to := m.addr
+	if m.serverUUID != "" {
+		to = m.serverUUID
+	}
+	if m.positions != nil {
+		// Positions of the new server do not compare to those of the old.
+		m.positions.forgetFlushed()
+	}
+	m.masterSwitch = &masterSwitch{
+		fromUUID: m.resumeServerUUID,
+		fromAddr: m.resumeAddr,
+		toUUID:   m.serverUUID,
+		toAddr:   m.addr,
+		position: m.resumePosition,
+	}
+	switch {
+	case m.resumeGTID != nil:
+		m.logger.Warnf("Connected to server %s instead of %s, resuming by GTID", to, from)
+		m.masterSwitch.resumedBy = "gtid"
+	case m.onMasterSwitch == masterSwitchResnapshot:
+		m.logger.Warnf("Connected to server %s instead of %s, discarding position %s:%d and taking a new snapshot", to, from, m.resumePosition.Name, m.resumePosition.Pos)
+		m.masterSwitch.resumedBy = "snapshot"
+		// The position synced on the previous server is no longer one to
+		// resume from, should the snapshot fail.
+		m.resumePosition, m.syncedPosition = mysql.Position{}, mysql.Position{}
+		m.health.mu.Lock()
+		m.health.synced = mysql.Position{}
+		m.health.mu.Unlock()
+		m.masterSwitchSnapshot = true
+	default:
+		m.masterSwitch = nil
+		return fmt.Errorf("cannot resume position %s:%d of server %s on server %s without GTIDs", m.resumePosition.Name, m.resumePosition.Pos, from, to)
+	}
+	return nil
+}
+
+// emitMasterSwitch reports a master switch detected on connect before the
+// stream resumes, ahead of the rows of the new snapshot when one is taken.
+func (m *MysqlStreamInput) emitMasterSwitch() error {
+	s := m.masterSwitch
+	if s == nil {
+		return nil
+	}
+	m.masterSwitch = nil
+	return m.send(StreamMessage{
+		Event: masterSwitchEvent,
+		Data: map[string]any{
+			"from_server_uuid": s.fromUUID,
+			"from_addr":        s.fromAddr,
+			"from_file":        s.position.Name,
+			"from_pos":         s.position.Pos,
+			"to_server_uuid":   s.toUUID,
+			"to_addr":          s.toAddr,
+			"resumed_by":       s.resumedBy,
+			"timestamp":        time.Now().Unix(),
+		},
+	})
+}
@@ -0,0 +1,82 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestCheckMasterSwitch(t *testing.T) {
+	const (
+		uuidA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+		uuidB = "5a2c8d5e-71ca-11e1-9e33-c80aa9429562"
+	)
+	gtidSet, err := mysql.ParseMysqlGTIDSet(uuidA + ":1-20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		policy        string
+		addr          string
+		uuid          string
+		resumeUUID    string
+		resumeGTID    mysql.GTIDSet
+		wantErr       bool
+		wantResumedBy string
+	}{
+		{name: "same server", policy: masterSwitchError, addr: "db1:3306", uuid: uuidA, resumeUUID: uuidA},
+		{name: "same server behind another address", policy: masterSwitchError, addr: "db2:3306", uuid: uuidA, resumeUUID: uuidA},
+		{name: "failover behind the same address", policy: masterSwitchError, addr: "db1:3306", uuid: uuidB, resumeUUID: uuidA, wantErr: true},
+		{name: "another address without uuid", policy: masterSwitchError, addr: "db2:3306", wantErr: true},
+		{name: "gtid", policy: masterSwitchError, addr: "db1:3306", uuid: uuidB, resumeUUID: uuidA, resumeGTID: gtidSet, wantResumedBy: "gtid"},
+		{name: "resnapshot", policy: masterSwitchResnapshot, addr: "db1:3306", uuid: uuidB, resumeUUID: uuidA, wantResumedBy: "snapshot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithOnMasterSwitch(tt.policy))
+			position := mysql.Position{Name: "mysql-bin.000003", Pos: 1200}
+			m.addr, m.serverUUID = tt.addr, tt.uuid
+			m.resumeAddr, m.resumeServerUUID = "db1:3306", tt.resumeUUID
+			m.resumePosition, m.syncedPosition, m.resumeGTID = position, position, tt.resumeGTID
+
+			err := m.checkMasterSwitch()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkMasterSwitch error = %v, want error %v", err, tt.wantErr)
+			}
+			if err := m.emitMasterSwitch(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantResumedBy == "" {
+				if len(m.stream) != 0 {
+					t.Errorf("%d events sent, want none", len(m.stream))
+				}
+				return
+			}
+
+			msg := <-m.stream
+			want := map[string]any{
+				"from_server_uuid": tt.resumeUUID,
+				"from_addr":        "db1:3306",
+				"from_file":        "mysql-bin.000003",
+				"from_pos":         uint32(1200),
+				"to_server_uuid":   tt.uuid,
+				"to_addr":          tt.addr,
+				"resumed_by":       tt.wantResumedBy,
+			}
+			if msg.Event != masterSwitchEvent {
+				t.Fatalf("event = %s, want %s", msg.Event, masterSwitchEvent)
+			}
+			for field, value := range want {
+				if msg.Data[field] != value {
+					t.Errorf("%s = %v, want %v", field, msg.Data[field], value)
+				}
+			}
+			// A new snapshot is taken in place of the discarded position.
+			if snapshot := tt.wantResumedBy == "snapshot"; m.masterSwitchSnapshot != snapshot || (m.resumePosition.Name == "") != snapshot {
+				t.Errorf("new snapshot = %v resuming from %v, want a new snapshot %v", m.masterSwitchSnapshot, m.resumePosition, snapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// metadataPool holds a small pool of control connections for the auxiliary
+// queries made while streaming, such as position monitoring, so that they reuse connections instead of opening one
+// each and never compete with the replication connection. Snapshots keep
+// dedicated connections since they hold transactions and locks.
+type metadataPool struct {
+	mu   sync.Mutex
+	pool *client.Pool
+	key  metadataPoolKey
+}
+
+// metadataPoolKey is what pooled connections are opened with. The pool is
+// rebuilt when it changes, for example after failing over to another server
+// or refreshing credentials.
+type metadataPoolKey struct {
+	addr     string
+	user     string
+	password string
+	tls      *tls.Config
+}
+
+// metadataKey returns the current connection parameters.
+func (m *MysqlStreamInput) metadataKey() metadataPoolKey {
+	return metadataPoolKey{addr: m.addr, user: m.user, password: m.password, tls: m.tlsConfig}
+}
+
+// withMetadataConn calls fn with a pooled control connection, which is
+// dropped rather than reused when fn fails.
+func (m *MysqlStreamInput) withMetadataConn(ctx context.Context, fn func(conn *client.Conn) error) error {
+	return m.withMetadataConnTo(ctx, m.metadataKey(), fn)
+}
+
+// withMetadataConnTo calls fn with a control connection opened with key, for
+// goroutines that must not read the connection parameters a reconnect
+// changes.
+func (m *MysqlStreamInput) withMetadataConnTo(ctx context.Context, key metadataPoolKey, fn func(conn *client.Conn) error) error {
+	pool, err := m.metadataPool(ctx, key)
+	if err != nil {
+		return err
+	}
+	conn, err := pool.GetConn(ctx)
+	if err != nil {
+		// The pool keeps redialing in the background until it is closed.
+		m.closeMetadataPool(pool)
+		return err
+	}
+	if err := fn(conn); err != nil {
+		pool.DropConn(conn)
+		return err
+	}
+	pool.PutConn(conn)
+	return nil
+}
+
+// metadataPool returns the pool of connections opened with key. A goroutine
+// whose ctx is done does not rebuild the pool, as its key may be that of the
+// server before a reconnect, which cancels it before changing servers.
+func (m *MysqlStreamInput) metadataPool(ctx context.Context, key metadataPoolKey) (*client.Pool, error) {
+	m.metadata.mu.Lock()
+	defer m.metadata.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.metadata.pool != nil && m.metadata.key == key {
+		return m.metadata.pool, nil
+	}
+	if m.metadata.pool != nil {
+		m.metadata.pool.Close()
+	}
+	pool, err := client.NewPoolWithOptions(key.addr, key.user, key.password, "",
+		client.WithPoolLimits(0, m.metadataPoolSize, 1),
+		client.WithLogFunc(m.logger.Debugf),
+		client.WithConnOptions(m.connOptions(key.tls)...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.metadata.pool, m.metadata.key = pool, key
+	return pool, nil
+}
+
+// closeMetadataPool closes pool unless it was already replaced.
+func (m *MysqlStreamInput) closeMetadataPool(pool *client.Pool) {
+	m.metadata.mu.Lock()
+	defer m.metadata.mu.Unlock()
+
+	if m.metadata.pool == pool {
+		m.metadata.pool.Close()
+		m.metadata.pool = nil
+	}
+}
+
+// close closes the idle connections of the pool. Connections in use are
+// closed when they are returned.
+func (p *metadataPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pool != nil {
+		p.pool.Close()
+		p.pool = nil
+	}
+}
@@ -0,0 +1,56 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestMonitorKeepsServerAcrossReconnect(t *testing.T) {
+	logs, err := mysql.BuildSimpleTextResultset([]string{"Log_name", "File_size"}, [][]any{{"mysql-bin.000001", uint64(4)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := &queryLog{}
+	addr := startSchemaServer(t, schemaServer{results: map[string]*mysql.Resultset{"SHOW BINARY LOGS": logs}, queries: queries})
+	m := newTestInput(t, WithAddr(addr), WithUser("root"), WithPositionCheckInterval(10*time.Millisecond))
+	t.Cleanup(m.metadata.close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.monitorPosition(ctx, m.metadataKey())
+	}()
+	checked := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(queries.take()) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("monitor did not check the server")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	checked()
+
+	// A reconnect to another server changes the connection parameters
+	// while the monitor of the previous connection still runs.
+	m.addr = "127.0.0.1:1"
+	checked()
+	cancel()
+	<-done
+
+	if m.metadata.key.addr != addr {
+		t.Errorf("metadata pool opened to %s, want %s", m.metadata.key.addr, addr)
+	}
+	if _, err := m.metadataPool(ctx, m.metadataKey()); !errors.Is(err, context.Canceled) {
+		t.Errorf("metadataPool after the monitor was stopped error = %v, want %v", err, context.Canceled)
+	}
+	if m.metadata.key.addr != addr {
+		t.Errorf("stopped monitor rebuilt the metadata pool for %s", m.metadata.key.addr)
+	}
+}
@@ -0,0 +1,120 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"net"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+type streamMetrics struct {
+	bytesRead         *service.MetricCounter
+	bytesWritten      *service.MetricCounter
+	messagesEmitted   *service.MetricCounter
+	messagesDropped   *service.MetricCounter
+	messagesOversized *service.MetricCounter
+
+	bufferDepth     *service.MetricGauge
+	bufferHighWater *service.MetricGauge
+	bufferBlocked   *service.MetricTimer
+
+	rowsEventsCompressed   *service.MetricCounter
+	rowsEventsUncompressed *service.MetricCounter
+
+	binlogFilesBehind *service.MetricGauge
+	tableActive       *service.MetricGauge
+	ready             *service.MetricGauge
+
+	ackLatency *service.MetricTimer
+
+	messagesDeadLettered *service.MetricCounter
+	nackBreakerOpen      *service.MetricGauge
+	rowsDeadLettered     *service.MetricCounter
+
+	snapshotRows     *service.MetricCounter
+	snapshotDuration *service.MetricTimer
+
+	snapshotThrottles *service.MetricCounter
+	snapshotThrottled *service.MetricTimer
+	snapshotPaused    *service.MetricGauge
+
+	binlogGaps *service.MetricCounter
+
+	unknownActionsSkipped *service.MetricCounter
+	noopUpdatesDropped    *service.MetricCounter
+
+	inFlightSpilled      *service.MetricCounter
+	inFlightSpillPending *service.MetricGauge
+}
+
+func newStreamMetrics(m *service.Metrics) *streamMetrics {
+	return &streamMetrics{
+		bytesRead:         m.NewCounter("mysql_stream_bytes_read"),
+		bytesWritten:      m.NewCounter("mysql_stream_bytes_written"),
+		messagesEmitted:   m.NewCounter("mysql_stream_messages_emitted"),
+		messagesDropped:   m.NewCounter("mysql_stream_messages_dropped"),
+		messagesOversized: m.NewCounter("mysql_stream_messages_oversized"),
+
+		bufferDepth:     m.NewGauge("mysql_stream_buffer_depth"),
+		bufferHighWater: m.NewGauge("mysql_stream_buffer_high_water"),
+		bufferBlocked:   m.NewTimer("mysql_stream_buffer_blocked_ns"),
+
+		rowsEventsCompressed:   m.NewCounter("mysql_stream_rows_events_compressed"),
+		rowsEventsUncompressed: m.NewCounter("mysql_stream_rows_events_uncompressed"),
+
+		binlogFilesBehind: m.NewGauge("mysql_stream_binlog_files_behind"),
+		tableActive:       m.NewGauge("mysql_stream_table_active", "table"),
+		ready:             m.NewGauge("mysql_stream_ready"),
+
+		ackLatency: m.NewTimer("mysql_stream_ack_latency_ns"),
+
+		messagesDeadLettered: m.NewCounter("mysql_stream_messages_dead_lettered"),
+		nackBreakerOpen:      m.NewGauge("mysql_stream_nack_breaker_open"),
+		rowsDeadLettered:     m.NewCounter("mysql_stream_rows_dead_lettered"),
+
+		snapshotRows:     m.NewCounter("mysql_stream_snapshot_rows", "table"),
+		snapshotDuration: m.NewTimer("mysql_stream_snapshot_duration_ns"),
+
+		snapshotThrottles: m.NewCounter("mysql_stream_snapshot_throttles"),
+		snapshotThrottled: m.NewTimer("mysql_stream_snapshot_throttled_ns"),
+		snapshotPaused:    m.NewGauge("mysql_stream_snapshot_paused"),
+
+		binlogGaps: m.NewCounter("mysql_stream_binlog_gaps"),
+
+		unknownActionsSkipped: m.NewCounter("mysql_stream_unknown_actions_skipped", "action"),
+		noopUpdatesDropped:    m.NewCounter("mysql_stream_noop_updates_dropped"),
+
+		inFlightSpilled:      m.NewCounter("mysql_stream_in_flight_spilled"),
+		inFlightSpillPending: m.NewGauge("mysql_stream_in_flight_spill_pending"),
+	}
+}
+
+// wrapDialer returns a dialer whose connections report the bytes they move
+// through the read/write counters.
+func (s *streamMetrics) wrapDialer(dial client.Dialer) client.Dialer {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, metrics: s}, nil
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	metrics *streamMetrics
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.metrics.bytesRead.Incr(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.metrics.bytesWritten.Incr(int64(n))
+	return n, err
+}
@@ -0,0 +1,88 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// monitorPosition periodically compares the consumed binlog position against
+// the binary logs available on the server over a pooled control connection,
+// so that checks never contend with the replication stream. It connects with
+// server, the connection parameters when it was started, as a reconnect
+// changes them while it may still be running.
+func (m *MysqlStreamInput) monitorPosition(ctx context.Context, server metadataPoolKey) {
+	ticker := time.NewTicker(m.positionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.withMetadataConnTo(ctx, server, m.checkBinlogFiles); err != nil {
+			m.logger.Warnf("Failed to check binary logs: %v", err)
+		}
+	}
+}
+
+func (m *MysqlStreamInput) controlConn() (*client.Conn, error) {
+	return client.Connect(m.addr, m.user, m.password, "", m.connOptions(m.tlsConfig)...)
+}
+
+// connOptions returns the options control connections are opened with, using
+// tlsConfig when it is set.
+func (m *MysqlStreamInput) connOptions(tlsConfig *tls.Config) []client.Option {
+	var opts []client.Option
+	if tlsConfig != nil {
+		opts = append(opts, func(c *client.Conn) error {
+			c.SetTLSConfig(tlsConfig)
+			return nil
+		})
+	}
+	if len(m.connectionAttributes) > 0 {
+		opts = append(opts, func(c *client.Conn) error {
+			c.SetAttributes(m.connectionAttributes)
+			return nil
+		})
+	}
+	return opts
+}
+
+func (m *MysqlStreamInput) checkBinlogFiles(conn *client.Conn) error {
+	res, err := conn.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return err
+	}
+
+	current := m.handledPosition()
+	if current.Name == "" {
+		return nil
+	}
+
+	files := res.Resultset.RowNumber()
+	for i := 0; i < files; i++ {
+		name, err := res.GetString(i, 0)
+		if err != nil {
+			return err
+		}
+		if name == current.Name {
+			behind := int64(files - 1 - i)
+			m.metrics.binlogFilesBehind.Set(behind)
+			// The stream has caught up once it handled the last
+			// transaction of the last file.
+			size, _ := res.GetUint(i, 1)
+			m.recordLag(behind, behind > 0 || uint64(current.Pos) < size)
+			return nil
+		}
+	}
+
+	m.logger.Warnf("Binlog file %s is no longer available on the server, it may have been purged", current.Name)
+	m.metrics.binlogFilesBehind.Set(int64(files))
+	m.recordLag(int64(files), true)
+	return nil
+}
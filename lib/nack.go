@@ -0,0 +1,171 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+const (
+	repeatedNackRetry      = "retry"
+	repeatedNackError      = "error"
+	repeatedNackDeadLetter = "dead_letter"
+)
+
+// nackBreaker wraps the retrying input and counts the consecutive nacks of
+// every message. Once a message has been nacked maxConsecutiveNacks times in a
+// row it is no longer retried: with on_repeated_nack error the input stops
+// without acknowledging it, so that the persisted position does not advance
+// past it, and with dead_letter it is written to dead_letter_cache and
+// acknowledged.
+type nackBreaker struct {
+	child service.Input
+	m     *MysqlStreamInput
+
+	mu        sync.Mutex
+	nacks     map[*service.Message]int
+	tripped   bool
+	interrupt func()
+}
+
+// withNackBreaker wraps input in a nackBreaker unless repeated nacks are
+// retried indefinitely.
+func (m *MysqlStreamInput) withNackBreaker(input service.Input) service.Input {
+	if m.onRepeatedNack == repeatedNackRetry {
+		return input
+	}
+	m.metrics.nackBreakerOpen.Set(0)
+	return &nackBreaker{
+		child:     input,
+		m:         m,
+		nacks:     map[*service.Message]int{},
+		interrupt: func() {},
+	}
+}
+
+func (b *nackBreaker) Connect(ctx context.Context) error {
+	return b.child.Connect(ctx)
+}
+
+func (b *nackBreaker) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	b.mu.Lock()
+	if b.tripped {
+		b.mu.Unlock()
+		return nil, nil, service.ErrEndOfInput
+	}
+	b.interrupt = cancel
+	b.mu.Unlock()
+
+	msg, ack, err := b.child.Read(ctx)
+
+	b.mu.Lock()
+	tripped := b.tripped
+	b.mu.Unlock()
+	if tripped {
+		// A message read while the breaker tripped is not acknowledged and
+		// is read again after a restart.
+		return nil, nil, service.ErrEndOfInput
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, b.wrapAckFunc(msg, ack), nil
+}
+
+func (b *nackBreaker) wrapAckFunc(msg *service.Message, ack service.AckFunc) service.AckFunc {
+	return func(ctx context.Context, err error) error {
+		if err == nil {
+			b.mu.Lock()
+			delete(b.nacks, msg)
+			b.mu.Unlock()
+			return ack(ctx, nil)
+		}
+
+		b.mu.Lock()
+		b.nacks[msg]++
+		nacks := b.nacks[msg]
+		b.mu.Unlock()
+		if nacks < b.m.maxConsecutiveNacks {
+			return ack(ctx, err)
+		}
+
+		if b.m.onRepeatedNack == repeatedNackDeadLetter {
+			if dlErr := b.m.deadLetter(ctx, msg, nacks, err); dlErr != nil {
+				b.m.logger.Errorf("Failed to write message to dead_letter_cache, retrying it: %v", dlErr)
+				return ack(ctx, err)
+			}
+			b.m.metrics.messagesDeadLettered.Incr(1)
+			b.mu.Lock()
+			delete(b.nacks, msg)
+			b.mu.Unlock()
+			return ack(ctx, nil)
+		}
+
+		event, _ := msg.MetaGet("event")
+		table, _ := msg.MetaGet("table")
+		b.m.logger.Errorf("Stopping after %s message from table %s was rejected %d times in a row: %v", event, table, nacks, err)
+		b.m.metrics.nackBreakerOpen.Set(1)
+		b.mu.Lock()
+		b.tripped = true
+		b.interrupt()
+		b.mu.Unlock()
+		return nil
+	}
+}
+
+func (b *nackBreaker) Close(ctx context.Context) error {
+	return b.child.Close(ctx)
+}
+
+type deadLetterRecord struct {
+	Error    string            `json:"error"`
+	Nacks    int               `json:"nacks"`
+	Time     time.Time         `json:"time"`
+	Metadata map[string]string `json:"metadata"`
+	Body     []byte            `json:"body"`
+}
+
+// deadLetter writes a rejected message to dead_letter_cache, keyed by its
+// idempotency_key metadata field, or by its event and the current time for
+// messages without one.
+func (m *MysqlStreamInput) deadLetter(ctx context.Context, msg *service.Message, nacks int, nackErr error) error {
+	body, err := msg.AsBytes()
+	if err != nil {
+		return err
+	}
+	record := deadLetterRecord{
+		Error:    nackErr.Error(),
+		Nacks:    nacks,
+		Time:     time.Now(),
+		Metadata: map[string]string{},
+		Body:     body,
+	}
+	_ = msg.MetaWalk(func(k, v string) error {
+		record.Metadata[k] = v
+		return nil
+	})
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	key, ok := msg.MetaGet("idempotency_key")
+	if !ok {
+		key = fmt.Sprintf("%s:%d", record.Metadata["event"], record.Time.UnixNano())
+	}
+
+	var cacheErr error
+	if err := m.resources.AccessCache(ctx, m.deadLetterCache, func(c service.Cache) {
+		cacheErr = c.Set(ctx, key, value, nil)
+	}); err != nil {
+		return err
+	}
+	return cacheErr
+}
@@ -0,0 +1,81 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+func TestNackBreaker(t *testing.T) {
+	const maxNacks = 3
+	rejected := errors.New("output rejected the message")
+
+	tests := []struct {
+		policy          string
+		wantEnd         bool
+		wantDeadLetters int
+	}{
+		{policy: repeatedNackError, wantEnd: true},
+		{policy: repeatedNackDeadLetter, wantDeadLetters: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			metrics, local := newTestMetrics(t)
+			m := newTestInput(t, WithResources(mgr), WithMetrics(metrics), WithRepeatedNack(tt.policy, maxNacks, "cache"), WithReadPollTimeout(10*time.Millisecond))
+			input := m.withNackBreaker(m)
+			m.stream <- StreamMessage{Table: "orders", Event: "insert", Data: map[string]any{"id": int64(1)}, idempotencyKey: "orders:1"}
+
+			ctx := context.Background()
+			msg, ack, err := input.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Benthos retries the same message after each nack.
+			for i := 0; i < maxNacks; i++ {
+				if err := ack(ctx, rejected); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, _, err = input.Read(ctx)
+			if ended := errors.Is(err, service.ErrEndOfInput); ended != tt.wantEnd {
+				t.Fatalf("Read after %d nacks error = %v, want end of input %v", maxNacks, err, tt.wantEnd)
+			}
+			wantOpen := int64(0)
+			if tt.wantEnd {
+				wantOpen = 1
+			}
+			counters := local.GetCounters()
+			if open := counters["mysql_stream_nack_breaker_open"]; open != wantOpen {
+				t.Errorf("mysql_stream_nack_breaker_open = %d, want %d", open, wantOpen)
+			}
+			if n := counters["mysql_stream_messages_dead_lettered"]; n != int64(tt.wantDeadLetters) {
+				t.Errorf("mysql_stream_messages_dead_lettered = %d, want %d", n, tt.wantDeadLetters)
+			}
+
+			value, err := cache.Get(ctx, "orders:1")
+			if tt.wantDeadLetters == 0 {
+				if err == nil {
+					t.Errorf("message written to dead_letter_cache under %s", tt.policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dead letter not written: %v", err)
+			}
+			var record deadLetterRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				t.Fatal(err)
+			}
+			body, _ := msg.AsBytes()
+			if record.Error != rejected.Error() || record.Nacks != maxNacks || string(record.Body) != string(body) || record.Metadata["table"] != "orders" {
+				t.Errorf("dead letter = %+v, want the message rejected %d times with %q", record, maxNacks, rejected)
+			}
+		})
+	}
+}
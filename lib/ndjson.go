@@ -0,0 +1,140 @@
+package mongodb_stream_benthos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+)
+
+const outputFormatNDJSONBatch = "ndjson_batch"
+
+// ndjsonLine is a change event within an ndjson_batch message, with the
+// metadata it would carry as a message of its own.
+type ndjsonLine struct {
+	Metadata map[string]string `json:"metadata"`
+	Data     json.RawMessage   `json:"data"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// readNDJSONBatch reads up to ndjson_batch_size messages, waiting at most
+// ndjson_batch_period for more after the first, and packs them into a single
+// message with a line of JSON each. The batch is acknowledged as a whole, so
+// the positions of its messages are only acknowledged once it is delivered.
+// An error hit after the first message, or the end of ctx as the pipeline
+// shuts down, does not discard the messages read before it: the batch is
+// emitted with them and an error is returned by the next read.
+func (m *MysqlStreamInput) readNDJSONBatch(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	if err := m.batchErr; err != nil {
+		m.batchErr = nil
+		return nil, nil, err
+	}
+
+	msg, ack, err := m.readMessage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs := []*service.Message{msg}
+	acks := []service.AckFunc{ack}
+
+	batchCtx, cancel := context.WithTimeout(ctx, m.ndjsonBatchPeriod)
+	defer cancel()
+	for len(msgs) < m.ndjsonBatchSize {
+		msg, ack, err := m.readMessage(batchCtx)
+		if err != nil {
+			if batchCtx.Err() == nil && !errors.Is(err, errReadPollTimeout) {
+				m.batchErr = err
+			}
+			break
+		}
+		msgs = append(msgs, msg)
+		acks = append(acks, ack)
+	}
+
+	batch, err := ndjsonBatch(msgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.includeMessageSize {
+		body, _ := batch.AsBytes()
+		batch.MetaSet("message_size_bytes", strconv.Itoa(len(body)))
+	}
+	m.pendingBatches.Add(1)
+	var once sync.Once
+	return batch, func(ctx context.Context, err error) error {
+		defer once.Do(m.pendingBatches.Done)
+		for _, ack := range acks {
+			if ackErr := ack(ctx, err); ackErr != nil {
+				return ackErr
+			}
+		}
+		return nil
+	}, nil
+}
+
+// awaitPendingBatches waits up to ndjson_shutdown_timeout for the batches
+// emitted to be acknowledged, so that their positions are persisted as the
+// input closes.
+func (m *MysqlStreamInput) awaitPendingBatches(ctx context.Context) {
+	if m.ndjsonShutdownTimeout <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		m.pendingBatches.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(m.ndjsonShutdownTimeout):
+		m.logger.Warnf("Closing with batches unacknowledged after %v, their messages are delivered again on the next run", m.ndjsonShutdownTimeout)
+	case <-ctx.Done():
+		m.logger.Warn("Closing with batches unacknowledged, their messages are delivered again on the next run")
+	}
+}
+
+// ndjsonBatch packs messages into a single message with a line of JSON each.
+// The batch carries the batch_size of its messages, and the binlog position
+// of the last one read from the binlog.
+func ndjsonBatch(msgs []*service.Message) (*service.Message, error) {
+	var body bytes.Buffer
+	var binlogFile, binlogPos string
+	for _, msg := range msgs {
+		data, err := msg.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		line := ndjsonLine{Metadata: map[string]string{}, Data: data}
+		_ = msg.MetaWalk(func(k, v string) error {
+			line.Metadata[k] = v
+			return nil
+		})
+		if err := msg.GetError(); err != nil {
+			line.Error = err.Error()
+		}
+		if file, ok := line.Metadata["binlog_file"]; ok {
+			binlogFile, binlogPos = file, line.Metadata["binlog_pos"]
+		}
+
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(encoded)
+		body.WriteByte('\n')
+	}
+
+	batch := service.NewMessage(body.Bytes())
+	batch.MetaSet("event", outputFormatNDJSONBatch)
+	batch.MetaSet("batch_size", strconv.Itoa(len(msgs)))
+	if binlogFile != "" {
+		batch.MetaSet("binlog_file", binlogFile)
+		batch.MetaSet("binlog_pos", binlogPos)
+	}
+	return batch, nil
+}
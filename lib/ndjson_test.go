@@ -0,0 +1,178 @@
+package mongodb_stream_benthos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestNDJSONBatchAck(t *testing.T) {
+	tests := []struct {
+		name       string
+		ackErr     error
+		wantStored bool
+	}{
+		{name: "delivered", wantStored: true},
+		{name: "rejected", ackErr: errors.New("sink unavailable")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithOutputFormat(outputFormatNDJSONBatch),
+				WithNDJSONBatch(3, time.Second), WithPositionCache("cache", "position"), WithPositionFlush(0, 1))
+			m.binlogFile = "mysql-bin.000001"
+			table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+			for i, pos := range []uint32{100, 200, 300} {
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(i + 1)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				commit := mysql.Position{Name: "mysql-bin.000001", Pos: pos + 50}
+				header := &replication.EventHeader{Timestamp: 1, LogPos: commit.Pos, EventType: replication.XID_EVENT}
+				if err := m.OnXID(header, commit); err != nil {
+					t.Fatal(err)
+				}
+				if err := m.OnPosSynced(header, commit, nil, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx := context.Background()
+			msg, ack, err := m.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := msg.AsBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n"))
+			if len(lines) != 3 {
+				t.Fatalf("batch has %d lines, want 3", len(lines))
+			}
+			var last ndjsonLine
+			if err := json.Unmarshal(lines[2], &last); err != nil {
+				t.Fatal(err)
+			}
+			if last.Metadata["binlog_pos"] != "300" {
+				t.Errorf("last line binlog_pos = %s, want 300", last.Metadata["binlog_pos"])
+			}
+			if stored, ok := cache.storedPosition(t, "position"); ok {
+				t.Fatalf("position %+v stored before the batch was acknowledged", stored)
+			}
+
+			if err := ack(ctx, tt.ackErr); err != nil {
+				t.Fatal(err)
+			}
+			stored, ok := cache.storedPosition(t, "position")
+			if ok != tt.wantStored {
+				t.Fatalf("position stored = %v, want %v", ok, tt.wantStored)
+			}
+			// The last change resumes after the transaction before it.
+			if ok && stored.BinlogPos != 250 {
+				t.Errorf("stored position = %+v, want mysql-bin.000001:250", stored)
+			}
+		})
+	}
+}
+
+func TestNDJSONBatchShutdown(t *testing.T) {
+	tests := []struct {
+		name       string
+		ack        bool
+		wantStored bool
+	}{
+		{name: "acknowledged while closing", ack: true, wantStored: true},
+		{name: "unacknowledged", ack: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithOutputFormat(outputFormatNDJSONBatch),
+				WithNDJSONBatch(3, time.Minute), WithNDJSONShutdownTimeout(200*time.Millisecond),
+				WithPositionCache("cache", "position"), WithPositionFlush(0, 100))
+			m.binlogFile = "mysql-bin.000001"
+			table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+			for i, pos := range []uint32{100, 200} {
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(i + 1)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				commit := mysql.Position{Name: "mysql-bin.000001", Pos: pos + 50}
+				header := &replication.EventHeader{Timestamp: 1, LogPos: commit.Pos, EventType: replication.XID_EVENT}
+				if err := m.OnXID(header, commit); err != nil {
+					t.Fatal(err)
+				}
+				if err := m.OnPosSynced(header, commit, nil, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The pipeline shuts down while the batch is being filled.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			msg, ack, err := m.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := msg.AsBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := bytes.Count(body, []byte("\n")); lines != 2 {
+				t.Fatalf("partial batch has %d lines, want 2", lines)
+			}
+
+			closed := make(chan error, 1)
+			go func() {
+				closed <- m.Close(context.Background())
+			}()
+			if tt.ack {
+				select {
+				case err := <-closed:
+					t.Fatalf("Close returned before the batch was acknowledged: %v", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				if err := ack(context.Background(), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			select {
+			case err := <-closed:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Close did not return after ndjson_shutdown_timeout")
+			}
+
+			stored, ok := cache.storedPosition(t, "position")
+			if ok != tt.wantStored {
+				t.Fatalf("position stored = %v, want %v", ok, tt.wantStored)
+			}
+			// The last change resumes after the transaction before it.
+			if ok && stored.BinlogPos != 150 {
+				t.Errorf("stored position = %+v, want mysql-bin.000001:150", stored)
+			}
+		})
+	}
+}
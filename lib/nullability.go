@@ -0,0 +1,71 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// nullabilityLookupTimeout bounds each lookup of the nullability of the
+// columns of a table, which holds up the stream while it runs.
+const nullabilityLookupTimeout = 5 * time.Second
+
+type tableNullability struct {
+	table    *schema.Table
+	nullable map[string]bool
+}
+
+// nullabilityFor returns whether each column of a table accepts NULL, for
+// include_nullability. Lookups are cached per table and done again when its
+// layout changes. It is only called from Read.
+func (m *MysqlStreamInput) nullabilityFor(table *schema.Table) (map[string]bool, error) {
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.nullabilityCache.get(key); ok && cached.table == table {
+		return cached.nullable, nil
+	}
+	nullable, err := m.lookupNullability(table)
+	if err != nil {
+		return nil, err
+	}
+	m.nullabilityCache.put(key, tableNullability{table: table, nullable: nullable})
+	return nullable, nil
+}
+
+// lookupNullability reads whether each column of a table accepts NULL. The
+// schemas read by the replication client do not record it, so primary key
+// columns, which never accept NULL, are taken from the schema and the other
+// columns are looked up in information_schema over the control connection.
+// Columns the lookup does not find are reported as nullable.
+func (m *MysqlStreamInput) lookupNullability(table *schema.Table) (map[string]bool, error) {
+	nullable := make(map[string]bool, len(table.Columns))
+	for _, col := range table.Columns {
+		nullable[col.Name] = true
+	}
+	for _, i := range table.PKColumns {
+		nullable[table.Columns[i].Name] = false
+	}
+	if len(table.PKColumns) < len(table.Columns) {
+		ctx, cancel := context.WithTimeout(context.Background(), nullabilityLookupTimeout)
+		defer cancel()
+		err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+			res, err := conn.Execute("SELECT COLUMN_NAME, IS_NULLABLE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", table.Schema, table.Name)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < res.RowNumber(); i++ {
+				name, _ := res.GetString(i, 0)
+				isNullable, _ := res.GetString(i, 1)
+				if _, ok := nullable[name]; ok {
+					nullable[name] = isNullable == "YES"
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nullable, nil
+}
@@ -0,0 +1,60 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestNullability(t *testing.T) {
+	m := newTestInput(t, WithColumnMetadata(true), WithSchemaFingerprint(true), WithNullability(true))
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id", RawType: "int"}, {Name: "sku", RawType: "varchar(16)"}, {Name: "note", RawType: "text"}},
+		PKColumns: []int{0},
+	}
+
+	// Primary key columns never accept NULL without a lookup.
+	keyOnly := &schema.Table{Schema: "shop", Name: "tags", Columns: []schema.TableColumn{{Name: "id", RawType: "int"}}, PKColumns: []int{0}}
+	nullable, err := m.nullabilityFor(keyOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nullable["id"] {
+		t.Error("primary key column id reported as nullable")
+	}
+
+	// The other columns are looked up in information_schema, and the lookup
+	// is cached for the layout.
+	m.nullabilityCache.put("shop.orders", tableNullability{table: table, nullable: map[string]bool{"id": false, "sku": true, "note": true}})
+	nullableSKU, err := m.schemaFingerprintFor(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.nullabilityCache.put("shop.orders", tableNullability{table: table, nullable: map[string]bool{"id": false, "sku": false, "note": true}})
+	m.fingerprintCache = newLRUCache[schemaFingerprint](typeHintCacheSize)
+
+	metadata, err := m.columnMetadataFor(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns map[string]columnMetadata
+	if err := json.Unmarshal([]byte(metadata), &columns); err != nil {
+		t.Fatal(err)
+	}
+	for column, want := range map[string]bool{"id": false, "sku": false, "note": true} {
+		if got := columns[column].Nullable; got == nil || *got != want {
+			t.Errorf("column %s nullable = %v, want %v", column, got, want)
+		}
+	}
+
+	notNullSKU, err := m.schemaFingerprintFor(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notNullSKU == nullableSKU {
+		t.Error("schema fingerprint unchanged by a column no longer accepting NULL")
+	}
+}
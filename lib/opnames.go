@@ -0,0 +1,80 @@
+package mongodb_stream_benthos
+
+import "fmt"
+
+// Event kinds of op_names.
+const (
+	opKindInsert    = "insert"
+	opKindUpdate    = "update"
+	opKindDelete    = "delete"
+	opKindRead      = "read"
+	opKindTruncate  = "truncate"
+	opKindDDL       = "ddl"
+	opKindHeartbeat = "heartbeat"
+)
+
+// defaultOpNames are the operation codes emitted per event kind unless
+// op_names overrides them: the codes of Debezium for row changes, snapshot
+// reads and truncates, and none for schema changes and offset markers.
+var defaultOpNames = map[string]string{
+	opKindInsert:    opCreate,
+	opKindUpdate:    opUpdate,
+	opKindDelete:    opDelete,
+	opKindRead:      opRead,
+	opKindTruncate:  opTruncate,
+	opKindDDL:       "",
+	opKindHeartbeat: "",
+}
+
+// parseOpNames returns the operation code of every event kind, with the
+// overrides of op_names applied. Row changes, snapshot reads and truncates
+// must keep a code, which Debezium output and the op metadata field rely on.
+func parseOpNames(overrides map[string]string) (map[string]string, error) {
+	names := make(map[string]string, len(defaultOpNames))
+	for kind, name := range defaultOpNames {
+		names[kind] = name
+	}
+	for kind, name := range overrides {
+		if _, ok := defaultOpNames[kind]; !ok {
+			return nil, fmt.Errorf("op_names: unknown event kind %s", kind)
+		}
+		switch kind {
+		case opKindDDL, opKindHeartbeat:
+		default:
+			if name == "" {
+				return nil, fmt.Errorf("op_names: empty name for event kind %s", kind)
+			}
+		}
+		names[kind] = name
+	}
+	return names, nil
+}
+
+// opKind returns the op_names event kind of a message, or an empty string for
+// messages that carry no operation.
+func opKind(msg StreamMessage) string {
+	switch msg.op {
+	case opCreate:
+		return opKindInsert
+	case opUpdate:
+		return opKindUpdate
+	case opDelete:
+		return opKindDelete
+	case opRead:
+		return opKindRead
+	case opTruncate:
+		return opKindTruncate
+	}
+	switch msg.Event {
+	case ddlEvent:
+		return opKindDDL
+	case offsetEvent:
+		return opKindHeartbeat
+	}
+	return ""
+}
+
+// opName returns the operation code emitted for a message.
+func (m *MysqlStreamInput) opName(msg StreamMessage) string {
+	return m.opNames[opKind(msg)]
+}
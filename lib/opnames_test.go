@@ -0,0 +1,104 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestParseOpNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		wantErr   bool
+	}{
+		{name: "defaults"},
+		{name: "overrides", overrides: map[string]string{opKindInsert: "create", opKindRead: "snapshot", opKindDDL: "schema"}},
+		{name: "unknown kind", overrides: map[string]string{"upsert": "u"}, wantErr: true},
+		{name: "empty row change code", overrides: map[string]string{opKindDelete: ""}, wantErr: true},
+		{name: "empty heartbeat code", overrides: map[string]string{opKindHeartbeat: ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := parseOpNames(tt.overrides)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOpNames error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// Every event kind has a code.
+			for kind := range defaultOpNames {
+				want, ok := tt.overrides[kind]
+				if !ok {
+					want = defaultOpNames[kind]
+				}
+				if got, ok := names[kind]; !ok || got != want {
+					t.Errorf("%s = %q, want %q", kind, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpNamePerEventKind(t *testing.T) {
+	m := newTestInput(t, WithOpNames(map[string]string{
+		opKindInsert:    "create",
+		opKindUpdate:    "update",
+		opKindDelete:    "remove",
+		opKindRead:      "snapshot",
+		opKindTruncate:  "truncate",
+		opKindDDL:       "schema",
+		opKindHeartbeat: "heartbeat",
+	}))
+	tests := []struct {
+		name string
+		msg  StreamMessage
+		want string
+	}{
+		{name: "insert", msg: StreamMessage{Event: canal.InsertAction, op: opCreate}, want: "create"},
+		{name: "renamed update", msg: StreamMessage{Event: "modified", op: opUpdate}, want: "update"},
+		{name: "delete", msg: StreamMessage{Event: canal.DeleteAction, op: opDelete}, want: "remove"},
+		{name: "snapshot read", msg: StreamMessage{Event: canal.InsertAction, op: opRead}, want: "snapshot"},
+		{name: "truncate", msg: StreamMessage{Event: truncateEvent, op: opTruncate}, want: "truncate"},
+		{name: "ddl", msg: StreamMessage{Event: ddlEvent}, want: "schema"},
+		{name: "offset marker", msg: StreamMessage{Event: offsetEvent}, want: "heartbeat"},
+		{name: "lifecycle event", msg: StreamMessage{Event: connectedEvent}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.opName(tt.msg); got != tt.want {
+				t.Errorf("opName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpNamesAcrossFormats(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	for _, format := range []string{outputFormatRow, outputFormatDebezium, outputFormatCloudEvents} {
+		t.Run(format, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(format), WithStructuredMessages(true),
+				WithOpNames(map[string]string{opKindInsert: "create"}))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(7)}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, body := readStructured(t, m)
+			if op, _ := msg.MetaGet("op"); op != "create" {
+				t.Errorf("op metadata = %q, want create", op)
+			}
+			if format != outputFormatRow && body["op"] != "create" {
+				t.Errorf("envelope op = %v, want create", body["op"])
+			}
+		})
+	}
+}
@@ -0,0 +1,1617 @@
+package mongodb_stream_benthos
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/pingcap/tidb/pkg/parser"
+)
+
+// Option configures a MysqlStreamInput created with NewMysqlStreamInput.
+type Option func(*MysqlStreamInput)
+
+// WithAddr sets the host:port of the MySQL server.
+func WithAddr(addr string) Option {
+	return func(m *MysqlStreamInput) {
+		m.addr = addr
+	}
+}
+
+// WithAddrs sets candidate host:port addresses of servers tried in order on
+// every connect, in place of the address set with WithAddr.
+func WithAddrs(addrs []string) Option {
+	return func(m *MysqlStreamInput) {
+		m.addrs = addrs
+	}
+}
+
+// WithUser sets the user used for replication.
+func WithUser(user string) Option {
+	return func(m *MysqlStreamInput) {
+		m.user = user
+	}
+}
+
+// WithPassword sets the password used for replication.
+func WithPassword(password string) Option {
+	return func(m *MysqlStreamInput) {
+		m.password = password
+	}
+}
+
+// WithDatabase sets the database whose row changes are streamed.
+func WithDatabase(database string) Option {
+	return func(m *MysqlStreamInput) {
+		m.database = database
+	}
+}
+
+// WithFlavor sets the server flavor, either mysql or mariadb.
+func WithFlavor(flavor string) Option {
+	return func(m *MysqlStreamInput) {
+		m.flavor = flavor
+	}
+}
+
+// WithTables sets the tables to stream, either as bare names in the configured
+// database or qualified as db.table.
+func WithTables(tables ...string) Option {
+	return func(m *MysqlStreamInput) {
+		m.tables = tables
+	}
+}
+
+// WithTLSConfig enables TLS for all connections to the server.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(m *MysqlStreamInput) {
+		m.tlsConfig = tlsConfig
+	}
+}
+
+// WithMode sets what the input reads: snapshot_and_stream, stream_only or
+// snapshot_only. It takes precedence over WithStreamSnapshot.
+func WithMode(mode string) Option {
+	return func(m *MysqlStreamInput) {
+		m.mode = mode
+	}
+}
+
+// WithStreamSnapshot dumps the configured tables before streaming the binlog.
+func WithStreamSnapshot(streamSnapshot bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.streamSnapshot = streamSnapshot
+	}
+}
+
+// WithRowFilters restricts both the snapshot and the stream of individual
+// tables, keyed by bare or db.table qualified name, to rows matching a filter
+// expression.
+func WithRowFilters(filters map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawRowFilters = filters
+	}
+}
+
+// WithKeyTemplates sets the key metadata field of the row changes of
+// individual tables, keyed by bare or db.table qualified name, from a template
+// of their column values such as tenant-{tenant_id}/user-{user_id}.
+func WithKeyTemplates(templates map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawKeyTemplates = templates
+	}
+}
+
+// WithSnapshotWhere restricts the snapshot of individual tables, keyed by bare
+// or db.table qualified name, to rows matching a WHERE clause. Clauses are
+// injected into the snapshot query verbatim.
+func WithSnapshotWhere(where map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotWhere = where
+	}
+}
+
+// WithSnapshotRetries retries a failed table snapshot up to maxRetries times,
+// waiting backoff before the first retry and doubling it for each further one.
+func WithSnapshotRetries(maxRetries int, backoff time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotMaxRetries = maxRetries
+		m.snapshotRetryBackoff = backoff
+	}
+}
+
+// WithOutputFormat selects between one message per row and one message per
+// transaction, and how messages are encoded. Custom formats are added with
+// RegisterMessageEncoder.
+func WithOutputFormat(outputFormat string) Option {
+	return func(m *MysqlStreamInput) {
+		m.outputFormat = outputFormat
+	}
+}
+
+// WithSchemaRegistryURL registers Avro schemas with a Schema Registry and
+// prefixes Avro messages with the registered schema id.
+func WithSchemaRegistryURL(url string) Option {
+	return func(m *MysqlStreamInput) {
+		m.schemaRegistryURL = url
+	}
+}
+
+// WithMaxTransactionRows bounds the number of row changes buffered for a
+// single transaction.
+func WithMaxTransactionRows(maxRows int) Option {
+	return func(m *MysqlStreamInput) {
+		m.maxTransactionRows = maxRows
+	}
+}
+
+// WithOnLargeTransaction sets what happens when a transaction exceeds the
+// maximum number of buffered row changes. It defaults to split.
+func WithOnLargeTransaction(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onLargeTransaction = policy
+	}
+}
+
+// WithBufferSize sets the number of messages buffered ahead of Read.
+func WithBufferSize(size int) Option {
+	return func(m *MysqlStreamInput) {
+		m.bufferSize = size
+	}
+}
+
+// WithOnBufferFull sets what happens when the message buffer is full.
+func WithOnBufferFull(onBufferFull string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onBufferFull = onBufferFull
+	}
+}
+
+// WithSignificantColumns drops UPDATE events on the given tables unless one of
+// the listed columns changed. Tables are named as in WithTables.
+func WithSignificantColumns(significant map[string][]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.significantColumns = significant
+	}
+}
+
+// WithDropNoopUpdates drops UPDATE events whose before and after images are
+// equal.
+func WithDropNoopUpdates(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.dropNoopUpdates = enabled
+	}
+}
+
+// WithEnrichments adds fields of the rows referenced by foreign key columns to
+// row changes, caching up to cacheSize looked up rows.
+func WithEnrichments(enrichments []Enrichment, cacheSize int) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawEnrichments = enrichments
+		m.enrichmentCacheSize = cacheSize
+	}
+}
+
+// WithPositionCheckInterval sets how often the consumed position is compared
+// against the server's binary logs. Zero disables the check.
+func WithPositionCheckInterval(interval time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.positionCheckInterval = interval
+	}
+}
+
+// WithReadyMaxFilesBehind sets how many binlog files behind the end of the
+// binlog the stream may be and still be reported as ready.
+func WithReadyMaxFilesBehind(files int) Option {
+	return func(m *MysqlStreamInput) {
+		m.readyMaxFilesBehind = files
+	}
+}
+
+// WithSemiSync registers the input as a semi-synchronous replica.
+func WithSemiSync(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.semiSync = enabled
+	}
+}
+
+// WithTracing starts a span per message and injects its context into the
+// message metadata.
+func WithTracing(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.tracing = enabled
+	}
+}
+
+// WithLifecycleEvents emits connected and disconnected messages whenever the
+// binlog connection changes state.
+func WithLifecycleEvents(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.emitLifecycleEvents = enabled
+	}
+}
+
+// WithMaxReconnectAttempts sets how many consecutive reconnects may fail
+// before the input shuts down. Zero retries forever.
+func WithMaxReconnectAttempts(attempts int) Option {
+	return func(m *MysqlStreamInput) {
+		m.maxReconnectAttempts = attempts
+	}
+}
+
+// WithReconnectJitter randomizes the delays between reconnect attempts with
+// full or decorrelated jitter from base up to max, or disables them with none.
+func WithReconnectJitter(jitter string, base, max time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.reconnectJitter = jitter
+		m.reconnectBackoffBase = base
+		m.reconnectBackoffMax = max
+	}
+}
+
+// WithTCPKeepAlive sends TCP keepalive probes on the replication connections
+// after they are idle for interval, or disables them.
+func WithTCPKeepAlive(enabled bool, interval time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.tcpKeepAlive = enabled
+		m.tcpKeepAliveInterval = interval
+	}
+}
+
+// WithCredentialsCache reads the user and password from keys of a Benthos
+// cache resource on every connect. Requires WithResources.
+func WithCredentialsCache(cache, userKey, passwordKey string) Option {
+	return func(m *MysqlStreamInput) {
+		m.credentialsCache = cache
+		m.credentialsUserKey = userKey
+		m.credentialsPasswordKey = passwordKey
+	}
+}
+
+// WithResources gives the input access to Benthos resources, and uses their
+// logger and metrics.
+func WithResources(mgr *service.Resources) Option {
+	return func(m *MysqlStreamInput) {
+		m.resources = mgr
+		m.logger = mgr.Logger()
+		m.metrics = newStreamMetrics(mgr.Metrics())
+	}
+}
+
+// WithReadPollTimeout bounds how long Read waits for a change before
+// returning a timeout. Zero waits indefinitely.
+func WithReadPollTimeout(timeout time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.readPollTimeout = timeout
+	}
+}
+
+// WithFillMinimalImages completes MINIMAL row images from an LRU cache of the
+// last full image seen per primary key, holding up to cacheSize rows.
+func WithFillMinimalImages(enabled bool, cacheSize int) Option {
+	return func(m *MysqlStreamInput) {
+		m.fillMinimalImages = enabled
+		m.rowCacheSize = cacheSize
+	}
+}
+
+// WithZeroDateBehavior sets how zero dates are emitted: null, epoch, string
+// or error.
+func WithZeroDateBehavior(behavior string) Option {
+	return func(m *MysqlStreamInput) {
+		m.zeroDateBehavior = behavior
+	}
+}
+
+// WithSpatialFormat sets how spatial columns are emitted: wkt, geojson or
+// base64.
+func WithSpatialFormat(format string) Option {
+	return func(m *MysqlStreamInput) {
+		m.spatialFormat = format
+	}
+}
+
+// WithMaxMessageBytes limits the size of encoded messages, handling larger
+// ones with the given policy: drop, truncate or error.
+func WithMaxMessageBytes(maxBytes int, onOversized string) Option {
+	return func(m *MysqlStreamInput) {
+		m.maxMessageBytes = maxBytes
+		m.onOversized = onOversized
+	}
+}
+
+// WithMessageSize adds the size of the encoded message to each message as the
+// message_size_bytes metadata field.
+func WithMessageSize(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeMessageSize = enabled
+	}
+}
+
+// WithBinlogFormatCheck enables or disables verifying on connect that the
+// server logs in ROW format.
+func WithBinlogFormatCheck(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.checkBinlogFormat = enabled
+	}
+}
+
+// WithUseDecimal emits DECIMAL columns as decimal.Decimal values instead of
+// float64.
+func WithUseDecimal(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.useDecimal = enabled
+	}
+}
+
+// WithParseTime emits DATETIME and TIMESTAMP columns as time.Time values
+// instead of strings.
+func WithParseTime(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.parseTime = enabled
+	}
+}
+
+// WithTemporalOutput sets how DATE, DATETIME, TIMESTAMP, TIME and YEAR
+// columns are emitted: native, rfc3339, unix_millis or mysql_string.
+func WithTemporalOutput(output string) Option {
+	return func(m *MysqlStreamInput) {
+		m.temporalOutput = output
+	}
+}
+
+// WithSnapshotConsistency sets how snapshot reads are isolated:
+// transactional, locking or none.
+func WithSnapshotConsistency(consistency string) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotConsistency = consistency
+	}
+}
+
+// WithUnknownTypeBehavior sets how columns of unrecognized types are emitted:
+// raw_bytes, base64, string, skip or error.
+func WithUnknownTypeBehavior(behavior string) Option {
+	return func(m *MysqlStreamInput) {
+		m.unknownTypeBehavior = behavior
+	}
+}
+
+// WithTruncateEvents emits a truncate message when a streamed table is
+// truncated.
+func WithTruncateEvents(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeTruncate = enabled
+	}
+}
+
+// WithColumnTransforms applies built in transforms, keyed by table.column or
+// db.table.column, to column values.
+func WithColumnTransforms(transforms map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawColumnTransforms = transforms
+	}
+}
+
+// WithRowCounts periodically emits the row count of every streamed table as
+// a rowcount message, counted exactly or estimated per mode. Zero disables
+// the counts.
+func WithRowCounts(interval time.Duration, mode string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rowCountInterval = interval
+		m.rowCountMode = mode
+	}
+}
+
+// WithOffsetMarkers periodically emits the binlog position read so far as an
+// offset message.
+func WithOffsetMarkers(enabled bool, interval time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.emitOffsetMarkers = enabled
+		m.offsetMarkerInterval = interval
+	}
+}
+
+// WithMaxEventsPerSecond limits the rate at which messages are emitted.
+func WithMaxEventsPerSecond(rate int) Option {
+	return func(m *MysqlStreamInput) {
+		m.maxEventsPerSecond = rate
+	}
+}
+
+// WithChangedFields adds the old and new values of changed columns to UPDATE
+// messages.
+func WithChangedFields(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeChangedFields = enabled
+	}
+}
+
+// WithRawValues adds the row values as read from MySQL, before type conversion
+// and column transforms, to row change messages.
+func WithRawValues(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeRaw = enabled
+	}
+}
+
+// WithRawField sets the field of the message body the values added by
+// WithRawValues are set in.
+func WithRawField(field string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawField = field
+	}
+}
+
+// WithTemporalDebug adds the values of temporal columns as read from MySQL to
+// row change messages, next to their interpreted values.
+func WithTemporalDebug(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.temporalDebug = enabled
+	}
+}
+
+// WithSyncRetry configures the replication client's own reconnection of a
+// broken binlog connection, which happens before the input reconnects.
+func WithSyncRetry(disabled bool, attempts int) Option {
+	return func(m *MysqlStreamInput) {
+		m.disableRetrySync = disabled
+		m.syncRetryAttempts = attempts
+	}
+}
+
+// WithSchemaChangeEvents emits a ddl message with the resulting table layout
+// when a streamed table is changed.
+func WithSchemaChangeEvents(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeSchemaChanges = enabled
+	}
+}
+
+// WithParseDDL describes the changes of ddl messages in a structured form.
+func WithParseDDL(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.parseDDL = enabled
+	}
+}
+
+// WithTypeHints adds a per column type hint mapping to row changes, with
+// type_mapping overriding the defaults per MySQL column type.
+func WithTypeHints(enabled bool, mapping map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeTypeHints = enabled
+		m.typeMapping = mapping
+	}
+}
+
+// WithTinyint1AsBool emits TINYINT(1) columns as booleans.
+func WithTinyint1AsBool(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.tinyint1AsBool = enabled
+	}
+}
+
+// WithValuesAsStrings emits every column value as a string, with NULL values
+// emitted as null or, when set, as the null sentinel.
+func WithValuesAsStrings(enabled bool, null string) Option {
+	return func(m *MysqlStreamInput) {
+		m.valuesAsStrings = enabled
+		m.valuesAsStringsNull = null
+	}
+}
+
+// WithConnectionAttributes sets connection attributes on the connections the
+// input opens itself.
+func WithConnectionAttributes(attributes map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.connectionAttributes = attributes
+	}
+}
+
+// WithGeneratedColumns includes or excludes generated columns in messages.
+func WithGeneratedColumns(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeGeneratedColumns = enabled
+	}
+}
+
+// WithResnapshotCache polls a cache key for comma separated lists of tables to
+// snapshot again.
+func WithResnapshotCache(cache, key string, interval time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.resnapshotCache = cache
+		m.resnapshotKey = key
+		m.resnapshotPollInterval = interval
+	}
+}
+
+// WithResnapshotMaxHeldChanges bounds the number of changes to the tables of
+// a resnapshot held back while it runs, after which the stream pauses until
+// it completes.
+func WithResnapshotMaxHeldChanges(maxHeld int) Option {
+	return func(m *MysqlStreamInput) {
+		m.resnapshotMaxHeld = maxHeld
+	}
+}
+
+// WithTablesCache polls a cache key for comma separated lists of tables to
+// stream instead of the configured ones, snapshotting added tables when
+// snapshotAdded is set.
+func WithTablesCache(cache, key string, interval time.Duration, snapshotAdded bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.tablesCache = cache
+		m.tablesKey = key
+		m.tablesPollInterval = interval
+		m.snapshotAddedTables = snapshotAdded
+	}
+}
+
+// WithPositionCache persists the acknowledged binlog position to key in a
+// cache resource and resumes from it on start.
+func WithPositionCache(cache, key string) Option {
+	return func(m *MysqlStreamInput) {
+		m.positionCache = cache
+		m.positionCacheKey = key
+	}
+}
+
+// WithOnServerIDConflict sets whether the input shuts down or reconnects with
+// a random server id when another replica connects with the same one.
+func WithOnServerIDConflict(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onServerIDConflict = policy
+	}
+}
+
+// WithOnMasterSwitch sets whether a stream followed by binlog file positions
+// fails or takes a new snapshot when it reconnects to a different server.
+func WithOnMasterSwitch(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onMasterSwitch = policy
+	}
+}
+
+// WithOnServerMismatch sets whether a persisted position stored for a server
+// other than the connected one fails the connection or is discarded.
+func WithOnServerMismatch(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onServerMismatch = policy
+	}
+}
+
+// WithPositionFlush writes the persisted position every interval and every
+// n acknowledgements. When both are zero it is written on every
+// acknowledgement.
+func WithPositionFlush(interval time.Duration, n int) Option {
+	return func(m *MysqlStreamInput) {
+		m.positionFlushInterval = interval
+		m.positionFlushEveryN = n
+	}
+}
+
+// WithPositionWriteRetries retries a failed write of the persisted position
+// retries times, with an exponential backoff starting at backoff.
+func WithPositionWriteRetries(retries int, backoff time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.positionWriteRetries = retries
+		m.positionWriteBackoff = backoff
+	}
+}
+
+// WithDebugDump appends every raw rows event to path as a JSON line, rotating
+// the file once it exceeds maxBytes.
+func WithDebugDump(path string, maxBytes int64) Option {
+	return func(m *MysqlStreamInput) {
+		m.debugDumpFile = path
+		m.debugDumpMaxBytes = maxBytes
+	}
+}
+
+// WithCanalLogLevel sets the minimum level of replication library log
+// messages forwarded to the input's logger.
+func WithCanalLogLevel(level string) Option {
+	return func(m *MysqlStreamInput) {
+		m.canalLogLevel = level
+	}
+}
+
+// WithEmptyTxMarkers emits a marker for every transaction that produced no
+// row changes for the streamed tables.
+func WithEmptyTxMarkers(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.emitEmptyTxMarkers = enabled
+	}
+}
+
+// WithSchemaFingerprint adds a hash of the table's column names and types to
+// row change messages.
+func WithSchemaFingerprint(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeSchemaFingerprint = enabled
+	}
+}
+
+// WithNullability adds whether each column accepts NULL to the column
+// metadata, schema fingerprints and ddl messages of tables.
+func WithNullability(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeNullability = enabled
+	}
+}
+
+// WithCommitTimestamp adds the commit time of the transaction to row change
+// messages.
+func WithCommitTimestamp(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeCommitTimestamp = enabled
+	}
+}
+
+// WithCommittedOnly buffers row changes until their transaction commits.
+func WithCommittedOnly(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.committedOnly = enabled
+	}
+}
+
+// WithStartPosition sets whether a stream without a position to resume from
+// starts at the latest or earliest available binlog position.
+func WithStartPosition(position string) Option {
+	return func(m *MysqlStreamInput) {
+		m.startPosition = position
+	}
+}
+
+// WithStartGTIDSet starts streaming after the transactions in a GTID set, in
+// the format of the server flavor, instead of from the current position.
+func WithStartGTIDSet(set string) Option {
+	return func(m *MysqlStreamInput) {
+		m.startGTID = set
+	}
+}
+
+// WithSnapshotLockPosition reads the binlog position the stream starts from
+// under a global read lock as the snapshot transaction starts, so that it
+// matches the snapshot exactly.
+func WithSnapshotLockPosition(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotLockPosition = enabled
+	}
+}
+
+// WithSnapshotTimeout aborts a snapshot that takes longer than timeout and
+// shuts the input down.
+func WithSnapshotTimeout(timeout time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotTimeout = timeout
+	}
+}
+
+// WithRepeatedNack sets what happens to a message once it has been nacked
+// maxNacks times in a row: it is retried, stops the input, or is written to
+// deadLetterCache. It only applies to inputs created from a config, as inputs
+// created with NewMysqlStreamInput do not retry nacks themselves.
+func WithRepeatedNack(policy string, maxNacks int, deadLetterCache string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onRepeatedNack = policy
+		m.maxConsecutiveNacks = maxNacks
+		m.deadLetterCache = deadLetterCache
+	}
+}
+
+// WithInlineErrors emits rows that cannot be converted and messages that
+// cannot be encoded as error messages instead of stopping the stream.
+func WithInlineErrors(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.emitErrorsInline = enabled
+	}
+}
+
+// WithDeadLetterRows emits rows that cannot be converted with their raw
+// values, tagged with the dead_letter and dead_letter_error metadata fields,
+// instead of stopping the stream.
+func WithDeadLetterRows(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.deadLetterRows = enabled
+	}
+}
+
+// WithActionNames renames the event of row changes, mapping the insert, update
+// and delete actions to the given names.
+func WithActionNames(names map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.actionNames = names
+	}
+}
+
+// WithOpNames overrides the operation codes emitted per event kind, such as
+// insert, read for snapshot rows or ddl, in every output format.
+func WithOpNames(names map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawOpNames = names
+	}
+}
+
+// WithAuroraMode sets whether the binlog retention of an Aurora cluster is
+// checked on connect, on, off or auto to check when the server is detected to
+// be Aurora, and the retention below which a warning is logged.
+func WithAuroraMode(mode string, minBinlogRetention time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.auroraMode = mode
+		m.auroraMinBinlogRetention = minBinlogRetention
+	}
+}
+
+// WithMessageTTL sets the expires_at metadata field of messages read from the
+// binlog to the time of their event plus ttl.
+func WithMessageTTL(ttl time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.messageTTL = ttl
+	}
+}
+
+// WithHealthEvents emits a health message whenever the replication state
+// returned by Health changes.
+func WithHealthEvents(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.emitHealthEvents = enabled
+	}
+}
+
+// WithMaxColumnBytes cuts string and binary column values of row changes to
+// maxBytes.
+func WithMaxColumnBytes(maxBytes int) Option {
+	return func(m *MysqlStreamInput) {
+		m.maxColumnBytes = maxBytes
+	}
+}
+
+// WithBeforeMode sets the before section of UPDATE messages to the whole
+// before image, the before values of the changed columns, or none.
+func WithBeforeMode(mode string) Option {
+	return func(m *MysqlStreamInput) {
+		m.beforeMode = mode
+	}
+}
+
+// WithMetadataPoolSize bounds the number of pooled control connections used
+// for server checks and position monitoring.
+func WithMetadataPoolSize(size int) Option {
+	return func(m *MysqlStreamInput) {
+		m.metadataPoolSize = size
+	}
+}
+
+// WithOnView sets how configured tables that are views are handled on
+// connect: error, or resolve to their base tables.
+func WithOnView(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onView = policy
+	}
+}
+
+// WithWaitUntilCaughtUp enables the caught_up event, emitted once the stream
+// has read up to the end of the binlog as it was when streaming started.
+func WithWaitUntilCaughtUp(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.waitUntilCaughtUp = enabled
+	}
+}
+
+// WithOnUnresolvedSchema sets how rows of tables whose schema cannot be
+// resolved are handled: error, or positional to emit them with positional
+// column names.
+func WithOnUnresolvedSchema(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onUnresolvedSchema = policy
+	}
+}
+
+// WithTimestampColumns sets, by table, the column whose value is set as the
+// event_time metadata field of row changes.
+func WithTimestampColumns(columns map[string]string) Option {
+	return func(m *MysqlStreamInput) {
+		m.timestampColumns = columns
+	}
+}
+
+// WithNDJSONBatch sets the maximum number of messages packed into a message
+// of ndjson_batch output, and how long a batch waits for more after its first.
+func WithNDJSONBatch(size int, period time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.ndjsonBatchSize = size
+		m.ndjsonBatchPeriod = period
+	}
+}
+
+// WithNDJSONShutdownTimeout sets how long closing the input waits for emitted
+// ndjson_batch batches to be acknowledged.
+func WithNDJSONShutdownTimeout(timeout time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.ndjsonShutdownTimeout = timeout
+	}
+}
+
+// WithSourceInfo sets the source metadata field describing the connector,
+// its version and the version of the server.
+func WithSourceInfo(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeSourceInfo = enabled
+	}
+}
+
+// WithLSN sets the lsn metadata field, an ordering token derived from the
+// global_seq of a message.
+func WithLSN(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeLSN = enabled
+	}
+}
+
+// WithServerIdentity sets the server_uuid, server_id and origin_server_uuid
+// metadata fields identifying the servers a change was read from and written
+// by.
+func WithServerIdentity(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeServerIdentity = enabled
+	}
+}
+
+// WithRowChecksum sets the hash algorithm of the row_checksum metadata field of
+// row changes: none, crc32 or sha256.
+func WithRowChecksum(algorithm string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rowChecksumAlgorithm = algorithm
+	}
+}
+
+// WithSpatialSRID sets how the SRID of spatial values emitted as WKT or
+// GeoJSON is carried: omit, embed or object.
+func WithSpatialSRID(mode string) Option {
+	return func(m *MysqlStreamInput) {
+		m.spatialSRID = mode
+	}
+}
+
+// WithSnapshotThrottle pauses the snapshot while the server has more than
+// maxThreads threads running, checked at most once per interval. A maxThreads
+// of 0 disables throttling.
+func WithSnapshotThrottle(maxThreads int, interval time.Duration) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotMaxSourceThreads = maxThreads
+		m.snapshotThrottleInterval = interval
+	}
+}
+
+// WithSnapshotResume persists the progress of the initial snapshot to the
+// position cache, so that a restarted input continues an interrupted snapshot
+// rather than taking it again.
+func WithSnapshotResume(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.snapshotResume = enabled
+	}
+}
+
+// WithSnapshotSchedule restricts the initial snapshot to daily time windows
+// such as 22:00-06:00, read as wall clock times in an IANA time zone. Outside
+// every window the snapshot pauses until the next one opens.
+func WithSnapshotSchedule(windows []string, timezone string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawSnapshotSchedule = windows
+		m.snapshotScheduleTimezone = timezone
+	}
+}
+
+// WithDeleteMinimalBehavior sets how deletes whose row image holds only the
+// primary key are emitted: flag, pk_only or cache.
+func WithDeleteMinimalBehavior(behavior string) Option {
+	return func(m *MysqlStreamInput) {
+		m.deleteMinimalBehavior = behavior
+	}
+}
+
+// WithSchemaCache stores the resolved schemas of the configured tables under
+// key in the named cache resource, so that reconnects and restarts do not
+// resolve them again while they are unchanged.
+func WithSchemaCache(cache, key string) Option {
+	return func(m *MysqlStreamInput) {
+		m.schemaCache = cache
+		m.schemaCacheKey = key
+	}
+}
+
+// WithColumnMetadata adds the type, length, precision and scale of each column
+// to row changes.
+func WithColumnMetadata(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeColumnMetadata = enabled
+	}
+}
+
+// WithInvisibleColumns emits INVISIBLE columns, generated invisible primary
+// keys included, along with the visible ones.
+func WithInvisibleColumns(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.includeInvisibleColumns = enabled
+	}
+}
+
+// WithOnUnknownAction sets what happens to rows events whose action is not an
+// insert, update or delete: skip or error.
+func WithOnUnknownAction(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onUnknownAction = policy
+	}
+}
+
+// WithThinTables emits only the primary key of the row changes of the given
+// tables.
+func WithThinTables(tables []string) Option {
+	return func(m *MysqlStreamInput) {
+		m.rawThinTables = tables
+	}
+}
+
+// WithNumPartitions sets the number of partitions row changes are hashed
+// into by primary key for the partition metadata field. Zero disables it.
+func WithNumPartitions(partitions int) Option {
+	return func(m *MysqlStreamInput) {
+		m.numPartitions = partitions
+	}
+}
+
+// WithInFlightLimit keeps at most limit positions of unacknowledged messages
+// in memory under position_cache and spills the rest to a file in dir, or in
+// the default temporary directory when dir is empty. Zero keeps every
+// position in memory.
+func WithInFlightLimit(limit int, dir string) Option {
+	return func(m *MysqlStreamInput) {
+		m.inFlightLimit = limit
+		m.inFlightSpillDir = dir
+	}
+}
+
+// WithSplitPKChange emits updates that change the primary key as a delete
+// followed by an insert.
+func WithSplitPKChange(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.splitPKChange = enabled
+	}
+}
+
+// WithSSLMode secures connections according to a MySQL client SSL mode,
+// verifying the server against the certificate authorities in caFile when the
+// mode requires it. It takes precedence over WithTLSConfig.
+func WithSSLMode(mode, caFile string) Option {
+	return func(m *MysqlStreamInput) {
+		m.sslMode = mode
+		m.sslCA = caFile
+	}
+}
+
+// WithEncodeWorkers encodes messages on the given number of goroutines,
+// delivering them in the order they were read.
+func WithEncodeWorkers(workers int) Option {
+	return func(m *MysqlStreamInput) {
+		m.encodeWorkers = workers
+	}
+}
+
+// WithStructuredMessages emits JSON messages as structured values rather than
+// encoded bytes.
+func WithStructuredMessages(enabled bool) Option {
+	return func(m *MysqlStreamInput) {
+		m.structuredMessages = enabled
+	}
+}
+
+// WithOnMissingTable sets how configured tables that do not exist are
+// handled on connect: error, warn or skip.
+func WithOnMissingTable(policy string) Option {
+	return func(m *MysqlStreamInput) {
+		m.onMissingTable = policy
+	}
+}
+
+// WithLogger sets the logger used by the input.
+func WithLogger(logger *service.Logger) Option {
+	return func(m *MysqlStreamInput) {
+		m.logger = logger
+	}
+}
+
+// WithMetrics registers the input's metrics with the given registry.
+func WithMetrics(metrics *service.Metrics) Option {
+	return func(m *MysqlStreamInput) {
+		m.metrics = newStreamMetrics(metrics)
+	}
+}
+
+// NewMysqlStreamInput creates a MySQL CDC input from options, without
+// requiring a Benthos config.
+func NewMysqlStreamInput(opts ...Option) (*MysqlStreamInput, error) {
+	m := &MysqlStreamInput{
+		flavor:                   "mysql",
+		metrics:                  newStreamMetrics(nil),
+		outputFormat:             outputFormatRow,
+		maxTransactionRows:       10000,
+		onLargeTransaction:       largeTransactionSplit,
+		bufferSize:               1024,
+		onBufferFull:             bufferFullBlock,
+		positionCheckInterval:    30 * time.Second,
+		rowCacheSize:             10000,
+		enrichmentCacheSize:      10000,
+		zeroDateBehavior:         zeroDateString,
+		temporalOutput:           temporalNative,
+		reconnectJitter:          reconnectJitterNone,
+		includeInvisibleColumns:  true,
+		onUnknownAction:          unknownActionSkip,
+		unknownActionLogged:      map[string]struct{}{},
+		reconnectBackoffBase:     time.Second,
+		reconnectBackoffMax:      30 * time.Second,
+		tcpKeepAlive:             true,
+		tcpKeepAliveInterval:     30 * time.Second,
+		spatialFormat:            spatialFormatWKT,
+		spatialSRID:              spatialSRIDOmit,
+		unknownTypeBehavior:      unknownTypeRawBytes,
+		snapshotMaxRetries:       3,
+		snapshotRetryBackoff:     time.Second,
+		snapshotConsistency:      snapshotConsistencyTransactional,
+		onOversized:              oversizedDrop,
+		checkBinlogFormat:        true,
+		onMissingTable:           missingTableError,
+		onView:                   onViewError,
+		deleteMinimalBehavior:    deleteMinimalFlag,
+		snapshotThrottleInterval: time.Second,
+		snapshotScheduleTimezone: "UTC",
+		rowChecksumAlgorithm:     rowChecksumNone,
+		ndjsonBatchSize:          100,
+		ndjsonBatchPeriod:        time.Second,
+		ndjsonShutdownTimeout:    5 * time.Second,
+		onUnresolvedSchema:       unresolvedSchemaError,
+		canalLogLevel:            canalLogLevelInfo,
+		includeGeneratedColumns:  true,
+		offsetMarkerInterval:     10 * time.Second,
+		rowCountMode:             rowCountExact,
+		resnapshotKey:            "resnapshot",
+		resnapshotPollInterval:   5 * time.Second,
+		resnapshotMaxHeld:        10000,
+		rawField:                 "_raw",
+		positionCacheKey:         "position",
+		positionFlushInterval:    time.Second,
+		positionWriteRetries:     3,
+		positionWriteBackoff:     100 * time.Millisecond,
+		onServerMismatch:         serverMismatchError,
+		onServerIDConflict:       serverIDConflictError,
+		onMasterSwitch:           masterSwitchError,
+		replicaServerID:          defaultServerID,
+		startPosition:            startPositionLatest,
+		onRepeatedNack:           repeatedNackRetry,
+		maxConsecutiveNacks:      10,
+		auroraMode:               auroraModeAuto,
+		beforeMode:               beforeModeChanged,
+		metadataPoolSize:         2,
+		auroraMinBinlogRetention: 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.addr == "" && len(m.addrs) == 0 {
+		return nil, errors.New("either addr or addrs must be set")
+	}
+	if m.addr == "" {
+		m.addr = m.addrs[0]
+	}
+
+	switch m.mode {
+	case "":
+	case modeSnapshotAndStream:
+		m.streamSnapshot = true
+	case modeStreamOnly:
+		m.streamSnapshot = false
+	case modeSnapshotOnly:
+		if m.positionCache != "" || m.startGTID != "" {
+			return nil, errors.New("mode snapshot_only cannot be combined with position_cache or start_gtid_set, which skip the snapshot")
+		}
+		m.streamSnapshot, m.snapshotOnly = true, true
+	default:
+		return nil, fmt.Errorf("invalid mode: %s", m.mode)
+	}
+
+	switch m.startPosition {
+	case startPositionLatest:
+	case startPositionEarliest:
+		if m.streamSnapshot {
+			return nil, errors.New("start_position earliest cannot be combined with a snapshot")
+		}
+	default:
+		return nil, fmt.Errorf("invalid start_position: %s", m.startPosition)
+	}
+
+	if m.metadataPoolSize < 1 {
+		return nil, fmt.Errorf("invalid metadata_pool_size: %d", m.metadataPoolSize)
+	}
+
+	if m.maxColumnBytes < 0 {
+		return nil, fmt.Errorf("invalid max_column_bytes: %d", m.maxColumnBytes)
+	}
+
+	if m.messageTTL < 0 {
+		return nil, fmt.Errorf("invalid message_ttl: %v", m.messageTTL)
+	}
+
+	if m.snapshotTimeout < 0 {
+		return nil, fmt.Errorf("invalid snapshot_timeout: %v", m.snapshotTimeout)
+	}
+
+	if m.startGTID != "" {
+		set, err := mysql.ParseGTIDSet(m.flavor, m.startGTID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_gtid_set for flavor %s: %w", m.flavor, err)
+		}
+		m.startGTIDSet = set
+	}
+
+	switch m.outputFormat {
+	case outputFormatRow, outputFormatTransaction:
+		m.encoder = jsonEncoder{}
+	case outputFormatAvro:
+		avro := newAvroEncoder(m.schemaRegistryURL)
+		avro.tinyint1AsBool = m.tinyint1AsBool
+		m.encoder = avro
+	case outputFormatDebezium:
+		m.encoder = debeziumEncoder{}
+		m.includeBeforeImage = true
+	case outputFormatCloudEvents:
+		m.encoder = cloudEventsEncoder{}
+	case outputFormatNDJSONBatch:
+		if m.ndjsonBatchSize <= 0 {
+			return nil, fmt.Errorf("invalid ndjson_batch_size: %d", m.ndjsonBatchSize)
+		}
+		if m.ndjsonBatchPeriod <= 0 {
+			return nil, fmt.Errorf("invalid ndjson_batch_period: %v", m.ndjsonBatchPeriod)
+		}
+		if m.ndjsonShutdownTimeout < 0 {
+			return nil, fmt.Errorf("invalid ndjson_shutdown_timeout: %v", m.ndjsonShutdownTimeout)
+		}
+		m.encoder = jsonEncoder{}
+		// Lines are encoded to be packed into the batch.
+		m.structuredMessages = false
+	default:
+		encoder, ok := customEncoder(m.outputFormat)
+		if !ok {
+			return nil, fmt.Errorf("invalid output format %q, expected one of %s", m.outputFormat, outputFormatNames())
+		}
+		m.encoder = encoder
+	}
+
+	for action, name := range m.actionNames {
+		switch action {
+		case canal.InsertAction, canal.UpdateAction, canal.DeleteAction:
+		default:
+			return nil, fmt.Errorf("action_names: unknown action %s", action)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("action_names: empty name for action %s", action)
+		}
+	}
+	opNames, err := parseOpNames(m.rawOpNames)
+	if err != nil {
+		return nil, err
+	}
+	m.opNames = opNames
+
+	switch m.onBufferFull {
+	case bufferFullBlock, bufferFullError:
+	case bufferFullDropOldest:
+		if m.bufferSize < 1 {
+			return nil, errors.New("on_buffer_full: drop_oldest requires a buffer_size of at least 1")
+		}
+	default:
+		return nil, fmt.Errorf("invalid on_buffer_full policy: %s", m.onBufferFull)
+	}
+	if m.encodeWorkers < 0 {
+		return nil, errors.New("encode_workers must not be negative")
+	}
+
+	if err := validateTables(m.database, m.tables); err != nil {
+		return nil, err
+	}
+	m.tableRefs = parseTableRefs(m.database, m.tables)
+	m.tableSet = make(map[string]struct{}, len(m.tableRefs))
+	for _, ref := range m.tableRefs {
+		m.tableSet[ref.key()] = struct{}{}
+	}
+
+	snapshotWhere := make(map[string]string, len(m.snapshotWhere))
+	for table, where := range m.snapshotWhere {
+		if strings.TrimSpace(where) == "" {
+			return nil, fmt.Errorf("snapshot_where: empty clause for table %s", table)
+		}
+		snapshotWhere[parseTableRefs(m.database, []string{table})[0].key()] = where
+	}
+	if len(snapshotWhere) > 0 {
+		m.logger.Warn("snapshot_where clauses are injected verbatim into snapshot queries")
+	}
+	m.snapshotWhere = snapshotWhere
+
+	m.rowFilters = make(map[string]rowFilter, len(m.rawRowFilters))
+	for table, expr := range m.rawRowFilters {
+		filter, err := parseRowFilter(expr)
+		if err != nil {
+			return nil, fmt.Errorf("row_filters: invalid filter for table %s: %w", table, err)
+		}
+		m.rowFilters[parseTableRefs(m.database, []string{table})[0].key()] = filter
+	}
+
+	significantColumns := make(map[string][]string, len(m.significantColumns))
+	for table, columns := range m.significantColumns {
+		key := parseTableRefs(m.database, []string{table})[0].key()
+		significantColumns[key] = append(significantColumns[key], columns...)
+	}
+	m.significantColumns = significantColumns
+
+	m.keyTemplates = make(map[string]keyTemplate, len(m.rawKeyTemplates))
+	for table, template := range m.rawKeyTemplates {
+		t, err := parseKeyTemplate(template)
+		if err != nil {
+			return nil, fmt.Errorf("key_templates: invalid template for table %s: %w", table, err)
+		}
+		m.keyTemplates[parseTableRefs(m.database, []string{table})[0].key()] = t
+	}
+
+	timestampColumns := make(map[string]string, len(m.timestampColumns))
+	for table, column := range m.timestampColumns {
+		if strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("timestamp_column: empty column for table %s", table)
+		}
+		timestampColumns[parseTableRefs(m.database, []string{table})[0].key()] = column
+	}
+	m.timestampColumns = timestampColumns
+
+	m.thinTables = make(map[string]struct{}, len(m.rawThinTables))
+	for _, ref := range parseTableRefs(m.database, m.rawThinTables) {
+		m.thinTables[ref.key()] = struct{}{}
+	}
+
+	columnTransforms, err := parseColumnTransforms(m.database, m.rawColumnTransforms)
+	if err != nil {
+		return nil, err
+	}
+	m.columnTransforms = columnTransforms
+
+	switch m.zeroDateBehavior {
+	case zeroDateNull, zeroDateEpoch, zeroDateString, zeroDateError:
+	default:
+		return nil, fmt.Errorf("invalid zero_date_behavior: %s", m.zeroDateBehavior)
+	}
+
+	switch m.reconnectJitter {
+	case reconnectJitterNone:
+	case reconnectJitterFull, reconnectJitterDecorrelated:
+		if m.reconnectBackoffBase <= 0 {
+			return nil, fmt.Errorf("invalid reconnect_backoff: %v", m.reconnectBackoffBase)
+		}
+		if m.reconnectBackoffMax < m.reconnectBackoffBase {
+			return nil, fmt.Errorf("invalid reconnect_max_backoff: %v", m.reconnectBackoffMax)
+		}
+	default:
+		return nil, fmt.Errorf("invalid reconnect_jitter: %s", m.reconnectJitter)
+	}
+	m.reconnectBackoff = newReconnectBackoff(m.reconnectJitter, m.reconnectBackoffBase, m.reconnectBackoffMax)
+	if m.readyMaxFilesBehind < 0 {
+		return nil, fmt.Errorf("invalid ready_max_files_behind: %d", m.readyMaxFilesBehind)
+	}
+	if m.tcpKeepAlive && m.tcpKeepAliveInterval <= 0 {
+		return nil, fmt.Errorf("invalid tcp_keepalive_interval: %v", m.tcpKeepAliveInterval)
+	}
+	switch m.temporalOutput {
+	case temporalNative:
+	case temporalRFC3339, temporalUnixMillis, temporalMySQLString:
+		if m.parseTime {
+			return nil, fmt.Errorf("parse_time cannot be combined with temporal_output %s", m.temporalOutput)
+		}
+	default:
+		return nil, fmt.Errorf("invalid temporal_output: %s", m.temporalOutput)
+	}
+	switch m.spatialFormat {
+	case spatialFormatWKT, spatialFormatGeoJSON, spatialFormatBase64:
+	default:
+		return nil, fmt.Errorf("invalid spatial_format: %s", m.spatialFormat)
+	}
+	switch m.spatialSRID {
+	case spatialSRIDOmit, spatialSRIDEmbed, spatialSRIDObject:
+	default:
+		return nil, fmt.Errorf("invalid spatial_srid: %s", m.spatialSRID)
+	}
+
+	if _, ok := canalLogLevels[m.canalLogLevel]; !ok {
+		return nil, fmt.Errorf("invalid canal_log_level: %s", m.canalLogLevel)
+	}
+
+	switch m.onServerMismatch {
+	case serverMismatchError, serverMismatchReset:
+	default:
+		return nil, fmt.Errorf("invalid on_server_mismatch policy: %s", m.onServerMismatch)
+	}
+	switch m.rowCountMode {
+	case rowCountExact, rowCountApproximate:
+	default:
+		return nil, fmt.Errorf("invalid row_count_mode: %s", m.rowCountMode)
+	}
+	switch m.onServerIDConflict {
+	case serverIDConflictError, serverIDConflictRandomizeRetry:
+	default:
+		return nil, fmt.Errorf("invalid on_server_id_conflict policy: %s", m.onServerIDConflict)
+	}
+	switch m.onMasterSwitch {
+	case masterSwitchError, masterSwitchResnapshot:
+	default:
+		return nil, fmt.Errorf("invalid on_master_switch policy: %s", m.onMasterSwitch)
+	}
+
+	switch m.onRepeatedNack {
+	case repeatedNackRetry:
+	case repeatedNackError, repeatedNackDeadLetter:
+		if m.maxConsecutiveNacks < 1 {
+			return nil, fmt.Errorf("invalid max_consecutive_nacks: %d", m.maxConsecutiveNacks)
+		}
+		if m.onRepeatedNack == repeatedNackDeadLetter {
+			if m.deadLetterCache == "" {
+				return nil, errors.New("on_repeated_nack: dead_letter requires a dead_letter_cache")
+			}
+			if m.resources == nil {
+				return nil, errors.New("dead_letter_cache requires access to Benthos resources")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid on_repeated_nack policy: %s", m.onRepeatedNack)
+	}
+
+	switch m.beforeMode {
+	case beforeModeFull, beforeModeChanged, beforeModeNone:
+	default:
+		return nil, fmt.Errorf("invalid before_mode: %s", m.beforeMode)
+	}
+
+	switch m.auroraMode {
+	case auroraModeAuto, auroraModeOn, auroraModeOff:
+	default:
+		return nil, fmt.Errorf("invalid aurora_mode: %s", m.auroraMode)
+	}
+
+	if m.valuesAsStrings && m.outputFormat == outputFormatAvro {
+		return nil, errors.New("values_as_strings cannot be used with avro output, whose schemas follow the column types")
+	}
+
+	switch m.onUnresolvedSchema {
+	case unresolvedSchemaError:
+	case unresolvedSchemaPositional:
+		if m.outputFormat == outputFormatAvro {
+			return nil, errors.New("on_unresolved_schema positional cannot be used with avro output, which requires the schema of every row")
+		}
+	default:
+		return nil, fmt.Errorf("invalid on_unresolved_schema policy: %s", m.onUnresolvedSchema)
+	}
+	switch m.rowChecksumAlgorithm {
+	case rowChecksumNone, rowChecksumCRC32, rowChecksumSHA256:
+	default:
+		return nil, fmt.Errorf("invalid row_checksum: %s", m.rowChecksumAlgorithm)
+	}
+	switch m.onUnknownAction {
+	case unknownActionSkip, unknownActionError:
+	default:
+		return nil, fmt.Errorf("invalid on_unknown_action policy: %s", m.onUnknownAction)
+	}
+	switch m.deleteMinimalBehavior {
+	case deleteMinimalFlag, deleteMinimalPKOnly, deleteMinimalCache:
+	default:
+		return nil, fmt.Errorf("invalid delete_minimal_behavior: %s", m.deleteMinimalBehavior)
+	}
+	switch m.onView {
+	case onViewError, onViewResolve:
+	default:
+		return nil, fmt.Errorf("invalid on_view policy: %s", m.onView)
+	}
+	switch m.onMissingTable {
+	case missingTableError, missingTableWarn, missingTableSkip:
+	default:
+		return nil, fmt.Errorf("invalid on_missing_table policy: %s", m.onMissingTable)
+	}
+
+	switch m.unknownTypeBehavior {
+	case unknownTypeRawBytes, unknownTypeBase64, unknownTypeString, unknownTypeSkip, unknownTypeError:
+	default:
+		return nil, fmt.Errorf("invalid unknown_type_behavior: %s", m.unknownTypeBehavior)
+	}
+	m.unknownTypesLogged = map[string]struct{}{}
+
+	switch m.snapshotConsistency {
+	case snapshotConsistencyTransactional, snapshotConsistencyLocking:
+	case snapshotConsistencyNone:
+		if m.streamSnapshot {
+			m.logger.Warn("snapshot_consistency none reads each table at a different point in time, so snapshot rows may be inconsistent with each other and with the binlog start position")
+		}
+	default:
+		return nil, fmt.Errorf("invalid snapshot_consistency: %s", m.snapshotConsistency)
+	}
+	if m.snapshotResume && (m.positionCache == "" || !m.streamSnapshot) {
+		return nil, errors.New("snapshot_resume requires position_cache and a snapshot")
+	}
+	if len(m.rawSnapshotSchedule) > 0 {
+		if !m.snapshotResume {
+			return nil, errors.New("snapshot_schedule requires snapshot_resume")
+		}
+		if m.snapshotSchedule, err = parseSnapshotSchedule(m.rawSnapshotSchedule, m.snapshotScheduleTimezone); err != nil {
+			return nil, err
+		}
+	}
+	if m.snapshotMaxSourceThreads < 0 {
+		return nil, fmt.Errorf("invalid snapshot_max_source_threads: %d", m.snapshotMaxSourceThreads)
+	}
+	if m.snapshotMaxSourceThreads > 0 && m.snapshotThrottleInterval <= 0 {
+		return nil, fmt.Errorf("invalid snapshot_throttle_interval: %v", m.snapshotThrottleInterval)
+	}
+	if m.snapshotLockPosition && m.snapshotConsistency != snapshotConsistencyTransactional {
+		return nil, errors.New("snapshot_lock_position requires snapshot_consistency transactional")
+	}
+
+	switch m.onOversized {
+	case oversizedDrop, oversizedTruncate, oversizedError:
+	default:
+		return nil, fmt.Errorf("invalid on_oversized policy: %s", m.onOversized)
+	}
+
+	if m.fillMinimalImages || m.deleteMinimalBehavior == deleteMinimalCache {
+		if m.rowCacheSize < 1 {
+			return nil, errors.New("fill_minimal_images and delete_minimal_behavior cache require a row_cache_size of at least 1")
+		}
+		m.rowCache = newLRUCache[[]any](m.rowCacheSize)
+	}
+
+	if m.numPartitions < 0 {
+		return nil, fmt.Errorf("invalid num_partitions: %d", m.numPartitions)
+	}
+
+	if m.maxEventsPerSecond < 0 {
+		return nil, fmt.Errorf("invalid max_events_per_second: %d", m.maxEventsPerSecond)
+	}
+	if m.maxEventsPerSecond > 0 {
+		m.limiter = newRateLimiter(m.maxEventsPerSecond)
+	}
+
+	if m.parseDDL && !m.includeSchemaChanges {
+		return nil, errors.New("parse_ddl requires include_schema_changes")
+	}
+	if m.committedOnly {
+		m.txParser = parser.New()
+	}
+	if m.includeTruncate || m.includeSchemaChanges {
+		m.ddlParser = parser.New()
+		m.temporaryTables = temporaryTables{}
+	}
+
+	if m.includeTypeHints {
+		typeMapping := make(map[string]string, len(m.typeMapping))
+		for columnType, hint := range m.typeMapping {
+			typeMapping[strings.ToLower(columnType)] = hint
+		}
+		m.typeMapping = typeMapping
+		m.typeHintCache = newLRUCache[typeHints](typeHintCacheSize)
+	}
+	m.invisibleCache = newLRUCache[invisibleColumns](typeHintCacheSize)
+	m.gipkChecked = newLRUCache[*schema.Table](typeHintCacheSize)
+	if len(m.rawEnrichments) > 0 {
+		if m.enrichmentCacheSize < 1 {
+			return nil, errors.New("enrichments require an enrichment_cache_size of at least 1")
+		}
+		enrichments, err := validateEnrichments(m.rawEnrichments)
+		if err != nil {
+			return nil, err
+		}
+		m.enrichments = enrichments
+		m.enrichmentCache = newLRUCache[relatedRow](m.enrichmentCacheSize)
+		m.enrichmentTargets = map[string]map[string]Enrichment{}
+	}
+	if len(m.significantColumns) > 0 {
+		m.columnIndexCache = newLRUCache[columnIndexes](typeHintCacheSize)
+	}
+	if m.includeColumnMetadata {
+		m.columnMetadataCache = newLRUCache[columnMetadataJSON](typeHintCacheSize)
+	}
+	if m.includeSchemaFingerprint {
+		m.fingerprintCache = newLRUCache[schemaFingerprint](typeHintCacheSize)
+	}
+	if m.includeNullability {
+		m.nullabilityCache = newLRUCache[tableNullability](typeHintCacheSize)
+	}
+
+	if m.resnapshotCache != "" {
+		if m.resources == nil {
+			return nil, errors.New("resnapshot_cache requires access to Benthos resources")
+		}
+		if m.resnapshotPollInterval <= 0 {
+			return nil, fmt.Errorf("invalid resnapshot_poll_interval: %v", m.resnapshotPollInterval)
+		}
+	}
+	if m.includeRaw && m.rawField == "" {
+		return nil, errors.New("include_raw requires a raw_field")
+	}
+	if m.resnapshotMaxHeld < 0 {
+		return nil, fmt.Errorf("invalid resnapshot_max_held_changes: %d", m.resnapshotMaxHeld)
+	}
+	m.resnapshotRequests = make(chan []tableRef, 16)
+
+	if m.tablesCache != "" {
+		if m.resources == nil {
+			return nil, errors.New("tables_cache requires access to Benthos resources")
+		}
+		if m.tablesPollInterval <= 0 {
+			return nil, fmt.Errorf("invalid tables_poll_interval: %v", m.tablesPollInterval)
+		}
+	}
+	m.tableUpdates = make(chan []tableRef, 1)
+
+	if m.positionCache != "" {
+		if m.resources == nil {
+			return nil, errors.New("position_cache requires access to Benthos resources")
+		}
+		if m.positionFlushInterval < 0 {
+			return nil, fmt.Errorf("invalid position_flush_interval: %v", m.positionFlushInterval)
+		}
+		if m.positionFlushEveryN < 0 {
+			return nil, fmt.Errorf("invalid position_flush_every_n: %d", m.positionFlushEveryN)
+		}
+		flushEvery := m.positionFlushEveryN
+		if flushEvery == 0 && m.positionFlushInterval == 0 {
+			flushEvery = 1
+		}
+		if m.positionWriteRetries < 0 {
+			return nil, fmt.Errorf("invalid position_write_retries: %d", m.positionWriteRetries)
+		}
+		if m.positionWriteBackoff < 0 {
+			return nil, fmt.Errorf("invalid position_write_backoff: %v", m.positionWriteBackoff)
+		}
+		m.positions = newPositionStore(m.resources, m.positionCache, m.positionCacheKey, flushEvery, m.logger)
+		m.positions.withWriteRetries(m.positionWriteRetries, m.positionWriteBackoff)
+		if m.inFlightLimit < 0 {
+			return nil, fmt.Errorf("invalid in_flight_limit: %d", m.inFlightLimit)
+		}
+		if m.inFlightLimit > 0 {
+			if err := m.positions.withSpill(m.inFlightLimit, m.inFlightSpillDir, m.metrics); err != nil {
+				return nil, fmt.Errorf("failed to create in flight spill file: %w", err)
+			}
+		}
+	}
+
+	if m.sslMode != "" {
+		m.sslMode = strings.ToUpper(m.sslMode)
+		tlsConfig, err := sslTLSConfig(m.sslMode, m.addr, m.sslCA)
+		if err != nil {
+			return nil, err
+		}
+		m.tlsConfig = tlsConfig
+		m.preferredTLSConfig = tlsConfig
+	}
+
+	if m.debugDumpFile != "" {
+		if m.debugDumpMaxBytes < 0 {
+			return nil, fmt.Errorf("invalid debug_dump_max_bytes: %d", m.debugDumpMaxBytes)
+		}
+		if m.debugDump, err = newDebugDump(m.debugDumpFile, m.debugDumpMaxBytes, m.logger); err != nil {
+			return nil, fmt.Errorf("opening debug_dump_file: %w", err)
+		}
+	}
+
+	m.active.tables = map[string]struct{}{}
+	m.stream = make(chan StreamMessage, m.bufferSize)
+	m.readerErr = make(chan error, 1)
+	m.closed = make(chan struct{})
+	return m, nil
+}
@@ -0,0 +1,37 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// globalSeq derives a sequence number from the binlog coordinates of a
+// message. The numeric suffix of the binlog file fills the upper 32 bits and a
+// position within the file the lower 32 bits. Events are ordered by their end
+// position, and the rows of an event with n rows are assigned the n positions
+// leading up to it: every row occupies at least one byte of its event, so these
+// never reach back to the end of the previous event. The sequence therefore
+// strictly increases in commit order across all tables, and since it only
+// depends on the binlog it is the same for a change read again after a
+// reconnect or restart. It is zero when the coordinates are unknown.
+func globalSeq(pos mysql.Position, row, rows int) uint64 {
+	i := strings.LastIndexByte(pos.Name, '.')
+	if i < 0 || pos.Pos == 0 {
+		return 0
+	}
+	file, err := strconv.ParseUint(pos.Name[i+1:], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return file<<32 | uint64(pos.Pos-uint32(rows-1-row))
+}
+
+// lsn formats a global_seq as an ordering token in the style of a PostgreSQL
+// LSN, with both halves zero padded to a fixed width so that tokens sort as
+// strings in the order of their sequence numbers.
+func lsn(seq uint64) string {
+	return fmt.Sprintf("%08X/%08X", seq>>32, uint32(seq))
+}
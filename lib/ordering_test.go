@@ -0,0 +1,104 @@
+package mongodb_stream_benthos
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestGlobalSeq(t *testing.T) {
+	tests := []struct {
+		name      string
+		pos       mysql.Position
+		row, rows int
+		want      uint64
+	}{
+		{name: "single row", pos: mysql.Position{Name: "mysql-bin.000003", Pos: 900}, row: 0, rows: 1, want: 3<<32 | 900},
+		{name: "first of three rows", pos: mysql.Position{Name: "mysql-bin.000003", Pos: 900}, row: 0, rows: 3, want: 3<<32 | 898},
+		{name: "last of three rows", pos: mysql.Position{Name: "mysql-bin.000003", Pos: 900}, row: 2, rows: 3, want: 3<<32 | 900},
+		{name: "dotted base name", pos: mysql.Position{Name: "db.host-bin.000012", Pos: 4}, row: 0, rows: 1, want: 12<<32 | 4},
+		{name: "unknown position", pos: mysql.Position{Name: "mysql-bin.000003"}, row: 0, rows: 1},
+		{name: "no file", pos: mysql.Position{Pos: 900}, row: 0, rows: 1},
+		{name: "non numeric suffix", pos: mysql.Position{Name: "mysql-bin.index", Pos: 900}, row: 0, rows: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := globalSeq(tt.pos, tt.row, tt.rows); got != tt.want {
+				t.Errorf("globalSeq = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLSN(t *testing.T) {
+	tests := []struct {
+		seq  uint64
+		want string
+	}{
+		{seq: 3<<32 | 0x1A2F0, want: "00000003/0001A2F0"},
+		{seq: 1<<32 | 4, want: "00000001/00000004"},
+		{seq: 0xFFFFFFFF<<32 | 0xFFFFFFFF, want: "FFFFFFFF/FFFFFFFF"},
+	}
+	for _, tt := range tests {
+		if got := lsn(tt.seq); got != tt.want {
+			t.Errorf("lsn(%d) = %s, want %s", tt.seq, got, tt.want)
+		}
+	}
+
+	// Tokens sort as strings in commit order, across binlog files whose
+	// suffixes and positions differ in length.
+	positions := []struct {
+		pos       mysql.Position
+		row, rows int
+	}{
+		{pos: mysql.Position{Name: "mysql-bin.000009", Pos: 4}},
+		{pos: mysql.Position{Name: "mysql-bin.000009", Pos: 900}, row: 0, rows: 2},
+		{pos: mysql.Position{Name: "mysql-bin.000009", Pos: 900}, row: 1, rows: 2},
+		{pos: mysql.Position{Name: "mysql-bin.000009", Pos: 65536}},
+		{pos: mysql.Position{Name: "mysql-bin.000010", Pos: 4}},
+		{pos: mysql.Position{Name: "mysql-bin.000010", Pos: 4000000000}},
+		{pos: mysql.Position{Name: "mysql-bin.100000", Pos: 120}},
+	}
+	var tokens []string
+	for _, p := range positions {
+		tokens = append(tokens, lsn(globalSeq(p.pos, p.row, p.rows)))
+	}
+	if !sort.StringsAreSorted(tokens) {
+		t.Errorf("tokens %v do not sort in position order", tokens)
+	}
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i] == tokens[i-1] {
+			t.Errorf("positions %d and %d share the token %s", i-1, i, tokens[i])
+		}
+	}
+}
+
+func TestLSNMetadata(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithLSN(true), WithLifecycleEvents(true))
+	m.binlogFile = "mysql-bin.000003"
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(1)}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 0x1A2F0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.emitLifecycle(connectedEvent, mysql.Position{})
+
+	msg, _ := readStructured(t, m)
+	if got, _ := msg.MetaGet("lsn"); got != "00000003/0001A2F0" {
+		t.Errorf("lsn = %q, want 00000003/0001A2F0", got)
+	}
+	// Messages without binlog coordinates carry no token.
+	msg, _ = readStructured(t, m)
+	if got, ok := msg.MetaGet("lsn"); ok {
+		t.Errorf("lsn = %q on a connected event, want none", got)
+	}
+}
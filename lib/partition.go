@@ -0,0 +1,29 @@
+package mongodb_stream_benthos
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// partition returns the partition of a row change for num_partitions, the
+// FNV-1a hash of its schema qualified table and primary key values modulo
+// the number of partitions, so that every change of a row maps to the same
+// partition across restarts. Rows of tables without a primary key are
+// partitioned by their table alone. It returns an empty string when
+// partitioning is disabled.
+func (m *MysqlStreamInput) partition(table *schema.Table, row []any) string {
+	if m.numPartitions < 1 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(table.Schema + "." + table.Name))
+	for _, i := range table.PKColumns {
+		if i < len(row) {
+			h.Write([]byte{0})
+			h.Write([]byte(keyValue(row[i])))
+		}
+	}
+	return strconv.FormatUint(uint64(h.Sum32()%uint32(m.numPartitions)), 10)
+}
@@ -0,0 +1,44 @@
+package mongodb_stream_benthos
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestPartition(t *testing.T) {
+	orders := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "status"}}, PKColumns: []int{0}}
+	logs := &schema.Table{Schema: "shop", Name: "logs", Columns: []schema.TableColumn{{Name: "line"}}}
+
+	tests := []struct {
+		name       string
+		partitions int
+		table      *schema.Table
+		row        []any
+		want       string
+	}{
+		{name: "disabled", table: orders, row: []any{int64(1), "new"}, want: ""},
+		// Partitions are pinned, as they must not change across restarts
+		// or releases.
+		{name: "row", partitions: 16, table: orders, row: []any{int64(1), "new"}, want: "15"},
+		{name: "other columns changed", partitions: 16, table: orders, row: []any{int64(1), "paid"}, want: "15"},
+		{name: "other row", partitions: 16, table: orders, row: []any{int64(2), "new"}, want: "2"},
+		{name: "no primary key", partitions: 16, table: logs, row: []any{"started"}, want: "6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithNumPartitions(tt.partitions))
+			got := m.partition(tt.table, tt.row)
+			if got != tt.want {
+				t.Errorf("partition = %q, want %q", got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			if n, err := strconv.Atoi(got); err != nil || n < 0 || n >= tt.partitions {
+				t.Errorf("partition %q out of range for %d partitions", got, tt.partitions)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// pkChanged reports whether an update changed any primary key column. Key
+// columns that absent tells either image left out are not compared, as the
+// after image of an update written with binlog_row_image=MINIMAL leaves out
+// a key that did not change.
+func pkChanged(table *schema.Table, before, after []any, absent absentColumns, i int) bool {
+	beforeAbsent, _ := absent.of(i - 1)
+	afterAbsent, _ := absent.of(i)
+	left := make(map[int]bool, len(beforeAbsent)+len(afterAbsent))
+	for _, col := range beforeAbsent {
+		left[col] = true
+	}
+	for _, col := range afterAbsent {
+		left[col] = true
+	}
+	for _, col := range table.PKColumns {
+		if col >= len(before) || col >= len(after) || left[col] {
+			continue
+		}
+		if !reflect.DeepEqual(before[col], after[col]) {
+			return true
+		}
+	}
+	return false
+}
+
+// emitPKChange emits an update at index i of e that moved a row to a new
+// primary key as a delete of the old key followed by an insert of the new
+// one. Every updated row occupies at least two bytes of its rows event, one
+// per image, so the rows of the event are given two slots of the global
+// sequence each, of which unsplit updates use the second.
+func (m *MysqlStreamInput) emitPKChange(e *canal.RowsEvent, before, after map[string]any, i, row, rows int, position mysql.Position) error {
+	for n, half := range []struct {
+		action string
+		data   map[string]any
+		image  []any
+	}{
+		{canal.DeleteAction, before, e.Rows[i-1]},
+		{canal.InsertAction, after, e.Rows[i]},
+	} {
+		split := *e
+		split.Action = half.action
+
+		var seq uint64
+		if !isCompressed(e.Header) {
+			seq = globalSeq(position, 2*row+n, 2*rows)
+		}
+
+		var raw, temporalRaw map[string]any
+		if m.includeRaw {
+			raw = m.rawData(e.Table, half.image)
+		}
+		if m.temporalDebug {
+			temporalRaw = m.temporalRawData(e.Table, half.image)
+		}
+
+		key, err := m.messageKey(e.Table, half.image)
+		if err != nil {
+			return m.rowError(e, i, position, err)
+		}
+
+		truncated := m.truncateColumns(half.data, nil)
+
+		err = m.emit(&split, StreamMessage{
+			Table:          e.Table.Name,
+			Event:          half.action,
+			Data:           half.data,
+			Raw:            raw,
+			rawField:       m.rawField,
+			TemporalRaw:    temporalRaw,
+			table:          e.Table,
+			traceparent:    m.upstreamTraceparent,
+			idempotencyKey: m.idempotencyKey(&split, half.image, row),
+			position:       position,
+			globalSeq:      seq,
+			truncated:      truncated,
+			partition:      m.partition(e.Table, half.image),
+			key:            key,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestSplitPKChange(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "qty"}}, PKColumns: []int{0}}
+
+	tests := []struct {
+		name string
+		rows [][]any
+		want []struct {
+			event string
+			id    any
+		}
+	}{
+		{
+			name: "full image key change",
+			rows: [][]any{{int64(5), int64(1)}, {int64(6), int64(1)}},
+			want: []struct {
+				event string
+				id    any
+			}{{canal.DeleteAction, int64(5)}, {canal.InsertAction, int64(6)}},
+		},
+		{
+			name: "full image key kept",
+			rows: [][]any{{int64(5), int64(1)}, {int64(5), int64(2)}},
+			want: []struct {
+				event string
+				id    any
+			}{{canal.UpdateAction, int64(5)}},
+		},
+		{
+			// The after image of a MINIMAL update holds only the columns the
+			// update set.
+			name: "minimal image key kept",
+			rows: [][]any{{int64(5), absentColumn{}}, {absentColumn{}, int64(2)}},
+			want: []struct {
+				event string
+				id    any
+			}{{canal.UpdateAction, nil}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithSplitPKChange(true))
+			m.columnBitmaps = true
+			m.binlogFile = "mysql-bin.000001"
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.UpdateAction,
+				Rows:   tt.rows,
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 500},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.stream) != len(tt.want) {
+				t.Fatalf("%d messages sent, want %d", len(m.stream), len(tt.want))
+			}
+			var seqs []uint64
+			for i, want := range tt.want {
+				msg := <-m.stream
+				if msg.Event != want.event || msg.Data["id"] != want.id {
+					t.Errorf("message %d = %s of id %v, want %s of id %v", i, msg.Event, msg.Data["id"], want.event, want.id)
+				}
+				seqs = append(seqs, msg.globalSeq)
+			}
+			// The delete and insert of a split update are ordered.
+			if len(seqs) == 2 && seqs[0] >= seqs[1] {
+				t.Errorf("global sequences = %v, want the delete before the insert", seqs)
+			}
+		})
+	}
+}
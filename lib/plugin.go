@@ -5,46 +5,1039 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/Jeffail/benthos/v3/public/service"
 	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/pingcap/tidb/pkg/parser"
 )
 
 var mongoStreamConfigSpec = service.NewConfigSpec().
 	Summary("Creates an input that generates mysql CDC stream").
-	Field(service.NewStringField("addr")).
+	Description("### Metadata\n\n"+
+		"This input adds the following metadata fields to messages:\n\n"+
+		"- `routing_key`: the schema qualified `<schema>.<table>` of row changes, or the event name, such as `transaction`, `connected` or `disconnected`, of messages not tied to a single table, for downstream `switch` outputs or brokers to route on.\n"+
+		"- `table`: the bare table name.\n"+
+		"- `database`: the database of the table.\n"+
+		"- `binlog_file` and `binlog_pos`: the position of the rows event of a row change read from the binlog, or of the start of its transaction when it is compressed.\n"+
+		"- `global_seq`: a number derived from the binlog coordinates of a message read from the binlog that strictly increases in commit order across all tables, reconnects and restarts, so that streams split per table can be merge sorted back into commit order. Snapshot rows and the row changes of compressed transactions (`binlog_transaction_compression=ON`), whose events have no binlog coordinates of their own, carry none.\n"+
+		"- `version`: an external version for upserts of row changes, for example with Elasticsearch `version_type: external`. It is the `global_seq` of the change, or for snapshot rows that of the binlog position the snapshot was taken at, which is lower than that of any change streamed after it, and for the changes of a compressed transaction the positions after its GTID event in turn, which fall between the versions of the transactions before and after it. Since it increases across the whole stream it also increases for the changes of every single row. Only a compressed transaction with more row changes than bytes after its GTID event, which takes a payload compressed to less than a byte per change, runs out of positions, and its remaining changes share the version of the end of the transaction.\n"+
+		"- `auto_increment_id`: the value allocated to the AUTO_INCREMENT column of an insert read from the binlog, so that downstream can track ID allocation. Values allocated by rolled back transactions or failed inserts are never written to the binlog and show up as gaps in the sequence.\n"+
+		"- `idempotency_key`: a key of each row change that is identical whenever the same change is delivered again, for example after a reconnect, so that downstream sinks can deduplicate. It has the form `<origin>|<schema>.<table>|<primary key>|<op>`, where the origin is `gtid:<gtid>:<event>.<row>` when the server logs GTIDs, with `event` the index of the rows event within the transaction and `row` that of the row change within the rows event, both counting changes that are not emitted, `<binlog file>:<end position>` of the rows event otherwise, `<binlog file>:<transaction start>/<event>.<row>` for compressed transactions, and `snapshot:<binlog file>:<position>` for snapshot rows. The primary key is the comma separated primary key values, or `#<row index>` within the rows event for tables without one, and the op is one of `c`, `u`, `d` or `r`.").
+	Example("Routing per table", "Send the changes of each table to its own output by switching on the `routing_key` metadata field.", `
+input:
+  mysql_stream:
+    addr: localhost:3306
+    user: user
+    password: password
+    flavor: mysql
+    database: shop
+    mode: snapshot_and_stream
+    tables: [ orders, customers ]
+
+output:
+  switch:
+    cases:
+      - check: meta("routing_key") == "shop.orders"
+        output:
+          kafka:
+            addresses: [ localhost:9092 ]
+            topic: orders
+      - check: meta("routing_key") == "shop.customers"
+        output:
+          kafka:
+            addresses: [ localhost:9092 ]
+            topic: customers
+      - output:
+          drop: {}
+`).
+	Field(service.NewStringField("addr").
+		Description("The `host:port` of the MySQL server.").
+		Default("")).
+	Field(service.NewStringListField("addrs").
+		Description("Candidate `host:port` addresses of servers of the same replica set, tried in order on every connect until one succeeds, in place of `addr`. When more than one is listed and the servers log GTIDs, the stream is followed by GTID so that it resumes on whichever server is reachable after a failure. Without GTIDs a stream that has started only resumes on the server it stopped on, see `on_master_switch`. The connected server is set in the `source_host` metadata field of every message.").
+		Advanced().
+		Default([]any{})).
 	Field(service.NewStringField("database")).
 	Field(service.NewStringField("user")).
 	Field(service.NewStringField("password")).
-	Field(service.NewStringListField("tables")).
+	Field(service.NewStringListField("tables").
+		Description("Tables to stream, either as bare names in `database` or qualified as `db.table`. When empty every table in `database` is streamed.")).
 	Field(service.NewStringField("flavor")).
-	Field(service.NewBoolField("stream_snapshot")).
-	Field(service.NewBoolField("enable_ssl").Default(false))
+	Field(service.NewStringField("mode").
+		Description("What the input reads: `snapshot_and_stream` emits the current contents of the streamed tables as inserts and then streams the binlog from where the snapshot was taken, `stream_only` only streams the binlog, and `snapshot_only` emits the snapshot and then shuts the input down once its messages are delivered, which cannot be combined with `position_cache` or `start_gtid_set`. When empty the mode follows the deprecated `stream_snapshot` field, `snapshot_and_stream` when it is true and `stream_only` otherwise.").
+		Example(modeSnapshotAndStream).
+		Default("")).
+	Field(service.NewBoolField("stream_snapshot").
+		Description("Deprecated, use `mode` instead. Enabling it is equivalent to a `mode` of `snapshot_and_stream`, and disabling it to `stream_only`.").
+		Default(false)).
+	Field(service.NewStringMapField("snapshot_where").
+		Description("Per table conditions appended as a `WHERE` clause to the snapshot query, keyed by bare or `db.table` qualified table name. Rows excluded from the snapshot are still streamed when they change later. Clauses are injected verbatim into the query.").
+		Example(map[string]any{"orders": "created_at > '2024-01-01'"}).
+		Default(map[string]any{})).
+	Field(service.NewStringMapField("row_filters").
+		Description("Per table filters, keyed by bare or `db.table` qualified table name, that apply to both the snapshot and the stream, so that a row left out of the snapshot is not streamed when it changes later. A filter is added to the `WHERE` clause of the snapshot query and evaluated against each streamed row, and an update is streamed when its row matches before or after it. Filters compare a column with a number or single quoted string (`=`, `!=`, `<>`, `<`, `<=`, `>`, `>=`, `IN`, `NOT IN`) or test it with `IS NULL` and `IS NOT NULL`, combined with `AND`, `OR`, `NOT` and parentheses, such as `region = 'eu' AND deleted_at IS NULL`. Strings compare byte by byte, ignoring the collation of the column, and temporal columns as their `YYYY-MM-DD hh:mm:ss` value. Columns left out of MINIMAL row images compare as NULL unless `fill_minimal_images` is enabled.").
+		Default(map[string]any{})).
+	Field(service.NewStringMapField("key_templates").
+		Description("Per table templates, keyed by bare or `db.table` qualified table name, of a `key` metadata field set on the row changes of the table, such as `tenant-{tenant_id}/user-{user_id}` for a composite key formatted as downstream needs, for example as the key of Kafka messages. Each `{column}` is replaced by the value of the column as read from MySQL, with NULL values left empty, and `{{` and `}}` stand for literal braces. Templates are checked on connect against the schemas of their tables, and the stream fails when one references a column its table does not have, including after a schema change drops it, unless `emit_errors_inline` or `dead_letter_rows` handles the row. Row changes of tables whose schema is unresolved carry no key.").
+		Example(map[string]any{"app.users": "tenant-{tenant_id}/user-{user_id}"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewBoolField("enable_ssl").
+		Description("Deprecated, use `ssl_mode` instead. Enabling it is equivalent to an `ssl_mode` of `REQUIRED`.").
+		Default(false)).
+	Field(service.NewStringEnumField("output_format", builtinOutputFormats...).
+		Description("Emit one JSON message per row change with `row`, one message per transaction containing all of its row changes with `transaction`, one Avro encoded message per row change using a schema derived from the table's columns with `avro`, or one message per row change in the envelope of Debezium's MySQL connector, with `before` and `after` row images, with `debezium`, one CloudEvents v1.0 JSON envelope per message with `cloudevents`, or batches of messages packed into a single message of newline delimited JSON with `ndjson_batch`, see `ndjson_batch_size`. CloudEvents carry the JSON body of the message as their `data`, a `type` such as `mysql.cdc.insert` or `mysql.cdc.transaction` derived from the event, a `source` of the form `mysql://<host>/<database>/<table>`, the table as their `subject`, the `idempotency_key` of row changes as their `id` so that redelivered changes keep it, and the binlog timestamp of the change as their `time`. Custom formats registered with `RegisterMessageEncoder` are only selectable through the Go API, with `WithOutputFormat`.").
+		Default(outputFormatRow)).
+	Field(service.NewStringField("schema_registry_url").
+		Description("When `output_format` is `avro`, register each table schema with this Schema Registry, under the subject `<database>.<table>-value`, and prefix messages with the schema id using the Confluent wire format. A schema is registered again when the columns of its table change. Unsigned BIGINT columns are encoded as decimals of scale 0, as their values may not fit a long. A message that cannot be encoded, for example while the registry is unreachable, is not acknowledged and is encoded again by the next read, so that the stream holds at it rather than skipping it.").
+		Default("")).
+	Field(service.NewIntField("max_transaction_rows").
+		Description("The maximum number of row changes buffered for a single transaction when `output_format` is `transaction` or `committed_only` is enabled.").
+		Default(10000)).
+	Field(service.NewStringEnumField("on_large_transaction", largeTransactionError, largeTransactionSplit).
+		Description("What to do when a transaction exceeds `max_transaction_rows`: fail the stream, or emit the buffered changes as a partial transaction and continue, which keeps the memory used by large transactions, such as bulk deletes, bounded. The messages of a split transaction carry `partial: true` except the last, and `continuation: true` except the first. Consumers that need every transaction to arrive whole, or the stream to fail instead, must set `error`. Transactions buffered by `committed_only` are never split, as that would emit changes before their commit.").
+		Default(largeTransactionSplit)).
+	Field(service.NewIntField("buffer_size").
+		Description("The number of messages buffered between the binlog reader and the pipeline. The `mysql_stream_buffer_depth` gauge reports how many messages are buffered and `mysql_stream_buffer_high_water` the most buffered at once since the input started, and with `on_buffer_full: block` the `mysql_stream_buffer_blocked_ns` timer times each wait of the reader for room in a full buffer. A buffer that stays full, while the reader is blocked often, shows that the pipeline downstream is the bottleneck rather than the source.").
+		Default(1024)).
+	Field(service.NewStringEnumField("on_buffer_full", bufferFullBlock, bufferFullDropOldest, bufferFullError).
+		Description("What to do when the buffer is full: `block` the binlog reader until the pipeline catches up, `drop_oldest` buffered message to make room, or fail the stream with an `error`. Only `block` preserves at-least-once delivery.").
+		Default(bufferFullBlock)).
+	Field(service.NewObjectListField("significant_columns",
+		service.NewStringField("table"),
+		service.NewStringListField("columns"),
+	).
+		Description("Per table columns of interest, with tables named as in `tables`. UPDATE events on a listed table are dropped unless at least one of its columns changed.").
+		Default([]any{})).
+	Field(service.NewBoolField("drop_noop_updates").
+		Description("Drop updates whose before and after images are equal in every column, as written for statements such as `UPDATE t SET x = x` when the server logs unchanged rows, and count them in the `mysql_stream_noop_updates_dropped` metric. Images are compared as read from the binlog, before any conversion or transform, so a value rewritten with a different byte representation counts as a change. Columns left out of both images, such as unchanged BLOB columns under `binlog_row_image=NOBLOB`, compare equal, and under `binlog_row_image=MINIMAL` the images are compared once completed by `fill_minimal_images`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewObjectListField("enrichments",
+		service.NewStringField("table").
+			Description("The table whose row changes are enriched, named as in `significant_columns`."),
+		service.NewStringField("column").
+			Description("The foreign key column of `table`."),
+		service.NewStringField("target_table").
+			Description("The referenced table, as `db.table` or as a table of the database of `table`."),
+		service.NewStringField("target_column").
+			Description("The column of `target_table` that `column` refers to.").
+			Default("id"),
+		service.NewStringListField("fields").
+			Description("The columns of the referenced row added to each message."),
+		service.NewStringField("prefix").
+			Description("Prepended to the name of every added field. Defaults to `column` without an `_id` suffix followed by an underscore, so that `customer_id` adds `customer_name`.").
+			Default(""),
+	).
+		Description("Foreign key columns whose referenced row is looked up over the control connection to add some of its fields to each row change of the table, such as `customer_name` alongside `customer_id`. Lookups are cached for up to `enrichment_cache_size` rows and a cached row is looked up again once a change to it is read from the binlog, which requires `target_table` to be streamed; rows of tables that are not streamed stay cached until evicted. Each lookup that misses the cache holds up the stream until it completes, with a timeout of 5s, and a failed lookup is handled as a row conversion error. A NULL foreign key or one without a matching row sets the added fields to null.").
+		Advanced().
+		Default([]any{})).
+	Field(service.NewIntField("enrichment_cache_size").
+		Description("The number of rows looked up by `enrichments` kept in memory.").
+		Advanced().
+		Default(10000)).
+	Field(service.NewDurationField("position_check_interval").
+		Description("How often a separate control connection compares the consumed binlog position against the server's binary logs to report how many files behind the stream is. Set to `0s` to disable.").
+		Default("30s")).
+	Field(service.NewIntField("ready_max_files_behind").
+		Description("How many binlog files the stream may be behind the end of the binlog and still count as ready, for readiness probes of orchestrators that should not route traffic until the stream is live. The input is ready once it is streaming the binlog, after the snapshot when one is taken, and the last position check found it at most this many files behind, or, before the first check after a connect, once the `caught_up` event of `wait_until_caught_up` has been emitted. Without position checks or `wait_until_caught_up` it is ready as soon as it is streaming. Readiness is reported by the `mysql_stream_ready` gauge, set to 1 while ready and 0 otherwise, by the `ready` field of `health` events and by `Health` to code embedding the input.").
+		Advanced().
+		Default(0)).
+	Field(service.NewBoolField("semi_sync").
+		Description("Register as a semi-synchronous replica so the source waits for this input to acknowledge each transaction before committing it. This adds a network round trip to every commit on the source and should only be enabled when the source has the semi-sync plugin configured.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("tracing").
+		Description("Start a span for every message using the configured tracer and inject its context into the message metadata. When the source logs row queries (`binlog_rows_query_log_events=ON`), a `traceparent` left in a statement comment by an instrumented client is used as the parent span and copied to the `traceparent` metadata field.").
+		Default(false)).
+	Field(service.NewBoolField("emit_lifecycle_events").
+		Description("Emit messages with the `event` metadata set to `connected` or `disconnected` whenever the binlog connection is established or lost. Their body carries the binlog position and a unix timestamp.").
+		Default(false)).
+	Field(service.NewIntField("max_reconnect_attempts").
+		Description("The number of consecutive failed attempts to reconnect after the binlog stream is lost before the input shuts down. Set to `0` to retry forever. An attempt only counts as successful once its stream reads past the position it started at or stays up for a minute, so that a server that accepts connections but fails every stream, for example because the binlog to resume from was purged, still exhausts the attempts. A stream stopped by a panic while handling an event always counts as failed, as it resumes before that event, and so does every retry of a message whose encoder panicked. Recovered panics are logged at error level with their stack. The stream is only lost once the replication client has exhausted its own retries, see `sync_retry_attempts`, so in the worst case, where every reconnect connects but its stream makes no progress, the input runs `max_reconnect_attempts + 1` streams, each connected up to `sync_retry_attempts + 1` times, before it shuts down. The replication client only counts retries that fail to connect, so a server that accepts every retry and breaks the stream again before sending an event is retried once a second until the input is closed. Each reconnect of the input itself dials every address of `addrs` at most once.").
+		Default(0)).
+	Field(service.NewStringField("credentials_cache").
+		Description("A cache resource to read the user and password from on every connect, allowing credentials to be rotated without restarting the pipeline. When empty the static `user` and `password` fields are used.").
+		Default("")).
+	Field(service.NewStringField("credentials_user_key").
+		Description("The key of the user within `credentials_cache`.").
+		Advanced().
+		Default("user")).
+	Field(service.NewStringField("credentials_password_key").
+		Description("The key of the password within `credentials_cache`.").
+		Advanced().
+		Default("password")).
+	Field(service.NewDurationField("read_poll_timeout").
+		Description("The maximum time a read waits for a change before yielding back to Benthos with a `context.DeadlineExceeded` error, which Benthos logs as a failed read before it backs off briefly and reads again. Set to `0s` to wait indefinitely.").
+		Advanced().
+		Default("0s")).
+	Field(service.NewBoolField("fill_minimal_images").
+		Description("Complete UPDATE and DELETE row images written with `binlog_row_image=MINIMAL` using the last full image seen for the same primary key, which is kept in memory for up to `row_cache_size` rows. Rows are only filled once a full image (an insert, a snapshot row or an update with a full before image) has been seen for their key. Only the columns the columns present bitmap of a rows event marks as left out are filled, so a column updated to NULL is NULL, except within compressed transactions (`binlog_transaction_compression=ON`), whose events are decoded without their bitmaps and where a column updated to NULL keeps its previous value.").
+		Advanced().
+		Default(false)).
+	Field(service.NewIntField("row_cache_size").
+		Description("The maximum number of rows kept by `fill_minimal_images` and `delete_minimal_behavior: cache`.").
+		Advanced().
+		Default(10000)).
+	Field(service.NewStringEnumField("zero_date_behavior", zeroDateNull, zeroDateEpoch, zeroDateString, zeroDateError).
+		Description("How to emit zero dates such as `0000-00-00`, and the zero `YEAR` `0000`: as `null`, as the unix `epoch`, as the literal `string`, which is `0` for years, or fail the stream with an `error`. Other `YEAR` values are emitted as four digit integers such as `2024`, and `TIME` values as `HH:MM:SS` strings, with as many fractional second digits as the precision of the column, such as `12:30:00.500` for `TIME(3)`, and a sign for negative durations.").
+		Default(zeroDateString)).
+	Field(service.NewStringEnumField("spatial_format", spatialFormatWKT, spatialFormatGeoJSON, spatialFormatBase64).
+		Description("How to emit spatial columns: as WKT strings, as GeoJSON objects, or as the base64 encoded internal MySQL value, which includes the SRID. Values of geometry types that are not supported, such as those with Z or M coordinates, are handled by `unknown_type_behavior`, and other values that cannot be parsed are always emitted base64 encoded.").
+		Default(spatialFormatWKT)).
+	Field(service.NewIntField("snapshot_max_retries").
+		Description("The number of times the snapshot of a table is retried after a failure, such as a deadlock or timeout, before the snapshot is aborted. These retries are separate from the reconnects of the binlog stream.").
+		Default(3)).
+	Field(service.NewDurationField("snapshot_retry_backoff").
+		Description("The delay before the first snapshot retry of a table, doubling with each further attempt up to 30 seconds.").
+		Advanced().
+		Default("1s")).
+	Field(service.NewIntField("max_message_bytes").
+		Description("The maximum size of an encoded message. Set to `0` for no limit.").
+		Default(0)).
+	Field(service.NewStringEnumField("on_oversized", oversizedDrop, oversizedTruncate, oversizedError).
+		Description("What to do with messages larger than `max_message_bytes`: `drop` them, `truncate` their largest column values until they fit and list those columns in the `truncated_columns` metadata field, or emit them flagged with an `error` so they can be routed with `errored()`.").
+		Default(oversizedDrop)).
+	Field(service.NewBoolField("include_message_size").
+		Description("Add a `message_size_bytes` metadata field with the size of the encoded message, after any `on_oversized` truncation, so that downstream can account for payload sizes and spot anomalies without measuring messages again. An `ndjson_batch` message carries the size of the whole batch, and each of its lines the size of its own message. Structured messages are encoded to measure them, as with `max_message_bytes`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("check_binlog_format").
+		Description("Check on connect that the server uses `binlog_format=ROW` and fail with an error otherwise, since STATEMENT and MIXED formats do not log the row changes this input streams. Disable only when the format is known to be ROW but cannot be queried.").
+		Advanced().
+		Default(true)).
+	Field(service.NewBoolField("use_decimal").
+		Description("Emit DECIMAL columns as exact decimals, serialized as JSON strings, rather than floating point numbers which may lose precision. Snapshot rows always carry DECIMAL values exactly, as strings when this is off. Avro output encodes decimals exactly either way.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("parse_time").
+		Description("Parse DATETIME and TIMESTAMP columns into timestamps, serialized in RFC 3339 format, rather than emitting the string MySQL returns. Zero dates are never parsed and are still handled by `zero_date_behavior`. Only applies when `temporal_output` is `native`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringEnumField("snapshot_consistency", snapshotConsistencyTransactional, snapshotConsistencyLocking, snapshotConsistencyNone).
+		Description("How the snapshot isolates its reads: `transactional` reads every table from a single consistent InnoDB snapshot, `locking` holds read locks on the snapshotted tables for the duration of the snapshot, which also works for MyISAM but blocks writers, and `none` reads each table independently without locking. Changes made while the snapshot runs are streamed afterwards in every mode, but with `none` the snapshot rows of different tables may reflect different points in time. The snapshot is read with `SELECT` statements rather than `mysqldump`, whose privileges are not needed. Values are read in binary form, so snapshot rows carry the same types as changes read from the binlog: `BINARY`, `VARBINARY` and `BLOB` values are emitted as bytes, which JSON output and `values_as_strings` base64 encode.").
+		Advanced().
+		Default(snapshotConsistencyTransactional)).
+	Field(service.NewStringEnumField("unknown_type_behavior", unknownTypeRawBytes, unknownTypeBase64, unknownTypeString, unknownTypeSkip, unknownTypeError).
+		Description("How to emit columns of a type the input does not recognize: as the `raw_bytes` received from the server, `base64` encoded, converted to a `string`, omitted from the message with `skip`, or fail the stream with an `error`. A warning is logged the first time each unknown type is seen.").
+		Advanced().
+		Default(unknownTypeRawBytes)).
+	Field(service.NewBoolField("include_truncate").
+		Description("Emit a message with the `event` metadata set to `truncate` when a streamed table is truncated, so that consumers can clear their copy of it. `TRUNCATE TABLE` is not logged as row deletions and is otherwise not visible in the stream.").
+		Default(false)).
+	Field(service.NewStringMapField("column_transforms").
+		Description("Built in transforms applied to column values before they are emitted, keyed by `table.column` or `db.table.column`. The available transforms are `lowercase`, `uppercase` and `trim` for text, and `to_epoch_millis`, which converts dates and times to milliseconds since the unix epoch. Values a transform does not apply to, such as NULL or a zero date, are left unchanged.").
+		Example(map[string]any{"users.email": "lowercase", "orders.created_at": "to_epoch_millis"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewBoolField("emit_offset_markers").
+		Description("Periodically emit messages with the `event` metadata set to `offset` carrying the binlog position, and GTID set when available, of the last transaction boundary the stream has handled, for downstream sinks that keep their own checkpoints. Every change before that position is delivered ahead of the marker, so once the marker and the messages before it are persisted the stream can safely be resumed from it. The converse does not hold: changes of the transaction being read may be delivered ahead of a marker that does not cover them yet, as with `output_format: row` changes are emitted as they are read, and the parts of a transaction that `on_large_transaction: split` sends are emitted before its commit. While `output_format: transaction` or `committed_only` buffers a transaction, markers keep carrying the position before it.").
+		Default(false)).
+	Field(service.NewDurationField("offset_marker_interval").
+		Description("How often `emit_offset_markers` emits an offset message. This is independent of any other interval.").
+		Advanced().
+		Default("10s")).
+	Field(service.NewDurationField("row_count_interval").
+		Description("How often to count the rows of every streamed table on a control connection and emit a message with the `rowcount` event per table, for data quality monitoring that compares them against the counts downstream to detect drift. The body carries the `database`, `table`, the row `count`, whether it is `approximate`, the `binlog_file` and `binlog_pos` the stream was at when the table was counted and a `timestamp`. The count is not taken at that position, so it only matches downstream counts up to the changes made while it ran. Set to `0s` to disable.").
+		Advanced().
+		Default("0s")).
+	Field(service.NewStringEnumField("row_count_mode", rowCountExact, rowCountApproximate).
+		Description("How `row_count_interval` counts rows. `exact` runs `SELECT COUNT(*)` on every table, which scans a whole index of the table and can take minutes and a lot of I/O on tables with many rows, on each interval. `approximate` reads `TABLE_ROWS` from `information_schema.TABLES`, which costs nothing but is only the estimate of the storage engine, often off by tens of percent for InnoDB, and which MySQL 8 caches for `information_schema_stats_expiry`, a day by default.").
+		Advanced().
+		Default(rowCountExact)).
+	Field(service.NewIntField("max_events_per_second").
+		Description("The maximum number of messages emitted per second, for example to avoid overwhelming downstream services while catching up on a backlog. Throttled changes wait in the binlog rather than being dropped, so the stream position stays correct. Set to `0` for no limit.").
+		Default(0)).
+	Field(service.NewBoolField("include_changed_fields").
+		Description("List the columns that changed in UPDATE messages in the `changed_fields` metadata field, and set a `changed_values` metadata field to a JSON object with the `old` and `new` value of each of them. Both are metadata, so they never collide with a column of the same name, and apply to every `output_format`. The `transaction` envelope carries the `changed_fields` object of each change next to its `data` as well.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("disable_retry_sync").
+		Description("Disable the replication client's own reconnection when the binlog connection breaks, so that every connection failure stops the stream and is handled by the input reconnecting, as counted by `max_reconnect_attempts`. When enabled, `sync_retry_attempts` has no effect.").
+		Advanced().
+		Default(false)).
+	Field(service.NewIntField("sync_retry_attempts").
+		Description("The number of times the replication client reconnects a broken binlog connection by itself before giving up, `0` retrying forever. Only once it gives up does the stream stop and the input reconnect, so `max_reconnect_attempts` counts input level reconnects of which each may have been preceded by up to this many client retries. Set `disable_retry_sync` or a low value here to leave reconnection to the input alone.").
+		Advanced().
+		Default(0)).
+	Field(service.NewBoolField("include_schema_changes").
+		Description("Emit a message with the `event` metadata set to `ddl` for every statement that changes the layout of a streamed table. Its body carries the statement, the resulting `columns` of the table with their `name`, `type`, whether they are `unsigned` and, with `include_nullability`, whether they are `nullable`, its `primary_key`, whether it was `dropped`, and a `schema_version` that increases with every change to the table. The `schema_version` is derived from the binlog position of the statement, so it keeps increasing across restarts and is the same when a statement is streamed again, but is not contiguous. The layout is read from the server when the statement is streamed, so while catching up on older binlogs it may already include later changes. Statements on temporary tables, which are written to the binlog when statements are logged as statements, never emit `ddl` or `truncate` messages, including statements without the `TEMPORARY` keyword on a table the same session created as a temporary table since the input started.").
+		Default(false)).
+	Field(service.NewBoolField("parse_ddl").
+		Description("Describe the changes of each `ddl` message of `include_schema_changes` in a `changes` list, so that downstream schema managers do not need to parse SQL. Each change has an `op`: `create_table` with its `columns` and `primary_key`, `drop_table`, `rename_table` with the `from` and `to` tables, `add_column`, `modify_column` and `change_column` with the `column`, its `type` as in `information_schema`, such as `varchar(255)` or `int(11) unsigned` with the display width of integer types filled in, whether it is `nullable`, `first` or the column it is placed `after` when given, and for `change_column` its `new_column` name, `drop_column` and `rename_column` with the `column` and its `new_column` name, `add_index` with the `index`, its `columns` and whether it is `unique` or the `primary` key, `drop_index` and `drop_primary_key`. A `parsed` field is set to `false` for statements with any part that is not one of these, such as partitioning, table options or `CREATE TABLE ... SELECT`, which carry no `changes` and are left to the raw `query`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_type_hints").
+		Description("Add a `schema` metadata field to row changes holding a JSON object that maps each column to a type hint for downstream systems: `integer`, `float`, `numeric`, `timestamp`, `date`, `time`, `json`, `bytes`, `geography` or `string`, and `boolean` for `TINYINT(1)` columns when `tinyint1_as_bool` is enabled.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringMapField("type_mapping").
+		Description("Overrides of the type hints added by `include_type_hints`, keyed by MySQL column type. A full type such as `tinyint(1)` takes precedence over a base type such as `tinyint`.").
+		Example(map[string]any{"tinyint(1)": "boolean", "bigint unsigned": "numeric"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewBoolField("tinyint1_as_bool").
+		Description("Emit `TINYINT(1)` columns, which MySQL uses for `BOOLEAN`, as `true` or `false` rather than integers. Other `TINYINT` columns are unaffected.").
+		Default(false)).
+	Field(service.NewBoolField("values_as_strings").
+		Description("Emit every column value of row change messages as a string, for sinks such as SQL `COPY` targets that only take strings. Values are converted after `temporal_output`, `use_decimal`, `parse_time`, `tinyint1_as_bool`, `spatial_format` and `column_transforms`, and formatted as JSON output shows them: numbers in plain decimal notation, booleans as `true` and `false`, times parsed by `parse_time` as RFC 3339, binary values base64 encoded and GeoJSON and other structured values as JSON text. `NULL` is emitted as `values_as_strings_null`. The values of tables whose schema is unresolved, emitted by `on_unresolved_schema: positional`, are left as read. Cannot be combined with `avro` output, whose schemas follow the column types.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringField("values_as_strings_null").
+		Description("The string `NULL` values are emitted as by `values_as_strings`, such as `\\N`. When empty `NULL` values are kept as `null`.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringMapField("connection_attributes").
+		Description("Connection attributes, such as `program_name`, sent when connecting so that DBAs can identify the pipeline in `performance_schema.session_connect_attrs`. They are only set on the control connections the input opens itself, for snapshots, server checks and position monitoring. This is a limitation of the replication client: the binlog replication connection always identifies itself with just `_client_role` set to `binary_log_listener`, and the connection canal reads table schemas over carries no attributes either, so neither shows up under these attributes.").
+		Example(map[string]any{"program_name": "orders-cdc"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewBoolField("include_generated_columns").
+		Description("Include the values of `VIRTUAL` and `STORED` generated columns in messages. Virtual columns that the server does not write to the binlog are always left out.").
+		Default(true)).
+	Field(service.NewStringField("resnapshot_cache").
+		Description("A cache resource polled for requests to snapshot individual tables again without restarting, for example after a consumer lost its copy of them. Setting `resnapshot_key` in the cache to a comma separated list of tables triggers a snapshot of those tables, which starts at the next transaction boundary of the stream and runs alongside it. Changes to the tables read while it runs are held back and streamed after its rows, up to `resnapshot_max_held_changes`, while changes to other tables keep flowing; as with the initial snapshot, no change is lost. The key is deleted once the request is claimed, which several inputs polling the same cache do through a second key, `resnapshot_key` suffixed with `.claim`, so that only one of them takes each request. When empty no cache is polled.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringField("resnapshot_key").
+		Description("The key of resnapshot requests within `resnapshot_cache`.").
+		Advanced().
+		Default("resnapshot")).
+	Field(service.NewDurationField("resnapshot_poll_interval").
+		Description("How often `resnapshot_cache` is polled for requests.").
+		Advanced().
+		Default("5s")).
+	Field(service.NewIntField("resnapshot_max_held_changes").
+		Description("The most changes to the tables of a running resnapshot held back until it completes. Once reached, the stream pauses until the resnapshot completes rather than holding more. Zero pauses it for every change to the tables.").
+		Advanced().
+		Default(10000)).
+	Field(service.NewBoolField("split_pk_change").
+		Description("Emit an UPDATE that changes the primary key of a row as a DELETE of the old key followed by an INSERT of the new one, so that stores keyed by primary key do not keep an orphaned record under the old key. Under `binlog_row_image=MINIMAL` a key column left out of an image is taken as unchanged, which relies on the columns present bitmaps of rows events; where they are not known, such as in compressed transactions, a key left out reads as `NULL` and every update is split.").
+		Default(false)).
+	Field(service.NewStringField("ssl_mode").
+		Description("The security of the connection to the server, with the semantics of the MySQL client's `--ssl-mode`: `DISABLED`, `PREFERRED` to use TLS when the server supports it, `REQUIRED` to always use TLS without verifying the server certificate, `VERIFY_CA` to also verify the certificate against `ssl_ca`, and `VERIFY_IDENTITY` to also check that it was issued for the host in `addr`. When empty the mode follows the deprecated `enable_ssl` field, `REQUIRED` when it is true and `DISABLED` otherwise.").
+		Default("")).
+	Field(service.NewStringField("ssl_ca").
+		Description("A PEM file with the certificate authorities used to verify the server under the `VERIFY_CA` and `VERIFY_IDENTITY` SSL modes. When empty the system roots are used.").
+		Default("")).
+	Field(service.NewIntField("encode_workers").
+		Description("The number of goroutines encoding messages, computing their `row_checksum` and enforcing `max_message_bytes`. With `1` messages are encoded one at a time as they are read, which is fine for JSON output of narrow rows, while encoding heavy configurations, such as `avro` output or wide rows with checksums, read faster with more workers. Messages are still delivered in the order they were read whichever worker finishes first, so that neither the global order of the stream nor the order of the changes of a row changes, and up to twice as many messages as workers are encoded ahead of delivery. Encoders registered with `RegisterMessageEncoder` must be safe for concurrent use when set above `1`.").
+		Advanced().
+		Default(1)).
+	Field(service.NewBoolField("structured_messages").
+		Description("Hand JSON messages to the pipeline as structured values instead of serialized bytes, which saves encoding every row when processors such as Bloblang work on the structured contents anyway. It skips the encoded body only: every row change is still converted into a map of its column values, so it reduces the allocations per row rather than removing them. Messages are still encoded when `max_message_bytes` or `include_message_size` is set, to measure them. Has no effect on `avro` output.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringEnumField("on_missing_table", missingTableError, missingTableWarn, missingTableSkip).
+		Description("What to do on connect when tables listed in `tables` do not exist: fail with an `error`, `warn` and stream them once they are created, or `skip` them for the lifetime of the input.").
+		Default(missingTableError)).
+	Field(service.NewStringField("position_cache").
+		Description("A cache resource the binlog position is persisted to as messages are acknowledged, and resumed from on start instead of the current server position, in which case no snapshot is taken. The stored position is the start of the oldest transaction with changes not yet acknowledged. When empty no position is persisted.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringField("position_cache_key").
+		Description("The key of the persisted position within `position_cache`.").
+		Advanced().
+		Default("position")).
+	Field(service.NewDurationField("position_flush_interval").
+		Description("How often the acknowledged position is written to `position_cache`. Writing less often reduces the load on the cache for high throughput streams in exchange for a wider window of changes delivered again after a crash, as the input resumes from the last position written. When zero the position is only written every `position_flush_every_n` acknowledgements.").
+		Advanced().
+		Default("1s")).
+	Field(service.NewIntField("position_flush_every_n").
+		Description("Also write the acknowledged position to `position_cache` once this many messages have been acknowledged since the last write. When zero, and `position_flush_interval` is also zero, the position is written on every acknowledgement. The latest position is always written when the input closes.").
+		Advanced().
+		Default(0)).
+	Field(service.NewIntField("position_write_retries").
+		Description("How many times a failed write of the position or snapshot progress to `position_cache` is retried, waiting `position_write_backoff` before the first retry and twice as long before each further one, up to 5s. Once a write fails after its retries no more messages are read and every read retries the write first, failing with its error until it succeeds, so that the changes delivered again after a crash stop piling up while the position cannot be persisted. A position behind the one already persisted for the same server, as can be acknowledged after a reconnect, is never written.").
+		Advanced().
+		Default(3)).
+	Field(service.NewDurationField("position_write_backoff").
+		Description("How long to wait before the first retry of a failed write to `position_cache`.").
+		Advanced().
+		Default("100ms")).
+	Field(service.NewIntField("in_flight_limit").
+		Description("The number of unacknowledged messages whose binlog position is tracked in memory for `position_cache`, which holds on to the position of every message read until every message before it is acknowledged. Beyond the limit positions are spilled to a file in `in_flight_spill_dir` and read back once the messages before them are acknowledged, keeping only one bit per spilled message in memory, so that a large backlog of unacknowledged messages, such as while catching up behind a slow output, does not exhaust memory. Each tracked position takes around 100 bytes, and more under `snapshot_resume`. Spilling costs a disk write per message read and a read per message acknowledged while messages are spilled, which slows the stream down, so the limit should be well above the number of messages normally in flight. The `mysql_stream_in_flight_spilled` metric counts spilled positions and `mysql_stream_in_flight_spill_pending` reports how many are on disk. When `0` every position is kept in memory.").
+		Advanced().
+		Default(0)).
+	Field(service.NewStringField("in_flight_spill_dir").
+		Description("The directory the file of `in_flight_limit` is created in, which is removed when the input closes. When empty the default directory for temporary files is used.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringField("debug_dump_file").
+		Description("A file every raw rows event is appended to as a JSON line with its table, action, binlog position and row values as they are received, before any filtering or conversion, independently of the messages emitted. Useful to diagnose why downstream received a message. When empty nothing is written.").
+		Advanced().
+		Default("")).
+	Field(service.NewIntField("debug_dump_max_bytes").
+		Description("The size `debug_dump_file` is rotated at, keeping a single previous file with a `.1` suffix. Set to `0` for no limit.").
+		Advanced().
+		Default(104857600)).
+	Field(service.NewStringEnumField("canal_log_level", canalLogLevelDebug, canalLogLevelInfo, canalLogLevelWarn, canalLogLevelError, canalLogLevelOff).
+		Description("The minimum level of the replication library's own log messages, such as reconnects and sync status, forwarded to the Benthos logger with a `canal:` prefix. Forwarded messages are also subject to the Benthos log level. Fatal messages are always logged as errors and fail the connect rather than exiting the process.").
+		Advanced().
+		Default(canalLogLevelInfo)).
+	Field(service.NewStringEnumField("on_server_mismatch", serverMismatchError, serverMismatchReset).
+		Description("What to do when the position persisted in `position_cache` was read from a server other than the connected one, as identified by `server_uuid`: fail to connect with an `error`, or `reset` by discarding the position and starting as if none had been persisted. Positions stored without a `server_uuid`, or read from servers without one such as MariaDB, are always resumed from. A discarded position is reported with a `gap` event, as is a stream the server moves on to a binlog file past the one it was read from, and counted by the `mysql_stream_binlog_gaps` metric, as the changes in between may be missing downstream and the affected tables may need to be snapshotted again. The body of the event carries the `from_file` and `from_pos` the stream should have continued from, the `to_file` and `to_pos` it continued from instead, and the `reason`, `position_discarded` or `binlog_files_skipped`.").
+		Advanced().
+		Default(serverMismatchError)).
+	Field(service.NewStringEnumField("on_server_id_conflict", serverIDConflictError, serverIDConflictRandomizeRetry).
+		Description("What to do when the server stops the binlog stream because another replica connected with the same server id, which the input registers with as `124`: shut the input down with an `error` naming the conflict, or `randomize_retry` by reconnecting under a new random server id, so that transient clashes in dynamic environments resolve themselves. Reconnecting under the same id would cut off the other replica in turn. With `randomize_retry` the stream resumes where it stopped, and the new id is kept until the input restarts.").
+		Advanced().
+		Default(serverIDConflictError)).
+	Field(service.NewStringEnumField("on_master_switch", masterSwitchError, masterSwitchResnapshot).
+		Description("What to do when the input reconnects to a server other than the one the stream stopped on, such as after a failover behind the same address or to another of `addrs`, as identified by `server_uuid`, or by address for servers without one such as MariaDB. Binlog file positions only apply to the server that wrote them, so a stream followed by GTID continues by GTID. A stream followed by file positions fails to connect with an `error`, or with `resnapshot` discards its position and takes a new snapshot of every streamed table before streaming from the new server, whatever the `mode`. Either way where the stream continued is reported by a `master_switch` event, emitted before the rows of the new snapshot, with the `from_server_uuid`, `from_addr`, `from_file` and `from_pos` the stream stopped at, the `to_server_uuid` and `to_addr` it continues on and `resumed_by`, `gtid` or `snapshot`. Positions persisted in `position_cache` by an earlier run are checked by `on_server_mismatch` instead.").
+		Advanced().
+		Default(masterSwitchError)).
+	Field(service.NewBoolField("emit_empty_tx_markers").
+		Description("Emit a message with the `event` metadata set to `empty_tx` when a transaction commits without producing any row changes for the streamed tables, for example because it only wrote to other tables. Its body carries the binlog position after the commit, the commit timestamp and the GTID when the server logs them, so that downstream watermarks can advance precisely on sparse captures.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_schema_fingerprint").
+		Description("Add a `schema_fingerprint` metadata field to row changes holding a short hash of the names and types of the table's columns in order, which changes whenever the table's schema does, so that consumers can detect schema drift without parsing the full schema on every message.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_nullability").
+		Description("Describe whether each column accepts NULL wherever the input describes the schema of a table, so that downstream can create target schemas with the nullability of the source: as `nullable` in `column_metadata` and in the `columns` of `ddl` messages, and as part of the hash of `schema_fingerprint`, which changes when a column changes nullability. The schemas read by the replication client do not record nullability, so it is looked up in `information_schema.COLUMNS` on a control connection whenever the schema of a table is first described or has changed, except for tables whose columns are all part of the primary key, which never accept NULL. Enabling it changes the fingerprints of tables with NOT NULL columns.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_commit_timestamp").
+		Description("Add a `commit_timestamp` metadata field in RFC 3339 format to row changes and transactions with the time their transaction committed, as recorded in GTID events by MySQL 8.0.1 and later with microsecond precision, falling back to the time of the binlog event when it is not recorded.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("committed_only").
+		Description("Buffer the row changes of each transaction and only emit them once its commit is read, so that uncommitted changes are never emitted. Transactions on non-transactional tables, such as MyISAM, end with a `COMMIT` query that releases their changes, or a `ROLLBACK` query that drops them. The input reads these queries back with `SHOW BINLOG EVENTS`, which the replication privileges allow. Up to `max_transaction_rows` changes are buffered per transaction, and a larger transaction fails the stream whatever `on_large_transaction` is set to. A transaction interrupted by a reconnect is read again from its start, and the changes buffered for it are dropped first. Changes are always buffered this way when `output_format` is `transaction`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringField("start_gtid_set").
+		Description("A GTID set, in the format of `flavor`, of transactions already processed. When set the stream starts with the first transaction not in the set instead of at the current position, and no snapshot is taken. Ignored when resuming from a position persisted in `position_cache`. The server must log GTIDs.").
+		Example("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5").
+		Advanced().
+		Default("")).
+	Field(service.NewDurationField("snapshot_timeout").
+		Description("The longest a snapshot, including its retries, may run before it is aborted and the input shuts down with an error, checked between the rows read. Since streaming only starts once the snapshot completes, the snapshot is taken again from the start on the next run. Progress is reported by the `mysql_stream_snapshot_rows` counter per table and the `mysql_stream_snapshot_duration_ns` timer. Set to `0` for no limit.").
+		Advanced().
+		Default("0s")).
+	Field(service.NewStringEnumField("start_position", startPositionLatest, startPositionEarliest).
+		Description("Where a stream without a position to resume from, neither persisted in `position_cache` nor set by `start_gtid_set`, starts: at the `latest` position of the server, skipping every change made before the input started, or at the `earliest` position still available in the binlog, reading every change the server has retained. Depending on `binlog_expire_logs_seconds` the retained binlog may be far larger than the tables themselves and take long to read before the stream catches up. Cannot be combined with a snapshot, which starts from the latest position.").
+		Advanced().
+		Default(startPositionLatest)).
+	Field(service.NewBoolField("include_raw").
+		Description("Add an object named by `raw_field` to the body of row change messages with the value of every column as read from MySQL, before type conversion and `column_transforms`, for comparing it with the converted values. Binary values are base64 encoded in JSON. The section is not part of `avro` output.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringField("raw_field").
+		Description("The field of the message body `include_raw` sets the values as read from MySQL in. JSON output sets it next to the columns of the row, where it replaces a column of the same name, so it should be changed for tables with a `_raw` column. In the envelope of `output_format: transaction` each change carries the values in its own `_raw` field whatever this is set to.").
+		Advanced().
+		Default("_raw")).
+	Field(service.NewBoolField("temporal_debug").
+		Description("Add a `_temporal_raw` object to the body of row change messages with the value of every `DATE`, `TIME`, `DATETIME` and `TIMESTAMP` column as read from MySQL, next to the value interpreted by `temporal_output`, `zero_date_behavior` and `parse_time`, so that the temporal settings can be checked against the stored values during a migration before they are trusted. Meant to be enabled for a transition period only. The section is not part of `avro` output and does not apply to `thin_tables`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringEnumField("on_repeated_nack", repeatedNackRetry, repeatedNackError, repeatedNackDeadLetter).
+		Description("What to do with a message that has been rejected by the output `max_consecutive_nacks` times in a row. `retry` keeps retrying it indefinitely, `error` stops the input without acknowledging it, so that the persisted position does not advance past it and it is read again once the problem is fixed and the input restarted, and `dead_letter` writes it to `dead_letter_cache` and acknowledges it. The `mysql_stream_nack_breaker_open` gauge is set to 1 when the input stops.").
+		Advanced().
+		Default(repeatedNackRetry)).
+	Field(service.NewIntField("max_consecutive_nacks").
+		Description("The number of times in a row a message may be rejected before `on_repeated_nack` applies.").
+		Advanced().
+		Default(10)).
+	Field(service.NewStringField("dead_letter_cache").
+		Description("A cache resource messages are written to when `on_repeated_nack` is `dead_letter`, keyed by their `idempotency_key` metadata field, or by their event and the time for messages without one. Each entry is a JSON object with the `error` of the last rejection, the number of `nacks`, the `time`, the message `metadata` and the base64 encoded message `body`.").
+		Advanced().
+		Default("")).
+	Field(service.NewBoolField("snapshot_lock_position").
+		Description("Take a global read lock (`FLUSH TABLES WITH READ LOCK`) while the snapshot transaction is started and the binlog position is read with `SHOW MASTER STATUS`, as `mysqldump --single-transaction --master-data` does, so that every table is read at exactly the position streaming starts from. Writes are blocked until the lock is released right after, but the lock must wait for running queries to finish. Requires the `RELOAD` privilege and `snapshot_consistency: transactional`. Without it the position is read before any table, so changes committed before a table is read are delivered in the snapshot and again by the stream. Every snapshot ends with a `snapshot_complete` message holding the rows read per table, the `snapshot_binlog_file`, `snapshot_binlog_pos` and `snapshot_gtid_set` it was taken at and the `binlog_file`, `binlog_pos` and `gtid_set` streaming continues from, which always match, and an `exact` field that is `true` when it was read under this lock without retries or `snapshot_schedule` pauses.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("emit_errors_inline").
+		Description("Emit a message with the `error` event in place of a row that cannot be converted, for example because of `zero_date_behavior: error` or `unknown_type_behavior: error`, or a message that cannot be encoded in `output_format`, instead of stopping the stream. The JSON body has the `database`, `table` and `action` of the failed change, whether it is a `snapshot` row, its `binlog_file` and `binlog_pos` when known, the `error` and a `timestamp`, and its `routing_key` is `error` so that a separate branch of the pipeline can log or alert on it while other changes keep flowing. The failed change itself is not delivered.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("dead_letter_rows").
+		Description("Emit a row that cannot be converted, for example because of `zero_date_behavior: error` or `unknown_type_behavior: error`, with its column values as read from MySQL in place of the converted ones, tagged with a `dead_letter` metadata field set to `true` and the error in a `dead_letter_error` metadata field, instead of stopping the stream. A `switch` output can route these messages to a dead letter output on `@dead_letter`, where they are captured for later inspection, and the position advances past them once they are acknowledged, so that one undecodable row never blocks the stream. Values are named by their columns, or by `_<index>` for values past the columns of the schema, and binary values are base64 encoded in JSON. Dead letters are sent as soon as they are read, outside of `output_format: transaction` messages and the buffering of `committed_only`, and are counted by the `mysql_stream_rows_dead_lettered` counter. Takes precedence over `emit_errors_inline` for rows, which still applies to messages that cannot be encoded.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringMapField("action_names").
+		Description("Names to emit as the `event` of row changes, in the `event` metadata field and the message body, in place of the `insert`, `update` and `delete` actions. Actions without a name keep theirs. Other events, such as `truncate` or `transaction`, are not renamed.").
+		Example(map[string]any{"insert": "created", "update": "modified", "delete": "removed"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewStringMapField("op_names").
+		Description("Operation codes to emit per event kind in place of the default ones, so that every output format uses the same vocabulary: the `op` metadata field of every format, the `op` of the `debezium` envelope and an `op` extension attribute of `cloudevents`. The kinds are `insert`, `update` and `delete` for row changes, `read` for snapshot rows, `truncate`, `ddl` for schema changes and `heartbeat` for the markers of `emit_offset_markers`. By default they are Debezium's `c`, `u`, `d`, `r` and `t`, and schema changes and markers carry no operation code. Kinds without a code keep the default, and row changes, snapshot rows and truncates cannot be mapped to an empty code, while mapping `ddl` or `heartbeat` to one emits none. Unknown kinds are rejected. The code is independent of the `event` name, which `action_names` renames.").
+		Example(map[string]any{"insert": "create", "update": "update", "delete": "delete", "read": "snapshot", "ddl": "schema"}).
+		Advanced().
+		Default(map[string]any{})).
+	Field(service.NewStringEnumField("aurora_mode", auroraModeAuto, auroraModeOn, auroraModeOff).
+		Description("Whether to check on connect that the binlog retention of an Aurora MySQL cluster is at least `aurora_min_binlog_retention`, logging a warning otherwise. Without a retention, configured with `CALL mysql.rds_set_configuration('binlog retention hours', <hours>)`, Aurora purges binlog files as soon as no replica reads them, so a stream that is down for a while cannot resume where it stopped. With `auto` the check runs when the server is detected to be Aurora.").
+		Advanced().
+		Default(auroraModeAuto)).
+	Field(service.NewDurationField("aurora_min_binlog_retention").
+		Description("The binlog retention below which `aurora_mode` logs a warning.").
+		Advanced().
+		Default("24h")).
+	Field(service.NewDurationField("message_ttl").
+		Description("When set, messages read from the binlog carry an `expires_at` metadata field, in RFC 3339 format in UTC, with the time their event was written to the binlog plus this duration, so that downstream stores can expire the records derived from a change relative to when it happened rather than when it was processed. Snapshot rows and other messages without an event time carry no `expires_at`. When `0` no field is set.").
+		Advanced().
+		Default("0s")).
+	Field(service.NewBoolField("emit_health_events").
+		Description("Emit a message with the `health` event whenever the replication state changes: when the stream connects or stops with an error, and when a position check, see `position_check_interval`, finds that it started or stopped catching up with the end of the binlog. The body has whether the stream is `connected`, whether it is `catching_up`, whether it is `ready`, see `ready_max_files_behind`, the number of `files_behind`, the `binlog_file` and `binlog_pos` of the last handled transaction, the number of failed `reconnect_attempts` since it last connected, and the `last_error` with its `last_error_time` in Unix seconds when the stream has failed. Failed reconnects do not emit events of their own. The same state, updated on every reconnect attempt, is available to code embedding the input from `Health`.").
+		Advanced().
+		Default(false)).
+	Field(service.NewIntField("max_column_bytes").
+		Description("The maximum size of a string or binary column value in row changes, such as a large `TEXT` or `BLOB`. Longer values are cut to this many bytes, at a character boundary for strings, and their columns are listed in the `truncated_columns` metadata field, so that the rest of the row still flows. The old and new values in `changed_values` are cut likewise, while the values of `include_raw` are not. Set to `0` for no limit.").
+		Advanced().
+		Default(0)).
+	Field(service.NewStringEnumField("before_mode", beforeModeFull, beforeModeChanged, beforeModeNone).
+		Description("The `_before` section added to the body of UPDATE messages next to the column values, named with a leading underscore so that it does not collide with a column named `before`: `full` carries the whole row before the update, `changed` only the previous values of the columns that changed, and `none` leaves it out. It is part of JSON output, including the changes of `transaction` output, but not of `avro` output, and `debezium` output always carries the whole row in its own `before` image. Updates emitted as a delete and insert by `split_pk_change` have no `_before` section. Breaking change: with the default `changed`, the body of every UPDATE message carries the section, where it used to hold the column values only; set `none` to keep the previous shape.").
+		Advanced().
+		Default(beforeModeChanged)).
+	Field(service.NewIntField("metadata_pool_size").
+		Description("The maximum number of control connections kept in a pool for the server checks made on connect and the position checks of `position_check_interval`, which reuse connections rather than opening one each and never go through the replication connection. Snapshots use dedicated connections of their own.").
+		Advanced().
+		Default(2)).
+	Field(service.NewStringEnumField("on_view", onViewError, onViewResolve).
+		Description("What to do on connect when tables listed in `tables` are views, which have no binlog events of their own: fail with an `error`, or `resolve` each view to the base tables it reads from and stream those instead. Resolving views requires MySQL 8.0.13 or later.").
+		Default(onViewError)).
+	Field(service.NewBoolField("wait_until_caught_up").
+		Description("Emit a `caught_up` event once the stream has read up to the end of the binlog as it was when streaming started, after the snapshot when one is taken, so that downstream can tell the backlog from live traffic. Every message before the event was already in the binlog when streaming started. The event is emitted once per input, and its body carries the `binlog_file` and `binlog_pos` it was reached at and the `duration_ms` catching up took.").
+		Default(false)).
+	Field(service.NewStringEnumField("on_unresolved_schema", unresolvedSchemaError, unresolvedSchemaPositional).
+		Description("What to do with row changes of a table whose schema cannot be resolved, for example because the schema query fails after a DDL statement: fail the stream with an `error`, or emit them with `positional` column names, `col_0`, `col_1` and so on, and the `schema_unresolved` metadata field set to `true`, so that no change is lost. Positional values are emitted as read from the binlog, without type conversion or column transforms, and resolving the schema is retried every ten seconds. Rows with more values than the cached schema of their table has columns, as written after an `ALTER TABLE ... ADD COLUMN` the cached schema does not reflect yet, make the input read the schema again first, and the policy only applies when the current schema does not fit the rows either. Cannot be used with `avro` output.").
+		Advanced().
+		Default(unresolvedSchemaError)).
+	Field(service.NewStringMapField("timestamp_column").
+		Description("Columns, by table as a bare name in `database` or qualified as `db.table`, whose value is set as the `event_time` metadata field of the row changes of that table, in RFC 3339 format in UTC, for event time processing downstream. Values can be `DATETIME`, `TIMESTAMP` or `DATE` columns, strings in those formats or in RFC 3339 format, or integer unix timestamps in seconds. String values without a zone are read as UTC. When the value is `NULL` or not a time, the time the change was written to the binlog is used instead, and snapshot rows carry no `event_time` then.").
+		Advanced().
+		Example(map[string]any{"orders": "updated_at"}).
+		Default(map[string]any{})).
+	Field(service.NewIntField("ndjson_batch_size").
+		Description("The maximum number of messages packed into a single message when `output_format` is `ndjson_batch`. Each is a line holding an object with the `metadata` it would carry as a message of its own and its JSON body as `data`, and, when it is flagged with an error such as by `on_oversized`, the `error`. The batch message carries the `batch_size` and the `binlog_file` and `binlog_pos` of its last change, and is acknowledged as a whole, so the persisted position only advances past its changes once the whole batch is delivered.").
+		Advanced().
+		Default(100)).
+	Field(service.NewDurationField("ndjson_batch_period").
+		Description("How long a batch of `ndjson_batch` output waits for more messages after its first before it is emitted with fewer than `ndjson_batch_size`.").
+		Advanced().
+		Default("1s")).
+	Field(service.NewDurationField("ndjson_shutdown_timeout").
+		Description("How long closing the input waits for the batches of `ndjson_batch` output already emitted to be acknowledged, before the acknowledged position is written to `position_cache` a last time. A batch being filled when the pipeline shuts down is emitted with the messages read so far rather than dropped. Batches acknowledged after the timeout are not reflected in the persisted position, and their messages are delivered again on the next run. Set to `0` to not wait.").
+		Advanced().
+		Default("5s")).
+	Field(service.NewBoolField("include_server_identity").
+		Description("Set metadata fields identifying the servers a change came from, to tell changes apart when streams of several servers are merged: `server_uuid` with the `server_uuid` of the server streamed from, read once on connect, and for changes read from the binlog `server_id` with the `server_id` of the server that wrote the event, which on a replica is that of its source, and `origin_server_uuid` with the server that originally executed the transaction, taken from its GTID when the server logs MySQL GTIDs. The name of the replication channel a replica applied a change through is not recorded in the binlog and cannot be set.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringEnumField("row_checksum", rowChecksumNone, rowChecksumCRC32, rowChecksumSHA256).
+		Description("Set a `row_checksum` metadata field on row changes with the hex encoded `crc32` (IEEE) or `sha256` hash of their column values, so that downstream can recompute it to detect corruption in transit. The hash covers the column names in sorted order, each followed by a NUL byte, its value encoded as JSON with the keys of objects sorted, and another NUL byte. It is computed from the values as emitted, after column transforms and `max_column_bytes`, but before `on_oversized` truncation.").
+		Advanced().
+		Default(rowChecksumNone)).
+	Field(service.NewStringEnumField("spatial_srid", spatialSRIDOmit, spatialSRIDEmbed, spatialSRIDObject).
+		Description("How spatial values emitted as WKT or GeoJSON by `spatial_format` carry their SRID: `omit` it, `embed` it as an EWKT `SRID=4326;` prefix or a named GeoJSON `crs` of `EPSG:4326`, which is left out for the SRID `0` of Cartesian values, or emit an `object` with the `srid` next to the value as `geometry`.").
+		Advanced().
+		Default(spatialSRIDOmit)).
+	Field(service.NewIntField("snapshot_max_source_threads").
+		Description("Pause the snapshot while the server has more than this many `Threads_running`, including those of the snapshot itself and of this check, so that bootstrapping does not degrade production queries. The status is checked on a control connection at most once per `snapshot_throttle_interval`, and while the server is over the limit the snapshot waits for one interval at a time. A table read is paused for at most 30 seconds at a time, after which rows are read until the next check, since the server drops reads paused for longer than its `net_write_timeout`. Pauses are counted by the `mysql_stream_snapshot_throttles` counter and timed by `mysql_stream_snapshot_throttled_ns`. Set to `0` to never throttle.").
+		Advanced().
+		Default(0)).
+	Field(service.NewDurationField("snapshot_throttle_interval").
+		Description("How often `snapshot_max_source_threads` checks the load of the server, and how long the snapshot waits before checking again while the server is over the limit.").
+		Advanced().
+		Default("1s")).
+	Field(service.NewBoolField("snapshot_resume").
+		Description("Persist the progress of the initial snapshot to `position_cache`, under `position_cache_key` suffixed with `_snapshot`, so that an input restarted during a snapshot continues it instead of taking it again. Tables are read in primary key order, and the progress records the tables already read and the primary key of the last row acknowledged in the current one, from which reading continues, also when the table is retried after a failure. On resume the table must still exist with the same primary key columns, and tables without a primary key are read again from the start. Resumed reads no longer see the instant the snapshot started at, but streaming still continues from the position captured then, so changes made in between are delivered, some of them twice. The progress is deleted once a streamed position has been persisted. Requires `position_cache` and a snapshot.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringListField("snapshot_schedule").
+		Description("Daily time windows, such as `22:00-06:00`, during which the initial snapshot reads tables, to keep it out of peak hours. Outside every window the snapshot pauses: a table read in progress is stopped once its window closes, the snapshot connection and with it the snapshot transaction or locks are released, and reading continues after the last row read once the next window opens. Windows ending before they start run past midnight, and `24:00` ends a window at midnight. The initial snapshot runs before streaming starts, so the stream only starts once the snapshot completes, while the binlog position captured when the snapshot started must still be retained by the server by then. A table without a primary key is read again from the start after a pause. Time paused does not count toward `snapshot_timeout`, and the `mysql_stream_snapshot_paused` gauge is `1` while paused. Requires `snapshot_resume`, which also continues the snapshot when the input is restarted outside a window. Snapshots of tables added later are not scheduled. When empty the snapshot runs at any time.").
+		Example([]string{"22:00-06:00"}).
+		Advanced().
+		Default([]string{})).
+	Field(service.NewStringField("snapshot_schedule_timezone").
+		Description("The IANA time zone, such as `Europe/Berlin`, in which the times of `snapshot_schedule` are read as wall clock times, or `Local` for the time zone of the host. Windows follow daylight saving changes, as they are compared with the local time of day: a window starting in an hour skipped by a change is open from the end of that hour, and an hour repeated by a change is in or out of a window both times.").
+		Advanced().
+		Default("UTC")).
+	Field(service.NewStringEnumField("delete_minimal_behavior", deleteMinimalFlag, deleteMinimalPKOnly, deleteMinimalCache).
+		Description("How to emit deletes whose row image holds only the primary key, as deletes written with `binlog_row_image=MINIMAL` do: with `flag` the other columns are emitted as `null`, with `pk_only` they are left out, and with `cache` the last full image of the row seen in an insert, snapshot row or update, kept for up to `row_cache_size` rows, is emitted with a `from_cache` metadata field set to `true`, falling back to `pk_only` for rows not cached. Such deletes carry a `minimal_image` metadata field set to `true`. The columns present bitmaps of rows events, which tell a minimal image apart from a row whose other columns are all `NULL`, are only read with `pk_only` or `cache`, `fill_minimal_images`, `split_pk_change` or `thin_tables`, and not within compressed transactions; otherwise such a row is handled as a minimal image.").
+		Advanced().
+		Default(deleteMinimalFlag)).
+	Field(service.NewStringEnumField("temporal_output", temporalNative, temporalRFC3339, temporalUnixMillis, temporalMySQLString).
+		Description("How DATE, DATETIME, TIMESTAMP, TIME and YEAR columns are emitted. `native` emits them as read: DATE, DATETIME and TIMESTAMP as strings such as `2024-05-01 13:45:00`, or as timestamps with `parse_time`, TIME as `[-]HH:MM:SS[.fraction]` and YEAR as a number. The other outputs convert snapshot and binlog values alike, reading TIMESTAMP and DATETIME values as UTC: `rfc3339` emits `2024-05-01T13:45:00Z` with the fractional digits of the column precision, and DATE as `2024-05-01`, `unix_millis` emits milliseconds since the unix epoch, and TIME as a signed number of milliseconds, and `mysql_string` emits values as MySQL formats them, and YEAR as a four digit string. Zero dates are resolved by `zero_date_behavior` first. Cannot be combined with `parse_time`.").
+		Advanced().
+		Default(temporalNative)).
+	Field(service.NewStringField("schema_cache").
+		Description("The name of a cache resource to store the resolved schemas of the configured `tables` in, so that reconnects and restarts do not resolve each table again, which takes two queries per table. On connect the column layouts of all tables are read with a single query from `information_schema.COLUMNS`, and a cached schema is only used while its column names, types and primary key still match, so that tables changed while the input was down are resolved from the server again. Schemas changed by DDL statements while streaming are updated in the cache.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringField("schema_cache_key").
+		Description("The key the table schemas are stored under in `schema_cache`.").
+		Advanced().
+		Default("mysql_stream_schemas")).
+	Field(service.NewStringField("tables_cache").
+		Description("A cache resource polled for the set of tables to stream, which replaces `tables` while the stream stays connected. Setting `tables_key` in the cache to a comma separated list of tables, named as in `tables`, switches to them at the next transaction boundary of the stream: tables no longer listed stop being emitted, and tables added are emitted from then on and snapshotted first with `snapshot_added_tables`. The key is kept and only read again whenever its value changes. Benthos config reloads restart the input, so this is how the streamed tables are changed without a restart. `on_missing_table` and `on_view` are not applied to the polled tables, tables that do not exist yet are streamed once created. When empty no cache is polled.").
+		Advanced().
+		Default("")).
+	Field(service.NewStringField("tables_key").
+		Description("The key of the streamed tables within `tables_cache`.").
+		Advanced().
+		Default("mysql_stream_tables")).
+	Field(service.NewDurationField("tables_poll_interval").
+		Description("How often `tables_cache` is polled for the streamed tables.").
+		Advanced().
+		Default("10s")).
+	Field(service.NewBoolField("snapshot_added_tables").
+		Description("Snapshot the tables added through `tables_cache` before streaming their changes, the way `resnapshot_cache` does, so that consumers receive their existing rows.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_column_metadata").
+		Description("Add a `column_metadata` metadata field to row changes holding a JSON object that maps each column to its `type`, such as `varchar(255)`, and, where they apply, its `max_length`, `precision`, `scale` and `unsigned` flag and, with `include_nullability`, whether it is `nullable`, so that downstream systems can create target columns of the right size. `max_length` is the declared length of CHAR, VARCHAR, BINARY and VARBINARY columns, counted in characters for CHAR and VARCHAR, and the maximum length in bytes of TEXT and BLOB columns. `precision` and `scale` are those of DECIMAL columns, and `precision` is the number of fractional second digits of DATETIME, TIMESTAMP and TIME columns.").
+		Advanced().
+		Default(false)).
+	Field(service.NewStringEnumField("reconnect_jitter", reconnectJitterNone, reconnectJitterFull, reconnectJitterDecorrelated).
+		Description("Randomize the delays between reconnect attempts, so that many pipelines losing the same server do not reconnect in lockstep and overload it as it recovers. With `full` each delay before the input reconnects is drawn from zero up to `reconnect_backoff` doubled for each consecutive failed attempt, and with `decorrelated` from `reconnect_backoff` up to three times the previous delay, both capped at `reconnect_max_backoff`. The replication client, which retries a broken stream every second before the input reconnects, also waits a delay drawn the same way before each redial that follows a failed one. With `none` the input reconnects without delay, leaving the backoff to Benthos.").
+		Advanced().
+		Default(reconnectJitterNone)).
+	Field(service.NewDurationField("reconnect_backoff").
+		Description("The base delay of `reconnect_jitter`.").
+		Advanced().
+		Default("1s")).
+	Field(service.NewDurationField("reconnect_max_backoff").
+		Description("The longest delay of `reconnect_jitter`.").
+		Advanced().
+		Default("30s")).
+	Field(service.NewBoolField("tcp_keepalive").
+		Description("Send TCP keepalive probes on the replication connections, so that NATs and firewalls do not drop an idle binlog stream and a server that went away without closing the connection is detected. The binlog connection has no read timeout, so without keepalives a stream to a peer that disappeared waits for events until the operating system gives up on the connection, which can take hours. A connection whose probes go unanswered fails with a read error and is reconnected as any other broken stream.").
+		Advanced().
+		Default(true)).
+	Field(service.NewDurationField("tcp_keepalive_interval").
+		Description("How long a connection of `tcp_keepalive` is idle before the first probe, and the interval between probes after it. Keep it below the idle timeout of NATs and firewalls between the input and the server.").
+		Advanced().
+		Default("30s")).
+	Field(service.NewStringListField("thin_tables").
+		Description("Tables, named as in `tables`, whose row changes carry only their primary key columns, for consumers that only need to know that a row changed, such as cache invalidation. No other column is converted or encoded, and updates that changed the primary key carry the previous key in a `_before` section. Row changes of tables without a primary key carry no columns. `split_pk_change`, `include_changed_fields`, `before_mode` and `include_raw` do not apply to thin tables.").
+		Advanced().
+		Default([]any{})).
+	Field(service.NewBoolField("include_invisible_columns").
+		Description("Emit INVISIBLE columns (MySQL 8.0.23 and later), including the generated invisible primary key `my_row_id` that MySQL 8.0.30 and later add to tables created without a primary key when `sql_generate_invisible_primary_key` is enabled. When disabled they are left out of row changes, which costs one query per table to find them. Either way a generated invisible primary key is the primary key of its table, in `idempotency_key` and everywhere else rows are keyed, and it is added to the schema of its table when the server hides it from `SHOW COLUMNS` with `show_gipk_in_create_table_and_information_schema=OFF`, so that rows stay aligned with their columns.").
+		Advanced().
+		Default(true)).
+	Field(service.NewStringEnumField("on_unknown_action", unknownActionSkip, unknownActionError).
+		Description("What to do with rows events whose action is not an insert, update or delete, which the replication client does not produce today but may in later versions: `skip` them, counting them in the `mysql_stream_unknown_actions_skipped` metric and logging a warning the first time each action is seen, or stop the stream with an `error`.").
+		Advanced().
+		Default(unknownActionSkip)).
+	Field(service.NewIntField("num_partitions").
+		Description("When set above `0`, every row change carries a `partition` metadata field, from `0` to `num_partitions - 1`, computed as the FNV-1a hash of its schema qualified table and primary key values modulo `num_partitions`, so that a broker or sharded output keyed on it (for example with `kafka` `partitioner: manual` and `partition: ${! meta(\"partition\") }`) keeps every change of a row on the same shard and in order. The partition of a row never changes as long as `num_partitions` does not. Rows of tables without a primary key are partitioned by their table. An update that changes the primary key is partitioned by its new key and may therefore land on another partition than the earlier changes of the row, and with `split_pk_change` the delete of the old key and the insert of the new key each carry the partition of their own key.").
+		Advanced().
+		Default(0)).
+	Field(service.NewBoolField("include_source_info").
+		Description("Set a `source` metadata field on every message other than `health` events holding a JSON object with the `connector` name, `benthos-mysql-plugin`, the `version` of the plugin the binary was built with, or `(devel)` when unknown, and the `server_version` of the MySQL server as returned by `SELECT VERSION()`, read once on connect, so that consumers can tell which producer and server generated a message. With `output_format: debezium` the `version` and `server_version` are added to the `source` block of the envelope as well.").
+		Advanced().
+		Default(false)).
+	Field(service.NewBoolField("include_lsn").
+		Description("Set an `lsn` metadata field on every message with a `global_seq` or `version`, an opaque ordering token in the style of a PostgreSQL LSN for downstream systems that correlate streams of several databases by one. It is the `global_seq` of the message, or its `version` when it has none, as two upper case hexadecimal numbers of eight digits each separated by a slash, such as `00000003/0001A2F0`: the numeric suffix of the binlog file, then the position within the file. The fixed width makes tokens sort as strings in the same order as their `global_seq`, which is commit order. The token is derived from binlog coordinates even when the server logs GTIDs, as GTIDs of different server UUIDs do not sort, so tokens read from different servers, such as before and after a failover, are not comparable. Snapshot rows carry the token of the binlog position the snapshot was taken at, lower than that of every change streamed after it, and the changes of a compressed transaction carry the token of their `version`.").
+		Advanced().
+		Default(false))
 
 type ProcessEventParams struct {
 	initValue, incrementValue int
+	absent                    absentColumns
 }
 
 type StreamMessage struct {
 	Table string         `json:"table"`
 	Event string         `json:"event"`
 	Data  map[string]any `json:"data"`
+
+	ChangedFields map[string]FieldChange `json:"changed_fields,omitempty"`
+	Previous      map[string]any         `json:"before,omitempty"`
+	Raw           map[string]any         `json:"_raw,omitempty"`
+	TemporalRaw   map[string]any         `json:"_temporal_raw,omitempty"`
+
+	// rawField is the body field Raw is set in, see include_raw.
+	rawField    string
+	table       *schema.Table
+	traceparent string
+	op          string
+	opName      string
+	snapshot    string
+
+	idempotencyKey string
+	position       mysql.Position
+	globalSeq      uint64
+	version        uint64
+	truncated      []string
+	database       string
+
+	schemaUnresolved bool
+	minimalImage     bool
+	fromCache        bool
+	deadLetterError  string
+	autoIncrementID  string
+	partition        string
+	key              string
+	progress         *snapshotProgress
+	serverUUID       string
+	serverID         uint32
+	originServerUUID string
+	resume           mysql.Position
+	before           map[string]any
+	timestamp        uint32
+	sourceHost       string
+	source           *sourceInfo
+	commitTime       time.Time
+	sentAt           time.Time
 }
 
-type mysqlStreamInput struct {
-	addr      string
-	user      string
-	password  string
-	database  string
-	flavor    string
-	enableSsl bool
-	tables    []string
-	canal     *canal.Canal
+// MysqlStreamInput streams row changes from a MySQL binlog. It implements
+// service.Input and can be used directly via NewMysqlStreamInput or through
+// the mysql_stream Benthos input.
+type MysqlStreamInput struct {
+	addr               string
+	addrs              []string
+	user               string
+	password           string
+	database           string
+	flavor             string
+	tlsConfig          *tls.Config
+	metadata           metadataPool
+	metadataPoolSize   int
+	sslMode            string
+	sslCA              string
+	preferredTLSConfig *tls.Config
+	tables             []string
+	tableRefs          []tableRef
+	tableSet           map[string]struct{}
+	canal              *canal.Canal
 	canal.DummyEventHandler
 	stream         chan StreamMessage
+	bufferStats    bufferStats
+	mode           string
 	streamSnapshot bool
+	snapshotOnly   bool
+	snapshotDone   bool
+	metrics        *streamMetrics
+	logger         *service.Logger
+
+	outputFormat       string
+	maxTransactionRows int
+	onLargeTransaction string
+	txBuffer           []StreamMessage
+	txParts            int
+	inTransaction      bool
+	schemaRegistryURL  string
+	encoder            MessageEncoder
+
+	bufferSize   int
+	onBufferFull string
+
+	significantColumns map[string][]string
+	dropNoopUpdates    bool
+
+	rawEnrichments      []Enrichment
+	enrichmentCacheSize int
+	enrichments         map[string][]Enrichment
+	enrichmentCache     *lruCache[relatedRow]
+	enrichmentTargets   map[string]map[string]Enrichment
+
+	positionCheckInterval time.Duration
+	readyMaxFilesBehind   int
+	stopMonitor           context.CancelFunc
+
+	semiSync bool
+
+	connectionAttributes map[string]string
+
+	tracing             bool
+	upstreamTraceparent string
+
+	snapshotWhere map[string]string
+	rawRowFilters map[string]string
+	rowFilters    map[string]rowFilter
+
+	rawKeyTemplates map[string]string
+	keyTemplates    map[string]keyTemplate
+
+	timestampColumns map[string]string
+
+	emitLifecycleEvents  bool
+	emitOffsetMarkers    bool
+	offsetMarkerInterval time.Duration
+	rowCountInterval     time.Duration
+	rowCountMode         string
+	resumePosition       mysql.Position
+	resumeGTID           mysql.GTIDSet
+	startGTID            string
+	startGTIDSet         mysql.GTIDSet
+	startPosition        string
+	resumeAddr           string
+	positions            *positionStore
+	readerErr            chan error
+	closed               chan struct{}
+	closeOnce            sync.Once
+
+	maxReconnectAttempts int
+
+	auroraMode               string
+	auroraMinBinlogRetention time.Duration
+
+	onRepeatedNack       string
+	maxConsecutiveNacks  int
+	deadLetterCache      string
+	reconnectAttempts    int
+	reconnecting         bool
+	streamProgressed     bool
+	streamPanicked       bool
+	reconnectJitter      string
+	reconnectBackoffBase time.Duration
+	reconnectBackoffMax  time.Duration
+	reconnectBackoff     *reconnectBackoff
+	tcpKeepAlive         bool
+	tcpKeepAliveInterval time.Duration
+	disableRetrySync     bool
+	syncRetryAttempts    int
+
+	resources              *service.Resources
+	credentialsCache       string
+	credentialsUserKey     string
+	credentialsPasswordKey string
+
+	readPollTimeout time.Duration
+
+	maxEventsPerSecond int
+	limiter            *rateLimiter
+
+	fillMinimalImages     bool
+	deleteMinimalBehavior string
+	rowCacheSize          int
+	rowCache              *lruCache[[]any]
+	// columnBitmaps is set when the rows events of the canal mark the
+	// columns their row images leave out, see markAbsentColumns.
+	columnBitmaps bool
+
+	pendingSnapshotRow *StreamMessage
+
+	resnapshotCache        string
+	resnapshotKey          string
+	resnapshotPollInterval time.Duration
+	resnapshotMaxHeld      int
+	resnapshotRequests     chan []tableRef
+
+	// eventMu is held by the EventHandler while it handles an event, and by a
+	// resnapshot running alongside the stream while it emits rows. It guards
+	// the state below as well as the state of the event handlers.
+	eventMu sync.Mutex
+	// streaming is set while canal reads the binlog, when resnapshots can
+	// start.
+	streaming      bool
+	resnapshotting *resnapshotRun
+	// snapshotAlongside is set by a resnapshot running alongside the stream
+	// and snapshotEmitting while it emits under eventMu.
+	snapshotAlongside bool
+	snapshotEmitting  bool
+
+	tablesCache         string
+	tablesKey           string
+	tablesPollInterval  time.Duration
+	tablesValue         string
+	snapshotAddedTables bool
+	tableUpdates        chan []tableRef
+
+	positionCache         string
+	positionCacheKey      string
+	positionFlushInterval time.Duration
+	positionFlushEveryN   int
+	positionWriteRetries  int
+	positionWriteBackoff  time.Duration
+	inFlightLimit         int
+	inFlightSpillDir      string
+	onServerMismatch      string
+	onServerIDConflict    string
+	replicaServerID       uint32
+	onMasterSwitch        string
+	masterSwitch          *masterSwitch
+	masterSwitchSnapshot  bool
+	resumeServerUUID      string
+	serverUUID            string
+	includeServerIdentity bool
+	includeSourceInfo     bool
+	includeLSN            bool
+	sourceInfo            *sourceInfo
+	rowChecksumAlgorithm  string
+
+	debugDumpFile     string
+	debugDumpMaxBytes int64
+	debugDump         *debugDump
+
+	canalLogLevel string
+
+	zeroDateBehavior string
+	spatialFormat    string
+	spatialSRID      string
+	tinyint1AsBool   bool
+
+	valuesAsStrings     bool
+	valuesAsStringsNull string
+
+	includeGeneratedColumns bool
+
+	structuredMessages bool
+	encodeWorkers      int
+	encodePool         *encodePool
+	// failedEncode is a message that could not be encoded, encoded again by
+	// the next read before any other is delivered.
+	failedEncode *encodeJob
+
+	splitPKChange bool
+
+	includeBeforeImage bool
+
+	emitEmptyTxMarkers bool
+	txEmitted          int
+
+	committedOnly bool
+	// txParser parses the queries that end transactions on
+	// non-transactional tables under committed_only.
+	txParser *parser.Parser
+
+	includeCommitTimestamp bool
+	commitTime             time.Time
+
+	unknownTypeBehavior string
+	unknownTypesLogged  map[string]struct{}
+
+	rawColumnTransforms map[string]string
+	columnTransforms    map[string]map[string]columnTransform
+
+	includeChangedFields bool
+	includeRaw           bool
+	rawField             string
+	temporalDebug        bool
+	beforeMode           string
+	maxColumnBytes       int
+	emitHealthEvents     bool
+	health               healthState
+	messageTTL           time.Duration
+	actionNames          map[string]string
+	rawOpNames           map[string]string
+	opNames              map[string]string
+	emitErrorsInline     bool
+	deadLetterRows       bool
+
+	includeTypeHints bool
+	typeMapping      map[string]string
+	typeHintCache    *lruCache[typeHints]
+
+	columnIndexCache *lruCache[columnIndexes]
+
+	includeInvisibleColumns bool
+	invisibleCache          *lruCache[invisibleColumns]
+	gipkChecked             *lruCache[*schema.Table]
+
+	onUnknownAction     string
+	unknownActionLogged map[string]struct{}
+
+	numPartitions int
+
+	rawThinTables []string
+	thinTables    map[string]struct{}
+
+	includeColumnMetadata bool
+	columnMetadataCache   *lruCache[columnMetadataJSON]
+
+	includeSchemaFingerprint bool
+	fingerprintCache         *lruCache[schemaFingerprint]
+
+	includeNullability bool
+	nullabilityCache   *lruCache[tableNullability]
+
+	snapshotMaxRetries   int
+	snapshotRetryBackoff time.Duration
+	snapshotConsistency  string
+	snapshotLockPosition bool
+	snapshotTimeout      time.Duration
+	snapshotDeadline     time.Time
+
+	snapshotResume bool
+	progress       *snapshotProgress
+
+	rawSnapshotSchedule      []string
+	snapshotScheduleTimezone string
+	snapshotSchedule         *snapshotSchedule
+
+	snapshotMaxSourceThreads int
+	snapshotThrottleInterval time.Duration
+	throttle                 snapshotThrottle
+
+	active activeTables
+
+	maxMessageBytes    int
+	onOversized        string
+	includeMessageSize bool
+
+	checkBinlogFormat bool
+	onMissingTable    string
+	onView            string
+
+	onUnresolvedSchema string
+	unresolvedTables   map[string]unresolvedTable
+
+	ndjsonBatchSize       int
+	ndjsonBatchPeriod     time.Duration
+	ndjsonShutdownTimeout time.Duration
+	batchErr              error
+	pendingBatches        sync.WaitGroup
+
+	waitUntilCaughtUp bool
+	caughtUp          bool
+	caughtUpTarget    mysql.Position
+	caughtUpAddr      string
+	caughtUpSince     time.Time
+
+	schemaCache    string
+	schemaCacheKey string
+	cachedSchemas  map[string]cachedSchema
+
+	gapFrom     mysql.Position
+	gapExpected mysql.Position
+
+	useDecimal bool
+	parseTime  bool
+
+	temporalOutput string
+
+	includeTruncate      bool
+	includeSchemaChanges bool
+	temporaryTables      temporaryTables
+	parseDDL             bool
+	ddlParser            *parser.Parser
+	ddlStatements        ddlStatements
+
+	binlogFile string
+	gtid       string
+	// txRowsEvents counts the rows events read so far in the current
+	// transaction, whether or not their changes are emitted.
+	txRowsEvents int
+	// txVersion is the version of the next row change of a compressed
+	// transaction, see compressedVersion.
+	txVersion mysql.Position
+	// txEnd is the end of the current transaction when its GTID event
+	// records its length, see trackTransactionStart.
+	txEnd            mysql.Position
+	syncedPosition   mysql.Position
+	snapshotPosition mysql.Position
+	snapshotGTIDSet  mysql.GTIDSet
+
+	// snapshotExact is set while the snapshot being read reflects the
+	// contents of its tables at snapshotPosition exactly.
+	snapshotExact bool
 }
 
-func newMysqlStreamInput(conf *service.ParsedConfig) (service.Input, error) {
+func newMysqlStreamInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
 	var (
 		addr           string
 		user           string
@@ -54,6 +1047,145 @@ func newMysqlStreamInput(conf *service.ParsedConfig) (service.Input, error) {
 		enableSsl      bool
 		tables         []string
 		streamSnapshot bool
+		snapshotWhere  map[string]string
+		rowFilters     map[string]string
+		keyTemplates   map[string]string
+
+		outputFormat          string
+		schemaRegistryURL     string
+		maxTransactionRows    int
+		onLargeTransaction    string
+		bufferSize            int
+		onBufferFull          string
+		positionCheckInterval time.Duration
+		readyMaxFilesBehind   int
+		semiSync              bool
+		tracing               bool
+		emitLifecycleEvents   bool
+		maxReconnectAttempts  int
+
+		credentialsCache         string
+		credentialsUserKey       string
+		credentialsPasswordKey   string
+		readPollTimeout          time.Duration
+		fillMinimalImages        bool
+		rowCacheSize             int
+		zeroDateBehavior         string
+		spatialFormat            string
+		snapshotMaxRetries       int
+		snapshotRetryBackoff     time.Duration
+		maxMessageBytes          int
+		onOversized              string
+		includeMessageSize       bool
+		checkBinlogFormat        bool
+		useDecimal               bool
+		parseTime                bool
+		snapshotConsistency      string
+		unknownTypeBehavior      string
+		includeTruncate          bool
+		columnTransforms         map[string]string
+		emitOffsetMarkers        bool
+		offsetMarkerInterval     time.Duration
+		rowCountInterval         time.Duration
+		rowCountMode             string
+		maxEventsPerSecond       int
+		includeChangedFields     bool
+		disableRetrySync         bool
+		syncRetryAttempts        int
+		includeSchemaChanges     bool
+		includeTypeHints         bool
+		typeMapping              map[string]string
+		tinyint1AsBool           bool
+		valuesAsStrings          bool
+		valuesAsStringsNull      string
+		connectionAttributes     map[string]string
+		includeGeneratedColumns  bool
+		resnapshotCache          string
+		resnapshotKey            string
+		resnapshotPollInterval   time.Duration
+		splitPKChange            bool
+		sslMode                  string
+		sslCA                    string
+		structuredMessages       bool
+		encodeWorkers            int
+		onMissingTable           string
+		positionCache            string
+		positionCacheKey         string
+		positionFlushInterval    time.Duration
+		positionFlushEveryN      int
+		positionWriteRetries     int
+		positionWriteBackoff     time.Duration
+		inFlightLimit            int
+		inFlightSpillDir         string
+		debugDumpFile            string
+		debugDumpMaxBytes        int
+		canalLogLevel            string
+		onServerMismatch         string
+		onServerIDConflict       string
+		onMasterSwitch           string
+		emitEmptyTxMarkers       bool
+		includeSchemaFingerprint bool
+		includeNullability       bool
+		includeCommitTimestamp   bool
+		committedOnly            bool
+		startGTID                string
+		snapshotTimeout          time.Duration
+		startPosition            string
+		includeRaw               bool
+		temporalDebug            bool
+		onRepeatedNack           string
+		maxConsecutiveNacks      int
+		deadLetterCache          string
+		snapshotLockPosition     bool
+		emitErrorsInline         bool
+		deadLetterRows           bool
+		actionNames              map[string]string
+		opNames                  map[string]string
+		auroraMode               string
+		auroraMinBinlogRetention time.Duration
+		messageTTL               time.Duration
+		emitHealthEvents         bool
+		maxColumnBytes           int
+		beforeMode               string
+		metadataPoolSize         int
+		onView                   string
+		waitUntilCaughtUp        bool
+		onUnresolvedSchema       string
+		timestampColumns         map[string]string
+		ndjsonBatchSize          int
+		ndjsonBatchPeriod        time.Duration
+		ndjsonShutdownTimeout    time.Duration
+		includeServerIdentity    bool
+		rowChecksumAlgorithm     string
+		spatialSRID              string
+		snapshotMaxSourceThreads int
+		snapshotThrottleInterval time.Duration
+		snapshotResume           bool
+		snapshotSchedule         []string
+		snapshotScheduleTimezone string
+		deleteMinimalBehavior    string
+		temporalOutput           string
+		schemaCache              string
+		schemaCacheKey           string
+		tablesCache              string
+		tablesKey                string
+		tablesPollInterval       time.Duration
+		snapshotAddedTables      bool
+		includeColumnMetadata    bool
+		reconnectJitter          string
+		reconnectBackoff         time.Duration
+		reconnectMaxBackoff      time.Duration
+		tcpKeepAlive             bool
+		tcpKeepAliveInterval     time.Duration
+		thinTables               []string
+		includeInvisibleColumns  bool
+		onUnknownAction          string
+		enrichmentCacheSize      int
+		numPartitions            int
+		includeSourceInfo        bool
+		includeLSN               bool
+		dropNoopUpdates          bool
+		parseDDL                 bool
 	)
 
 	addr, err := conf.FieldString("addr")
@@ -62,6 +1194,12 @@ func newMysqlStreamInput(conf *service.ParsedConfig) (service.Input, error) {
 		return nil, err
 	}
 
+	addrs, err := conf.FieldStringList("addrs")
+
+	if err != nil {
+		return nil, err
+	}
+
 	user, err = conf.FieldString("user")
 
 	if err != nil {
@@ -91,128 +1229,1641 @@ func newMysqlStreamInput(conf *service.ParsedConfig) (service.Input, error) {
 		return nil, err
 	}
 
+	tables, err = conf.FieldStringList("tables")
+	if err != nil {
+		return nil, err
+	}
+
 	streamSnapshot, err = conf.FieldBool("stream_snapshot")
 	if err != nil {
 		return nil, err
 	}
 
-	return service.AutoRetryNacks(&mysqlStreamInput{
-		addr:           addr,
-		user:           user,
-		password:       password,
-		database:       database,
-		flavor:         flavor,
-		enableSsl:      enableSsl,
-		tables:         tables,
-		streamSnapshot: streamSnapshot,
-		stream:         make(chan StreamMessage),
-	}), nil
-}
+	mode, err := conf.FieldString("mode")
+	if err != nil {
+		return nil, err
+	}
 
-func init() {
-	err := service.RegisterInput(
-		"mysql_stream",
-		mongoStreamConfigSpec,
-		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
-			return newMysqlStreamInput(conf)
-		},
-	)
+	snapshotWhere, err = conf.FieldStringMap("snapshot_where")
+	if err != nil {
+		return nil, err
+	}
 
+	rowFilters, err = conf.FieldStringMap("row_filters")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-}
 
-func (m *mysqlStreamInput) Connect(ctx context.Context) error {
-	cfg := canal.NewDefaultConfig()
-	cfg.Addr = m.addr
-	cfg.User = m.user
-	cfg.Password = m.password
-	cfg.Dump.Tables = m.tables
-	cfg.Dump.TableDB = m.database
-	cfg.ServerID = 124
-	cfg.Flavor = m.flavor
-	if m.enableSsl {
-		cfg.TLSConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
+	keyTemplates, err = conf.FieldStringMap("key_templates")
+	if err != nil {
+		return nil, err
 	}
 
-	c, err := canal.NewCanal(cfg)
+	outputFormat, err = conf.FieldString("output_format")
+	if err != nil {
+		return nil, err
+	}
 
+	schemaRegistryURL, err = conf.FieldString("schema_registry_url")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	m.canal = c
+	maxTransactionRows, err = conf.FieldInt("max_transaction_rows")
+	if err != nil {
+		return nil, err
+	}
 
-	m.canal.SetEventHandler(m)
-	go m.bingLogReader()
-	return nil
-}
+	onLargeTransaction, err = conf.FieldString("on_large_transaction")
+	if err != nil {
+		return nil, err
+	}
 
-func (m *mysqlStreamInput) Close(ctx context.Context) error {
-	if m.canal != nil {
-		m.canal.Close()
+	bufferSize, err = conf.FieldInt("buffer_size")
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (m *mysqlStreamInput) processEvent(e *canal.RowsEvent, params ProcessEventParams) error {
-	for i := params.initValue; i < len(e.Rows); i += params.incrementValue {
-		message := map[string]any{}
-		for i, v := range e.Rows[i] {
-			message[e.Table.Columns[i].Name] = v
-		}
+	onBufferFull, err = conf.FieldString("on_buffer_full")
+	if err != nil {
+		return nil, err
+	}
 
-		m.stream <- StreamMessage{
-			Table: e.Table.Name,
-			Event: e.Action,
-			Data:  message,
-		}
+	significantColumns, err := parseSignificantColumns(conf)
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (m *mysqlStreamInput) OnRow(e *canal.RowsEvent) error {
-	if m.database != e.Table.Schema {
-		return nil
+	dropNoopUpdates, err = conf.FieldBool("drop_noop_updates")
+	if err != nil {
+		return nil, err
 	}
 
-	switch e.Action {
-	case canal.InsertAction:
-		return m.processEvent(e, ProcessEventParams{initValue: 0, incrementValue: 1})
-	case canal.DeleteAction:
-		return m.processEvent(e, ProcessEventParams{initValue: 0, incrementValue: 1})
-	case canal.UpdateAction:
-		return m.processEvent(e, ProcessEventParams{initValue: 1, incrementValue: 2})
-	default:
-		return errors.New("invalid rows action")
+	enrichments, err := parseEnrichments(conf)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (m *mysqlStreamInput) bingLogReader() {
-	if m.streamSnapshot {
-		// Doesn't work at the moment
-		if err := m.canal.Run(); err != nil {
-			panic(err)
-		}
-	} else {
-		coords, _ := m.canal.GetMasterPos()
-		if err := m.canal.RunFrom(coords); err != nil {
-			panic(err)
-		}
+	enrichmentCacheSize, err = conf.FieldInt("enrichment_cache_size")
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (m *mysqlStreamInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
-	streamMessage := <-m.stream
-	messageBodyEncoded, _ := json.Marshal(streamMessage.Data)
-	createdMessage := service.NewMessage(messageBodyEncoded)
-	createdMessage.MetaSet("table", streamMessage.Table)
-	createdMessage.MetaSet("event", streamMessage.Event)
+	positionCheckInterval, err = conf.FieldDuration("position_check_interval")
+	if err != nil {
+		return nil, err
+	}
 
-	return createdMessage, func(ctx context.Context, err error) error {
-		return nil
-	}, nil
+	readyMaxFilesBehind, err = conf.FieldInt("ready_max_files_behind")
+	if err != nil {
+		return nil, err
+	}
+
+	semiSync, err = conf.FieldBool("semi_sync")
+	if err != nil {
+		return nil, err
+	}
+
+	tracing, err = conf.FieldBool("tracing")
+	if err != nil {
+		return nil, err
+	}
+
+	emitLifecycleEvents, err = conf.FieldBool("emit_lifecycle_events")
+	if err != nil {
+		return nil, err
+	}
+
+	maxReconnectAttempts, err = conf.FieldInt("max_reconnect_attempts")
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsCache, err = conf.FieldString("credentials_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsUserKey, err = conf.FieldString("credentials_user_key")
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsPasswordKey, err = conf.FieldString("credentials_password_key")
+	if err != nil {
+		return nil, err
+	}
+
+	readPollTimeout, err = conf.FieldDuration("read_poll_timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	fillMinimalImages, err = conf.FieldBool("fill_minimal_images")
+	if err != nil {
+		return nil, err
+	}
+
+	rowCacheSize, err = conf.FieldInt("row_cache_size")
+	if err != nil {
+		return nil, err
+	}
+
+	zeroDateBehavior, err = conf.FieldString("zero_date_behavior")
+	if err != nil {
+		return nil, err
+	}
+
+	spatialFormat, err = conf.FieldString("spatial_format")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotMaxRetries, err = conf.FieldInt("snapshot_max_retries")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotRetryBackoff, err = conf.FieldDuration("snapshot_retry_backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	maxMessageBytes, err = conf.FieldInt("max_message_bytes")
+	if err != nil {
+		return nil, err
+	}
+
+	onOversized, err = conf.FieldString("on_oversized")
+	if err != nil {
+		return nil, err
+	}
+
+	includeMessageSize, err = conf.FieldBool("include_message_size")
+	if err != nil {
+		return nil, err
+	}
+
+	checkBinlogFormat, err = conf.FieldBool("check_binlog_format")
+	if err != nil {
+		return nil, err
+	}
+
+	useDecimal, err = conf.FieldBool("use_decimal")
+	if err != nil {
+		return nil, err
+	}
+
+	parseTime, err = conf.FieldBool("parse_time")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotConsistency, err = conf.FieldString("snapshot_consistency")
+	if err != nil {
+		return nil, err
+	}
+
+	unknownTypeBehavior, err = conf.FieldString("unknown_type_behavior")
+	if err != nil {
+		return nil, err
+	}
+
+	includeTruncate, err = conf.FieldBool("include_truncate")
+	if err != nil {
+		return nil, err
+	}
+
+	columnTransforms, err = conf.FieldStringMap("column_transforms")
+	if err != nil {
+		return nil, err
+	}
+
+	emitOffsetMarkers, err = conf.FieldBool("emit_offset_markers")
+	if err != nil {
+		return nil, err
+	}
+
+	offsetMarkerInterval, err = conf.FieldDuration("offset_marker_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	rowCountInterval, err = conf.FieldDuration("row_count_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	rowCountMode, err = conf.FieldString("row_count_mode")
+	if err != nil {
+		return nil, err
+	}
+
+	maxEventsPerSecond, err = conf.FieldInt("max_events_per_second")
+	if err != nil {
+		return nil, err
+	}
+
+	includeChangedFields, err = conf.FieldBool("include_changed_fields")
+	if err != nil {
+		return nil, err
+	}
+
+	disableRetrySync, err = conf.FieldBool("disable_retry_sync")
+	if err != nil {
+		return nil, err
+	}
+
+	syncRetryAttempts, err = conf.FieldInt("sync_retry_attempts")
+	if err != nil {
+		return nil, err
+	}
+
+	includeSchemaChanges, err = conf.FieldBool("include_schema_changes")
+	if err != nil {
+		return nil, err
+	}
+
+	parseDDL, err = conf.FieldBool("parse_ddl")
+	if err != nil {
+		return nil, err
+	}
+
+	includeTypeHints, err = conf.FieldBool("include_type_hints")
+	if err != nil {
+		return nil, err
+	}
+
+	typeMapping, err = conf.FieldStringMap("type_mapping")
+	if err != nil {
+		return nil, err
+	}
+
+	tinyint1AsBool, err = conf.FieldBool("tinyint1_as_bool")
+	if err != nil {
+		return nil, err
+	}
+
+	valuesAsStrings, err = conf.FieldBool("values_as_strings")
+	if err != nil {
+		return nil, err
+	}
+
+	valuesAsStringsNull, err = conf.FieldString("values_as_strings_null")
+	if err != nil {
+		return nil, err
+	}
+
+	connectionAttributes, err = conf.FieldStringMap("connection_attributes")
+	if err != nil {
+		return nil, err
+	}
+
+	includeGeneratedColumns, err = conf.FieldBool("include_generated_columns")
+	if err != nil {
+		return nil, err
+	}
+
+	resnapshotCache, err = conf.FieldString("resnapshot_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	resnapshotKey, err = conf.FieldString("resnapshot_key")
+	if err != nil {
+		return nil, err
+	}
+
+	resnapshotPollInterval, err = conf.FieldDuration("resnapshot_poll_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	resnapshotMaxHeld, err := conf.FieldInt("resnapshot_max_held_changes")
+	if err != nil {
+		return nil, err
+	}
+
+	splitPKChange, err = conf.FieldBool("split_pk_change")
+	if err != nil {
+		return nil, err
+	}
+
+	sslMode, err = conf.FieldString("ssl_mode")
+	if err != nil {
+		return nil, err
+	}
+
+	sslCA, err = conf.FieldString("ssl_ca")
+	if err != nil {
+		return nil, err
+	}
+
+	encodeWorkers, err = conf.FieldInt("encode_workers")
+	if err != nil {
+		return nil, err
+	}
+
+	structuredMessages, err = conf.FieldBool("structured_messages")
+	if err != nil {
+		return nil, err
+	}
+
+	onMissingTable, err = conf.FieldString("on_missing_table")
+	if err != nil {
+		return nil, err
+	}
+
+	positionCache, err = conf.FieldString("position_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	positionCacheKey, err = conf.FieldString("position_cache_key")
+	if err != nil {
+		return nil, err
+	}
+
+	positionFlushInterval, err = conf.FieldDuration("position_flush_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	positionFlushEveryN, err = conf.FieldInt("position_flush_every_n")
+	if err != nil {
+		return nil, err
+	}
+
+	positionWriteRetries, err = conf.FieldInt("position_write_retries")
+	if err != nil {
+		return nil, err
+	}
+
+	positionWriteBackoff, err = conf.FieldDuration("position_write_backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightLimit, err = conf.FieldInt("in_flight_limit")
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightSpillDir, err = conf.FieldString("in_flight_spill_dir")
+	if err != nil {
+		return nil, err
+	}
+
+	debugDumpFile, err = conf.FieldString("debug_dump_file")
+	if err != nil {
+		return nil, err
+	}
+
+	debugDumpMaxBytes, err = conf.FieldInt("debug_dump_max_bytes")
+	if err != nil {
+		return nil, err
+	}
+
+	canalLogLevel, err = conf.FieldString("canal_log_level")
+	if err != nil {
+		return nil, err
+	}
+
+	onServerMismatch, err = conf.FieldString("on_server_mismatch")
+	if err != nil {
+		return nil, err
+	}
+
+	onServerIDConflict, err = conf.FieldString("on_server_id_conflict")
+	if err != nil {
+		return nil, err
+	}
+
+	onMasterSwitch, err = conf.FieldString("on_master_switch")
+	if err != nil {
+		return nil, err
+	}
+
+	emitEmptyTxMarkers, err = conf.FieldBool("emit_empty_tx_markers")
+	if err != nil {
+		return nil, err
+	}
+
+	includeSchemaFingerprint, err = conf.FieldBool("include_schema_fingerprint")
+	if err != nil {
+		return nil, err
+	}
+
+	includeNullability, err = conf.FieldBool("include_nullability")
+	if err != nil {
+		return nil, err
+	}
+
+	includeCommitTimestamp, err = conf.FieldBool("include_commit_timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	committedOnly, err = conf.FieldBool("committed_only")
+	if err != nil {
+		return nil, err
+	}
+
+	startGTID, err = conf.FieldString("start_gtid_set")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotTimeout, err = conf.FieldDuration("snapshot_timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	startPosition, err = conf.FieldString("start_position")
+	if err != nil {
+		return nil, err
+	}
+
+	includeRaw, err = conf.FieldBool("include_raw")
+	if err != nil {
+		return nil, err
+	}
+
+	rawField, err := conf.FieldString("raw_field")
+	if err != nil {
+		return nil, err
+	}
+
+	temporalDebug, err = conf.FieldBool("temporal_debug")
+	if err != nil {
+		return nil, err
+	}
+
+	onRepeatedNack, err = conf.FieldString("on_repeated_nack")
+	if err != nil {
+		return nil, err
+	}
+
+	maxConsecutiveNacks, err = conf.FieldInt("max_consecutive_nacks")
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetterCache, err = conf.FieldString("dead_letter_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotLockPosition, err = conf.FieldBool("snapshot_lock_position")
+	if err != nil {
+		return nil, err
+	}
+
+	emitErrorsInline, err = conf.FieldBool("emit_errors_inline")
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetterRows, err = conf.FieldBool("dead_letter_rows")
+	if err != nil {
+		return nil, err
+	}
+
+	actionNames, err = conf.FieldStringMap("action_names")
+	if err != nil {
+		return nil, err
+	}
+
+	opNames, err = conf.FieldStringMap("op_names")
+	if err != nil {
+		return nil, err
+	}
+
+	auroraMode, err = conf.FieldString("aurora_mode")
+	if err != nil {
+		return nil, err
+	}
+
+	auroraMinBinlogRetention, err = conf.FieldDuration("aurora_min_binlog_retention")
+	if err != nil {
+		return nil, err
+	}
+
+	messageTTL, err = conf.FieldDuration("message_ttl")
+	if err != nil {
+		return nil, err
+	}
+
+	emitHealthEvents, err = conf.FieldBool("emit_health_events")
+	if err != nil {
+		return nil, err
+	}
+
+	maxColumnBytes, err = conf.FieldInt("max_column_bytes")
+	if err != nil {
+		return nil, err
+	}
+
+	beforeMode, err = conf.FieldString("before_mode")
+	if err != nil {
+		return nil, err
+	}
+
+	metadataPoolSize, err = conf.FieldInt("metadata_pool_size")
+	if err != nil {
+		return nil, err
+	}
+
+	onView, err = conf.FieldString("on_view")
+	if err != nil {
+		return nil, err
+	}
+
+	waitUntilCaughtUp, err = conf.FieldBool("wait_until_caught_up")
+	if err != nil {
+		return nil, err
+	}
+
+	onUnresolvedSchema, err = conf.FieldString("on_unresolved_schema")
+	if err != nil {
+		return nil, err
+	}
+
+	timestampColumns, err = conf.FieldStringMap("timestamp_column")
+	if err != nil {
+		return nil, err
+	}
+
+	ndjsonBatchSize, err = conf.FieldInt("ndjson_batch_size")
+	if err != nil {
+		return nil, err
+	}
+
+	ndjsonBatchPeriod, err = conf.FieldDuration("ndjson_batch_period")
+	if err != nil {
+		return nil, err
+	}
+
+	ndjsonShutdownTimeout, err = conf.FieldDuration("ndjson_shutdown_timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	includeServerIdentity, err = conf.FieldBool("include_server_identity")
+	if err != nil {
+		return nil, err
+	}
+
+	rowChecksumAlgorithm, err = conf.FieldString("row_checksum")
+	if err != nil {
+		return nil, err
+	}
+
+	spatialSRID, err = conf.FieldString("spatial_srid")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotMaxSourceThreads, err = conf.FieldInt("snapshot_max_source_threads")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotThrottleInterval, err = conf.FieldDuration("snapshot_throttle_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotResume, err = conf.FieldBool("snapshot_resume")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotSchedule, err = conf.FieldStringList("snapshot_schedule")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotScheduleTimezone, err = conf.FieldString("snapshot_schedule_timezone")
+	if err != nil {
+		return nil, err
+	}
+
+	deleteMinimalBehavior, err = conf.FieldString("delete_minimal_behavior")
+	if err != nil {
+		return nil, err
+	}
+
+	temporalOutput, err = conf.FieldString("temporal_output")
+	if err != nil {
+		return nil, err
+	}
+
+	schemaCache, err = conf.FieldString("schema_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	schemaCacheKey, err = conf.FieldString("schema_cache_key")
+	if err != nil {
+		return nil, err
+	}
+
+	tablesCache, err = conf.FieldString("tables_cache")
+	if err != nil {
+		return nil, err
+	}
+
+	tablesKey, err = conf.FieldString("tables_key")
+	if err != nil {
+		return nil, err
+	}
+
+	tablesPollInterval, err = conf.FieldDuration("tables_poll_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotAddedTables, err = conf.FieldBool("snapshot_added_tables")
+	if err != nil {
+		return nil, err
+	}
+
+	includeColumnMetadata, err = conf.FieldBool("include_column_metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	reconnectJitter, err = conf.FieldString("reconnect_jitter")
+	if err != nil {
+		return nil, err
+	}
+
+	reconnectBackoff, err = conf.FieldDuration("reconnect_backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	reconnectMaxBackoff, err = conf.FieldDuration("reconnect_max_backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	tcpKeepAlive, err = conf.FieldBool("tcp_keepalive")
+	if err != nil {
+		return nil, err
+	}
+
+	tcpKeepAliveInterval, err = conf.FieldDuration("tcp_keepalive_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	thinTables, err = conf.FieldStringList("thin_tables")
+	if err != nil {
+		return nil, err
+	}
+
+	includeInvisibleColumns, err = conf.FieldBool("include_invisible_columns")
+	if err != nil {
+		return nil, err
+	}
+
+	onUnknownAction, err = conf.FieldString("on_unknown_action")
+	if err != nil {
+		return nil, err
+	}
+
+	numPartitions, err = conf.FieldInt("num_partitions")
+	if err != nil {
+		return nil, err
+	}
+
+	includeSourceInfo, err = conf.FieldBool("include_source_info")
+	if err != nil {
+		return nil, err
+	}
+
+	includeLSN, err = conf.FieldBool("include_lsn")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []Option{
+		WithAddr(addr),
+		WithAddrs(addrs),
+		WithUser(user),
+		WithPassword(password),
+		WithDatabase(database),
+		WithFlavor(flavor),
+		WithTables(tables...),
+		WithStreamSnapshot(streamSnapshot),
+		WithSnapshotWhere(snapshotWhere),
+		WithRowFilters(rowFilters),
+		WithKeyTemplates(keyTemplates),
+		WithOutputFormat(outputFormat),
+		WithSchemaRegistryURL(schemaRegistryURL),
+		WithMaxTransactionRows(maxTransactionRows),
+		WithOnLargeTransaction(onLargeTransaction),
+		WithBufferSize(bufferSize),
+		WithOnBufferFull(onBufferFull),
+		WithSignificantColumns(significantColumns),
+		WithDropNoopUpdates(dropNoopUpdates),
+		WithEnrichments(enrichments, enrichmentCacheSize),
+		WithPositionCheckInterval(positionCheckInterval),
+		WithReadyMaxFilesBehind(readyMaxFilesBehind),
+		WithSemiSync(semiSync),
+		WithTracing(tracing),
+		WithLifecycleEvents(emitLifecycleEvents),
+		WithMaxReconnectAttempts(maxReconnectAttempts),
+		WithCredentialsCache(credentialsCache, credentialsUserKey, credentialsPasswordKey),
+		WithReadPollTimeout(readPollTimeout),
+		WithFillMinimalImages(fillMinimalImages, rowCacheSize),
+		WithZeroDateBehavior(zeroDateBehavior),
+		WithSpatialFormat(spatialFormat),
+		WithSnapshotRetries(snapshotMaxRetries, snapshotRetryBackoff),
+		WithMaxMessageBytes(maxMessageBytes, onOversized),
+		WithMessageSize(includeMessageSize),
+		WithBinlogFormatCheck(checkBinlogFormat),
+		WithUseDecimal(useDecimal),
+		WithParseTime(parseTime),
+		WithSnapshotConsistency(snapshotConsistency),
+		WithUnknownTypeBehavior(unknownTypeBehavior),
+		WithTruncateEvents(includeTruncate),
+		WithColumnTransforms(columnTransforms),
+		WithOffsetMarkers(emitOffsetMarkers, offsetMarkerInterval),
+		WithRowCounts(rowCountInterval, rowCountMode),
+		WithMaxEventsPerSecond(maxEventsPerSecond),
+		WithChangedFields(includeChangedFields),
+		WithSyncRetry(disableRetrySync, syncRetryAttempts),
+		WithSchemaChangeEvents(includeSchemaChanges),
+		WithParseDDL(parseDDL),
+		WithTypeHints(includeTypeHints, typeMapping),
+		WithTinyint1AsBool(tinyint1AsBool),
+		WithValuesAsStrings(valuesAsStrings, valuesAsStringsNull),
+		WithConnectionAttributes(connectionAttributes),
+		WithGeneratedColumns(includeGeneratedColumns),
+		WithResnapshotCache(resnapshotCache, resnapshotKey, resnapshotPollInterval),
+		WithResnapshotMaxHeldChanges(resnapshotMaxHeld),
+		WithSplitPKChange(splitPKChange),
+		WithSSLMode(sslMode, sslCA),
+		WithEncodeWorkers(encodeWorkers),
+		WithStructuredMessages(structuredMessages),
+		WithOnMissingTable(onMissingTable),
+		WithPositionCache(positionCache, positionCacheKey),
+		WithPositionFlush(positionFlushInterval, positionFlushEveryN),
+		WithPositionWriteRetries(positionWriteRetries, positionWriteBackoff),
+		WithInFlightLimit(inFlightLimit, inFlightSpillDir),
+		WithDebugDump(debugDumpFile, int64(debugDumpMaxBytes)),
+		WithCanalLogLevel(canalLogLevel),
+		WithOnServerMismatch(onServerMismatch),
+		WithOnServerIDConflict(onServerIDConflict),
+		WithOnMasterSwitch(onMasterSwitch),
+		WithEmptyTxMarkers(emitEmptyTxMarkers),
+		WithSchemaFingerprint(includeSchemaFingerprint),
+		WithNullability(includeNullability),
+		WithCommitTimestamp(includeCommitTimestamp),
+		WithCommittedOnly(committedOnly),
+		WithStartGTIDSet(startGTID),
+		WithSnapshotTimeout(snapshotTimeout),
+		WithStartPosition(startPosition),
+		WithRawValues(includeRaw),
+		WithRawField(rawField),
+		WithTemporalDebug(temporalDebug),
+		WithRepeatedNack(onRepeatedNack, maxConsecutiveNacks, deadLetterCache),
+		WithSnapshotLockPosition(snapshotLockPosition),
+		WithInlineErrors(emitErrorsInline),
+		WithDeadLetterRows(deadLetterRows),
+		WithActionNames(actionNames),
+		WithOpNames(opNames),
+		WithAuroraMode(auroraMode, auroraMinBinlogRetention),
+		WithMessageTTL(messageTTL),
+		WithHealthEvents(emitHealthEvents),
+		WithMaxColumnBytes(maxColumnBytes),
+		WithBeforeMode(beforeMode),
+		WithMetadataPoolSize(metadataPoolSize),
+		WithOnView(onView),
+		WithWaitUntilCaughtUp(waitUntilCaughtUp),
+		WithOnUnresolvedSchema(onUnresolvedSchema),
+		WithTimestampColumns(timestampColumns),
+		WithNDJSONBatch(ndjsonBatchSize, ndjsonBatchPeriod),
+		WithNDJSONShutdownTimeout(ndjsonShutdownTimeout),
+		WithServerIdentity(includeServerIdentity),
+		WithRowChecksum(rowChecksumAlgorithm),
+		WithSpatialSRID(spatialSRID),
+		WithSnapshotThrottle(snapshotMaxSourceThreads, snapshotThrottleInterval),
+		WithSnapshotResume(snapshotResume),
+		WithSnapshotSchedule(snapshotSchedule, snapshotScheduleTimezone),
+		WithDeleteMinimalBehavior(deleteMinimalBehavior),
+		WithTemporalOutput(temporalOutput),
+		WithSchemaCache(schemaCache, schemaCacheKey),
+		WithTablesCache(tablesCache, tablesKey, tablesPollInterval, snapshotAddedTables),
+		WithColumnMetadata(includeColumnMetadata),
+		WithReconnectJitter(reconnectJitter, reconnectBackoff, reconnectMaxBackoff),
+		WithTCPKeepAlive(tcpKeepAlive, tcpKeepAliveInterval),
+		WithThinTables(thinTables),
+		WithInvisibleColumns(includeInvisibleColumns),
+		WithOnUnknownAction(onUnknownAction),
+		WithNumPartitions(numPartitions),
+		WithSourceInfo(includeSourceInfo),
+		WithLSN(includeLSN),
+		WithResources(mgr),
+	}
+	if mode == "" {
+		mode = modeStreamOnly
+		if streamSnapshot {
+			mode = modeSnapshotAndStream
+		}
+	}
+	opts = append(opts, WithMode(mode))
+	if enableSsl && sslMode == "" {
+		opts = append(opts, WithSSLMode(sslModeRequired, sslCA))
+	}
+
+	input, err := NewMysqlStreamInput(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return input.withNackBreaker(service.AutoRetryNacks(input)), nil
+}
+
+func init() {
+	err := service.RegisterInput(
+		"mysql_stream",
+		mongoStreamConfigSpec,
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+			return newMysqlStreamInputFromConfig(conf, mgr)
+		},
+	)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (m *MysqlStreamInput) Connect(ctx context.Context) error {
+	if m.reconnecting {
+		m.reconnectAttempts++
+		if err := m.reconnectBackoff.wait(ctx); err != nil {
+			return err
+		}
+		m.logger.Infof("Reconnect attempt %d", m.reconnectAttempts)
+	}
+
+	if m.stopMonitor != nil {
+		m.stopMonitor()
+	}
+
+	if err := m.refreshCredentials(ctx); err != nil {
+		return err
+	}
+
+	var c *canal.Canal
+	var err error
+	for _, addr := range m.candidateAddrs() {
+		m.useAddr(addr)
+		if c, err = m.connectCanal(ctx); err == nil {
+			break
+		}
+		if len(m.addrs) > 1 {
+			m.logger.Warnf("Failed to connect to %s: %v", addr, err)
+		}
+	}
+
+	if err != nil {
+		m.recordReconnectFailure(err, m.reconnectAttempts)
+		if m.reconnecting && m.maxReconnectAttempts > 0 && m.reconnectAttempts >= m.maxReconnectAttempts {
+			return fmt.Errorf("%w: giving up after %d reconnect attempts: %v", service.ErrEndOfInput, m.reconnectAttempts, err)
+		}
+		return err
+	}
+	m.reconnecting = false
+
+	if m.canal != nil {
+		m.canal.Close()
+	}
+	m.canal = c
+
+	m.canal.SetEventHandler(EventHandler{m: m})
+	go m.bingLogReader(c)
+
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	m.stopMonitor = cancel
+	if m.positionCheckInterval > 0 {
+		go m.monitorPosition(monitorCtx, m.metadataKey())
+	}
+	if m.emitOffsetMarkers && m.offsetMarkerInterval > 0 {
+		go m.emitOffsets(monitorCtx, c)
+	}
+	if m.rowCountInterval > 0 {
+		go m.emitRowCounts(monitorCtx, c)
+	}
+	go m.runResnapshots(monitorCtx)
+	if m.resnapshotCache != "" {
+		go m.pollResnapshotRequests(monitorCtx)
+	}
+	if m.tablesCache != "" {
+		go m.pollTableUpdates(monitorCtx)
+	}
+	if m.positions != nil && m.positionFlushInterval > 0 {
+		go m.positions.flushPeriodically(monitorCtx, m.positionFlushInterval)
+	}
+	return nil
+}
+
+// connectCanal checks the server at the current address and creates a canal
+// streaming from it.
+func (m *MysqlStreamInput) connectCanal(ctx context.Context) (*canal.Canal, error) {
+	if m.sslMode == sslModePreferred {
+		if err := m.negotiatePreferredTLS(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.checkServer(); err != nil {
+		return nil, err
+	}
+	if err := m.checkMasterSwitch(); err != nil {
+		return nil, err
+	}
+
+	if m.positions != nil && m.resumePosition.Name == "" && !m.masterSwitchSnapshot {
+		if err := m.resumePersistedPosition(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := newCanalRecovering(m.canalConfig())
+	if err != nil {
+		return nil, err
+	}
+	if m.usesColumnBitmaps() {
+		m.columnBitmaps = markAbsentColumns(c)
+	}
+	return c, nil
+}
+
+// resumePersistedPosition resumes from the persisted position, checking with
+// on_server_mismatch that it was stored for the connected server. Positions
+// stored without a server_uuid are resumed from on any server.
+func (m *MysqlStreamInput) resumePersistedPosition(ctx context.Context) error {
+	pos, uuid, err := m.positions.load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading persisted position: %w", err)
+	}
+	if pos.Name != "" && uuid != "" && m.serverUUID != "" && uuid != m.serverUUID {
+		if m.onServerMismatch != serverMismatchReset {
+			return fmt.Errorf("persisted position %s:%d belongs to server %s, not the connected server %s", pos.Name, pos.Pos, uuid, m.serverUUID)
+		}
+		m.logger.Warnf("Discarding persisted position %s:%d of server %s, connected to server %s", pos.Name, pos.Pos, uuid, m.serverUUID)
+		m.gapFrom, pos = pos, mysql.Position{}
+	}
+	if pos.Name != "" {
+		m.logger.Infof("Resuming from persisted position %s:%d", pos.Name, pos.Pos)
+		m.resumePosition = pos
+	}
+	return nil
+}
+
+// canalConfig returns the config of a canal streaming from the current
+// address.
+func (m *MysqlStreamInput) canalConfig() *canal.Config {
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr = m.addr
+	cfg.User = m.user
+	cfg.Password = m.password
+	m.configureTables(cfg)
+	cfg.ServerID = m.replicaServerID
+	cfg.Flavor = m.flavor
+	// A negative KeepAlive disables keepalive probes, while zero would
+	// leave them at the default of the net package.
+	dialer := &net.Dialer{KeepAlive: -1}
+	if m.tcpKeepAlive {
+		dialer.KeepAlive = m.tcpKeepAliveInterval
+	}
+	cfg.Dialer = m.metrics.wrapDialer(dialer.DialContext)
+	if m.reconnectJitter != reconnectJitterNone {
+		// Each canal gets its own backoff, as its redials are independent
+		// of the reconnects of the input.
+		cfg.Dialer = newReconnectBackoff(m.reconnectJitter, m.reconnectBackoffBase, m.reconnectBackoffMax).jitterDialer(cfg.Dialer)
+	}
+	cfg.TLSConfig = m.tlsConfig
+	cfg.SemiSyncEnabled = m.semiSync
+	cfg.UseDecimal = m.useDecimal
+	cfg.ParseTime = m.parseTime
+	if m.temporalOutput != temporalNative {
+		cfg.TimestampStringLocation = time.UTC
+	}
+	cfg.DisableRetrySync = m.disableRetrySync
+	cfg.MaxReconnectAttempts = m.syncRetryAttempts
+	cfg.Logger = newCanalLogger(m.logger, m.canalLogLevel)
+	return cfg
+}
+
+// newCanalRecovering creates a canal, turning a fatal message canal logs
+// while setting up into a connect error.
+func newCanalRecovering(cfg *canal.Config) (c *canal.Canal, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fatal, ok := r.(canalFatal)
+			if !ok {
+				panic(r)
+			}
+			c, err = nil, fmt.Errorf("canal: %s", string(fatal))
+		}
+	}()
+	return canal.NewCanal(cfg)
+}
+
+func (m *MysqlStreamInput) Close(ctx context.Context) error {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+	if m.stopMonitor != nil {
+		m.stopMonitor()
+	}
+	if m.canal != nil {
+		m.canal.Close()
+	}
+	m.metadata.close()
+	if m.outputFormat == outputFormatNDJSONBatch {
+		m.awaitPendingBatches(ctx)
+	}
+	if m.debugDump != nil {
+		if err := m.debugDump.close(); err != nil {
+			m.logger.Warnf("Failed to close debug dump file: %v", err)
+		}
+	}
+	if m.positions != nil {
+		err := m.positions.flush(ctx)
+		if closeErr := m.positions.close(); closeErr != nil {
+			m.logger.Warnf("Failed to remove in flight spill file: %v", closeErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *MysqlStreamInput) processEvent(e *canal.RowsEvent, params ProcessEventParams) error {
+	// Update rows come in before and after image pairs, rows 2i and 2i+1.
+	if e.Action == canal.UpdateAction && len(e.Rows)%2 != 0 {
+		return fmt.Errorf("update event on %s has %d rows, expected before and after image pairs", e.Table, len(e.Rows))
+	}
+
+	var fromCache map[int]bool
+	if m.rowCache != nil {
+		fromCache = m.fillRowImages(e, params.absent)
+	}
+	if m.enrichmentCache != nil {
+		m.invalidateEnrichments(e)
+	}
+
+	var columnIndex map[string]int
+	if _, ok := m.significantColumns[tableRef{schema: e.Table.Schema, name: e.Table.Name}.key()]; ok && e.Action == canal.UpdateAction {
+		columnIndex = m.columnIndexFor(e.Table)
+	}
+
+	transforms := m.columnTransforms[tableRef{schema: e.Table.Schema, name: e.Table.Name}.key()]
+	filter := m.rowFilters[tableRef{schema: e.Table.Schema, name: e.Table.Name}.key()]
+
+	var position mysql.Position
+	switch {
+	case isCompressed(e.Header):
+		position = mysql.Position{Name: m.binlogFile, Pos: m.syncedPosition.Pos}
+	case e.Header != nil:
+		position = mysql.Position{Name: m.binlogFile, Pos: e.Header.LogPos}
+	}
+
+	thin := m.isThin(e.Table)
+	splitPKChanges := !thin && m.splitPKChange && e.Action == canal.UpdateAction && len(e.Table.PKColumns) > 0
+
+	rows := len(e.Rows) / params.incrementValue
+	for i := params.initValue; i < len(e.Rows); i += params.incrementValue {
+		row := (i - params.initValue) / params.incrementValue
+		var seq uint64
+		switch {
+		case isCompressed(e.Header):
+			// Compressed row changes share the position of their transaction.
+		case splitPKChanges:
+			// Updates split into a delete and insert take up two slots, see
+			// emitPKChange.
+			seq = globalSeq(position, 2*row+1, 2*rows)
+		default:
+			seq = globalSeq(position, row, rows)
+		}
+		if m.dropNoopUpdates && e.Action == canal.UpdateAction && isNoopUpdate(e.Rows[i-1], e.Rows[i]) {
+			m.metrics.noopUpdatesDropped.Incr(1)
+			continue
+		}
+		if columnIndex != nil && !m.hasSignificantChange(e.Table, columnIndex, e.Rows[i-1], e.Rows[i]) {
+			continue
+		}
+		// Snapshot rows are filtered by the snapshot query.
+		if filter != nil && e.Header != nil {
+			match, err := m.filterMatches(filter, e, i)
+			if err != nil {
+				if err := m.rowError(e, i, position, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if !match {
+				continue
+			}
+		}
+		if thin {
+			if err := m.emitThin(e, params.absent, i, row, position, seq); err != nil {
+				return err
+			}
+			continue
+		}
+
+		message, err := m.rowData(e.Table, e.Rows[i], transforms)
+		if err != nil {
+			if err := m.rowError(e, i, position, err); err != nil {
+				return err
+			}
+			continue
+		}
+		absent, known := params.absent.of(i)
+		minimal := e.Action == canal.DeleteAction && isMinimalImage(e.Table, e.Rows[i], absent, known)
+		if minimal && m.deleteMinimalBehavior != deleteMinimalFlag {
+			message = pkOnly(e.Table, message)
+		}
+
+		if splitPKChanges && pkChanged(e.Table, e.Rows[i-1], e.Rows[i], params.absent, i) {
+			before, err := m.rowData(e.Table, e.Rows[i-1], transforms)
+			if err == nil {
+				err = m.enrich(e.Table, e.Rows[i], message)
+			}
+			if err != nil {
+				if err := m.rowError(e, i, position, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := m.emitPKChange(e, before, message, i, row, rows, position); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var before map[string]any
+		if e.Action == canal.UpdateAction && (m.includeChangedFields || m.includeBeforeImage || m.beforeMode != beforeModeNone) {
+			if before, err = m.rowData(e.Table, e.Rows[i-1], transforms); err != nil {
+				if err := m.rowError(e, i, position, err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		var changed map[string]FieldChange
+		if before != nil && (m.includeChangedFields || m.beforeMode == beforeModeChanged) {
+			changed = changedFields(before, message)
+		}
+		truncated := m.truncateColumns(message, changed)
+		m.truncateColumns(before, nil)
+		previous := m.previousValues(before, changed)
+		if !m.includeChangedFields {
+			changed = nil
+		}
+		// Messages are enriched once changes are found, so that the added
+		// fields are not reported as changed.
+		if err := m.enrich(e.Table, e.Rows[i], message); err != nil {
+			if err := m.rowError(e, i, position, err); err != nil {
+				return err
+			}
+			continue
+		}
+		var raw, temporalRaw map[string]any
+		if m.includeRaw {
+			raw = m.rawData(e.Table, e.Rows[i])
+		}
+		if m.temporalDebug {
+			temporalRaw = m.temporalRawData(e.Table, e.Rows[i])
+		}
+		key, err := m.messageKey(e.Table, e.Rows[i])
+		if err != nil {
+			if err := m.rowError(e, i, position, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = m.emit(e, StreamMessage{
+			Table:           e.Table.Name,
+			Event:           e.Action,
+			Data:            message,
+			ChangedFields:   changed,
+			Previous:        previous,
+			Raw:             raw,
+			rawField:        m.rawField,
+			TemporalRaw:     temporalRaw,
+			table:           e.Table,
+			traceparent:     m.upstreamTraceparent,
+			idempotencyKey:  m.idempotencyKey(e, e.Rows[i], row),
+			position:        position,
+			globalSeq:       seq,
+			before:          before,
+			truncated:       truncated,
+			minimalImage:    minimal,
+			fromCache:       fromCache[i],
+			autoIncrementID: autoIncrementID(e, e.Rows[i]),
+			partition:       m.partition(e.Table, e.Rows[i]),
+			key:             key,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowData converts a row image into the column values of a message. Values
+// are paired with columns by position, so an image whose width does not match
+// the schema, such as one written before an ALTER that the cached schema
+// already reflects, is rejected rather than emitted under the wrong names.
+func (m *MysqlStreamInput) rowData(table *schema.Table, row []any, transforms map[string]columnTransform) (map[string]any, error) {
+	if len(row) != len(table.Columns) {
+		return nil, fmt.Errorf("row of %s has %d values but its schema has %d columns", table, len(row), len(table.Columns))
+	}
+	invisible := m.invisibleColumnsOf(table)
+	data := make(map[string]any, len(table.Columns))
+	for i, v := range row {
+		col := table.Columns[i]
+		if m.skipColumn(col, v) || invisible[col.Name] {
+			continue
+		}
+		v, err := m.convertValue(col, v)
+		if errors.Is(err, errSkipColumn) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if transform, ok := transforms[col.Name]; ok && v != nil {
+			v = transform(v)
+		}
+		if m.valuesAsStrings {
+			v = m.stringValue(v)
+		}
+		data[col.Name] = v
+	}
+	return data, nil
+}
+
+func (m *MysqlStreamInput) OnRow(e *canal.RowsEvent) (err error) {
+	defer m.recoverRowsEvent(e, &err)
+
+	if e.Header != nil {
+		m.beginTransaction()
+		m.txRowsEvents++
+	}
+	if m.debugDump != nil {
+		m.debugDump.write(e, m.binlogFile)
+	}
+	if !m.isTableStreamed(e.Table.Schema, e.Table.Name) {
+		return nil
+	}
+	m.markTableActive(e.Table)
+	m.countRowsEvent(e.Header)
+
+	table, unresolved := m.resolvePlaceholder(e)
+	if !unresolved && hasWiderRows(e) {
+		var err error
+		if table, unresolved, err = m.refreshStaleSchema(e); err != nil {
+			return err
+		}
+	}
+	e.Table = table
+	if unresolved {
+		m.takeAbsentColumns(e)
+		switch e.Action {
+		case canal.UpdateAction:
+			return m.emitPositional(e, ProcessEventParams{initValue: 1, incrementValue: 2})
+		default:
+			return m.emitPositional(e, ProcessEventParams{initValue: 0, incrementValue: 1})
+		}
+	}
+	m.alignInvisiblePK(e)
+	alignGeneratedColumns(e)
+	absent := m.takeAbsentColumns(e)
+
+	switch e.Action {
+	case canal.InsertAction:
+		return m.processEvent(e, ProcessEventParams{initValue: 0, incrementValue: 1, absent: absent})
+	case canal.DeleteAction:
+		return m.processEvent(e, ProcessEventParams{initValue: 0, incrementValue: 1, absent: absent})
+	case canal.UpdateAction:
+		return m.processEvent(e, ProcessEventParams{initValue: 1, incrementValue: 2, absent: absent})
+	default:
+		return m.unknownAction(e)
+	}
+}
+
+func (m *MysqlStreamInput) bingLogReader(c *canal.Canal) {
+	err := m.runBinlog(c)
+	if err != nil && !errors.Is(err, errSnapshotOnlyDone) {
+		m.recordStreamError(err)
+	}
+	m.readerErr <- err
+}
+
+// errReadPollTimeout is returned by Read when no change arrives within
+// read_poll_timeout, after which Benthos backs off and reads again.
+var errReadPollTimeout = fmt.Errorf("no change within read_poll_timeout: %w", context.DeadlineExceeded)
+
+func (m *MysqlStreamInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	if m.outputFormat == outputFormatNDJSONBatch {
+		return m.readNDJSONBatch(ctx)
+	}
+	return m.readMessage(ctx)
+}
+
+// readMessage reads the next message from the stream.
+func (m *MysqlStreamInput) readMessage(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	var pollTimeout <-chan time.Time
+	if m.readPollTimeout > 0 {
+		timer := time.NewTimer(m.readPollTimeout)
+		defer timer.Stop()
+		pollTimeout = timer.C
+	}
+	if m.encodeWorkers > 1 && m.encodePool == nil {
+		m.encodePool = m.startEncodePool()
+	}
+	if m.positions != nil && m.positions.writeFailed() != nil {
+		// Nothing more is read until the position is persisted again, so that
+		// the changes delivered again after a crash do not pile up.
+		if err := m.positions.flush(ctx); err != nil {
+			return nil, nil, fmt.Errorf("persisting binlog position: %w", err)
+		}
+	}
+
+	for {
+		if m.limiter != nil {
+			if err := m.limiter.wait(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		job := m.failedEncode
+		if job != nil {
+			m.failedEncode = nil
+			job.ctx = ctx
+			job.result = m.encodeMessage(ctx, job.msg)
+		} else {
+			job = m.nextEncodeJob(ctx)
+		}
+		if job == nil {
+			if m.snapshotDone && len(m.stream) == 0 {
+				return nil, nil, service.ErrEndOfInput
+			}
+
+			select {
+			case streamMessage := <-m.stream:
+				job = m.receive(streamMessage)
+			case err := <-m.readerErr:
+				if errors.Is(err, errSnapshotOnlyDone) {
+					m.logger.Info("Snapshot completed, shutting down once its messages are delivered as mode is snapshot_only")
+					m.snapshotDone = true
+					continue
+				}
+				if errors.Is(err, errSnapshotTimeout) {
+					m.logger.Errorf("Snapshot aborted: %v", err)
+					return nil, nil, service.ErrEndOfInput
+				}
+				if isServerIDConflict(err) {
+					if m.onServerIDConflict != serverIDConflictRandomizeRetry {
+						m.logger.Errorf("Binlog stream stopped as another replica connected with server id %d: %v", m.replicaServerID, err)
+						return nil, nil, fmt.Errorf("%w: another replica connected with server id %d", service.ErrEndOfInput, m.replicaServerID)
+					}
+					previous := m.replicaServerID
+					m.replicaServerID = randomServerID(previous)
+					m.logger.Warnf("Another replica connected with server id %d, reconnecting with server id %d", previous, m.replicaServerID)
+				} else {
+					m.logger.Errorf("Binlog stream stopped: %v", err)
+				}
+				// Reconnects only count as successful once their stream
+				// makes progress, so that a server that accepts connections
+				// but fails every stream still exhausts the attempts.
+				// A stream that stopped on a panic resumes before the event
+				// that panicked, so it counts as failed whatever progress it
+				// made.
+				if m.streamProgressed && !m.streamPanicked {
+					m.reconnectAttempts = 0
+					m.reconnectBackoff.reset()
+				} else if m.maxReconnectAttempts > 0 && m.reconnectAttempts >= m.maxReconnectAttempts {
+					return nil, nil, fmt.Errorf("%w: giving up after %d reconnect attempts: %v", service.ErrEndOfInput, m.reconnectAttempts, err)
+				}
+				m.reconnecting = true
+				return nil, nil, service.ErrNotConnected
+			case <-pollTimeout:
+				// Benthos backs off before reading again after a timeout, so an idle
+				// stream yields to the scheduler without busy looping.
+				return nil, nil, errReadPollTimeout
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+			if m.encodePool != nil {
+				m.encodePool.submit(ctx, job)
+				job = m.nextEncodeJob(ctx)
+			} else {
+				job.result = m.encodeMessage(ctx, job.msg)
+			}
+		}
+
+		if m.encodePool != nil {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				// The job is delivered first by the next read instead.
+				m.encodePool.pending = append([]*encodeJob{job}, m.encodePool.pending...)
+				return nil, nil, ctx.Err()
+			}
+			if job.result.err != nil && job.ctx != ctx && job.ctx.Err() != nil {
+				// The read that queued the job ended while it was encoded,
+				// so it is encoded again under this one.
+				job.result = m.encodeMessage(ctx, job.msg)
+			}
+		}
+		result, positionID := job.result, job.positionID
+		// skip acknowledges a message that is not delivered, so that it does
+		// not hold back the persisted position.
+		skip := func() {
+			if m.positions != nil {
+				_ = m.positions.ack(ctx, positionID)
+			}
+		}
+		if result.err != nil {
+			// The message is neither acknowledged nor dropped, so that the
+			// position never advances past it and it is delivered in order
+			// once it encodes, for example when the schema registry is
+			// reachable again.
+			m.failedEncode = job
+			err := fmt.Errorf("encoding %s message from table %s: %w", job.msg.Event, job.msg.Table, result.err)
+			if result.panicked {
+				// Retries of a panicking encoder count toward
+				// max_reconnect_attempts, as they fail the same way.
+				m.reconnectAttempts++
+				if m.maxReconnectAttempts > 0 && m.reconnectAttempts >= m.maxReconnectAttempts {
+					return nil, nil, fmt.Errorf("%w: giving up after %d attempts: %v", service.ErrEndOfInput, m.reconnectAttempts, err)
+				}
+			}
+			return nil, nil, err
+		}
+		if result.drop {
+			skip()
+			continue
+		}
+		streamMessage, messageBodyEncoded, structuredBody := result.msg, result.body, result.structured
+		checksum, truncated, oversizedErr := result.checksum, result.truncated, result.oversizedErr
+
+		createdMessage := service.NewMessage(messageBodyEncoded)
+		if messageBodyEncoded == nil {
+			createdMessage.SetStructured(structuredBody)
+		}
+		createdMessage.MetaSet("table", streamMessage.Table)
+		if database := streamMessage.Database(); database != "" {
+			createdMessage.MetaSet("database", database)
+		}
+		createdMessage.MetaSet("event", streamMessage.Event)
+		createdMessage.MetaSet("routing_key", routingKey(streamMessage))
+		if streamMessage.opName != "" {
+			createdMessage.MetaSet("op", streamMessage.opName)
+		}
+		if streamMessage.op != "" {
+			createdMessage.MetaSet("snapshot", streamMessage.snapshot)
+		}
+		if streamMessage.position.Name != "" {
+			createdMessage.MetaSet("binlog_file", streamMessage.position.Name)
+			createdMessage.MetaSet("binlog_pos", strconv.FormatUint(uint64(streamMessage.position.Pos), 10))
+		}
+		if m.includeSchemaFingerprint && streamMessage.table != nil {
+			fingerprint, err := m.schemaFingerprintFor(streamMessage.table)
+			if err != nil {
+				return nil, nil, err
+			}
+			createdMessage.MetaSet("schema_fingerprint", fingerprint)
+		}
+		if m.includeTypeHints && streamMessage.table != nil {
+			hints, err := m.typeHintsFor(streamMessage.table)
+			if err != nil {
+				return nil, nil, err
+			}
+			createdMessage.MetaSet("schema", hints)
+		}
+		if m.includeColumnMetadata && streamMessage.table != nil {
+			columns, err := m.columnMetadataFor(streamMessage.table)
+			if err != nil {
+				return nil, nil, err
+			}
+			createdMessage.MetaSet("column_metadata", columns)
+		}
+		if len(streamMessage.ChangedFields) > 0 {
+			values, err := json.Marshal(streamMessage.ChangedFields)
+			if err != nil {
+				return nil, nil, err
+			}
+			createdMessage.MetaSet("changed_fields", changedFieldNames(streamMessage.ChangedFields))
+			createdMessage.MetaSet("changed_values", string(values))
+		}
+		if streamMessage.globalSeq != 0 {
+			createdMessage.MetaSet("global_seq", strconv.FormatUint(streamMessage.globalSeq, 10))
+		}
+		if streamMessage.version != 0 {
+			createdMessage.MetaSet("version", strconv.FormatUint(streamMessage.version, 10))
+		}
+		if m.includeLSN {
+			seq := streamMessage.globalSeq
+			if seq == 0 {
+				seq = streamMessage.version
+			}
+			if seq != 0 {
+				createdMessage.MetaSet("lsn", lsn(seq))
+			}
+		}
+		if !streamMessage.commitTime.IsZero() {
+			createdMessage.MetaSet("commit_timestamp", streamMessage.commitTime.UTC().Format(time.RFC3339Nano))
+		}
+		if ts := streamMessage.Timestamp(); m.messageTTL > 0 && !ts.IsZero() {
+			createdMessage.MetaSet("expires_at", ts.Add(m.messageTTL).UTC().Format(time.RFC3339Nano))
+		}
+		if t, ok := m.eventTime(streamMessage); ok {
+			createdMessage.MetaSet("event_time", t.UTC().Format(time.RFC3339Nano))
+		}
+		if streamMessage.sourceHost != "" {
+			createdMessage.MetaSet("source_host", streamMessage.sourceHost)
+		}
+		if streamMessage.serverUUID != "" {
+			createdMessage.MetaSet("server_uuid", streamMessage.serverUUID)
+		}
+		if streamMessage.source != nil {
+			createdMessage.MetaSet("source", streamMessage.source.encoded)
+		}
+		if streamMessage.serverID != 0 {
+			createdMessage.MetaSet("server_id", strconv.FormatUint(uint64(streamMessage.serverID), 10))
+		}
+		if streamMessage.originServerUUID != "" {
+			createdMessage.MetaSet("origin_server_uuid", streamMessage.originServerUUID)
+		}
+		if streamMessage.idempotencyKey != "" {
+			createdMessage.MetaSet("idempotency_key", streamMessage.idempotencyKey)
+		}
+		if checksum != "" {
+			createdMessage.MetaSet("row_checksum", checksum)
+		}
+		if streamMessage.autoIncrementID != "" {
+			createdMessage.MetaSet("auto_increment_id", streamMessage.autoIncrementID)
+		}
+		if streamMessage.partition != "" {
+			createdMessage.MetaSet("partition", streamMessage.partition)
+		}
+		if streamMessage.key != "" {
+			createdMessage.MetaSet("key", streamMessage.key)
+		}
+		if streamMessage.minimalImage {
+			createdMessage.MetaSet("minimal_image", "true")
+		}
+		if streamMessage.fromCache {
+			createdMessage.MetaSet("from_cache", "true")
+		}
+		if streamMessage.schemaUnresolved {
+			createdMessage.MetaSet("schema_unresolved", "true")
+		}
+		if streamMessage.deadLetterError != "" {
+			createdMessage.MetaSet("dead_letter", "true")
+			createdMessage.MetaSet("dead_letter_error", streamMessage.deadLetterError)
+		}
+		truncated = mergeTruncated(streamMessage.truncated, truncated)
+		if len(truncated) > 0 {
+			createdMessage.MetaSet("truncated_columns", strings.Join(truncated, ","))
+		}
+		if m.includeMessageSize {
+			createdMessage.MetaSet("message_size_bytes", strconv.Itoa(len(messageBodyEncoded)))
+		}
+		if oversizedErr != nil {
+			createdMessage.SetError(oversizedErr)
+		}
+		createdMessage = m.startSpan(ctx, streamMessage, createdMessage)
+		m.metrics.messagesEmitted.Incr(1)
+
+		sentAt := streamMessage.sentAt
+		return createdMessage, func(ctx context.Context, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !sentAt.IsZero() {
+				m.metrics.ackLatency.Timing(time.Since(sentAt).Nanoseconds())
+			}
+			if m.positions == nil {
+				return nil
+			}
+			return m.positions.ack(ctx, positionID)
+		}, nil
+	}
+}
+
+func (m *MysqlStreamInput) encode(ctx context.Context, msg StreamMessage) ([]byte, error) {
+	return m.encoder.Encode(ctx, msg)
 }
@@ -0,0 +1,447 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func newTestInput(t *testing.T, opts ...Option) *MysqlStreamInput {
+	t.Helper()
+	m, err := NewMysqlStreamInput(append([]Option{WithAddr("127.0.0.1:3306")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newTestCanal returns a canal that resolves tables from its table cache,
+// which holds tables, without a connection to a server.
+func newTestCanal(t *testing.T, tables ...*schema.Table) *canal.Canal {
+	t.Helper()
+	c := &canal.Canal{}
+	field := reflect.ValueOf(c).Elem().FieldByName("tables")
+	cache := map[string]*schema.Table{}
+	if !field.IsValid() || field.Type() != reflect.TypeOf(cache) {
+		t.Fatal("canal.Canal has no tables map[string]*schema.Table field")
+	}
+	for _, table := range tables {
+		cache[table.Schema+"."+table.Name] = table
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(cache))
+	// Setting a table in the cache reads the config.
+	cfg := reflect.ValueOf(c).Elem().FieldByName("cfg")
+	if !cfg.IsValid() || cfg.Type() != reflect.TypeOf(&canal.Config{}) {
+		t.Fatal("canal.Canal has no cfg *canal.Config field")
+	}
+	reflect.NewAt(cfg.Type(), unsafe.Pointer(cfg.UnsafeAddr())).Elem().Set(reflect.ValueOf(&canal.Config{Flavor: mysql.MySQLFlavor}))
+	return c
+}
+
+// newTestMetrics returns metrics recorded in a local registry, which Benthos
+// does not expose a way to build service.Metrics from.
+func newTestMetrics(t *testing.T) (*service.Metrics, *metrics.Local) {
+	t.Helper()
+	local := metrics.NewLocal()
+	m := &service.Metrics{}
+	field := reflect.ValueOf(m).Elem().FieldByName("t")
+	if !field.IsValid() || !reflect.TypeOf(local).AssignableTo(field.Type()) {
+		t.Fatal("service.Metrics has no t metrics.Type field")
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(local))
+	return m, local
+}
+
+// readStructured reads a message and decodes its body.
+func readStructured(t *testing.T, m *MysqlStreamInput) (*service.Message, map[string]any) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ack, err := m.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ack(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	body, err := msg.AsStructured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, body.(map[string]any)
+}
+
+func TestReadReconnectAttempts(t *testing.T) {
+	streamErr := errors.New("binlog purged")
+
+	tests := []struct {
+		name         string
+		attempts     int
+		progressed   bool
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "below limit", attempts: 1, wantErr: service.ErrNotConnected, wantAttempts: 1},
+		{name: "limit without progress", attempts: 3, wantErr: service.ErrEndOfInput, wantAttempts: 3},
+		{name: "limit with progress", attempts: 3, progressed: true, wantErr: service.ErrNotConnected, wantAttempts: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithMaxReconnectAttempts(3))
+			m.reconnectAttempts = tt.attempts
+			m.streamProgressed = tt.progressed
+			m.readerErr <- streamErr
+
+			_, _, err := m.Read(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Read error = %v, want %v", err, tt.wantErr)
+			}
+			if m.reconnectAttempts != tt.wantAttempts {
+				t.Errorf("reconnect attempts = %d, want %d", m.reconnectAttempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestConnectGivesUpAfterMaxReconnectAttempts(t *testing.T) {
+	// Nothing listens on the discard port, so every connect fails.
+	m := newTestInput(t, WithAddr("127.0.0.1:9"), WithMaxReconnectAttempts(2))
+	m.reconnecting = true
+
+	connect := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return m.Connect(ctx)
+	}
+	if err := connect(); err == nil || errors.Is(err, service.ErrEndOfInput) {
+		t.Fatalf("first reconnect error = %v, want a retryable error", err)
+	}
+	if err := connect(); !errors.Is(err, service.ErrEndOfInput) {
+		t.Fatalf("second reconnect error = %v, want %v", err, service.ErrEndOfInput)
+	}
+	if m.reconnectAttempts != 2 {
+		t.Errorf("reconnect attempts = %d, want 2", m.reconnectAttempts)
+	}
+}
+
+func TestLifecycleEventsBufferFull(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr error
+		want    string
+	}{
+		{policy: bufferFullDropOldest, want: connectedEvent},
+		{policy: bufferFullError, wantErr: errBufferFull, want: "insert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			m := newTestInput(t, WithLifecycleEvents(true), WithBufferSize(1), WithOnBufferFull(tt.policy))
+			m.stream <- StreamMessage{Event: "insert"}
+
+			err := m.emitLifecycle(connectedEvent, mysql.Position{Name: "mysql-bin.000001", Pos: 4})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("emitLifecycle error = %v, want %v", err, tt.wantErr)
+			}
+			msg := <-m.stream
+			if msg.Event != tt.want {
+				t.Fatalf("buffered %s event, want %s", msg.Event, tt.want)
+			}
+			if msg.Event == connectedEvent && msg.sourceHost != "127.0.0.1:3306" {
+				t.Errorf("source host = %q, want 127.0.0.1:3306", msg.sourceHost)
+			}
+		})
+	}
+}
+
+func TestReadPollTimeout(t *testing.T) {
+	m := newTestInput(t, WithReadPollTimeout(10*time.Millisecond))
+
+	_, _, err := m.Read(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Read error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestUpdateRowPairs(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "qty"}}, PKColumns: []int{0}}
+
+	tests := []struct {
+		name    string
+		rows    [][]any
+		want    []struct{ id, before, after int64 }
+		wantErr bool
+	}{
+		{
+			name: "two pairs",
+			rows: [][]any{{int64(1), int64(1)}, {int64(1), int64(5)}, {int64(2), int64(2)}, {int64(2), int64(7)}},
+			want: []struct{ id, before, after int64 }{{1, 1, 5}, {2, 2, 7}},
+		},
+		{
+			name:    "unpaired image",
+			rows:    [][]any{{int64(1), int64(1)}, {int64(1), int64(5)}, {int64(2), int64(2)}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.UpdateAction,
+				Rows:   tt.rows,
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 500},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OnRow error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(m.stream) != len(tt.want) {
+				t.Fatalf("%d messages sent, want %d", len(m.stream), len(tt.want))
+			}
+			for i, want := range tt.want {
+				msg := <-m.stream
+				// Each after image is paired with the before image preceding
+				// it.
+				if msg.Data["id"] != want.id || msg.before["qty"] != want.before || msg.Data["qty"] != want.after {
+					t.Errorf("change %d = %v before %v, want id %d qty %d before %d", i, msg.Data, msg.before, want.id, want.after, want.before)
+				}
+			}
+		})
+	}
+}
+
+func TestReconnectAttemptsUnderFailures(t *testing.T) {
+	const (
+		streamFails   = "stream fails"
+		streamRuns    = "stream progresses and fails"
+		connectFails  = "connect fails"
+		connectWorks  = "connect works"
+		maxReconnects = 3
+	)
+
+	tests := []struct {
+		name  string
+		steps []string
+		// wantStreams is the number of streams run before the input gives
+		// up, and zero when it does not.
+		wantStreams int
+	}{
+		{
+			name:        "every stream fails",
+			steps:       []string{streamFails, connectWorks, streamFails, connectWorks, streamFails, connectWorks, streamFails},
+			wantStreams: maxReconnects + 1,
+		},
+		{
+			name:        "connects fail",
+			steps:       []string{streamFails, connectFails, connectFails, connectFails},
+			wantStreams: 1,
+		},
+		{
+			name:        "mixed",
+			steps:       []string{streamFails, connectFails, connectWorks, streamFails, connectWorks, streamFails},
+			wantStreams: 3,
+		},
+		{
+			name:  "progress resets the attempts",
+			steps: []string{streamFails, connectWorks, streamFails, connectWorks, streamRuns, connectWorks, streamFails, connectWorks, streamFails},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing listens on the discard port, so every real connect
+			// fails.
+			m := newTestInput(t, WithAddr("127.0.0.1:9"), WithMaxReconnectAttempts(maxReconnects), WithReconnectJitter(reconnectJitterNone, 0, 0))
+			streams, gaveUp := 0, false
+			for i, step := range tt.steps {
+				if gaveUp {
+					t.Fatalf("step %d (%s) after the input gave up", i, step)
+				}
+				var err error
+				switch step {
+				case streamFails, streamRuns:
+					streams++
+					m.streamProgressed = step == streamRuns
+					m.readerErr <- errors.New("binlog purged")
+					_, _, err = m.Read(context.Background())
+				case connectFails:
+					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+					err = m.Connect(ctx)
+					cancel()
+				case connectWorks:
+					// Stands in for a Connect that reaches a server, which
+					// counts the attempt and clears reconnecting.
+					if m.reconnecting {
+						m.reconnectAttempts++
+					}
+					m.reconnecting = false
+				}
+				gaveUp = errors.Is(err, service.ErrEndOfInput)
+			}
+			if !gaveUp {
+				if tt.wantStreams != 0 {
+					t.Fatalf("input did not give up, want it to after %d streams", tt.wantStreams)
+				}
+				return
+			}
+			if streams != tt.wantStreams {
+				t.Errorf("gave up after %d streams, want %d", streams, tt.wantStreams)
+			}
+			if m.reconnectAttempts != maxReconnects {
+				t.Errorf("reconnect attempts = %d, want %d", m.reconnectAttempts, maxReconnects)
+			}
+		})
+	}
+}
+
+func TestReconnectDialAttempts(t *testing.T) {
+	const maxReconnects = 3
+
+	// acceptAndClose serves a server that closes every connection before its
+	// handshake, and counts the connections made to it.
+	acceptAndClose := func(dials *atomic.Int64) string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				dials.Add(1)
+				conn.Close()
+			}
+		}()
+		return l.Addr().String()
+	}
+
+	for _, servers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("%d servers", servers), func(t *testing.T) {
+			var dials atomic.Int64
+			var addrs []string
+			for i := 0; i < servers; i++ {
+				addrs = append(addrs, acceptAndClose(&dials))
+			}
+			m := newTestInput(t, WithAddrs(addrs), WithMaxReconnectAttempts(maxReconnects), WithReconnectJitter(reconnectJitterNone, 0, 0))
+			t.Cleanup(m.metadata.close)
+			m.reconnecting = true
+
+			connects := 0
+			for {
+				connects++
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				err := m.Connect(ctx)
+				cancel()
+				if err == nil {
+					t.Fatal("Connect to a failing server succeeded")
+				}
+				if errors.Is(err, service.ErrEndOfInput) {
+					break
+				}
+				if connects > maxReconnects {
+					t.Fatalf("input did not give up after %d connects", connects)
+				}
+			}
+			if connects != maxReconnects {
+				t.Errorf("gave up after %d connects, want %d", connects, maxReconnects)
+			}
+			// Every attempt dials each server once, and nothing keeps
+			// dialing once the input gave up.
+			time.Sleep(200 * time.Millisecond)
+			if n := dials.Load(); n != int64(maxReconnects*servers) {
+				t.Errorf("%d connections made to the servers, want %d", n, maxReconnects*servers)
+			}
+		})
+	}
+}
+
+// wideTable returns a table with a primary key and columns-1 text columns.
+func wideTable(columns int) *schema.Table {
+	table := &schema.Table{Schema: "shop", Name: "wide", PKColumns: []int{0}}
+	table.Columns = append(table.Columns, schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER})
+	for i := 1; i < columns; i++ {
+		table.Columns = append(table.Columns, schema.TableColumn{Name: fmt.Sprintf("c%d", i), Type: schema.TYPE_STRING})
+	}
+	return table
+}
+
+// wideRow returns a row of table with its id set to id.
+func wideRow(table *schema.Table, id int64) []any {
+	row := make([]any, len(table.Columns))
+	row[0] = id
+	for i := 1; i < len(row); i++ {
+		row[i] = "value"
+	}
+	return row
+}
+
+func TestRowDataWidth(t *testing.T) {
+	table := wideTable(500)
+	tests := []struct {
+		name    string
+		values  int
+		wantErr bool
+	}{
+		{name: "matching", values: 500},
+		{name: "narrower", values: 499, wantErr: true},
+		{name: "wider", values: 501, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t)
+			row := wideRow(wideTable(tt.values), 1)
+			data, err := m.rowData(table, row, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rowData error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(data) != len(table.Columns) {
+				t.Errorf("rowData has %d columns, want %d", len(data), len(table.Columns))
+			}
+		})
+	}
+}
+
+// BenchmarkReadWideRow measures converting a change of a 500 column row and
+// reading it as a message.
+func BenchmarkReadWideRow(b *testing.B) {
+	table := wideTable(500)
+	m, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{wideRow(table, int64(i))},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: uint32(i + 1)},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, ack, err := m.Read(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := ack(ctx, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	serverMismatchError = "error"
+	serverMismatchReset = "reset"
+)
+
+// maxPositionWriteBackoff caps the wait between retries of a failed write to
+// position_cache.
+const maxPositionWriteBackoff = 5 * time.Second
+
+// positionStore persists the binlog position every message read before it has
+// been acknowledged up to, so that a restarted input resumes without losing
+// changes. Each message is tracked with the synced position at the time it was
+// sent, which only advances once every message of the preceding transactions
+// has been sent, so once a message and every message read before it are acked
+// streaming can safely resume from its position.
+type positionStore struct {
+	resources  *service.Resources
+	cache      string
+	key        string
+	flushEvery int
+	logger     *service.Logger
+
+	mu        sync.Mutex
+	nextID    uint64
+	frontID   uint64
+	inFlight  map[uint64]*trackedPosition
+	committed mysql.Position
+	unflushed int
+
+	progress *snapshotProgress
+
+	// With in_flight_limit at most inFlightLimit positions are kept in
+	// inFlight and the rest in spill.
+	inFlightLimit int
+	spill         *positionSpill
+
+	// writeErr is the error of the last flush, which stops reads until a
+	// flush succeeds.
+	writeErr error
+
+	writeRetries int
+	writeBackoff time.Duration
+
+	flushMu         sync.Mutex
+	flushed         mysql.Position
+	flushedUUID     string
+	flushedProgress *snapshotProgress
+	serverUUID      string
+
+	// flushedRead is set once flushed holds the position persisted before
+	// this run, read by load or by the first flush.
+	flushedRead bool
+}
+
+type trackedPosition struct {
+	resume   mysql.Position
+	progress *snapshotProgress
+	acked    bool
+}
+
+type storedPosition struct {
+	BinlogFile string `json:"binlog_file"`
+	BinlogPos  uint32 `json:"binlog_pos"`
+	ServerUUID string `json:"server_uuid,omitempty"`
+}
+
+func newPositionStore(resources *service.Resources, cache, key string, flushEvery int, logger *service.Logger) *positionStore {
+	return &positionStore{
+		resources:  resources,
+		cache:      cache,
+		key:        key,
+		flushEvery: flushEvery,
+		logger:     logger,
+		inFlight:   map[uint64]*trackedPosition{},
+	}
+}
+
+// setServerUUID sets the server_uuid stored with the position, identifying the
+// server whose binlog it refers to.
+func (s *positionStore) setServerUUID(uuid string) {
+	s.flushMu.Lock()
+	s.serverUUID = uuid
+	s.flushMu.Unlock()
+}
+
+// track registers a message read from the stream in read order, along with
+// the snapshot progress it carries under snapshot_resume, and returns its id
+// for ack.
+func (s *positionStore) track(resume mysql.Position, progress *snapshotProgress) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	p := &trackedPosition{resume: resume, progress: progress}
+	if s.spill != nil && (s.spill.pending() > 0 || len(s.inFlight) >= s.inFlightLimit) {
+		err := s.spill.add(id, p)
+		if err == nil {
+			return id
+		}
+		s.logger.Errorf("Failed to spill in flight position to disk, keeping it in memory: %v", err)
+	}
+	s.inFlight[id] = p
+	return id
+}
+
+// withSpill keeps at most limit tracked positions in memory and spills the
+// rest to a file in dir.
+func (s *positionStore) withSpill(limit int, dir string, metrics *streamMetrics) error {
+	spill, err := newPositionSpill(dir, metrics)
+	if err != nil {
+		return err
+	}
+	s.inFlightLimit = limit
+	s.spill = spill
+	return nil
+}
+
+// withWriteRetries retries failed cache writes up to retries times, waiting
+// backoff before the first retry and twice as long before each further one.
+func (s *positionStore) withWriteRetries(retries int, backoff time.Duration) {
+	s.writeRetries = retries
+	s.writeBackoff = backoff
+}
+
+// writeFailed returns the error of the last flush when it failed.
+func (s *positionStore) writeFailed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeErr
+}
+
+// forgetFlushed drops the position last written, so that the position of a
+// stream moved to another server is written even though it may compare lower.
+func (s *positionStore) forgetFlushed() {
+	s.flushMu.Lock()
+	s.flushed, s.flushedUUID = mysql.Position{}, ""
+	s.flushMu.Unlock()
+}
+
+// write runs a write to the cache, retrying it on failure with exponential
+// backoff. Writes only ever store a complete value under a fixed key, so a
+// retried write that had already succeeded stores the same value again.
+func (s *positionStore) write(ctx context.Context, op func(c service.Cache) error) error {
+	backoff := s.writeBackoff
+	for attempt := 0; ; attempt++ {
+		var cacheErr error
+		err := s.resources.AccessCache(ctx, s.cache, func(c service.Cache) {
+			cacheErr = op(c)
+		})
+		if err == nil {
+			err = cacheErr
+		}
+		if err == nil || attempt >= s.writeRetries {
+			return err
+		}
+
+		s.logger.Warnf("Failed to write to position_cache, retrying in %v: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; backoff > maxPositionWriteBackoff {
+			backoff = maxPositionWriteBackoff
+		}
+	}
+}
+
+// close removes the spill file.
+func (s *positionStore) close() error {
+	if s.spill == nil {
+		return nil
+	}
+	return s.spill.close()
+}
+
+// ack marks a message as delivered and advances the committed position past
+// the longest run of acked messages, flushing it once flushEvery acks have
+// accumulated.
+func (s *positionStore) ack(ctx context.Context, id uint64) error {
+	s.mu.Lock()
+	if p, ok := s.inFlight[id]; ok {
+		p.acked = true
+	} else if s.spill != nil {
+		s.spill.ack(id)
+	}
+	for {
+		p, ok := s.inFlight[s.frontID]
+		if !ok && s.spill != nil && s.spill.pending() > 0 {
+			// The front has reached the spilled positions, read them back
+			// as far as in_flight_limit allows.
+			if err := s.spill.load(s.inFlight, max(s.inFlightLimit-len(s.inFlight), 1)); err != nil {
+				s.mu.Unlock()
+				return fmt.Errorf("failed to read spilled in flight positions: %w", err)
+			}
+			p, ok = s.inFlight[s.frontID]
+		}
+		if !ok || !p.acked {
+			break
+		}
+		if p.resume.Pos != 0 {
+			s.committed = p.resume
+		}
+		if p.progress != nil {
+			s.progress = p.progress
+		}
+		delete(s.inFlight, s.frontID)
+		s.frontID++
+	}
+	s.unflushed++
+	flush := s.flushEvery > 0 && s.unflushed >= s.flushEvery
+	s.mu.Unlock()
+
+	if flush {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+// flush writes the committed position and snapshot progress to the cache if
+// they changed since the last write, and records whether it failed.
+func (s *positionStore) flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pos := s.committed
+	progress := s.progress
+	s.unflushed = 0
+	s.mu.Unlock()
+
+	err := s.flushSnapshotProgress(ctx, pos, progress)
+	if err == nil {
+		err = s.flushPosition(ctx, pos)
+	}
+	s.mu.Lock()
+	s.writeErr = err
+	s.mu.Unlock()
+	return err
+}
+
+// flushPosition writes pos to the cache unless it was written last or is
+// behind the position written last for the same server.
+func (s *positionStore) flushPosition(ctx context.Context, pos mysql.Position) error {
+	if pos.Name == "" || pos == s.flushed {
+		return nil
+	}
+	if !s.flushedRead {
+		// A run resuming from a configured position rather than the
+		// persisted one must not move the persisted position back either.
+		stored, err := s.read(ctx)
+		if err != nil {
+			return err
+		}
+		s.flushed, s.flushedUUID = mysql.Position{Name: stored.BinlogFile, Pos: stored.BinlogPos}, stored.ServerUUID
+		s.flushedRead = true
+		if pos == s.flushed {
+			return nil
+		}
+	}
+	if s.flushed.Name != "" && s.flushedUUID == s.serverUUID && pos.Compare(s.flushed) < 0 {
+		s.logger.Warnf("Not persisting binlog position %s:%d behind the persisted position %s:%d", pos.Name, pos.Pos, s.flushed.Name, s.flushed.Pos)
+		return nil
+	}
+
+	value, err := json.Marshal(storedPosition{BinlogFile: pos.Name, BinlogPos: pos.Pos, ServerUUID: s.serverUUID})
+	if err != nil {
+		return err
+	}
+	if err := s.write(ctx, func(c service.Cache) error {
+		return c.Set(ctx, s.key, value, nil)
+	}); err != nil {
+		return err
+	}
+	s.flushed, s.flushedUUID = pos, s.serverUUID
+	return nil
+}
+
+// load reads the persisted position and the server_uuid of the server it was
+// read from, returning the zero position when none has been stored yet.
+func (s *positionStore) load(ctx context.Context) (mysql.Position, string, error) {
+	stored, err := s.read(ctx)
+	if err != nil {
+		return mysql.Position{}, "", err
+	}
+	pos := mysql.Position{Name: stored.BinlogFile, Pos: stored.BinlogPos}
+	s.flushMu.Lock()
+	s.flushed, s.flushedUUID, s.flushedRead = pos, stored.ServerUUID, true
+	s.flushMu.Unlock()
+	return pos, stored.ServerUUID, nil
+}
+
+// read returns the persisted position, which is zero when none has been
+// stored yet.
+func (s *positionStore) read(ctx context.Context) (storedPosition, error) {
+	var value []byte
+	var cacheErr error
+	if err := s.resources.AccessCache(ctx, s.cache, func(c service.Cache) {
+		value, cacheErr = c.Get(ctx, s.key)
+	}); err != nil {
+		return storedPosition{}, err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return storedPosition{}, nil
+	}
+	if cacheErr != nil {
+		return storedPosition{}, cacheErr
+	}
+
+	var stored storedPosition
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return storedPosition{}, err
+	}
+	return stored, nil
+}
+
+// flushPeriodically flushes the committed position every interval until ctx
+// is done.
+func (s *positionStore) flushPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.flush(ctx); err != nil {
+			s.logger.Warnf("Failed to persist binlog position: %v", err)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// testCache is an in memory cache resource whose writes can be made to fail.
+type testCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	sets   int
+	// fails is the number of writes still to fail.
+	fails int
+}
+
+func (c *testCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		return nil, service.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (c *testCache) Set(_ context.Context, key string, value []byte, _ *time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	if c.fails > 0 {
+		c.fails--
+		return errors.New("cache unavailable")
+	}
+	c.values[key] = value
+	return nil
+}
+
+func (c *testCache) Add(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	c.mu.Lock()
+	_, exists := c.values[key]
+	c.mu.Unlock()
+	if exists {
+		return service.ErrKeyAlreadyExists
+	}
+	return c.Set(ctx, key, value, ttl)
+}
+
+func (c *testCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+func (c *testCache) Close(context.Context) error {
+	return nil
+}
+
+// storedPosition returns the position stored under key, if any.
+func (c *testCache) storedPosition(t *testing.T, key string) (storedPosition, bool) {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		return storedPosition{}, false
+	}
+	var stored storedPosition
+	if err := json.Unmarshal(v, &stored); err != nil {
+		t.Fatal(err)
+	}
+	return stored, true
+}
+
+type testResourcesInput struct{}
+
+func (testResourcesInput) Connect(context.Context) error {
+	return nil
+}
+
+func (testResourcesInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func (testResourcesInput) Close(context.Context) error {
+	return nil
+}
+
+var (
+	registerTestPlugins sync.Once
+	testCachesCh        = make(chan *testCache, 1)
+	testResourcesCh     = make(chan *service.Resources, 1)
+)
+
+// newTestResources runs a stream with a test_cache resource labelled cache
+// and returns its resources, along with the cache.
+func newTestResources(t *testing.T) (*service.Resources, *testCache) {
+	t.Helper()
+	registerTestPlugins.Do(func() {
+		err := service.RegisterCache("test_cache", service.NewConfigSpec(), func(*service.ParsedConfig, *service.Resources) (service.Cache, error) {
+			c := &testCache{values: map[string][]byte{}}
+			testCachesCh <- c
+			return c, nil
+		})
+		if err == nil {
+			err = service.RegisterInput("test_resources", service.NewConfigSpec(), func(_ *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+				testResourcesCh <- mgr
+				return testResourcesInput{}, nil
+			})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	b := service.NewStreamBuilder()
+	err := b.SetYAML(`
+input:
+  test_resources: {}
+output:
+  drop: {}
+cache_resources:
+  - label: cache
+    test_cache: {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Benthos wraps cache resources, so the cache is taken from its
+	// constructor.
+	cache := <-testCachesCh
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = stream.Run(ctx)
+	}()
+	t.Cleanup(cancel)
+	return <-testResourcesCh, cache
+}
+
+func TestPositionStoreAckOrder(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	s := newPositionStore(mgr, "cache", "position", 1, mgr.Logger())
+	ctx := context.Background()
+
+	first := s.track(mysql.Position{Name: "mysql-bin.000001", Pos: 100}, nil)
+	second := s.track(mysql.Position{Name: "mysql-bin.000001", Pos: 200}, nil)
+
+	// The position never moves past a message that is not acknowledged.
+	if err := s.ack(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if stored, ok := cache.storedPosition(t, "position"); ok {
+		t.Fatalf("position %+v stored before the first message was acknowledged", stored)
+	}
+	if err := s.ack(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	stored, ok := cache.storedPosition(t, "position")
+	if !ok || stored.BinlogFile != "mysql-bin.000001" || stored.BinlogPos != 200 {
+		t.Fatalf("stored position = %+v, want mysql-bin.000001:200", stored)
+	}
+
+	pos, _, err := s.load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != (mysql.Position{Name: "mysql-bin.000001", Pos: 200}) {
+		t.Errorf("loaded position = %v, want mysql-bin.000001:200", pos)
+	}
+}
+
+func TestPositionFlushEveryN(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	m := newTestInput(t, WithResources(mgr), WithPositionCache("cache", "position"), WithPositionFlush(time.Hour, 3))
+	ctx := context.Background()
+
+	var ids []uint64
+	for _, pos := range []uint32{100, 200, 300, 400} {
+		ids = append(ids, m.positions.track(mysql.Position{Name: "mysql-bin.000001", Pos: pos}, nil))
+	}
+	// The third ack flushes, and only the acks up to the first message not
+	// acknowledged count, so the position of the first message is written
+	// rather than that of the last one acknowledged.
+	for _, i := range []int{2, 0, 3} {
+		if err := m.positions.ack(ctx, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, ok := cache.storedPosition(t, "position")
+	if !ok || stored.BinlogPos != 100 {
+		t.Fatalf("stored position after 3 acks = %+v, want mysql-bin.000001:100", stored)
+	}
+
+	// The fourth ack advances the position to the last message without
+	// reaching the next flush.
+	if err := m.positions.ack(ctx, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := cache.storedPosition(t, "position"); stored.BinlogPos != 100 {
+		t.Fatalf("stored position after 4 acks = %+v, want it unchanged at mysql-bin.000001:100", stored)
+	}
+
+	// Closing writes what was acknowledged since the last flush.
+	if err := m.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := cache.storedPosition(t, "position"); stored.BinlogPos != 400 {
+		t.Errorf("stored position after Close = %+v, want mysql-bin.000001:400", stored)
+	}
+}
+
+func TestPositionFlushInterval(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	m := newTestInput(t, WithResources(mgr), WithPositionCache("cache", "position"), WithPositionFlush(10*time.Millisecond, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.positions.flushPeriodically(ctx, m.positionFlushInterval)
+
+	first := m.positions.track(mysql.Position{Name: "mysql-bin.000001", Pos: 100}, nil)
+	second := m.positions.track(mysql.Position{Name: "mysql-bin.000001", Pos: 200}, nil)
+	if err := m.positions.ack(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if stored, ok := cache.storedPosition(t, "position"); ok {
+		t.Fatalf("position %+v stored before the first message was acknowledged", stored)
+	}
+
+	if err := m.positions.ack(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if stored, _ := cache.storedPosition(t, "position"); stored.BinlogPos == 200 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("acknowledged position not written by the periodic flush")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPositionStoreSpill(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		// order lists the messages, by index, in the order they are acked.
+		order []int
+	}{
+		{name: "in order", limit: 2, order: []int{0, 1, 2, 3, 4, 5}},
+		{name: "reverse", limit: 2, order: []int{5, 4, 3, 2, 1, 0}},
+		{name: "spilled first", limit: 1, order: []int{3, 4, 1, 5, 2, 0}},
+		{name: "front last", limit: 3, order: []int{1, 2, 3, 4, 5, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			s := newPositionStore(mgr, "cache", "position", 1, mgr.Logger())
+			if err := s.withSpill(tt.limit, t.TempDir(), newStreamMetrics(nil)); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			var ids []uint64
+			for i := range tt.order {
+				ids = append(ids, s.track(mysql.Position{Name: "mysql-bin.000001", Pos: uint32(100 * (i + 1))}, nil))
+			}
+			if len(s.inFlight) != tt.limit || s.spill.pending() != len(ids)-tt.limit {
+				t.Fatalf("%d positions in memory and %d spilled, want %d and %d", len(s.inFlight), s.spill.pending(), tt.limit, len(ids)-tt.limit)
+			}
+
+			firstAcked := false
+			for _, i := range tt.order {
+				if err := s.ack(ctx, ids[i]); err != nil {
+					t.Fatal(err)
+				}
+				firstAcked = firstAcked || i == 0
+				if stored, ok := cache.storedPosition(t, "position"); ok && !firstAcked {
+					t.Fatalf("position %+v stored before the first message was acknowledged", stored)
+				}
+				if len(s.inFlight) > tt.limit {
+					t.Fatalf("%d positions in memory, want at most %d", len(s.inFlight), tt.limit)
+				}
+			}
+			stored, ok := cache.storedPosition(t, "position")
+			if want := uint32(100 * len(ids)); !ok || stored.BinlogPos != want {
+				t.Errorf("stored position = %+v, want mysql-bin.000001:%d", stored, want)
+			}
+			// The file is truncated once every spilled position was read back.
+			if s.spill.pending() != 0 || s.spill.written != 0 {
+				t.Errorf("spill holds %d positions, %d pending, want none", s.spill.written, s.spill.pending())
+			}
+
+			name := s.spill.file.Name()
+			if err := s.close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Errorf("spill file %s not removed on close: %v", name, err)
+			}
+		})
+	}
+}
+
+func TestPositionStoreWriteFailures(t *testing.T) {
+	persisted := storedPosition{BinlogFile: "mysql-bin.000001", BinlogPos: 500, ServerUUID: "uuid-a"}
+	tests := []struct {
+		name    string
+		retries int
+		fails   int
+		// stored is the position persisted by a previous run, which is not
+		// loaded as the run resumes from a configured position.
+		stored  *storedPosition
+		ack     uint32
+		wantErr bool
+		want    uint32
+	}{
+		{name: "retried", retries: 3, fails: 2, ack: 200, want: 200},
+		{name: "retries exhausted", retries: 1, fails: 2, ack: 200, wantErr: true},
+		{name: "behind the persisted position", stored: &persisted, ack: 300, want: 500},
+		{name: "ahead of the persisted position", stored: &persisted, ack: 600, want: 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			ctx := context.Background()
+			if tt.stored != nil {
+				value, err := json.Marshal(tt.stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := cache.Set(ctx, "position", value, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cache.mu.Lock()
+			cache.fails = tt.fails
+			cache.mu.Unlock()
+			s := newPositionStore(mgr, "cache", "position", 1, mgr.Logger())
+			s.withWriteRetries(tt.retries, time.Millisecond)
+			s.setServerUUID("uuid-a")
+
+			err := s.ack(ctx, s.track(mysql.Position{Name: "mysql-bin.000001", Pos: tt.ack}, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ack error = %v, want error %v", err, tt.wantErr)
+			}
+			if (s.writeFailed() != nil) != tt.wantErr {
+				t.Fatalf("writeFailed = %v, want error %v", s.writeFailed(), tt.wantErr)
+			}
+			stored, ok := cache.storedPosition(t, "position")
+			if tt.wantErr {
+				if ok {
+					t.Errorf("position %+v stored although every write failed", stored)
+				}
+				return
+			}
+			if !ok || stored.BinlogPos != tt.want {
+				t.Errorf("stored position = %+v, want mysql-bin.000001:%d", stored, tt.want)
+			}
+		})
+	}
+}
+
+func TestPositionServerMismatch(t *testing.T) {
+	persisted := mysql.Position{Name: "mysql-bin.000004", Pos: 700}
+	tests := []struct {
+		name    string
+		uuid    string
+		policy  string
+		wantErr bool
+		want    mysql.Position
+		wantGap mysql.Position
+	}{
+		{name: "same server", uuid: "uuid-a", policy: serverMismatchError, want: persisted},
+		{name: "other server with error", uuid: "uuid-b", policy: serverMismatchError, wantErr: true},
+		{name: "other server with reset", uuid: "uuid-b", policy: serverMismatchReset, wantGap: persisted},
+		// Positions stored before server_uuid was recorded resume on any
+		// server.
+		{name: "legacy position", policy: serverMismatchError, want: persisted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, cache := newTestResources(t)
+			ctx := context.Background()
+			value, err := json.Marshal(storedPosition{BinlogFile: persisted.Name, BinlogPos: persisted.Pos, ServerUUID: tt.uuid})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.Set(ctx, "position", value, nil); err != nil {
+				t.Fatal(err)
+			}
+			m := newTestInput(t, WithResources(mgr), WithPositionCache("cache", "position"), WithOnServerMismatch(tt.policy))
+			m.serverUUID = "uuid-a"
+
+			err = m.resumePersistedPosition(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resumePersistedPosition error = %v, want error %v", err, tt.wantErr)
+			}
+			if m.resumePosition != tt.want {
+				t.Errorf("resume position = %v, want %v", m.resumePosition, tt.want)
+			}
+			if m.gapFrom != tt.wantGap {
+				t.Errorf("discarded position = %v, want %v", m.gapFrom, tt.wantGap)
+			}
+		})
+	}
+}
+
+func TestPositionWriteFailureStopsReads(t *testing.T) {
+	mgr, cache := newTestResources(t)
+	m := newTestInput(t, WithResources(mgr), WithDatabase("shop"), WithPositionCache("cache", "position"),
+		WithPositionFlush(0, 1), WithPositionWriteRetries(0, 0))
+	m.binlogFile = "mysql-bin.000001"
+	m.syncedPosition = mysql.Position{Name: "mysql-bin.000001", Pos: 100}
+	insertRows(t, m, 1, 2)
+	ctx := context.Background()
+
+	// The acknowledgement and the flush the next read retries both fail.
+	cache.mu.Lock()
+	cache.fails = 2
+	cache.mu.Unlock()
+	_, ack, err := m.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ack(ctx, nil); err == nil {
+		t.Fatal("ack succeeded although the position was not persisted")
+	}
+	if _, _, err := m.Read(ctx); err == nil {
+		t.Fatal("Read delivered a message while the position cannot be persisted")
+	}
+	if _, ok := cache.storedPosition(t, "position"); ok {
+		t.Fatal("position stored although every write failed")
+	}
+
+	// Once the cache recovers the position is persisted before reading on.
+	if _, _, err := m.Read(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.storedPosition(t, "position"); !ok {
+		t.Error("position not stored once the cache recovered")
+	}
+}
@@ -0,0 +1,154 @@
+package mongodb_stream_benthos
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// positionSpill holds the tracked positions of unacknowledged messages that
+// do not fit in memory under in_flight_limit, in an append only file of JSON
+// lines in the order they were read. Only an acked bit per spilled message
+// stays in memory. Spilled positions are read back in order once the
+// acknowledged front reaches them, and the file is truncated whenever every
+// spilled position has been read back.
+type positionSpill struct {
+	file     *os.File
+	readFile *os.File
+	writer   *bufio.Writer
+	reader   *bufio.Reader
+	metrics  *streamMetrics
+
+	// firstID is the id of the first position in the file, and acked holds
+	// the acked bit of every position from it on.
+	firstID uint64
+	acked   []uint64
+	written int
+	read    int
+}
+
+type spilledPosition struct {
+	ID       uint64            `json:"id"`
+	File     string            `json:"file,omitempty"`
+	Pos      uint32            `json:"pos,omitempty"`
+	Progress *snapshotProgress `json:"progress,omitempty"`
+}
+
+func newPositionSpill(dir string, metrics *streamMetrics) (*positionSpill, error) {
+	file, err := os.CreateTemp(dir, "mysql-stream-in-flight-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	// Positions are appended through one handle and read back through
+	// another, each with its own offset.
+	readFile, err := os.Open(file.Name())
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &positionSpill{
+		file:     file,
+		readFile: readFile,
+		writer:   bufio.NewWriter(file),
+		reader:   bufio.NewReader(readFile),
+		metrics:  metrics,
+	}, nil
+}
+
+// pending returns the number of spilled positions not read back yet.
+func (s *positionSpill) pending() int {
+	return s.written - s.read
+}
+
+// add appends the position of message id to the file.
+func (s *positionSpill) add(id uint64, p *trackedPosition) error {
+	if s.written == 0 {
+		s.firstID = id
+	}
+	line, err := json.Marshal(spilledPosition{ID: id, File: p.resume.Name, Pos: p.resume.Pos, Progress: p.progress})
+	if err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.written++
+	s.metrics.inFlightSpilled.Incr(1)
+	s.metrics.inFlightSpillPending.Set(int64(s.pending()))
+	return nil
+}
+
+// ack records that spilled message id has been acknowledged.
+func (s *positionSpill) ack(id uint64) {
+	if id < s.firstID {
+		return
+	}
+	i := id - s.firstID
+	for uint64(len(s.acked)) <= i/64 {
+		s.acked = append(s.acked, 0)
+	}
+	s.acked[i/64] |= 1 << (i % 64)
+}
+
+func (s *positionSpill) isAcked(id uint64) bool {
+	i := id - s.firstID
+	return i/64 < uint64(len(s.acked)) && s.acked[i/64]&(1<<(i%64)) != 0
+}
+
+// load reads up to n spilled positions back into inFlight.
+func (s *positionSpill) load(inFlight map[uint64]*trackedPosition, n int) error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	for ; n > 0 && s.pending() > 0; n-- {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		var p spilledPosition
+		if err := json.Unmarshal(line, &p); err != nil {
+			return err
+		}
+		inFlight[p.ID] = &trackedPosition{
+			resume:   mysql.Position{Name: p.File, Pos: p.Pos},
+			progress: p.Progress,
+			acked:    s.isAcked(p.ID),
+		}
+		s.read++
+	}
+	s.metrics.inFlightSpillPending.Set(int64(s.pending()))
+	if s.pending() == 0 {
+		return s.reset()
+	}
+	return nil
+}
+
+// reset truncates the file once every spilled position has been read back.
+func (s *positionSpill) reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.readFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.writer.Reset(s.file)
+	s.reader.Reset(s.readFile)
+	s.acked = s.acked[:0]
+	s.written, s.read = 0, 0
+	return nil
+}
+
+func (s *positionSpill) close() error {
+	s.readFile.Close()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,225 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// checkServer verifies on connect that the server can be streamed from,
+// turning setups that would otherwise fail opaquely inside canal or stream
+// nothing at all into explicit errors. It uses a connection of its own rather
+// than the metadata pool, which keeps redialing a server it cannot reach until
+// its caller gives up, so that each connect dials the server once and fails
+// as soon as it cannot be reached.
+func (m *MysqlStreamInput) checkServer() error {
+	conn, err := m.controlConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return m.checkServerConn(conn)
+}
+
+func (m *MysqlStreamInput) checkServerConn(conn *client.Conn) error {
+	if err := checkNotVitess(conn); err != nil {
+		return err
+	}
+	if m.checkBinlogFormat {
+		if err := verifyBinlogFormat(conn); err != nil {
+			return err
+		}
+	}
+	m.serverUUID = serverUUID(conn)
+	if m.positions != nil {
+		m.positions.setServerUUID(m.serverUUID)
+	}
+	m.checkAurora(conn)
+	m.readSourceInfo(conn)
+	return m.checkTablesExist(conn)
+}
+
+// serverUUID returns the server's server_uuid, or an empty string for servers
+// without one such as MariaDB.
+func serverUUID(conn *client.Conn) string {
+	res, err := conn.Execute("SELECT @@server_uuid")
+	if err != nil || res.RowNumber() == 0 {
+		return ""
+	}
+	uuid, _ := res.GetString(0, 0)
+	return uuid
+}
+
+// checkTablesExist applies on_missing_table to the configured tables that do
+// not exist on the server.
+func (m *MysqlStreamInput) checkTablesExist(conn *client.Conn) error {
+	if len(m.tableRefs) == 0 {
+		return nil
+	}
+
+	conds := make([]string, 0, len(m.tableRefs))
+	args := make([]any, 0, 2*len(m.tableRefs))
+	for _, ref := range m.tableRefs {
+		conds = append(conds, "(TABLE_SCHEMA = ? AND TABLE_NAME = ?)")
+		args = append(args, ref.schema, ref.name)
+	}
+	res, err := conn.Execute("SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES WHERE "+strings.Join(conds, " OR "), args...)
+	if err != nil {
+		return fmt.Errorf("querying configured tables: %w", err)
+	}
+
+	existing := make(map[string]struct{}, res.RowNumber())
+	var views []tableRef
+	for i := 0; i < res.RowNumber(); i++ {
+		db, _ := res.GetString(i, 0)
+		table, _ := res.GetString(i, 1)
+		tableType, _ := res.GetString(i, 2)
+		if tableType == "VIEW" {
+			views = append(views, tableRef{schema: db, name: table})
+			continue
+		}
+		existing[tableRef{schema: db, name: table}.key()] = struct{}{}
+	}
+	if len(views) > 0 {
+		if err := m.applyOnView(conn, views); err != nil {
+			return err
+		}
+		return m.checkTablesExist(conn)
+	}
+
+	var missing []string
+	present := make([]tableRef, 0, len(m.tableRefs))
+	for _, ref := range m.tableRefs {
+		if _, ok := existing[ref.key()]; ok {
+			present = append(present, ref)
+		} else {
+			missing = append(missing, ref.key())
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	switch m.onMissingTable {
+	case missingTableWarn:
+		m.logger.Warnf("Configured tables do not exist and will be streamed once created: %s", strings.Join(missing, ", "))
+	case missingTableSkip:
+		if len(present) == 0 {
+			return fmt.Errorf("none of the configured tables exist: %s", strings.Join(missing, ", "))
+		}
+		m.logger.Warnf("Skipping configured tables that do not exist: %s", strings.Join(missing, ", "))
+		m.tableRefs = present
+		for _, key := range missing {
+			delete(m.tableSet, key)
+		}
+	default:
+		return fmt.Errorf("configured tables do not exist: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// applyOnView handles configured tables that are views, which never appear in
+// the binlog: with on_view error it fails naming them, and with resolve it
+// replaces each view with the base tables it reads from, following views
+// defined on other views.
+func (m *MysqlStreamInput) applyOnView(conn *client.Conn, views []tableRef) error {
+	names := make([]string, 0, len(views))
+	for _, view := range views {
+		names = append(names, view.key())
+	}
+	if m.onView != onViewResolve {
+		return fmt.Errorf("configured tables are views, which have no binlog events, list their base tables instead or set on_view to resolve: %s", strings.Join(names, ", "))
+	}
+
+	isView := make(map[string]bool, len(views))
+	for _, view := range views {
+		isView[view.key()] = true
+	}
+	var base []tableRef
+	seen := map[string]bool{}
+	for len(views) > 0 {
+		view := views[0]
+		views = views[1:]
+		if seen[view.key()] {
+			continue
+		}
+		seen[view.key()] = true
+
+		res, err := conn.Execute(`SELECT u.TABLE_SCHEMA, u.TABLE_NAME, t.TABLE_TYPE FROM information_schema.VIEW_TABLE_USAGE u
+			JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = u.TABLE_SCHEMA AND t.TABLE_NAME = u.TABLE_NAME
+			WHERE u.VIEW_SCHEMA = ? AND u.VIEW_NAME = ?`, view.schema, view.name)
+		if err != nil {
+			return fmt.Errorf("resolving the base tables of view %s, which requires MySQL 8.0.13 or later: %w", view.key(), err)
+		}
+		if res.RowNumber() == 0 {
+			return fmt.Errorf("view %s does not read from any table", view.key())
+		}
+		for i := 0; i < res.RowNumber(); i++ {
+			db, _ := res.GetString(i, 0)
+			table, _ := res.GetString(i, 1)
+			tableType, _ := res.GetString(i, 2)
+			ref := tableRef{schema: db, name: table}
+			if tableType == "VIEW" {
+				views = append(views, ref)
+			} else {
+				base = append(base, ref)
+			}
+		}
+	}
+
+	refs := make([]tableRef, 0, len(m.tableRefs)+len(base))
+	for _, ref := range m.tableRefs {
+		if !isView[ref.key()] {
+			refs = append(refs, ref)
+		}
+	}
+	for _, ref := range base {
+		if _, ok := m.tableSet[ref.key()]; !ok {
+			refs = append(refs, ref)
+			m.tableSet[ref.key()] = struct{}{}
+		}
+	}
+	for key := range isView {
+		delete(m.tableSet, key)
+	}
+	m.tableRefs = refs
+	m.logger.Infof("Streaming the base tables of views %s", strings.Join(names, ", "))
+	return nil
+}
+
+// checkNotVitess fails for Vitess and PlanetScale endpoints. vtgate speaks
+// the MySQL protocol but does not serve binlog replication, its change data
+// is only available through the VStream API, which is not supported.
+func checkNotVitess(conn *client.Conn) error {
+	res, err := conn.Execute("SELECT @@version, @@version_comment")
+	if err != nil {
+		return fmt.Errorf("querying server version: %w", err)
+	}
+	version, _ := res.GetString(0, 0)
+	comment, _ := res.GetString(0, 1)
+
+	if strings.Contains(strings.ToLower(version+" "+comment), "vitess") {
+		return fmt.Errorf("%w: %s is a Vitess endpoint, which does not support binlog replication; streaming from Vitess or PlanetScale requires the VStream API and is not supported, connect to an underlying MySQL tablet instead", service.ErrEndOfInput, version)
+	}
+	return nil
+}
+
+// verifyBinlogFormat fails when the server does not log row events, which
+// would otherwise leave the stream silently empty.
+func verifyBinlogFormat(conn *client.Conn) error {
+	res, err := conn.Execute("SELECT @@GLOBAL.binlog_format")
+	if err != nil {
+		return fmt.Errorf("querying binlog_format: %w", err)
+	}
+	format, err := res.GetString(0, 0)
+	if err != nil {
+		return fmt.Errorf("querying binlog_format: %w", err)
+	}
+
+	if !strings.EqualFold(format, "ROW") {
+		return fmt.Errorf("%w: server binlog_format is %s but ROW is required, set binlog_format=ROW on the server or disable check_binlog_format", service.ErrEndOfInput, format)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"time"
+)
+
+// rateLimiter is a token bucket allowing up to rate events per second, with
+// bursts of up to one second worth of events. It is only used from Read,
+// which Benthos never calls concurrently, so it is not safe for concurrent
+// use.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	burst := float64(rate)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: float64(rate), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until an event may be emitted or ctx is done.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		now := time.Now()
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+		r.last = now
+
+		if r.tokens >= 1 {
+			r.tokens--
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration((1 - r.tokens) / r.rate * float64(time.Second)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestRateLimiterRate(t *testing.T) {
+	const rate = 100
+	r := newRateLimiter(rate)
+
+	// The bucket starts full, with one second worth of events.
+	start := time.Now()
+	for i := 0; i < rate; i++ {
+		if err := r.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took > 100*time.Millisecond {
+		t.Errorf("burst of %d events took %v, want no wait", rate, took)
+	}
+
+	// Past the burst, events are spaced by 1/rate seconds.
+	start = time.Now()
+	for i := 0; i < rate/5; i++ {
+		if err := r.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took < 150*time.Millisecond || took > time.Second {
+		t.Errorf("%d events past the burst took %v, want about 200ms", rate/5, took)
+	}
+}
+
+func TestRateLimiterContextDone(t *testing.T) {
+	r := newRateLimiter(1)
+	if err := r.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := r.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("wait returned %v after its context was done", took)
+	}
+}
+
+func TestMaxEventsPerSecondKeepsPosition(t *testing.T) {
+	m := newTestInput(t, WithMaxEventsPerSecond(1))
+	for _, pos := range []uint32{100, 200} {
+		m.stream <- StreamMessage{
+			Table:    "orders",
+			Event:    "insert",
+			Data:     map[string]any{"id": int64(pos)},
+			position: mysql.Position{Name: "mysql-bin.000001", Pos: pos},
+		}
+	}
+
+	msg, _ := readStructured(t, m)
+	if pos, _ := msg.MetaGet("binlog_pos"); pos != "100" {
+		t.Fatalf("first message at binlog_pos %s, want 100", pos)
+	}
+
+	// The second message is throttled, and stays buffered rather than
+	// being dropped.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("throttled Read error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(m.stream) != 1 {
+		t.Fatalf("%d messages buffered after a throttled read, want 1", len(m.stream))
+	}
+
+	m.limiter.tokens = 1
+	msg, _ = readStructured(t, m)
+	if pos, _ := msg.MetaGet("binlog_pos"); pos != "200" {
+		t.Errorf("message after the throttled read at binlog_pos %s, want 200", pos)
+	}
+}
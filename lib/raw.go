@@ -0,0 +1,41 @@
+package mongodb_stream_benthos
+
+import "github.com/go-mysql-org/go-mysql/schema"
+
+// rawData returns the values of a row image as read from the binlog or the
+// snapshot query, before any type conversion or column transform, for the
+// _raw section of include_raw.
+func (m *MysqlStreamInput) rawData(table *schema.Table, row []any) map[string]any {
+	invisible := m.invisibleColumnsOf(table)
+	raw := make(map[string]any, len(row))
+	for i, v := range row {
+		col := table.Columns[i]
+		if m.skipColumn(col, v) || invisible[col.Name] {
+			continue
+		}
+		raw[col.Name] = v
+	}
+	return raw
+}
+
+// temporalRawData returns the values of the date and time columns of a row
+// image as read from MySQL, for the _temporal_raw section of temporal_debug.
+// Values read as bytes by the snapshot query are returned as strings.
+func (m *MysqlStreamInput) temporalRawData(table *schema.Table, row []any) map[string]any {
+	invisible := m.invisibleColumnsOf(table)
+	raw := map[string]any{}
+	for i, v := range row {
+		if i >= len(table.Columns) {
+			break
+		}
+		col := table.Columns[i]
+		if !isTemporal(col) && col.Type != schema.TYPE_TIME || m.skipColumn(col, v) || invisible[col.Name] {
+			continue
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		raw[col.Name] = v
+	}
+	return raw
+}
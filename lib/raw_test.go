@@ -0,0 +1,58 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestRawField(t *testing.T) {
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "imports",
+		Columns:   []schema.TableColumn{{Name: "id"}, {Name: "_raw", Type: schema.TYPE_STRING}},
+		PKColumns: []int{0},
+	}
+
+	tests := []struct {
+		name      string
+		field     string
+		wantField string
+	}{
+		{name: "default", wantField: "_raw"},
+		{name: "renamed", field: "_mysql", wantField: "_mysql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithDatabase("shop"), WithRawValues(true), WithStructuredMessages(true)}
+			if tt.field != "" {
+				opts = append(opts, WithRawField(tt.field))
+			}
+			m := newTestInput(t, opts...)
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(1), "line 1"}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, body := readStructured(t, m)
+			raw, ok := body[tt.wantField].(map[string]any)
+			if !ok || raw["id"] != int64(1) {
+				t.Fatalf("%s = %v, want the raw values", tt.wantField, body[tt.wantField])
+			}
+			if tt.field != "" && body["_raw"] != "line 1" {
+				t.Errorf("_raw column = %v, want line 1", body["_raw"])
+			}
+		})
+	}
+
+	if _, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithRawValues(true), WithRawField("")); err == nil {
+		t.Error("empty raw_field accepted")
+	}
+}
@@ -0,0 +1,61 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// recoverRowsEvent turns a panic while handling a rows event into an error
+// naming the table and position of the event, so that a malformed event
+// stops the stream, which is reported by Read, rather than the process.
+func (m *MysqlStreamInput) recoverRowsEvent(e *canal.RowsEvent, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	at := "snapshot"
+	if e.Header != nil {
+		at = fmt.Sprintf("%s:%d", m.binlogFile, e.Header.LogPos)
+	}
+	*err = fmt.Errorf("panic handling %s event on %s at %s: %v", e.Action, e.Table, at, r)
+	m.recordPanic(*err)
+}
+
+// recoverEvent does the same as recoverRowsEvent for the other binlog
+// events, naming the event and its position when it has a header.
+func (m *MysqlStreamInput) recoverEvent(event string, header *replication.EventHeader, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	at := m.binlogFile
+	if header != nil {
+		at = fmt.Sprintf("%s:%d", m.binlogFile, header.LogPos)
+	}
+	*err = fmt.Errorf("panic handling %s event at %s: %v", event, at, r)
+	m.recordPanic(*err)
+}
+
+// recordPanic logs a recovered panic with its stack, and marks the stream as
+// panicked so that the reconnect is counted toward max_reconnect_attempts
+// even if the stream progressed: it resumes before the event that panicked,
+// which most likely panics again. It must be called from a deferred func.
+func (m *MysqlStreamInput) recordPanic(err error) {
+	m.streamPanicked = true
+	m.logger.Errorf("%v\n%s", err, debug.Stack())
+}
+
+// recoverEncode turns a panic while encoding a message into an error result,
+// see recoverRowsEvent.
+func (m *MysqlStreamInput) recoverEncode(msg StreamMessage, result *encodedMessage) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := fmt.Errorf("panic encoding %s message from table %s: %v", msg.Event, msg.Table, r)
+	m.logger.Errorf("%v\n%s", err, debug.Stack())
+	*result = encodedMessage{err: err, panicked: true}
+}
@@ -0,0 +1,72 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestHandlerPanicCountsTowardReconnects(t *testing.T) {
+	m := newTestInput(t, WithMaxReconnectAttempts(2))
+	m.binlogFile = "mysql-bin.000004"
+	h := EventHandler{m: m}
+
+	// Without a canal to resolve the table from, the handler panics.
+	err := h.OnTableChanged(&replication.EventHeader{LogPos: 700}, "shop", "orders")
+	if err == nil || !strings.Contains(err.Error(), "panic handling table changed event at mysql-bin.000004:700") {
+		t.Fatalf("OnTableChanged error = %v, want the recovered panic", err)
+	}
+
+	// The stream progressed before it panicked, but resumes before the
+	// event, so its reconnect still counts.
+	m.reconnectAttempts = 2
+	m.streamProgressed = true
+	m.readerErr <- err
+	if _, _, err := m.Read(context.Background()); !errors.Is(err, service.ErrEndOfInput) {
+		t.Fatalf("Read error = %v, want %v", err, service.ErrEndOfInput)
+	}
+}
+
+type panicEncoder struct{}
+
+func (panicEncoder) Encode(context.Context, StreamMessage) ([]byte, error) {
+	var data map[string]any
+	data["boom"] = 1
+	return nil, nil
+}
+
+var registerPanicEncoder sync.Once
+
+func TestEncoderPanicCountsTowardReconnects(t *testing.T) {
+	registerPanicEncoder.Do(func() {
+		if err := RegisterMessageEncoder("test_panic", panicEncoder{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat("test_panic"), WithMaxReconnectAttempts(2))
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(1)}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = m.Read(context.Background())
+	if err == nil || errors.Is(err, service.ErrEndOfInput) || !strings.Contains(err.Error(), "panic encoding") {
+		t.Fatalf("first Read error = %v, want the recovered panic", err)
+	}
+	if _, _, err = m.Read(context.Background()); !errors.Is(err, service.ErrEndOfInput) {
+		t.Fatalf("second Read error = %v, want %v", err, service.ErrEndOfInput)
+	}
+}
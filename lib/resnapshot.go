@@ -0,0 +1,281 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+var errResnapshotQueueFull = errors.New("too many pending resnapshot requests")
+
+// resnapshotBoundaryPoll is how often a queued resnapshot checks whether the
+// stream reached a transaction boundary it can start at.
+const resnapshotBoundaryPoll = 10 * time.Millisecond
+
+// resnapshotClaimTTL bounds how long the claim on a resnapshot request held by
+// an input that stopped before releasing it blocks other inputs.
+const resnapshotClaimTTL = time.Minute
+
+// resnapshotRun is a resnapshot running alongside the stream. Changes to its
+// tables read from the binlog while it runs are held back, and sent once its
+// rows are, so that consumers never see a snapshot row overwrite a newer
+// change.
+type resnapshotRun struct {
+	refs   []tableRef
+	tables map[string]struct{}
+	// position is the synced position the run started at. Messages sent while
+	// it runs resume from it, as the snapshot is only complete once it ends.
+	position mysql.Position
+	held     []StreamMessage
+	done     chan struct{}
+}
+
+// touches reports whether msg changes one of the tables of the run.
+func (r *resnapshotRun) touches(msg StreamMessage) bool {
+	if msg.Event == transactionEvent {
+		changes, _ := msg.Data["changes"].([]StreamMessage)
+		for _, change := range changes {
+			if r.touches(change) {
+				return true
+			}
+		}
+		return false
+	}
+	var ref tableRef
+	switch {
+	case msg.table != nil:
+		ref = tableRef{schema: msg.table.Schema, name: msg.table.Name}
+	case msg.database != "" && msg.Table != "":
+		ref = tableRef{schema: msg.database, name: msg.Table}
+	default:
+		return false
+	}
+	_, ok := r.tables[ref.key()]
+	return ok
+}
+
+// Resnapshot requests a fresh snapshot of the given tables, named as in the
+// tables field, while the binlog stream stays connected. The snapshot starts
+// at the next transaction boundary of the stream and runs alongside it:
+// changes to the tables read meanwhile are held back until it completes and
+// are then streamed after its rows, while changes to other tables keep
+// flowing. As with the initial snapshot, no change to the tables is lost.
+func (m *MysqlStreamInput) Resnapshot(tables ...string) error {
+	if len(tables) == 0 {
+		return errors.New("resnapshot requires at least one table")
+	}
+
+	refs := parseTableRefs(m.database, tables)
+	for _, ref := range refs {
+		if !m.isTableStreamed(ref.schema, ref.name) {
+			return fmt.Errorf("resnapshot: table %s is not streamed", ref.key())
+		}
+	}
+
+	select {
+	case m.resnapshotRequests <- refs:
+		m.logger.Infof("Queued resnapshot of %d tables", len(refs))
+		return nil
+	default:
+		return errResnapshotQueueFull
+	}
+}
+
+// runResnapshots starts the queued resnapshot requests one at a time, each at
+// the first transaction boundary of the stream once the previous one ended.
+func (m *MysqlStreamInput) runResnapshots(ctx context.Context) {
+	for {
+		var refs []tableRef
+		select {
+		case <-ctx.Done():
+			return
+		case refs = <-m.resnapshotRequests:
+		}
+		for !m.tryStartResnapshot(refs) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resnapshotBoundaryPoll):
+			}
+		}
+	}
+}
+
+// tryStartResnapshot starts a resnapshot of refs if the stream is between
+// transactions and no other one is running.
+func (m *MysqlStreamInput) tryStartResnapshot(refs []tableRef) bool {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	if !m.streaming || m.inTransaction || m.resnapshotting != nil {
+		return false
+	}
+	m.startResnapshot(refs)
+	return true
+}
+
+// startResnapshot starts a resnapshot of refs alongside the stream. It must be
+// called with the event lock held, between transactions, while no other
+// resnapshot runs.
+func (m *MysqlStreamInput) startResnapshot(refs []tableRef) {
+	run := &resnapshotRun{
+		refs:     refs,
+		tables:   make(map[string]struct{}, len(refs)),
+		position: m.syncedPosition,
+		done:     make(chan struct{}),
+	}
+	for _, ref := range refs {
+		run.tables[ref.key()] = struct{}{}
+	}
+	m.resnapshotting = run
+	m.snapshotPosition, m.snapshotGTIDSet = run.position, nil
+	go m.resnapshot(run)
+}
+
+// resnapshot reads the tables of run and then sends the changes held back
+// while it ran. A failed resnapshot is logged rather than ending the stream,
+// which has moved on since it started, and can be requested again.
+func (m *MysqlStreamInput) resnapshot(run *resnapshotRun) {
+	defer close(run.done)
+
+	m.snapshotAlongside = true
+	err := m.snapshot(run.refs, nil)
+	m.snapshotAlongside = false
+	if err != nil {
+		m.logger.Errorf("Resnapshot of %d tables failed: %v", len(run.refs), err)
+	}
+	m.finishResnapshot(run)
+}
+
+// finishResnapshot ends run, sending the changes held back while it ran.
+func (m *MysqlStreamInput) finishResnapshot(run *resnapshotRun) {
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	m.resnapshotting = nil
+	if len(run.held) > 0 {
+		m.logger.Infof("Sending %d changes held back during the resnapshot", len(run.held))
+	}
+	// The held changes were read from the binlog already, so they wait for
+	// room in the buffer whatever on_buffer_full says.
+	for _, msg := range run.held {
+		if err := m.sendBlocking(m.prepare(msg)); err != nil {
+			return
+		}
+	}
+}
+
+// holdForResnapshot holds back a change to a table a resnapshot is reading,
+// reporting whether it did. Once resnapshot_max_held_changes are held the
+// stream pauses until the resnapshot completes, and the change is sent as
+// usual after the held ones. It must be called with the event lock held.
+func (m *MysqlStreamInput) holdForResnapshot(msg StreamMessage) bool {
+	run := m.resnapshotting
+	if run == nil || m.snapshotEmitting || !run.touches(msg) {
+		return false
+	}
+	if len(run.held) < m.resnapshotMaxHeld {
+		run.held = append(run.held, msg)
+		return true
+	}
+	m.logger.Warnf("Pausing the stream until the resnapshot completes, as %d changes are held back", len(run.held))
+	m.eventMu.Unlock()
+	<-run.done
+	m.eventMu.Lock()
+	return false
+}
+
+// awaitResnapshot waits for a resnapshot running alongside the stream to
+// complete. It must be called without the event lock held.
+func (m *MysqlStreamInput) awaitResnapshot() {
+	m.eventMu.Lock()
+	run := m.resnapshotting
+	m.eventMu.Unlock()
+	if run != nil {
+		<-run.done
+	}
+}
+
+// emitAlongside runs fn, which emits snapshot rows, under the event lock when
+// the snapshot runs alongside the stream.
+func (m *MysqlStreamInput) emitAlongside(fn func() error) error {
+	if !m.snapshotAlongside {
+		return fn()
+	}
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	m.snapshotEmitting = true
+	defer func() { m.snapshotEmitting = false }()
+	return fn()
+}
+
+// pollResnapshotRequests periodically reads a comma separated list of tables
+// from the resnapshot cache, deleting the key once the request is claimed.
+func (m *MysqlStreamInput) pollResnapshotRequests(ctx context.Context) {
+	ticker := time.NewTicker(m.resnapshotPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var request []byte
+		var cacheErr error
+		err := m.resources.AccessCache(ctx, m.resnapshotCache, func(c service.Cache) {
+			request, cacheErr = m.claimResnapshotRequest(ctx, c)
+		})
+		if err == nil {
+			err = cacheErr
+		}
+		if errors.Is(err, service.ErrKeyNotFound) || errors.Is(err, service.ErrKeyAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			m.logger.Warnf("Failed to read resnapshot requests: %v", err)
+			continue
+		}
+
+		var tables []string
+		for _, table := range strings.Split(string(request), ",") {
+			if table = strings.TrimSpace(table); table != "" {
+				tables = append(tables, table)
+			}
+		}
+		if err := m.Resnapshot(tables...); err != nil {
+			m.logger.Errorf("Ignoring resnapshot request %q: %v", request, err)
+		}
+	}
+}
+
+// claimResnapshotRequest takes the request from the cache, so that of several
+// inputs polling the same key only one runs it. The claim is a second key
+// added next to the request, which fails with service.ErrKeyAlreadyExists
+// while another input holds it, and the request is read again under the
+// claim in case another input took it in between.
+func (m *MysqlStreamInput) claimResnapshotRequest(ctx context.Context, c service.Cache) ([]byte, error) {
+	if _, err := c.Get(ctx, m.resnapshotKey); err != nil {
+		return nil, err
+	}
+	claim := m.resnapshotKey + ".claim"
+	ttl := resnapshotClaimTTL
+	if err := c.Add(ctx, claim, []byte(m.addr), &ttl); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.Delete(ctx, claim); err != nil {
+			m.logger.Warnf("Failed to release the claim on resnapshot requests: %v", err)
+		}
+	}()
+
+	request, err := c.Get(ctx, m.resnapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	return request, c.Delete(ctx, m.resnapshotKey)
+}
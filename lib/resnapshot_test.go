@@ -0,0 +1,125 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestClaimResnapshotRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		claimed   bool
+		wantErr   error
+		wantTaken bool
+	}{
+		{name: "unclaimed", wantTaken: true},
+		{name: "claimed by another input", claimed: true, wantErr: service.ErrKeyAlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t)
+			ctx := context.Background()
+			cache := &testCache{values: map[string][]byte{"resnapshot": []byte("orders,users")}}
+			if tt.claimed {
+				cache.values["resnapshot.claim"] = []byte("10.0.0.2:3306")
+			}
+
+			request, err := m.claimResnapshotRequest(ctx, cache)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("claimResnapshotRequest error = %v, want %v", err, tt.wantErr)
+			}
+			_, kept := cache.values["resnapshot"]
+			if kept == tt.wantTaken {
+				t.Errorf("request kept in the cache = %v, want %v", kept, !tt.wantTaken)
+			}
+			if !tt.wantTaken {
+				return
+			}
+			if string(request) != "orders,users" {
+				t.Errorf("request = %q, want orders,users", request)
+			}
+			if _, ok := cache.values["resnapshot.claim"]; ok {
+				t.Error("claim not released")
+			}
+			// The request is gone, so claiming it again finds nothing.
+			if _, err := m.claimResnapshotRequest(ctx, cache); !errors.Is(err, service.ErrKeyNotFound) {
+				t.Errorf("second claim error = %v, want %v", err, service.ErrKeyNotFound)
+			}
+		})
+	}
+}
+
+func TestResnapshotHoldsChangesToItsTables(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithResnapshotMaxHeldChanges(1))
+	h := EventHandler{m: m}
+	orders := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	users := &schema.Table{Schema: "shop", Name: "users", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	m.syncedPosition = mysql.Position{Name: "mysql-bin.000001", Pos: 1000}
+	m.binlogFile = "mysql-bin.000001"
+
+	run := &resnapshotRun{
+		tables:   map[string]struct{}{"shop.orders": {}},
+		position: mysql.Position{Name: "mysql-bin.000001", Pos: 400},
+		done:     make(chan struct{}),
+	}
+	m.resnapshotting = run
+	insert := func(table *schema.Table, id int64, pos uint32) error {
+		return h.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{id}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+		})
+	}
+
+	if err := insert(orders, 1, 1100); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert(users, 2, 1200); err != nil {
+		t.Fatal(err)
+	}
+	// Changes to other tables keep flowing, resuming from where the run
+	// started.
+	msg := <-m.stream
+	if msg.table != users || msg.resume != run.position {
+		t.Fatalf("sent %s resuming at %v, want shop.users resuming at %v", msg.table, msg.resume, run.position)
+	}
+	if len(m.stream) != 0 || len(run.held) != 1 {
+		t.Fatalf("%d messages sent and %d held, want 0 and 1", len(m.stream), len(run.held))
+	}
+
+	// Past resnapshot_max_held_changes the stream pauses until the run
+	// completes, and the change follows the held one.
+	paused := make(chan error, 1)
+	go func() {
+		paused <- insert(orders, 3, 1300)
+	}()
+	select {
+	case err := <-paused:
+		t.Fatalf("stream did not pause past the held limit: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	m.finishResnapshot(run)
+	close(run.done)
+	if err := <-paused; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int64{1, 3} {
+		msg := <-m.stream
+		if msg.table != orders || msg.Data["id"] != want {
+			t.Errorf("sent %s %v, want shop.orders %d", msg.table, msg.Data["id"], want)
+		}
+	}
+	if m.resnapshotting != nil {
+		t.Error("resnapshot still running after it finished")
+	}
+}
@@ -0,0 +1,14 @@
+package mongodb_stream_benthos
+
+// routingKey returns the value of the routing_key metadata field: the schema
+// qualified table for row changes, or the event name for messages that are
+// not tied to a single table, such as transactions and lifecycle events.
+func routingKey(msg StreamMessage) string {
+	if msg.table != nil {
+		return msg.table.Schema + "." + msg.table.Name
+	}
+	if msg.database != "" {
+		return msg.database + "." + msg.Table
+	}
+	return msg.Event
+}
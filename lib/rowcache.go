@@ -0,0 +1,171 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// rowKey identifies a row by its table and primary key values. Tables without
+// a primary key cannot be keyed.
+func rowKey(table *schema.Table, row []any) (string, bool) {
+	if len(table.PKColumns) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(table.String())
+	for _, i := range table.PKColumns {
+		if i >= len(row) || row[i] == nil {
+			return "", false
+		}
+		fmt.Fprintf(&b, "\x00%v", row[i])
+	}
+	return b.String(), true
+}
+
+// fillRowImages completes row images written with binlog_row_image=MINIMAL
+// from the last full image seen for the same primary key. Inserts and
+// snapshot rows are always full images and seed the cache, as do updates
+// with a full before image; deletes evict it. With fill_minimal_images every
+// image is completed, and otherwise only the minimal images of deletes for
+// delete_minimal_behavior cache. It returns the rows of a delete filled in
+// from a minimal image.
+//
+// Only the columns absent tells the images left out are filled, so that a
+// column an update sets to NULL is NULL. When they are not known, for
+// compressed transactions, every NULL value is taken to be left out and an
+// update that sets a column to NULL keeps its cached value.
+func (m *MysqlStreamInput) fillRowImages(e *canal.RowsEvent, absent absentColumns) map[int]bool {
+	var fromCache map[int]bool
+	switch e.Action {
+	case canal.InsertAction:
+		for _, row := range e.Rows {
+			if key, ok := rowKey(e.Table, row); ok {
+				m.rowCache.put(key, row)
+			}
+		}
+	case canal.DeleteAction:
+		for i, row := range e.Rows {
+			key, ok := rowKey(e.Table, row)
+			if !ok {
+				continue
+			}
+			left, known := absent.of(i)
+			minimal := isMinimalImage(e.Table, row, left, known)
+			if cached, ok := m.rowCache.get(key); ok && (minimal || m.fillMinimalImages) {
+				e.Rows[i] = fillRow(row, cached, left, known)
+				if minimal {
+					if fromCache == nil {
+						fromCache = map[int]bool{}
+					}
+					fromCache[i] = true
+				}
+			}
+			m.rowCache.remove(key)
+		}
+	case canal.UpdateAction:
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			before, after := e.Rows[i], e.Rows[i+1]
+			beforeAbsent, beforeKnown := absent.of(i)
+			afterAbsent, afterKnown := absent.of(i + 1)
+
+			beforeKey, ok := rowKey(e.Table, before)
+			if !ok {
+				continue
+			}
+			cached, ok := m.rowCache.get(beforeKey)
+			if !ok {
+				if !isMinimalImage(e.Table, before, beforeAbsent, beforeKnown) {
+					if afterKey, ok := rowKey(e.Table, after); ok {
+						m.rowCache.put(afterKey, after)
+					}
+				}
+				continue
+			}
+
+			filled := fillRow(after, cached, afterAbsent, afterKnown)
+			if m.fillMinimalImages {
+				e.Rows[i] = fillRow(before, cached, beforeAbsent, beforeKnown)
+				e.Rows[i+1] = filled
+			}
+
+			if afterKey, ok := rowKey(e.Table, filled); ok {
+				if afterKey != beforeKey {
+					m.rowCache.remove(beforeKey)
+				}
+				m.rowCache.put(afterKey, filled)
+			}
+		}
+	}
+	return fromCache
+}
+
+// fillRow returns a copy of row with the values of the columns it left out
+// taken from full. When the columns left out are not known every NULL value
+// is taken from full.
+func fillRow(row, full []any, absent []int, known bool) []any {
+	filled := append([]any(nil), row...)
+	if known {
+		for _, i := range absent {
+			if i < len(full) {
+				filled[i] = full[i]
+			}
+		}
+		return filled
+	}
+	for i, v := range row {
+		if v == nil && i < len(full) {
+			filled[i] = full[i]
+		}
+	}
+	return filled
+}
+
+const (
+	deleteMinimalFlag   = "flag"
+	deleteMinimalPKOnly = "pk_only"
+	deleteMinimalCache  = "cache"
+)
+
+// isMinimalImage reports whether a row image holds only its primary key, as
+// the images of deletes written with binlog_row_image=MINIMAL do. When the
+// columns the image left out are not known, such an image cannot be told
+// apart from a row whose other columns are all NULL.
+func isMinimalImage(table *schema.Table, row []any, absent []int, known bool) bool {
+	if len(table.PKColumns) == 0 || len(table.PKColumns) == len(table.Columns) {
+		return false
+	}
+	pk := make(map[int]bool, len(table.PKColumns))
+	for _, i := range table.PKColumns {
+		pk[i] = true
+	}
+	left := make(map[int]bool, len(absent))
+	for _, i := range absent {
+		left[i] = true
+	}
+	for i, v := range row {
+		if _, omitted := v.(omittedColumn); pk[i] || omitted || left[i] {
+			continue
+		}
+		if known || v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pkOnly returns the primary key columns of a message for the pk_only and
+// cache delete_minimal_behavior.
+func pkOnly(table *schema.Table, data map[string]any) map[string]any {
+	pk := make(map[string]any, len(table.PKColumns))
+	for _, i := range table.PKColumns {
+		name := table.Columns[i].Name
+		if v, ok := data[name]; ok {
+			pk[name] = v
+		}
+	}
+	return pk
+}
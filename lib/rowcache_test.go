@@ -0,0 +1,244 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func rowCacheTestTable() *schema.Table {
+	return &schema.Table{
+		Schema: "shop",
+		Name:   "users",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "name", Type: schema.TYPE_STRING},
+			{Name: "email", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+}
+
+func TestFillRow(t *testing.T) {
+	full := []any{int64(1), "ann", "ann@example.com"}
+
+	tests := []struct {
+		name   string
+		row    []any
+		absent []int
+		known  bool
+		want   []any
+	}{
+		{name: "unknown fills nil", row: []any{int64(1), "bob", nil}, want: []any{int64(1), "bob", "ann@example.com"}},
+		{name: "known fills absent", row: []any{int64(1), "bob", nil}, absent: []int{2}, known: true, want: []any{int64(1), "bob", "ann@example.com"}},
+		{name: "known keeps explicit null", row: []any{int64(1), nil, nil}, absent: []int{2}, known: true, want: []any{int64(1), nil, "ann@example.com"}},
+		{name: "known without absent", row: []any{int64(1), nil, nil}, known: true, want: []any{int64(1), nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fillRow(tt.row, full, tt.absent, tt.known)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillRow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsMinimalImage(t *testing.T) {
+	table := rowCacheTestTable()
+
+	tests := []struct {
+		name   string
+		row    []any
+		absent []int
+		known  bool
+		want   bool
+	}{
+		{name: "unknown pk only", row: []any{int64(1), nil, nil}, want: true},
+		{name: "unknown full", row: []any{int64(1), "ann", nil}, want: false},
+		{name: "known absent", row: []any{int64(1), nil, nil}, absent: []int{1, 2}, known: true, want: true},
+		{name: "known all null", row: []any{int64(1), nil, nil}, known: true, want: false},
+		{name: "known partly absent", row: []any{int64(1), nil, nil}, absent: []int{2}, known: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMinimalImage(table, tt.row, tt.absent, tt.known); got != tt.want {
+				t.Errorf("isMinimalImage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillRowImagesExplicitNull(t *testing.T) {
+	m := newTestInput(t, WithFillMinimalImages(true, 16))
+	m.columnBitmaps = true
+	table := rowCacheTestTable()
+
+	insert := &canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(1), "ann", "ann@example.com"}},
+		Header: &replication.EventHeader{LogPos: 100},
+	}
+	m.fillRowImages(insert, m.takeAbsentColumns(insert))
+
+	// A MINIMAL update setting name to NULL: the before image holds the key
+	// and the after image the key and name.
+	update := &canal.RowsEvent{
+		Table:  table,
+		Action: canal.UpdateAction,
+		Rows: [][]any{
+			{int64(1), absentColumn{}, absentColumn{}},
+			{int64(1), nil, absentColumn{}},
+		},
+		Header: &replication.EventHeader{LogPos: 200},
+	}
+	m.fillRowImages(update, m.takeAbsentColumns(update))
+
+	wantBefore := []any{int64(1), "ann", "ann@example.com"}
+	wantAfter := []any{int64(1), nil, "ann@example.com"}
+	if !reflect.DeepEqual(update.Rows[0], wantBefore) {
+		t.Errorf("before image = %v, want %v", update.Rows[0], wantBefore)
+	}
+	if !reflect.DeepEqual(update.Rows[1], wantAfter) {
+		t.Errorf("after image = %v, want %v", update.Rows[1], wantAfter)
+	}
+
+	// The NULL is cached, so a later minimal delete is completed with it.
+	del := &canal.RowsEvent{
+		Table:  table,
+		Action: canal.DeleteAction,
+		Rows:   [][]any{{int64(1), absentColumn{}, absentColumn{}}},
+		Header: &replication.EventHeader{LogPos: 300},
+	}
+	fromCache := m.fillRowImages(del, m.takeAbsentColumns(del))
+	if !fromCache[0] {
+		t.Error("delete not filled from cache")
+	}
+	if !reflect.DeepEqual(del.Rows[0], wantAfter) {
+		t.Errorf("deleted row = %v, want %v", del.Rows[0], wantAfter)
+	}
+}
+
+// TestMarkAbsentColumnsLayout guards the unexported canal and parser fields
+// markAbsentColumns reaches into against go-mysql upgrades.
+func TestMarkAbsentColumnsLayout(t *testing.T) {
+	syncer, ok := reflect.TypeOf(canal.Canal{}).FieldByName("syncer")
+	if !ok || syncer.Type != reflect.TypeOf((*replication.BinlogSyncer)(nil)) {
+		t.Fatal("canal.Canal has no syncer *replication.BinlogSyncer field")
+	}
+	parser, ok := reflect.TypeOf(replication.BinlogSyncer{}).FieldByName("parser")
+	if !ok || parser.Type != reflect.TypeOf((*replication.BinlogParser)(nil)) {
+		t.Fatal("replication.BinlogSyncer has no parser *replication.BinlogParser field")
+	}
+	decode, ok := reflect.TypeOf(replication.BinlogParser{}).FieldByName("rowsEventDecodeFunc")
+	if !ok || decode.Type != reflect.TypeOf((func(*replication.RowsEvent, []byte) error)(nil)) {
+		t.Fatal("replication.BinlogParser has no rowsEventDecodeFunc field")
+	}
+
+	if markAbsentColumns(&canal.Canal{}) {
+		t.Error("markAbsentColumns succeeded on a canal without a syncer")
+	}
+}
+
+func TestUsesColumnBitmaps(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want bool
+	}{
+		{name: "default"},
+		{name: "fill_minimal_images", opts: []Option{WithFillMinimalImages(true, 100)}, want: true},
+		{name: "delete_minimal_behavior cache", opts: []Option{WithDeleteMinimalBehavior(deleteMinimalCache)}, want: true},
+		{name: "delete_minimal_behavior pk_only", opts: []Option{WithDeleteMinimalBehavior(deleteMinimalPKOnly)}, want: true},
+		{name: "split_pk_change", opts: []Option{WithSplitPKChange(true)}, want: true},
+		{name: "thin_tables", opts: []Option{WithDatabase("shop"), WithThinTables([]string{"orders"})}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestInput(t, tt.opts...).usesColumnBitmaps(); got != tt.want {
+				t.Errorf("usesColumnBitmaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteMinimalBehavior(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior string
+		row      []any
+		want     map[string]any
+		minimal  bool
+	}{
+		{name: "flag", behavior: deleteMinimalFlag, row: []any{int64(1), nil, nil}, want: map[string]any{"id": int64(1), "name": nil, "email": nil}, minimal: true},
+		{name: "pk only", behavior: deleteMinimalPKOnly, row: []any{int64(1), nil, nil}, want: map[string]any{"id": int64(1)}, minimal: true},
+		{name: "full image", behavior: deleteMinimalPKOnly, row: []any{int64(1), "ann", nil}, want: map[string]any{"id": int64(1), "name": "ann", "email": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithDeleteMinimalBehavior(tt.behavior), WithStructuredMessages(true))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  rowCacheTestTable(),
+				Action: canal.DeleteAction,
+				Rows:   [][]any{tt.row},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, body := readStructured(t, m)
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+			if v, _ := msg.MetaGet("minimal_image"); (v == "true") != tt.minimal {
+				t.Errorf("minimal_image = %q, want %v", v, tt.minimal)
+			}
+		})
+	}
+}
+
+func TestDeleteMinimalBehaviorCache(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithDeleteMinimalBehavior(deleteMinimalCache), WithFillMinimalImages(false, 16), WithStructuredMessages(true))
+	table := rowCacheTestTable()
+	rows := func(action string, row []any) {
+		t.Helper()
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: action,
+			Rows:   [][]any{row},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows(canal.InsertAction, []any{int64(1), "ann", "ann@example.com"})
+	readStructured(t, m)
+
+	tests := []struct {
+		name      string
+		row       []any
+		want      map[string]any
+		fromCache bool
+	}{
+		{name: "cached row", row: []any{int64(1), nil, nil}, want: map[string]any{"id": int64(1), "name": "ann", "email": "ann@example.com"}, fromCache: true},
+		{name: "uncached row", row: []any{int64(2), nil, nil}, want: map[string]any{"id": int64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows(canal.DeleteAction, tt.row)
+			msg, body := readStructured(t, m)
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+			if v, _ := msg.MetaGet("from_cache"); (v == "true") != tt.fromCache {
+				t.Errorf("from_cache = %q, want %v", v, tt.fromCache)
+			}
+		})
+	}
+}
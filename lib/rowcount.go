@@ -0,0 +1,101 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+const (
+	rowCountEvent = "rowcount"
+
+	rowCountExact       = "exact"
+	rowCountApproximate = "approximate"
+)
+
+// emitRowCounts periodically counts the rows of every streamed table over a
+// pooled control connection and pushes a rowcount event per table through
+// the stream under the on_buffer_full policy, for downstream to compare
+// against its own counts.
+func (m *MysqlStreamInput) emitRowCounts(ctx context.Context, c *canal.Canal) {
+	ticker := time.NewTicker(m.rowCountInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var counts []StreamMessage
+		err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+			refs, err := m.snapshotTables(conn)
+			if err != nil {
+				return err
+			}
+			for _, ref := range refs {
+				count, err := m.rowCount(conn, ref)
+				if err != nil {
+					m.logger.Warnf("Failed to count the rows of %s: %v", ref.key(), err)
+					continue
+				}
+				pos := m.handledPosition()
+				counts = append(counts, StreamMessage{
+					Table:    ref.name,
+					Event:    rowCountEvent,
+					database: ref.schema,
+					Data: map[string]any{
+						"database":    ref.schema,
+						"table":       ref.name,
+						"count":       count,
+						"approximate": m.rowCountMode == rowCountApproximate,
+						"binlog_file": pos.Name,
+						"binlog_pos":  pos.Pos,
+						"timestamp":   time.Now().Unix(),
+					},
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			m.logger.Warnf("Failed to count table rows: %v", err)
+		}
+
+		for _, msg := range counts {
+			err := m.sendUnlocked(msg)
+			if errors.Is(err, service.ErrEndOfInput) {
+				return
+			}
+			if err != nil {
+				m.logger.Warnf("Failed to send the row count of %s.%s: %v", msg.database, msg.Table, err)
+			}
+		}
+	}
+}
+
+// rowCount returns the number of rows of a table, counted exactly or read
+// from the estimate of information_schema per row_count_mode.
+func (m *MysqlStreamInput) rowCount(conn *client.Conn, ref tableRef) (int64, error) {
+	if m.rowCountMode == rowCountApproximate {
+		res, err := conn.Execute("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", ref.schema, ref.name)
+		if err != nil {
+			return 0, err
+		}
+		if res.RowNumber() == 0 {
+			return 0, fmt.Errorf("table %s does not exist", ref.key())
+		}
+		return res.GetInt(0, 0)
+	}
+
+	res, err := conn.Execute(fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", quoteIdentifier(ref.schema), quoteIdentifier(ref.name)))
+	if err != nil {
+		return 0, err
+	}
+	return res.GetInt(0, 0)
+}
@@ -0,0 +1,38 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestRowCountsPrepared(t *testing.T) {
+	count, err := mysql.BuildSimpleTextResultset([]string{"COUNT(*)"}, [][]any{{int64(42)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSchemaServer(t, schemaServer{results: map[string]*mysql.Resultset{
+		"SELECT COUNT(*) FROM `shop`.`orders`": count,
+	}})
+	m := newTestInput(t, WithAddr(addr), WithUser("root"), WithDatabase("shop"), WithTables("orders"), WithRowCounts(time.Millisecond, rowCountExact))
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 300}
+	m.syncedPosition = pos
+	m.trackSyncedPosition(pos)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.emitRowCounts(ctx, nil)
+
+	// The count goes through the buffer like a change, resuming from the
+	// synced position once acked.
+	msg := <-m.stream
+	if msg.Event != rowCountEvent || fmt.Sprint(msg.Data["count"]) != "42" {
+		t.Fatalf("sent %s event with %v, want rowcount with count 42", msg.Event, msg.Data)
+	}
+	if msg.resume != pos || msg.sourceHost != addr {
+		t.Errorf("count resumes from %v from %q, want %v from %s", msg.resume, msg.sourceHost, pos, addr)
+	}
+}
@@ -0,0 +1,521 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// rowFilter is a parsed row_filters expression, which is both rendered into
+// the WHERE clause of the snapshot query and evaluated against the rows of
+// streamed changes. It supports comparisons of a column with a number or
+// string literal, IN lists, IS [NOT] NULL and their combination with AND, OR,
+// NOT and parentheses.
+type rowFilter interface {
+	sql() string
+	eval(table *schema.Table, row []any) (truth, error)
+}
+
+// truth is a value of SQL's three valued logic, under which comparisons with
+// NULL are unknown.
+type truth int8
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+type filterAnd struct{ left, right rowFilter }
+
+func (f filterAnd) sql() string { return "(" + f.left.sql() + " AND " + f.right.sql() + ")" }
+
+func (f filterAnd) eval(table *schema.Table, row []any) (truth, error) {
+	l, err := f.left.eval(table, row)
+	if err != nil || l == truthFalse {
+		return l, err
+	}
+	r, err := f.right.eval(table, row)
+	if err != nil || r == truthFalse {
+		return r, err
+	}
+	if l == truthUnknown || r == truthUnknown {
+		return truthUnknown, nil
+	}
+	return truthTrue, nil
+}
+
+type filterOr struct{ left, right rowFilter }
+
+func (f filterOr) sql() string { return "(" + f.left.sql() + " OR " + f.right.sql() + ")" }
+
+func (f filterOr) eval(table *schema.Table, row []any) (truth, error) {
+	l, err := f.left.eval(table, row)
+	if err != nil || l == truthTrue {
+		return l, err
+	}
+	r, err := f.right.eval(table, row)
+	if err != nil || r == truthTrue {
+		return r, err
+	}
+	if l == truthUnknown || r == truthUnknown {
+		return truthUnknown, nil
+	}
+	return truthFalse, nil
+}
+
+type filterNot struct{ inner rowFilter }
+
+func (f filterNot) sql() string { return "(NOT " + f.inner.sql() + ")" }
+
+func (f filterNot) eval(table *schema.Table, row []any) (truth, error) {
+	t, err := f.inner.eval(table, row)
+	switch t {
+	case truthTrue:
+		return truthFalse, err
+	case truthFalse:
+		return truthTrue, err
+	}
+	return t, err
+}
+
+// filterLiteral is a number or string literal of a filter expression.
+type filterLiteral struct {
+	text    string
+	number  float64
+	numeric bool
+}
+
+func (l filterLiteral) sql() string {
+	if l.numeric {
+		return l.text
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(l.text) + "'"
+}
+
+// compare compares a column value with the literal, numerically when the
+// literal is a number and as strings otherwise, returning false when a
+// value cannot be compared with a number.
+func (l filterLiteral) compare(v any) (int, bool) {
+	s := filterString(v)
+	if !l.numeric {
+		return strings.Compare(s, l.text), true
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case n < l.number:
+		return -1, true
+	case n > l.number:
+		return 1, true
+	}
+	return 0, true
+}
+
+// filterString returns the text a column value is compared as, with
+// temporal values in MySQL's own format.
+func filterString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(mysqlDateTimeLayout)
+	}
+	return fmt.Sprint(v)
+}
+
+type filterCompare struct {
+	column  string
+	op      string
+	literal filterLiteral
+}
+
+func (f filterCompare) sql() string {
+	return quoteIdentifier(f.column) + " " + f.op + " " + f.literal.sql()
+}
+
+func (f filterCompare) eval(table *schema.Table, row []any) (truth, error) {
+	v, err := filterValue(table, row, f.column)
+	if err != nil || v == nil {
+		return truthUnknown, err
+	}
+	c, ok := f.literal.compare(v)
+	if !ok {
+		return truthFalse, nil
+	}
+	var match bool
+	switch f.op {
+	case "=":
+		match = c == 0
+	case "!=", "<>":
+		match = c != 0
+	case "<":
+		match = c < 0
+	case "<=":
+		match = c <= 0
+	case ">":
+		match = c > 0
+	case ">=":
+		match = c >= 0
+	}
+	if match {
+		return truthTrue, nil
+	}
+	return truthFalse, nil
+}
+
+type filterIn struct {
+	column   string
+	literals []filterLiteral
+}
+
+func (f filterIn) sql() string {
+	values := make([]string, len(f.literals))
+	for i, l := range f.literals {
+		values[i] = l.sql()
+	}
+	return quoteIdentifier(f.column) + " IN (" + strings.Join(values, ", ") + ")"
+}
+
+func (f filterIn) eval(table *schema.Table, row []any) (truth, error) {
+	v, err := filterValue(table, row, f.column)
+	if err != nil || v == nil {
+		return truthUnknown, err
+	}
+	for _, l := range f.literals {
+		if c, ok := l.compare(v); ok && c == 0 {
+			return truthTrue, nil
+		}
+	}
+	return truthFalse, nil
+}
+
+type filterIsNull struct {
+	column string
+	not    bool
+}
+
+func (f filterIsNull) sql() string {
+	if f.not {
+		return quoteIdentifier(f.column) + " IS NOT NULL"
+	}
+	return quoteIdentifier(f.column) + " IS NULL"
+}
+
+func (f filterIsNull) eval(table *schema.Table, row []any) (truth, error) {
+	v, err := filterValue(table, row, f.column)
+	if err != nil {
+		return truthUnknown, err
+	}
+	if (v == nil) != f.not {
+		return truthTrue, nil
+	}
+	return truthFalse, nil
+}
+
+func filterValue(table *schema.Table, row []any, column string) (any, error) {
+	i := table.FindColumn(column)
+	if i < 0 || i >= len(row) {
+		return nil, fmt.Errorf("row_filters: table %s has no column %s", table, column)
+	}
+	return row[i], nil
+}
+
+// filterMatches reports whether the rows of a streamed change pass the row
+// filter of its table. Inserts and deletes pass when their row matches, and
+// updates when either image does, so that a row entering or leaving the
+// filtered set is streamed.
+func (m *MysqlStreamInput) filterMatches(filter rowFilter, e *canal.RowsEvent, i int) (bool, error) {
+	t, err := filter.eval(e.Table, e.Rows[i])
+	if err != nil || t == truthTrue || e.Action != canal.UpdateAction {
+		return t == truthTrue, err
+	}
+	t, err = filter.eval(e.Table, e.Rows[i-1])
+	return t == truthTrue, err
+}
+
+// parseRowFilter parses a row_filters expression.
+func parseRowFilter(expr string) (rowFilter, error) {
+	tokens, err := lexRowFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type filterTokenKind int
+
+const (
+	tokenWord filterTokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+func lexRowFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	r := []rune(expr)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, filterToken{tokenSymbol, string(c)})
+			i++
+		case c == '=':
+			tokens = append(tokens, filterToken{tokenSymbol, "="})
+			i++
+		case c == '<' || c == '>' || c == '!':
+			op := string(c)
+			if i+1 < len(r) && (r[i+1] == '=' || (c == '<' && r[i+1] == '>')) {
+				op += string(r[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected ! at offset %d", i)
+			}
+			tokens = append(tokens, filterToken{tokenSymbol, op})
+			i += len(op)
+		case c == '\'' || c == '`':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(r); j++ {
+				if r[j] == c {
+					if j+1 < len(r) && r[j+1] == c {
+						sb.WriteRune(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(r[j])
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("unterminated %c at offset %d", c, i)
+			}
+			kind := tokenString
+			if c == '`' {
+				kind = tokenIdent
+			}
+			tokens = append(tokens, filterToken{kind, sb.String()})
+			i = j + 1
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(r) && (r[j] == '.' || unicode.IsDigit(r[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokenNumber, string(r[i:j])})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(r) && (r[j] == '_' || r[j] == '$' || unicode.IsLetter(r[j]) || unicode.IsDigit(r[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{tokenWord, string(r[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %c at offset %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// keyword consumes the next token if it is the given keyword.
+func (p *filterParser) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is the given symbol.
+func (p *filterParser) symbol(s string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokenSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (rowFilter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (rowFilter, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) not() (rowFilter, error) {
+	if p.keyword("NOT") {
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{inner}, nil
+	}
+	if p.symbol("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return f, nil
+	}
+	return p.predicate()
+}
+
+func (p *filterParser) predicate() (rowFilter, error) {
+	t, ok := p.peek()
+	if !ok || (t.kind != tokenWord && t.kind != tokenIdent) || isFilterKeyword(t) {
+		return nil, fmt.Errorf("expected a column name")
+	}
+	p.pos++
+	column := t.text
+
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, fmt.Errorf("expected NULL after IS")
+		}
+		return filterIsNull{column: column, not: not}, nil
+	}
+
+	not := p.keyword("NOT")
+	if p.keyword("IN") {
+		if !p.symbol("(") {
+			return nil, fmt.Errorf("expected ( after IN")
+		}
+		var literals []filterLiteral
+		for {
+			l, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			literals = append(literals, l)
+			if p.symbol(")") {
+				break
+			}
+			if !p.symbol(",") {
+				return nil, fmt.Errorf("expected , or ) in IN list")
+			}
+		}
+		var f rowFilter = filterIn{column: column, literals: literals}
+		if not {
+			f = filterNot{f}
+		}
+		return f, nil
+	}
+	if not {
+		return nil, fmt.Errorf("expected IN after NOT")
+	}
+
+	op, ok := p.peek()
+	if !ok || op.kind != tokenSymbol || op.text == "(" || op.text == ")" || op.text == "," {
+		return nil, fmt.Errorf("expected a comparison after %s", column)
+	}
+	p.pos++
+	l, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	return filterCompare{column: column, op: op.text, literal: l}, nil
+}
+
+func (p *filterParser) literal() (filterLiteral, error) {
+	t, ok := p.peek()
+	if !ok {
+		return filterLiteral{}, fmt.Errorf("expected a value")
+	}
+	switch {
+	case t.kind == tokenString:
+		p.pos++
+		return filterLiteral{text: t.text}, nil
+	case t.kind == tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return filterLiteral{}, fmt.Errorf("invalid number %s", t.text)
+		}
+		p.pos++
+		return filterLiteral{text: t.text, number: n, numeric: true}, nil
+	case t.kind == tokenWord && (strings.EqualFold(t.text, "TRUE") || strings.EqualFold(t.text, "FALSE")):
+		p.pos++
+		if strings.EqualFold(t.text, "TRUE") {
+			return filterLiteral{text: "1", number: 1, numeric: true}, nil
+		}
+		return filterLiteral{text: "0", numeric: true}, nil
+	}
+	return filterLiteral{}, fmt.Errorf("expected a number or quoted string, got %q", t.text)
+}
+
+func isFilterKeyword(t filterToken) bool {
+	if t.kind != tokenWord {
+		return false
+	}
+	switch strings.ToUpper(t.text) {
+	case "AND", "OR", "NOT", "IS", "IN", "NULL", "TRUE", "FALSE":
+		return true
+	}
+	return false
+}
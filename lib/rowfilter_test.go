@@ -0,0 +1,102 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func rowFilterTestTable() *schema.Table {
+	return &schema.Table{
+		Schema: "shop",
+		Name:   "orders",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "region", Type: schema.TYPE_STRING},
+			{Name: "total", Type: schema.TYPE_DECIMAL},
+		},
+		PKColumns: []int{0},
+	}
+}
+
+func TestParseRowFilter(t *testing.T) {
+	table := rowFilterTestTable()
+
+	tests := []struct {
+		expr    string
+		wantSQL string
+		row     []any
+		want    truth
+	}{
+		{expr: "region = 'eu'", wantSQL: "`region` = 'eu'", row: []any{int64(1), "eu", "10.00"}, want: truthTrue},
+		{expr: "total >= 100", wantSQL: "`total` >= 100", row: []any{int64(1), "eu", "99.99"}, want: truthFalse},
+		{expr: "region = 'eu'", wantSQL: "`region` = 'eu'", row: []any{int64(1), nil, "10.00"}, want: truthUnknown},
+		{expr: "NOT region = 'eu'", wantSQL: "(NOT `region` = 'eu')", row: []any{int64(1), nil, "10.00"}, want: truthUnknown},
+		{expr: "region IS NULL OR region = 'eu'", wantSQL: "(`region` IS NULL OR `region` = 'eu')", row: []any{int64(1), nil, "10.00"}, want: truthTrue},
+		{expr: "region NOT IN ('eu', 'us')", wantSQL: "(NOT `region` IN ('eu', 'us'))", row: []any{int64(1), "apac", "10.00"}, want: truthTrue},
+		{expr: "(id > 10 AND total < 5) OR region != 'eu'", wantSQL: "((`id` > 10 AND `total` < 5) OR `region` != 'eu')", row: []any{int64(11), "eu", "1.5"}, want: truthTrue},
+		{expr: "region = 'o''neil'", wantSQL: "`region` = 'o''neil'", row: []any{int64(1), "o'neil", "0"}, want: truthTrue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := parseRowFilter(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.sql(); got != tt.wantSQL {
+				t.Errorf("sql = %s, want %s", got, tt.wantSQL)
+			}
+			got, err := f.eval(table, tt.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("eval(%v) = %v, want %v", tt.row, got, tt.want)
+			}
+		})
+	}
+
+	for _, expr := range []string{"", "region =", "region = eu", "region IN ('eu'", "region NOT = 'eu'", "region = 'eu' AND", "region IS 'eu'"} {
+		t.Run("invalid "+expr, func(t *testing.T) {
+			if _, err := parseRowFilter(expr); err == nil {
+				t.Errorf("parseRowFilter(%q) succeeded", expr)
+			}
+		})
+	}
+}
+
+func TestRowFilterOnStream(t *testing.T) {
+	table := rowFilterTestTable()
+	tests := []struct {
+		name   string
+		action string
+		rows   [][]any
+		want   bool
+	}{
+		{name: "matching insert", action: canal.InsertAction, rows: [][]any{{int64(1), "eu", "1"}}, want: true},
+		{name: "other insert", action: canal.InsertAction, rows: [][]any{{int64(1), "us", "1"}}},
+		{name: "update into the filter", action: canal.UpdateAction, rows: [][]any{{int64(1), "us", "1"}, {int64(1), "eu", "1"}}, want: true},
+		{name: "update out of the filter", action: canal.UpdateAction, rows: [][]any{{int64(1), "eu", "1"}, {int64(1), "us", "1"}}, want: true},
+		{name: "update outside the filter", action: canal.UpdateAction, rows: [][]any{{int64(1), "us", "1"}, {int64(1), "us", "2"}}},
+		{name: "null delete", action: canal.DeleteAction, rows: [][]any{{int64(1), nil, "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithRowFilters(map[string]string{"orders": "region = 'eu'"}))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: tt.action,
+				Rows:   tt.rows,
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if streamed := len(m.stream) == 1; streamed != tt.want {
+				t.Errorf("streamed = %v, want %v", streamed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// errSnapshotOutsideSchedule stops a table read once the current window of
+// snapshot_schedule closes.
+var errSnapshotOutsideSchedule = errors.New("snapshot_schedule window closed")
+
+// snapshotWindow is a daily time window of snapshot_schedule, in minutes since
+// midnight. A window whose end is before its start runs past midnight.
+type snapshotWindow struct {
+	start, end int
+}
+
+// snapshotSchedule is a parsed snapshot_schedule, with its windows read as
+// wall clock times in location.
+type snapshotSchedule struct {
+	windows  []snapshotWindow
+	location *time.Location
+}
+
+// parseSnapshotSchedule parses windows such as 22:00-06:00 in the IANA time
+// zone timezone.
+func parseSnapshotSchedule(windows []string, timezone string) (*snapshotSchedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot_schedule_timezone: %w", err)
+	}
+	s := &snapshotSchedule{location: location}
+	for _, window := range windows {
+		from, to, ok := strings.Cut(window, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid snapshot_schedule window %q: expected HH:MM-HH:MM", window)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot_schedule window %q: %w", window, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot_schedule window %q: %w", window, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("invalid snapshot_schedule window %q: empty window", window)
+		}
+		s.windows = append(s.windows, snapshotWindow{start: start, end: end})
+	}
+	return s, nil
+}
+
+// parseClock parses a time of day such as 06:30 into minutes since midnight,
+// accepting 24:00 as the end of the day.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		if strings.TrimSpace(clock) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time of day %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// open reports whether t falls within one of the windows.
+func (s *snapshotSchedule) open(t time.Time) bool {
+	t = t.In(s.location)
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if minute >= w.start && minute < w.end {
+				return true
+			}
+		} else if minute >= w.start || minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpen returns when the next window after t starts, which is only used to
+// wait for it as open decides by the local time of day. A window starting in
+// an hour skipped by a daylight saving change starts an hour later.
+func (s *snapshotSchedule) nextOpen(t time.Time) time.Time {
+	t = t.In(s.location)
+	var next time.Time
+	for _, w := range s.windows {
+		for day := 0; day <= 1; day++ {
+			start := time.Date(t.Year(), t.Month(), t.Day()+day, w.start/60, w.start%60, 0, 0, s.location)
+			if start.After(t) {
+				if next.IsZero() || start.Before(next) {
+					next = start
+				}
+				break
+			}
+		}
+	}
+	return next
+}
+
+// scheduleSnapshot reports whether the snapshot being read is paused outside
+// snapshot_schedule, which applies to the initial snapshot only, the one
+// tracked by snapshot_resume.
+func (m *MysqlStreamInput) scheduleSnapshot() bool {
+	return m.snapshotSchedule != nil && m.progress != nil
+}
+
+// awaitSnapshotWindow blocks until a window of snapshot_schedule is open, and
+// returns how long it waited.
+func (m *MysqlStreamInput) awaitSnapshotWindow() (time.Duration, error) {
+	if !m.scheduleSnapshot() || m.snapshotSchedule.open(time.Now()) {
+		return 0, nil
+	}
+
+	started := time.Now()
+	next := m.snapshotSchedule.nextOpen(started)
+	m.logger.Infof("Pausing the snapshot outside snapshot_schedule until %s", next.Format(time.RFC3339))
+	m.metrics.snapshotPaused.Set(1)
+	defer m.metrics.snapshotPaused.Set(0)
+	for !m.snapshotSchedule.open(time.Now()) {
+		// Wake up at least once a minute, so that a change of the system clock
+		// does not delay the snapshot for long.
+		wait := time.Until(m.snapshotSchedule.nextOpen(time.Now()))
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+		select {
+		case <-time.After(wait):
+		case <-m.closed:
+			return 0, errors.New("input closed while the snapshot was paused outside snapshot_schedule")
+		}
+	}
+	paused := time.Since(started)
+	m.logger.Infof("Resuming the snapshot after pausing for %v outside snapshot_schedule", paused.Round(time.Second))
+	return paused, nil
+}
+
+// pauseSnapshot releases the snapshot connection, and with it the snapshot
+// transaction or locks, until the next window of snapshot_schedule opens, then
+// isolates the snapshot again on a new connection. Time spent paused does not
+// count toward snapshot_timeout.
+func (m *MysqlStreamInput) pauseSnapshot(conn **client.Conn, refs []tableRef) error {
+	(*conn).Close()
+	*conn = nil
+	m.snapshotExact = false
+	paused, err := m.awaitSnapshotWindow()
+	if err != nil {
+		return err
+	}
+	if !m.snapshotDeadline.IsZero() {
+		m.snapshotDeadline = m.snapshotDeadline.Add(paused)
+	}
+	if *conn, err = m.controlConn(); err != nil {
+		return err
+	}
+	return m.beginSnapshot(*conn, refs, nil)
+}
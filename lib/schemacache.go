@@ -0,0 +1,216 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// schemaCacheTimeout bounds every read and write of schema_cache, which is
+// accessed while the stream is blocked.
+const schemaCacheTimeout = 5 * time.Second
+
+// cachedSchema is a table schema stored in schema_cache, along with the
+// fingerprint of the column layout it was resolved from.
+type cachedSchema struct {
+	Fingerprint string        `json:"fingerprint"`
+	Table       *schema.Table `json:"table"`
+}
+
+// loadCachedSchemas seeds the table cache of a new canal with the schemas of
+// the configured tables stored in schema_cache, so that they are not resolved
+// again with two queries per table. The column layouts of all tables are read
+// with a single query and a cached schema is only used while its fingerprint
+// still matches, so schemas changed while the input was down are resolved
+// again. The schemas of the configured tables are then written back.
+func (m *MysqlStreamInput) loadCachedSchemas(c *canal.Canal) {
+	if m.schemaCache == "" || len(m.tableRefs) == 0 {
+		return
+	}
+
+	if m.cachedSchemas == nil {
+		cached, err := m.readSchemaCache()
+		if err != nil {
+			m.logger.Warnf("Failed to read schema_cache, resolving table schemas from the server: %v", err)
+		}
+		m.cachedSchemas = cached
+	}
+	fingerprints, err := m.liveSchemaFingerprints(c)
+	if err != nil {
+		m.logger.Warnf("Failed to check the schemas in schema_cache, resolving table schemas from the server: %v", err)
+		return
+	}
+
+	loaded := 0
+	for _, ref := range m.tableRefs {
+		cached, ok := m.cachedSchemas[ref.key()]
+		if !ok || cached.Table == nil || cached.Fingerprint != fingerprints[ref.key()] {
+			continue
+		}
+		c.SetTableCache([]byte(ref.schema), []byte(ref.name), cached.Table)
+		loaded++
+	}
+	if loaded > 0 {
+		m.logger.Debugf("Loaded %d of %d table schemas from schema_cache", loaded, len(m.tableRefs))
+	}
+	if loaded == len(m.tableRefs) {
+		return
+	}
+
+	for _, ref := range m.tableRefs {
+		if table, err := c.GetTable(ref.schema, ref.name); err == nil {
+			m.cacheSchema(table)
+		}
+	}
+	m.writeSchemaCache()
+}
+
+// refreshCachedSchema updates the schema of a table in schema_cache after a
+// DDL statement changed it.
+func (m *MysqlStreamInput) refreshCachedSchema(table *schema.Table) {
+	if m.schemaCache == "" || !m.isTableStreamed(table.Schema, table.Name) {
+		return
+	}
+	m.cacheSchema(table)
+	m.writeSchemaCache()
+}
+
+// cacheSchema records the schema of a table for schema_cache. Placeholders of
+// tables whose schema could not be resolved are never cached.
+func (m *MysqlStreamInput) cacheSchema(table *schema.Table) {
+	if len(table.Columns) == 0 {
+		return
+	}
+	if m.cachedSchemas == nil {
+		m.cachedSchemas = map[string]cachedSchema{}
+	}
+	m.cachedSchemas[tableRef{schema: table.Schema, name: table.Name}.key()] = cachedSchema{
+		Fingerprint: tableLayoutFingerprint(table),
+		Table:       table,
+	}
+}
+
+func (m *MysqlStreamInput) readSchemaCache() (map[string]cachedSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCacheTimeout)
+	defer cancel()
+
+	var value []byte
+	var cacheErr error
+	if err := m.resources.AccessCache(ctx, m.schemaCache, func(c service.Cache) {
+		value, cacheErr = c.Get(ctx, m.schemaCacheKey)
+	}); err != nil {
+		return nil, err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if cacheErr != nil {
+		return nil, cacheErr
+	}
+
+	var cached map[string]cachedSchema
+	if err := json.Unmarshal(value, &cached); err != nil {
+		return nil, err
+	}
+	return cached, nil
+}
+
+// writeSchemaCache stores the recorded schemas in schema_cache. A failed write
+// only costs resolving the schemas again on the next connect.
+func (m *MysqlStreamInput) writeSchemaCache() {
+	value, err := json.Marshal(m.cachedSchemas)
+	if err != nil {
+		m.logger.Warnf("Failed to encode table schemas for schema_cache: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCacheTimeout)
+	defer cancel()
+
+	var cacheErr error
+	if err := m.resources.AccessCache(ctx, m.schemaCache, func(c service.Cache) {
+		cacheErr = c.Set(ctx, m.schemaCacheKey, value, nil)
+	}); err != nil {
+		cacheErr = err
+	}
+	if cacheErr != nil {
+		m.logger.Warnf("Failed to write table schemas to schema_cache: %v", cacheErr)
+	}
+}
+
+// liveSchemaFingerprints reads the column layouts of the configured tables
+// from information_schema and returns their fingerprints keyed by table.
+func (m *MysqlStreamInput) liveSchemaFingerprints(c *canal.Canal) (map[string]string, error) {
+	conditions := make([]string, 0, len(m.tableRefs))
+	args := make([]any, 0, 2*len(m.tableRefs))
+	for _, ref := range m.tableRefs {
+		conditions = append(conditions, "(TABLE_SCHEMA = ? AND TABLE_NAME = ?)")
+		args = append(args, ref.schema, ref.name)
+	}
+	res, err := c.Execute(`SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY FROM information_schema.COLUMNS
+		WHERE `+strings.Join(conditions, " OR ")+` ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := map[string][]string{}
+	for i := 0; i < res.RowNumber(); i++ {
+		db, _ := res.GetString(i, 0)
+		table, _ := res.GetString(i, 1)
+		column, _ := res.GetString(i, 2)
+		columnType, _ := res.GetString(i, 3)
+		columnKey, _ := res.GetString(i, 4)
+		key := tableRef{schema: db, name: table}.key()
+		layouts[key] = append(layouts[key], column, columnType, columnKey)
+	}
+
+	fingerprints := make(map[string]string, len(layouts))
+	for key, layout := range layouts {
+		fingerprints[key] = layoutFingerprint(layout)
+	}
+	return fingerprints, nil
+}
+
+// tableLayoutFingerprint returns the fingerprint of a resolved table in the
+// form liveSchemaFingerprints computes it.
+func tableLayoutFingerprint(table *schema.Table) string {
+	pk := make(map[int]bool, len(table.PKColumns))
+	for _, i := range table.PKColumns {
+		pk[i] = true
+	}
+	layout := make([]string, 0, 3*len(table.Columns))
+	for i, col := range table.Columns {
+		columnKey := ""
+		if pk[i] {
+			columnKey = "PRI"
+		}
+		layout = append(layout, col.Name, col.RawType, columnKey)
+	}
+	return layoutFingerprint(layout)
+}
+
+// layoutFingerprint hashes the name, type and primary key membership of every
+// column in order. Other keys than the primary key do not change how rows are
+// decoded and are ignored.
+func layoutFingerprint(layout []string) string {
+	h := sha256.New()
+	for i, field := range layout {
+		if i%3 == 1 {
+			field = strings.ToLower(field)
+		}
+		if i%3 == 2 && field != "PRI" {
+			field = ""
+		}
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,76 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestTableLayoutFingerprint(t *testing.T) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "orders",
+		Columns: []schema.TableColumn{
+			{Name: "id", RawType: "bigint(20) unsigned"},
+			{Name: "customer_id", RawType: "int(11)"},
+		},
+		PKColumns: []int{0},
+	}
+	resolved := tableLayoutFingerprint(table)
+
+	tests := []struct {
+		name      string
+		layout    []string
+		wantMatch bool
+	}{
+		{name: "same layout", layout: []string{"id", "bigint(20) unsigned", "PRI", "customer_id", "int(11)", ""}, wantMatch: true},
+		{name: "secondary key", layout: []string{"id", "bigint(20) unsigned", "PRI", "customer_id", "int(11)", "MUL"}, wantMatch: true},
+		{name: "type case", layout: []string{"id", "BIGINT(20) UNSIGNED", "PRI", "customer_id", "INT(11)", ""}, wantMatch: true},
+		{name: "column type changed", layout: []string{"id", "bigint(20) unsigned", "PRI", "customer_id", "bigint(20)", ""}},
+		{name: "primary key changed", layout: []string{"id", "bigint(20) unsigned", "PRI", "customer_id", "int(11)", "PRI"}},
+		{name: "column added", layout: []string{"id", "bigint(20) unsigned", "PRI", "customer_id", "int(11)", "", "note", "text", ""}},
+		{name: "columns reordered", layout: []string{"customer_id", "int(11)", "", "id", "bigint(20) unsigned", "PRI"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match := layoutFingerprint(tt.layout) == resolved; match != tt.wantMatch {
+				t.Errorf("fingerprint match = %v, want %v", match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestSchemaCacheRoundTrip(t *testing.T) {
+	mgr, _ := newTestResources(t)
+	opts := []Option{WithResources(mgr), WithDatabase("shop"), WithSchemaCache("cache", "schemas")}
+	orders := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int(11)"}, {Name: "status", Type: schema.TYPE_ENUM, RawType: "enum('new','paid')", EnumValues: []string{"new", "paid"}}},
+		PKColumns: []int{0},
+	}
+
+	m := newTestInput(t, opts...)
+	m.refreshCachedSchema(orders)
+	// Placeholders of unresolved schemas are not cached.
+	m.refreshCachedSchema(&schema.Table{Schema: "shop", Name: "users"})
+
+	restarted := newTestInput(t, opts...)
+	cached, err := restarted.readSchemaCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 1 {
+		t.Fatalf("cached %d schemas, want 1", len(cached))
+	}
+	got, ok := cached["shop.orders"]
+	if !ok || got.Table == nil {
+		t.Fatal("shop.orders not cached")
+	}
+	if got.Fingerprint != tableLayoutFingerprint(orders) {
+		t.Errorf("cached fingerprint %s, want %s", got.Fingerprint, tableLayoutFingerprint(orders))
+	}
+	if len(got.Table.Columns) != 2 || got.Table.Columns[1].EnumValues[1] != "paid" || len(got.Table.PKColumns) != 1 {
+		t.Errorf("cached table %+v, want the columns of shop.orders", got.Table)
+	}
+}
@@ -0,0 +1,98 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+func TestSemiSyncConfig(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		m := newTestInput(t, WithSemiSync(enabled))
+		if cfg := m.canalConfig(); cfg.SemiSyncEnabled != enabled {
+			t.Errorf("semi_sync %v gives canal SemiSyncEnabled %v", enabled, cfg.SemiSyncEnabled)
+		}
+	}
+}
+
+// TestSemiSyncServer registers as a semi-synchronous replica of the server at
+// MYSQL_SEMI_SYNC_ADDR, which must have the semi-sync source plugin enabled,
+// as user MYSQL_SEMI_SYNC_USER, root by default, with password
+// MYSQL_SEMI_SYNC_PASSWORD. It is skipped when MYSQL_SEMI_SYNC_ADDR is unset.
+func TestSemiSyncServer(t *testing.T) {
+	addr := os.Getenv("MYSQL_SEMI_SYNC_ADDR")
+	if addr == "" {
+		t.Skip("MYSQL_SEMI_SYNC_ADDR is not set")
+	}
+	user := os.Getenv("MYSQL_SEMI_SYNC_USER")
+	if user == "" {
+		user = "root"
+	}
+	password := os.Getenv("MYSQL_SEMI_SYNC_PASSWORD")
+
+	conn, err := client.Connect(addr, user, password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// MySQL 8.0.26 renamed the master status variables to source.
+	status := func(name string) int64 {
+		t.Helper()
+		res, err := conn.Execute("SHOW GLOBAL STATUS WHERE Variable_name IN ('Rpl_semi_sync_master_" + name + "', 'Rpl_semi_sync_source_" + name + "')")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.RowNumber() == 0 {
+			t.Fatal("server has no semi-sync source status, is the plugin enabled?")
+		}
+		v, err := res.GetIntByName(0, "Value")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, q := range []string{
+		"CREATE DATABASE IF NOT EXISTS semi_sync_test",
+		"CREATE TABLE IF NOT EXISTS semi_sync_test.events (id INT AUTO_INCREMENT PRIMARY KEY)",
+	} {
+		if _, err := conn.Execute(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := NewMysqlStreamInput(WithAddr(addr), WithUser(user), WithPassword(password), WithDatabase("semi_sync_test"),
+		WithMode(modeStreamOnly), WithSemiSync(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := m.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close(context.Background())
+
+	for status("clients") == 0 {
+		if ctx.Err() != nil {
+			t.Fatal("input did not register as a semi-sync replica")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// A commit acknowledged by the replica counts as a semi-sync
+	// transaction, one that timed out waiting for it does not.
+	acked := status("yes_tx")
+	if _, err := conn.Execute("INSERT INTO semi_sync_test.events () VALUES ()"); err != nil {
+		t.Fatal(err)
+	}
+	if got := status("yes_tx"); got != acked+1 {
+		t.Errorf("%d transactions acknowledged by semi-sync replicas, want %d", got-acked, 1)
+	}
+	msg, _ := readStructured(t, m)
+	if table, _ := msg.MetaGet("table"); table != "events" {
+		t.Errorf("message of table %q, want events", table)
+	}
+}
@@ -0,0 +1,41 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	serverIDConflictError          = "error"
+	serverIDConflictRandomizeRetry = "randomize_retry"
+
+	// defaultServerID is the server id the input registers as a replica with
+	// until a conflict makes it pick another.
+	defaultServerID = 124
+)
+
+// isServerIDConflict reports whether the binlog stream was stopped by the
+// server because another replica connected with the same server id, which
+// makes the server drop the dump thread of the first one.
+func isServerIDConflict(err error) bool {
+	var myErr *mysql.MyError
+	if !errors.As(err, &myErr) || myErr.Code != mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG {
+		return false
+	}
+	return strings.Contains(myErr.Message, "same server_uuid/server_id") || strings.Contains(myErr.Message, "same server_id")
+}
+
+// randomServerID returns a server id for on_server_id_conflict:
+// randomize_retry other than the one in use, drawn from a range well above
+// the small ids replicas are usually configured with.
+func randomServerID(current uint32) uint32 {
+	for {
+		id := 1<<16 + uint32(rand.Int63n(1<<32-1<<16))
+		if id != current {
+			return id
+		}
+	}
+}
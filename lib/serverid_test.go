@@ -0,0 +1,51 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestServerIDConflict(t *testing.T) {
+	// The error MySQL stops the dump thread of a replica with when another
+	// one connects with the same server id.
+	conflict := fmt.Errorf("sync binlog: %w", &mysql.MyError{
+		Code:    mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG,
+		State:   "HY000",
+		Message: "A slave with the same server_uuid/server_id as this slave has connected to the master; the first event 'mysql-bin.000001' at 4, the last event read from './mysql-bin.000001' at 1200, the last byte read from './mysql-bin.000001' at 1200.",
+	})
+	purged := &mysql.MyError{Code: mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, State: "HY000", Message: "Could not find first log file name in binary log index file"}
+
+	tests := []struct {
+		name         string
+		policy       string
+		err          error
+		wantErr      error
+		wantServerID bool
+	}{
+		{name: "error", policy: serverIDConflictError, err: conflict, wantErr: service.ErrEndOfInput},
+		{name: "randomize retry", policy: serverIDConflictRandomizeRetry, err: conflict, wantErr: service.ErrNotConnected, wantServerID: true},
+		{name: "other fatal error", policy: serverIDConflictRandomizeRetry, err: purged, wantErr: service.ErrNotConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithOnServerIDConflict(tt.policy))
+			m.readerErr <- tt.err
+
+			_, _, err := m.Read(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Read error = %v, want %v", err, tt.wantErr)
+			}
+			if changed := m.replicaServerID != defaultServerID; changed != tt.wantServerID {
+				t.Errorf("server id = %d, want it changed %v", m.replicaServerID, tt.wantServerID)
+			}
+			if m.replicaServerID < 1<<16 && tt.wantServerID {
+				t.Errorf("random server id %d below %d", m.replicaServerID, 1<<16)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	oversizedDrop     = "drop"
+	oversizedTruncate = "truncate"
+	oversizedError    = "error"
+)
+
+var errCannotTruncate = errors.New("no column values left to truncate")
+
+// truncate shortens the largest string and binary values of a message until
+// its encoding fits within max_message_bytes, returning the encoding and the
+// names of the columns that were cut.
+func (m *MysqlStreamInput) truncate(ctx context.Context, msg StreamMessage) ([]byte, []string, error) {
+	data := make(map[string]any, len(msg.Data))
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	msg.Data = data
+
+	var truncated []string
+	for {
+		body, err := m.encode(ctx, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		excess := len(body) - m.maxMessageBytes
+		if excess <= 0 {
+			return body, truncated, nil
+		}
+
+		column, length := "", 0
+		for k, v := range data {
+			if n := valueLength(v); n > length {
+				column, length = k, n
+			}
+		}
+		if length == 0 {
+			return nil, nil, errCannotTruncate
+		}
+
+		keep := length - excess
+		if keep < 0 {
+			keep = 0
+		}
+		// Strings are cut at a character boundary, so that their encoding
+		// does not grow with a replacement character.
+		data[column], _ = truncateValue(data[column], keep)
+		if len(truncated) == 0 || truncated[len(truncated)-1] != column {
+			truncated = append(truncated, column)
+		}
+	}
+}
+
+func valueLength(v any) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	return 0
+}
+
+// truncateColumns cuts the string and binary values of data longer than
+// max_column_bytes in place, along with the old and new values of changed,
+// returning the sorted names of the columns that were cut. Strings are cut at
+// a character boundary.
+func (m *MysqlStreamInput) truncateColumns(data map[string]any, changed map[string]FieldChange) []string {
+	if m.maxColumnBytes <= 0 {
+		return nil
+	}
+
+	var truncated []string
+	for column, v := range data {
+		if cut, ok := truncateValue(v, m.maxColumnBytes); ok {
+			data[column] = cut
+			truncated = append(truncated, column)
+		}
+	}
+	for column, change := range changed {
+		change.Old, _ = truncateValue(change.Old, m.maxColumnBytes)
+		change.New, _ = truncateValue(change.New, m.maxColumnBytes)
+		changed[column] = change
+	}
+	sort.Strings(truncated)
+	return truncated
+}
+
+// truncateValue cuts a string or binary value to max bytes, reporting whether
+// it was longer.
+func truncateValue(v any, max int) (any, bool) {
+	switch v := v.(type) {
+	case string:
+		if len(v) <= max {
+			return v, false
+		}
+		keep := max
+		for keep > 0 && !utf8.RuneStart(v[keep]) {
+			keep--
+		}
+		return v[:keep], true
+	case []byte:
+		if len(v) <= max {
+			return v, false
+		}
+		return v[:max], true
+	}
+	return v, false
+}
+
+// mergeTruncated returns the columns of both lists, without duplicates.
+func mergeTruncated(a, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+	merged := append([]string(nil), a...)
+	for _, column := range b {
+		found := false
+		for _, existing := range a {
+			if existing == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, column)
+		}
+	}
+	return merged
+}
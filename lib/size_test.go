@@ -0,0 +1,102 @@
+package mongodb_stream_benthos
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         any
+		max           int
+		want          any
+		wantTruncated bool
+	}{
+		{name: "short string", value: "paid", max: 8, want: "paid"},
+		{name: "exact string", value: "shipped", max: 7, want: "shipped"},
+		{name: "long string", value: "shipped", max: 4, want: "ship", wantTruncated: true},
+		// "é" is two bytes, which are not split.
+		{name: "multi byte rune", value: "café au lait", max: 4, want: "caf", wantTruncated: true},
+		{name: "rune boundary", value: "café", max: 5, want: "café"},
+		{name: "bytes", value: []byte{1, 2, 3, 4}, max: 2, want: []byte{1, 2}, wantTruncated: true},
+		{name: "short bytes", value: []byte{1, 2}, max: 2, want: []byte{1, 2}},
+		{name: "number", value: int64(123456789), max: 2, want: int64(123456789)},
+		{name: "null", value: nil, max: 2, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateValue(tt.value, tt.max)
+			if !reflect.DeepEqual(got, tt.want) || truncated != tt.wantTruncated {
+				t.Errorf("truncateValue = %v, %v, want %v, %v", got, truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestMergeTruncated(t *testing.T) {
+	got := mergeTruncated([]string{"note", "body"}, []string{"body", "title"})
+	if want := []string{"note", "body", "title"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeTruncated = %v, want %v", got, want)
+	}
+}
+
+func TestOversizedMessages(t *testing.T) {
+	const maxBytes = 201
+	// Every "é" is two bytes, and the limit leaves room for an odd number of
+	// bytes of the note, which would split a character.
+	oversized := StreamMessage{Table: "orders", Event: "insert", Data: map[string]any{"id": int64(1), "note": strings.Repeat("é", 300)}}
+	small := StreamMessage{Table: "orders", Event: "insert", Data: map[string]any{"id": int64(2)}}
+
+	tests := []struct {
+		policy        string
+		wantID        string
+		wantTruncated string
+		wantErr       bool
+	}{
+		{policy: oversizedDrop, wantID: `"id":2`},
+		{policy: oversizedTruncate, wantID: `"id":1`, wantTruncated: "note"},
+		{policy: oversizedError, wantID: `"id":1`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			metrics, local := newTestMetrics(t)
+			m := newTestInput(t, WithMaxMessageBytes(maxBytes, tt.policy), WithMetrics(metrics))
+			m.stream <- oversized
+			m.stream <- small
+
+			msg, _, err := m.Read(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := msg.AsBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(body, []byte(tt.wantID)) {
+				t.Fatalf("read %s, want the message with %s", body, tt.wantID)
+			}
+			if truncated, _ := msg.MetaGet("truncated_columns"); truncated != tt.wantTruncated {
+				t.Errorf("truncated_columns = %q, want %q", truncated, tt.wantTruncated)
+			}
+			if tt.policy == oversizedTruncate {
+				if len(body) > maxBytes {
+					t.Errorf("truncated message is %d bytes, want at most %d", len(body), maxBytes)
+				}
+				if !utf8.Valid(body) || bytes.ContainsRune(body, utf8.RuneError) {
+					t.Errorf("truncated message %s splits a character", body)
+				}
+			}
+			if (msg.GetError() != nil) != tt.wantErr {
+				t.Errorf("message error = %v, want error %v", msg.GetError(), tt.wantErr)
+			}
+			if n := local.GetCounters()["mysql_stream_messages_oversized"]; n != 1 {
+				t.Errorf("mysql_stream_messages_oversized = %d, want 1", n)
+			}
+		})
+	}
+}
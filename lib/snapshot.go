@@ -0,0 +1,504 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/shopspring/decimal"
+)
+
+const maxSnapshotRetryBackoff = 30 * time.Second
+
+const snapshotCompleteEvent = "snapshot_complete"
+
+var errSnapshotTimeout = errors.New("snapshot exceeded snapshot_timeout")
+
+// errSnapshotOnlyDone ends the stream once the snapshot of snapshot_only mode
+// completes.
+var errSnapshotOnlyDone = errors.New("snapshot completed")
+
+const (
+	modeSnapshotAndStream = "snapshot_and_stream"
+	modeStreamOnly        = "stream_only"
+	modeSnapshotOnly      = "snapshot_only"
+)
+
+const (
+	snapshotConsistencyTransactional = "transactional"
+	snapshotConsistencyLocking       = "locking"
+	snapshotConsistencyNone          = "none"
+)
+
+// runSnapshot reads the current contents of every streamed table, emits each
+// row as an insert and returns the position streaming continues from. By
+// default the position is read before the snapshot starts, so that changes
+// made in between are both in the snapshot and replayed afterwards. With
+// snapshot_lock_position it is read while a global read lock is held as the
+// snapshot transaction starts, so that it matches the snapshot exactly.
+func (m *MysqlStreamInput) runSnapshot(c *canal.Canal) (mysql.Position, mysql.GTIDSet, error) {
+	m.progress = nil
+	if m.snapshotResume {
+		progress, err := m.resumedSnapshot(context.Background())
+		if err != nil {
+			return mysql.Position{}, nil, fmt.Errorf("loading snapshot progress: %w", err)
+		}
+		if progress != nil {
+			return m.resumeSnapshot(progress)
+		}
+		m.progress = &snapshotProgress{ServerUUID: m.serverUUID}
+		if _, err := m.awaitSnapshotWindow(); err != nil {
+			return mysql.Position{}, nil, err
+		}
+	}
+
+	var coords mysql.Position
+	var gtidSet mysql.GTIDSet
+	capture := func() error {
+		var err error
+		coords, gtidSet, err = m.initialPosition(c)
+		m.snapshotPosition, m.snapshotGTIDSet = coords, gtidSet
+		if m.progress != nil {
+			m.progress.BinlogFile, m.progress.BinlogPos = coords.Name, coords.Pos
+			if gtidSet != nil {
+				m.progress.GTIDSet = gtidSet.String()
+			}
+		}
+		return err
+	}
+	if !m.snapshotLockPosition {
+		if err := capture(); err != nil {
+			return mysql.Position{}, nil, err
+		}
+		capture = nil
+	}
+	if err := m.snapshot(nil, capture); err != nil {
+		return mysql.Position{}, nil, err
+	}
+	return coords, gtidSet, nil
+}
+
+// resumeSnapshot continues an interrupted initial snapshot from its persisted
+// progress, skipping the tables already read and continuing the current table
+// after the last row acknowledged. Streaming continues from the position
+// captured when the snapshot started, so that changes made since are streamed
+// even though the resumed reads no longer see the same instant.
+func (m *MysqlStreamInput) resumeSnapshot(progress *snapshotProgress) (mysql.Position, mysql.GTIDSet, error) {
+	coords, gtidSet, err := m.progressPosition(progress)
+	if err != nil {
+		return mysql.Position{}, nil, err
+	}
+	m.snapshotPosition, m.snapshotGTIDSet = coords, gtidSet
+	if progress.Complete {
+		m.logger.Infof("Snapshot already completed, streaming from %s:%d", coords.Name, coords.Pos)
+		return coords, gtidSet, nil
+	}
+
+	m.logger.Infof("Resuming snapshot with %d tables already read, streaming from %s:%d afterwards", len(progress.Done), coords.Name, coords.Pos)
+	m.progress = progress
+	if err := m.snapshot(nil, nil); err != nil {
+		return mysql.Position{}, nil, err
+	}
+	return coords, gtidSet, nil
+}
+
+// snapshot reads the current contents of the given tables, or of every
+// streamed table when refs is empty. When capture is set it is called while
+// writes are locked out as the snapshot is isolated, see beginSnapshot.
+func (m *MysqlStreamInput) snapshot(refs []tableRef, capture func() error) error {
+	if _, err := m.awaitSnapshotWindow(); err != nil {
+		return err
+	}
+	conn, err := m.controlConn()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	if len(refs) == 0 {
+		if refs, err = m.snapshotTables(conn); err != nil {
+			return err
+		}
+	}
+	if err := m.beginSnapshot(conn, refs, capture); err != nil {
+		return err
+	}
+	// Only a snapshot transaction started under the lock the position is
+	// read in sees the tables exactly as of that position, see beginSnapshot.
+	m.snapshotExact = capture != nil
+
+	started := time.Now()
+	m.snapshotDeadline = time.Time{}
+	if m.snapshotTimeout > 0 {
+		m.snapshotDeadline = started.Add(m.snapshotTimeout)
+	}
+	counts := make(map[string]any, len(refs))
+	var total int64
+	for _, ref := range refs {
+		if m.progress != nil && m.progress.isDone(ref.key()) {
+			continue
+		}
+		// A table read stopped as its snapshot_schedule window closes is
+		// continued after its last row in the next window.
+		var n, read int64
+		for {
+			read, err = m.snapshotTableWithRetries(&conn, refs, ref)
+			n += read
+			if !errors.Is(err, errSnapshotOutsideSchedule) {
+				break
+			}
+			if err = m.pauseSnapshot(&conn, refs); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			// A failed snapshot is taken again from the start on the next
+			// connect, so the row held back for the last marker is dropped.
+			m.pendingSnapshotRow = nil
+			return err
+		}
+		counts[ref.key()] = n
+		total += n
+		if m.progress != nil {
+			m.progress = m.progress.finish(ref.key())
+		}
+	}
+	m.metrics.snapshotDuration.Timing(time.Since(started).Nanoseconds())
+	return m.emitAlongside(func() error {
+		if err := m.flushSnapshotRow(snapshotLast); err != nil {
+			return err
+		}
+		return m.emitSnapshotComplete(counts, total, time.Since(started))
+	})
+}
+
+// emitSnapshotComplete sends a summary of a finished snapshot with the row
+// count of each table, the binlog position the snapshot was taken at and the
+// one streaming continues from. Streaming always continues from the snapshot
+// position, the initial snapshot from the position runSnapshot returns and
+// resnapshots from the position they started at, so downstream can
+// check that the two match to verify that no change falls between snapshot
+// and stream.
+func (m *MysqlStreamInput) emitSnapshotComplete(counts map[string]any, total int64, took time.Duration) error {
+	m.logger.Infof("Snapshot of %d tables completed in %v with %d rows, streaming from %s:%d",
+		len(counts), took.Round(time.Millisecond), total, m.snapshotPosition.Name, m.snapshotPosition.Pos)
+
+	var progress *snapshotProgress
+	if m.progress != nil {
+		progress = m.progress.complete()
+		m.progress = nil
+	}
+	data := map[string]any{
+		"tables":               counts,
+		"rows":                 total,
+		"binlog_file":          m.snapshotPosition.Name,
+		"binlog_pos":           m.snapshotPosition.Pos,
+		"snapshot_binlog_file": m.snapshotPosition.Name,
+		"snapshot_binlog_pos":  m.snapshotPosition.Pos,
+		"exact":                m.snapshotExact,
+		"duration_ms":          took.Milliseconds(),
+		"timestamp":            time.Now().Unix(),
+	}
+	if m.snapshotGTIDSet != nil {
+		data["gtid_set"] = m.snapshotGTIDSet.String()
+		data["snapshot_gtid_set"] = m.snapshotGTIDSet.String()
+	}
+	return m.send(StreamMessage{
+		Event:    snapshotCompleteEvent,
+		progress: progress,
+		Data:     data,
+	})
+}
+
+// snapshotTableWithRetries retries a failed table snapshot with exponential
+// backoff on a fresh connection, so that a transient error such as a deadlock
+// or timeout does not abort the whole snapshot. Rows emitted before a failure
+// are emitted again by the retry, which reads from a new transaction or lock
+// when snapshot_consistency requires one, so the tables read after a retry no
+// longer reflect the same instant as those read before it. It returns the number of rows read
+// by the successful attempt.
+func (m *MysqlStreamInput) snapshotTableWithRetries(conn **client.Conn, refs []tableRef, ref tableRef) (int64, error) {
+	backoff := m.snapshotRetryBackoff
+	for attempt := 0; ; attempt++ {
+		n, err := m.snapshotTable(*conn, ref)
+		if err == nil {
+			return n, nil
+		}
+		if errors.Is(err, errSnapshotTimeout) {
+			return 0, fmt.Errorf("snapshot of %s: %w after %v", ref.key(), err, m.snapshotTimeout)
+		}
+		if errors.Is(err, errSnapshotOutsideSchedule) {
+			return n, err
+		}
+		if attempt >= m.snapshotMaxRetries {
+			return 0, fmt.Errorf("snapshot of %s failed after %d attempts: %w", ref.key(), attempt+1, err)
+		}
+
+		m.logger.Warnf("Snapshot of %s failed, retrying in %v: %v", ref.key(), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-m.closed:
+			return 0, fmt.Errorf("snapshot of %s: %w", ref.key(), err)
+		}
+		if backoff *= 2; backoff > maxSnapshotRetryBackoff {
+			backoff = maxSnapshotRetryBackoff
+		}
+
+		(*conn).Close()
+		m.snapshotExact = false
+		if *conn, err = m.controlConn(); err != nil {
+			return 0, fmt.Errorf("snapshot of %s: %w", ref.key(), err)
+		}
+		if err = m.beginSnapshot(*conn, refs, nil); err != nil {
+			return 0, fmt.Errorf("snapshot of %s: %w", ref.key(), err)
+		}
+	}
+}
+
+// beginSnapshot isolates the snapshot reads made on conn according to
+// snapshot_consistency. The transaction or locks are released when the
+// connection is closed. With transactional consistency and a capture func, the
+// transaction is started under a global read lock, as mysqldump
+// --single-transaction --master-data does, and capture is called before the
+// lock is released so that it can read the binlog position the transaction
+// sees.
+func (m *MysqlStreamInput) beginSnapshot(conn *client.Conn, refs []tableRef, capture func() error) error {
+	if m.temporalOutput != temporalNative {
+		// TIMESTAMP values are read in UTC, as the binlog formats them.
+		if _, err := conn.Execute("SET SESSION time_zone = '+00:00'"); err != nil {
+			return err
+		}
+	}
+	switch m.snapshotConsistency {
+	case snapshotConsistencyTransactional:
+		if capture != nil {
+			if _, err := conn.Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+				return fmt.Errorf("snapshot_lock_position: %w", err)
+			}
+		}
+		if _, err := conn.Execute("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return err
+		}
+		if _, err := conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return err
+		}
+		if capture != nil {
+			if err := capture(); err != nil {
+				return err
+			}
+			// Releasing a global read lock does not end the transaction.
+			if _, err := conn.Execute("UNLOCK TABLES"); err != nil {
+				return err
+			}
+		}
+	case snapshotConsistencyLocking:
+		if len(refs) == 0 {
+			return nil
+		}
+		tables := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			tables = append(tables, fmt.Sprintf("%s.%s READ", quoteIdentifier(ref.schema), quoteIdentifier(ref.name)))
+		}
+		if _, err := conn.Execute("LOCK TABLES " + strings.Join(tables, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitSnapshotRow holds back each snapshot row until the next one arrives, so
+// that the final row of the snapshot can be tagged as the last one.
+func (m *MysqlStreamInput) emitSnapshotRow(msg StreamMessage) error {
+	if err := m.flushSnapshotRow(snapshotTrue); err != nil {
+		return err
+	}
+	msg.op = opRead
+	msg.version = globalSeq(m.snapshotPosition, 0, 1)
+	msg.progress = m.progress
+	m.pendingSnapshotRow = &msg
+	return nil
+}
+
+func (m *MysqlStreamInput) flushSnapshotRow(snapshot string) error {
+	if m.pendingSnapshotRow == nil {
+		return nil
+	}
+	msg := *m.pendingSnapshotRow
+	m.pendingSnapshotRow = nil
+	msg.snapshot = snapshot
+	return m.send(msg)
+}
+
+// snapshotTables returns the configured tables, or every base table of the
+// database when no tables are configured.
+func (m *MysqlStreamInput) snapshotTables(conn *client.Conn) ([]tableRef, error) {
+	if len(m.tableRefs) > 0 {
+		return m.tableRefs, nil
+	}
+
+	res, err := conn.Execute(fmt.Sprintf("SHOW FULL TABLES FROM %s WHERE Table_type = 'BASE TABLE'", quoteIdentifier(m.database)))
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]tableRef, 0, res.RowNumber())
+	for i := 0; i < res.RowNumber(); i++ {
+		name, err := res.GetString(i, 0)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, tableRef{schema: m.database, name: name})
+	}
+	return refs, nil
+}
+
+func (m *MysqlStreamInput) snapshotTable(conn *client.Conn, ref tableRef) (int64, error) {
+	table, err := m.canal.GetTable(ref.schema, ref.name)
+	if err != nil {
+		return 0, err
+	}
+
+	// Columns are selected by name, as SELECT * leaves out INVISIBLE columns
+	// that rows are paired with the schema by position.
+	columns := make([]string, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		columns = append(columns, quoteIdentifier(col.Name))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(columns, ", "), quoteIdentifier(ref.schema), quoteIdentifier(ref.name))
+	var conds []string
+	if where, ok := m.snapshotWhere[ref.key()]; ok {
+		conds = append(conds, where)
+	}
+	if filter, ok := m.rowFilters[ref.key()]; ok {
+		conds = append(conds, filter.sql())
+	}
+	var order string
+	if m.progress != nil {
+		var cursor string
+		if cursor, order = m.progress.progressQuery(table, ref.key()); cursor != "" {
+			conds = append(conds, cursor)
+		} else if m.progress.Table == ref.key() {
+			m.logger.Warnf("Reading %s again from the start, the snapshot progress has no cursor matching its primary key", ref.key())
+		}
+	}
+	switch len(conds) {
+	case 0:
+	case 1:
+		query += " WHERE " + conds[0]
+	default:
+		query += " WHERE (" + strings.Join(conds, ") AND (") + ")"
+	}
+	query += order
+
+	var result mysql.Result
+	var rows int64
+	err = conn.ExecuteSelectStreaming(query, &result, func(row []mysql.FieldValue) error {
+		if !m.snapshotDeadline.IsZero() && time.Now().After(m.snapshotDeadline) {
+			return errSnapshotTimeout
+		}
+		if m.scheduleSnapshot() && !m.snapshotSchedule.open(time.Now()) {
+			return errSnapshotOutsideSchedule
+		}
+		if err := m.throttleSnapshot(); err != nil {
+			return err
+		}
+		values, err := m.snapshotRow(table, row)
+		if err != nil {
+			return err
+		}
+		rows++
+		m.metrics.snapshotRows.Incr(1, ref.key())
+		if m.progress != nil {
+			m.progress = m.progress.advance(table, ref.key(), values)
+		}
+		return m.emitAlongside(func() error {
+			return m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{values},
+			})
+		})
+	}, nil)
+	return rows, err
+}
+
+// snapshotRow copies a streamed row out of the connection's buffers, parsing
+// values into the same types the binlog produces for the configured
+// use_decimal and parse_time settings. DECIMAL values are kept as the exact
+// strings MySQL sent when use_decimal is off, as parsing them into floats
+// would lose precision, which is what the dump based snapshot emitted.
+func (m *MysqlStreamInput) snapshotRow(table *schema.Table, row []mysql.FieldValue) ([]any, error) {
+	values := make([]any, len(row))
+	for i := range row {
+		v := row[i].Value()
+		raw, ok := v.([]byte)
+		if !ok {
+			values[i] = v
+			continue
+		}
+		if i >= len(table.Columns) {
+			values[i] = string(raw)
+			continue
+		}
+
+		col := table.Columns[i]
+		switch {
+		case col.Type == schema.TYPE_DECIMAL && m.useDecimal:
+			d, err := decimal.NewFromString(string(raw))
+			if err != nil {
+				return nil, fmt.Errorf("parse column %s: %w", col.Name, err)
+			}
+			values[i] = d
+		case isBinary(col):
+			values[i] = append([]byte(nil), raw...)
+		case (col.Type == schema.TYPE_DATETIME || col.Type == schema.TYPE_TIMESTAMP) && m.parseTime && !strings.HasPrefix(string(raw), "0000-00-00"):
+			t, err := time.ParseInLocation(mysql.TimeFormat, string(raw), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("parse column %s: %w", col.Name, err)
+			}
+			values[i] = t
+		default:
+			values[i] = string(raw)
+		}
+	}
+	return values, nil
+}
+
+// Debezium style operation codes and snapshot markers.
+const (
+	opCreate   = "c"
+	opUpdate   = "u"
+	opDelete   = "d"
+	opRead     = "r"
+	opTruncate = "t"
+
+	snapshotTrue  = "true"
+	snapshotLast  = "last"
+	snapshotFalse = "false"
+)
+
+func actionOp(action string) string {
+	switch action {
+	case canal.InsertAction:
+		return opCreate
+	case canal.UpdateAction:
+		return opUpdate
+	case canal.DeleteAction:
+		return opDelete
+	}
+	return ""
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,272 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/shopspring/decimal"
+)
+
+// textRow builds a row of a text protocol result set, as streamed by a
+// snapshot SELECT.
+func textRow(t *testing.T, types []byte, values ...string) []mysql.FieldValue {
+	t.Helper()
+	var data mysql.RowData
+	fields := make([]*mysql.Field, len(values))
+	for i, v := range values {
+		data = append(data, mysql.PutLengthEncodedString([]byte(v))...)
+		fields[i] = &mysql.Field{Type: types[i]}
+	}
+	row, err := data.ParseText(fields, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestSnapshotRowDecimal(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "prices", Columns: []schema.TableColumn{
+		{Name: "amount", Type: schema.TYPE_DECIMAL, RawType: "decimal(30,18)"},
+	}}
+	const amount = "123456789012.123456789012345678"
+
+	tests := []struct {
+		name       string
+		useDecimal bool
+		want       any
+	}{
+		{name: "string", want: amount},
+		{name: "use_decimal", useDecimal: true, want: decimal.RequireFromString(amount)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MysqlStreamInput{useDecimal: tt.useDecimal}
+			values, err := m.snapshotRow(table, textRow(t, []byte{mysql.MYSQL_TYPE_NEWDECIMAL}, amount))
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch want := tt.want.(type) {
+			case decimal.Decimal:
+				got, ok := values[0].(decimal.Decimal)
+				if !ok || !got.Equal(want) {
+					t.Errorf("amount = %v (%T), want %v", values[0], values[0], want)
+				}
+			default:
+				if values[0] != want {
+					t.Errorf("amount = %v (%T), want %v", values[0], values[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotBinaryMatchesStream(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"))
+	table := &schema.Table{Schema: "shop", Name: "files", Columns: []schema.TableColumn{
+		{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int"},
+		{Name: "digest", Type: schema.TYPE_BINARY, RawType: "varbinary(4)"},
+		{Name: "content", Type: schema.TYPE_STRING, RawType: "blob"},
+	}, PKColumns: []int{0}}
+	digest, content := "\x00\xff\x10\n", "\x89PNG\r\n\x1a\n\x00"
+
+	snapshotValues, err := m.snapshotRow(table, textRow(t, []byte{mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_VAR_STRING, mysql.MYSQL_TYPE_BLOB}, "1", digest, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.OnRow(&canal.RowsEvent{Table: table, Action: canal.InsertAction, Rows: [][]any{snapshotValues}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.flushSnapshotRow(snapshotLast); err != nil {
+		t.Fatal(err)
+	}
+	// The binlog carries VARBINARY values as strings and BLOB values as bytes.
+	err = m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int32(1), digest, []byte(content)}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, snapshotBody := readStructured(t, m)
+	_, streamBody := readStructured(t, m)
+	for _, column := range []string{"digest", "content"} {
+		if snapshotBody[column] != streamBody[column] {
+			t.Errorf("%s = %v in the snapshot and %v streamed, want them equal", column, snapshotBody[column], streamBody[column])
+		}
+	}
+	if want := base64.StdEncoding.EncodeToString([]byte(content)); streamBody["content"] != want {
+		t.Errorf("content = %v, want base64 %s", streamBody["content"], want)
+	}
+}
+
+func TestSnapshotCompletePositions(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{
+		{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int"},
+	}, PKColumns: []int{0}}
+	master, err := mysql.BuildSimpleTextResultset(
+		[]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+		[][]any{{"mysql-bin.000003", uint64(4321), "", "", ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := mysql.BuildSimpleTextResultset([]string{"id"}, [][]any{{int64(1)}, {int64(2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSchemaServer(t, schemaServer{
+		tables: map[string]*schema.Table{"shop.orders": table},
+		results: map[string]*mysql.Resultset{
+			"SHOW MASTER STATUS":               master,
+			"SHOW BINARY LOG STATUS":           master,
+			"SELECT `id` FROM `shop`.`orders`": rows,
+		},
+	})
+	paused := mysql.Position{Name: "mysql-bin.000002", Pos: 900}
+
+	tests := []struct {
+		name         string
+		lockPosition bool
+		resnapshot   bool
+		want         mysql.Position
+		wantExact    bool
+	}{
+		{name: "initial", want: mysql.Position{Name: "mysql-bin.000003", Pos: 4321}},
+		{name: "initial under lock", lockPosition: true, want: mysql.Position{Name: "mysql-bin.000003", Pos: 4321}, wantExact: true},
+		{name: "resnapshot", resnapshot: true, want: paused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithAddr(addr), WithUser("root"), WithDatabase("shop"), WithTables("orders"),
+				WithSnapshotLockPosition(tt.lockPosition))
+			m.canal = newTestCanal(t, table)
+			connectCanal(t, m.canal, addr)
+
+			// Streaming continues from the position runSnapshot returns, or
+			// for a resnapshot from the position the stream is at.
+			streamFrom := paused
+			if tt.resnapshot {
+				m.syncedPosition = paused
+				m.eventMu.Lock()
+				m.startResnapshot([]tableRef{{schema: "shop", name: "orders"}})
+				run := m.resnapshotting
+				m.eventMu.Unlock()
+				<-run.done
+			} else {
+				if streamFrom, _, err = m.runSnapshot(m.canal); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(m.stream) != 3 {
+				t.Fatalf("%d messages sent, want 2 rows and snapshot_complete", len(m.stream))
+			}
+			<-m.stream
+			<-m.stream
+			msg := <-m.stream
+			if msg.Event != snapshotCompleteEvent {
+				t.Fatalf("last message is %s, want %s", msg.Event, snapshotCompleteEvent)
+			}
+			snapshotAt := mysql.Position{Name: msg.Data["snapshot_binlog_file"].(string), Pos: msg.Data["snapshot_binlog_pos"].(uint32)}
+			continuesAt := mysql.Position{Name: msg.Data["binlog_file"].(string), Pos: msg.Data["binlog_pos"].(uint32)}
+			if snapshotAt != tt.want {
+				t.Errorf("snapshot position = %v, want %v", snapshotAt, tt.want)
+			}
+			if continuesAt != snapshotAt || streamFrom != snapshotAt {
+				t.Errorf("snapshot taken at %v, snapshot_complete continues at %v and streaming continues at %v, want all equal",
+					snapshotAt, continuesAt, streamFrom)
+			}
+			if msg.Data["exact"] != tt.wantExact {
+				t.Errorf("exact = %v, want %v", msg.Data["exact"], tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestSnapshotConsistency(t *testing.T) {
+	queries := &queryLog{}
+	addr := startSchemaServer(t, schemaServer{queries: queries})
+	refs := []tableRef{{schema: "shop", name: "orders"}, {schema: "shop", name: "items"}}
+
+	tests := []struct {
+		name         string
+		consistency  string
+		lockPosition bool
+		want         []string
+	}{
+		{
+			name:        "transactional",
+			consistency: snapshotConsistencyTransactional,
+			want:        []string{"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT"},
+		},
+		{
+			// The position is captured between the start of the transaction
+			// and the release of the global read lock.
+			name:         "transactional under lock",
+			consistency:  snapshotConsistencyTransactional,
+			lockPosition: true,
+			want: []string{"FLUSH TABLES WITH READ LOCK", "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+				"START TRANSACTION WITH CONSISTENT SNAPSHOT", "capture", "UNLOCK TABLES"},
+		},
+		{
+			name:        "locking",
+			consistency: snapshotConsistencyLocking,
+			want:        []string{"LOCK TABLES `shop`.`orders` READ, `shop`.`items` READ"},
+		},
+		{name: "none", consistency: snapshotConsistencyNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithMode(modeSnapshotAndStream), WithSnapshotConsistency(tt.consistency), WithSnapshotLockPosition(tt.lockPosition))
+			conn, err := client.Connect(addr, "root", "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			queries.take()
+
+			var capture func() error
+			if tt.lockPosition {
+				capture = func() error {
+					queries.add("capture")
+					return nil
+				}
+			}
+			if err := m.beginSnapshot(conn, refs, capture); err != nil {
+				t.Fatal(err)
+			}
+			if got := queries.take(); strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("queries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotConsistencyInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		consistency  string
+		lockPosition bool
+		wantErr      string
+	}{
+		{name: "unknown", consistency: "serializable", wantErr: "invalid snapshot_consistency: serializable"},
+		{name: "lock position without transaction", consistency: snapshotConsistencyLocking, lockPosition: true, wantErr: "snapshot_lock_position requires snapshot_consistency transactional"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithMode(modeSnapshotAndStream),
+				WithSnapshotConsistency(tt.consistency), WithSnapshotLockPosition(tt.lockPosition))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewMysqlStreamInput error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
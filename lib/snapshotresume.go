@@ -0,0 +1,259 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// snapshotProgressSuffix is appended to position_cache_key for the key the
+// progress of an initial snapshot is persisted under.
+const snapshotProgressSuffix = "_snapshot"
+
+// snapshotProgress is the progress of an initial snapshot persisted by
+// snapshot_resume: the position streaming continues from once it completes,
+// the tables already read, and the primary key of the last row read from the
+// current table, hex encoded. Each snapshot row carries the progress as of
+// that row, which is persisted once the row and every message before it are
+// acknowledged.
+type snapshotProgress struct {
+	BinlogFile string   `json:"binlog_file"`
+	BinlogPos  uint32   `json:"binlog_pos"`
+	GTIDSet    string   `json:"gtid_set,omitempty"`
+	ServerUUID string   `json:"server_uuid,omitempty"`
+	Done       []string `json:"done,omitempty"`
+	Table      string   `json:"table,omitempty"`
+	Columns    []string `json:"columns,omitempty"`
+	Cursor     []string `json:"cursor,omitempty"`
+	Complete   bool     `json:"complete,omitempty"`
+}
+
+func (p *snapshotProgress) isDone(table string) bool {
+	for _, done := range p.Done {
+		if done == table {
+			return true
+		}
+	}
+	return false
+}
+
+// advance returns the progress after reading row from table, which resumes
+// after row when the table has a primary key and from the start of the table
+// otherwise.
+func (p *snapshotProgress) advance(table *schema.Table, key string, row []any) *snapshotProgress {
+	next := *p
+	next.Table, next.Columns, next.Cursor = key, nil, nil
+	for _, i := range table.PKColumns {
+		if i >= len(row) || row[i] == nil {
+			next.Columns, next.Cursor = nil, nil
+			break
+		}
+		next.Columns = append(next.Columns, table.Columns[i].Name)
+		next.Cursor = append(next.Cursor, hex.EncodeToString([]byte(cursorValue(row[i]))))
+	}
+	return &next
+}
+
+// cursorValue renders a primary key value the way MySQL parses it back,
+// which for times parsed by parse_time is not their default format.
+func cursorValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05.999999")
+	}
+	return keyValue(v)
+}
+
+// finish returns the progress after every row of table has been read.
+func (p *snapshotProgress) finish(key string) *snapshotProgress {
+	next := *p
+	next.Done = append(append([]string(nil), p.Done...), key)
+	next.Table, next.Columns, next.Cursor = "", nil, nil
+	return &next
+}
+
+// complete returns the progress of the completed snapshot.
+func (p *snapshotProgress) complete() *snapshotProgress {
+	next := *p
+	next.Table, next.Columns, next.Cursor = "", nil, nil
+	next.Complete = true
+	return &next
+}
+
+// cursorCondition returns the condition selecting the rows of table after the
+// cursor of the progress, or false when the progress has no cursor for it or
+// the cursor no longer matches the primary key of the table, in which case
+// the table is read again from the start.
+func (p *snapshotProgress) cursorCondition(table *schema.Table, key string) (string, bool) {
+	if p.Table != key || len(p.Cursor) == 0 {
+		return "", false
+	}
+	if len(p.Columns) != len(table.PKColumns) || len(p.Cursor) != len(p.Columns) {
+		return "", false
+	}
+
+	columns := make([]string, 0, len(p.Columns))
+	values := make([]string, 0, len(p.Cursor))
+	for n, i := range table.PKColumns {
+		col := table.Columns[i]
+		if col.Name != p.Columns[n] {
+			return "", false
+		}
+		raw, err := hex.DecodeString(p.Cursor[n])
+		if err != nil {
+			return "", false
+		}
+		literal, ok := cursorLiteral(col, raw)
+		if !ok {
+			return "", false
+		}
+		columns = append(columns, quoteIdentifier(col.Name))
+		values = append(values, literal)
+	}
+	return fmt.Sprintf("(%s) > (%s)", strings.Join(columns, ", "), strings.Join(values, ", ")), true
+}
+
+// collationName matches the names of MySQL collations, which start with the
+// name of their character set.
+var collationName = regexp.MustCompile(`^([A-Za-z0-9]+)_[A-Za-z0-9_]+$`)
+
+// cursorLiteral renders a primary key value as an SQL literal. Numbers are
+// written as such so that large integers are compared exactly, and other
+// values as hex literals, which need no escaping. Snapshot rows are read in
+// UTF-8, so text is converted to the character set of its column and compared
+// in its collation, the order the snapshot query reads the table in.
+func cursorLiteral(col schema.TableColumn, raw []byte) (string, bool) {
+	switch col.Type {
+	case schema.TYPE_NUMBER, schema.TYPE_MEDIUM_INT, schema.TYPE_DECIMAL, schema.TYPE_FLOAT, schema.TYPE_BIT:
+		if isYear(col) {
+			break
+		}
+		if _, err := strconv.ParseFloat(string(raw), 64); err != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+	if isBinary(col) {
+		return "_binary X'" + hex.EncodeToString(raw) + "'", true
+	}
+	literal := "_utf8mb4 X'" + hex.EncodeToString(raw) + "'"
+	charset := collationName.FindStringSubmatch(col.Collation)
+	if charset == nil {
+		return literal, true
+	}
+	return fmt.Sprintf("CONVERT(%s USING %s) COLLATE %s", literal, charset[1], col.Collation), true
+}
+
+// progressQuery returns the clauses that order the snapshot query of a table
+// by primary key and continue after the cursor of the progress.
+func (p *snapshotProgress) progressQuery(table *schema.Table, key string) (cond string, order string) {
+	if len(table.PKColumns) == 0 {
+		return "", ""
+	}
+	columns := make([]string, 0, len(table.PKColumns))
+	for _, i := range table.PKColumns {
+		columns = append(columns, quoteIdentifier(table.Columns[i].Name))
+	}
+	order = " ORDER BY " + strings.Join(columns, ", ")
+	cond, _ = p.cursorCondition(table, key)
+	return cond, order
+}
+
+// resumedSnapshot loads the progress of an interrupted initial snapshot. It
+// returns nil when there is none, or when it was taken from another server.
+func (m *MysqlStreamInput) resumedSnapshot(ctx context.Context) (*snapshotProgress, error) {
+	progress, err := m.positions.loadSnapshotProgress(ctx)
+	if err != nil || progress == nil {
+		return nil, err
+	}
+	if progress.ServerUUID != "" && m.serverUUID != "" && progress.ServerUUID != m.serverUUID {
+		m.logger.Warnf("Discarding the progress of a snapshot of server %s, connected to server %s", progress.ServerUUID, m.serverUUID)
+		return nil, nil
+	}
+	return progress, nil
+}
+
+// progressPosition returns the position and GTID set streaming continues from
+// after the snapshot of progress.
+func (m *MysqlStreamInput) progressPosition(progress *snapshotProgress) (mysql.Position, mysql.GTIDSet, error) {
+	pos := mysql.Position{Name: progress.BinlogFile, Pos: progress.BinlogPos}
+	if progress.GTIDSet == "" {
+		return pos, nil, nil
+	}
+	set, err := mysql.ParseGTIDSet(m.flavor, progress.GTIDSet)
+	if err != nil {
+		return mysql.Position{}, nil, fmt.Errorf("invalid gtid_set of the snapshot progress: %w", err)
+	}
+	return pos, set, nil
+}
+
+// loadSnapshotProgress reads the persisted snapshot progress, returning nil
+// when none is stored.
+func (s *positionStore) loadSnapshotProgress(ctx context.Context) (*snapshotProgress, error) {
+	var value []byte
+	var cacheErr error
+	if err := s.resources.AccessCache(ctx, s.cache, func(c service.Cache) {
+		value, cacheErr = c.Get(ctx, s.key+snapshotProgressSuffix)
+	}); err != nil {
+		return nil, err
+	}
+	if errors.Is(cacheErr, service.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if cacheErr != nil {
+		return nil, cacheErr
+	}
+
+	var progress snapshotProgress
+	if err := json.Unmarshal(value, &progress); err != nil {
+		return nil, err
+	}
+	s.flushMu.Lock()
+	s.flushedProgress = &progress
+	s.flushMu.Unlock()
+	return &progress, nil
+}
+
+// flushSnapshotProgress writes the acknowledged snapshot progress to the
+// cache, and deletes it once a streamed position has been committed, from
+// which a restarted stream resumes instead.
+func (s *positionStore) flushSnapshotProgress(ctx context.Context, committed mysql.Position, progress *snapshotProgress) error {
+	if progress == nil || progress == s.flushedProgress {
+		return nil
+	}
+
+	key := s.key + snapshotProgressSuffix
+	if committed.Name != "" {
+		if err := s.write(ctx, func(c service.Cache) error {
+			if err := c.Delete(ctx, key); err != nil && !errors.Is(err, service.ErrKeyNotFound) {
+				return err
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		s.flushedProgress = progress
+		return nil
+	}
+
+	value, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	if err := s.write(ctx, func(c service.Cache) error {
+		return c.Set(ctx, key, value, nil)
+	}); err != nil {
+		return err
+	}
+	s.flushedProgress = progress
+	return nil
+}
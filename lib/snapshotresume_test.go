@@ -0,0 +1,158 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestCursorCondition(t *testing.T) {
+	id := schema.TableColumn{Name: "id", Type: schema.TYPE_NUMBER, RawType: "bigint(20) unsigned"}
+	code := schema.TableColumn{Name: "code", Type: schema.TYPE_STRING, RawType: "varchar(16)", Collation: "latin1_swedish_ci"}
+	uncollated := schema.TableColumn{Name: "code", Type: schema.TYPE_STRING, RawType: "varchar(16)"}
+	hash := schema.TableColumn{Name: "hash", Type: schema.TYPE_BINARY, RawType: "binary(2)"}
+	table := func(pk ...schema.TableColumn) *schema.Table {
+		t := &schema.Table{Schema: "shop", Name: "orders", Columns: pk}
+		for i := range pk {
+			t.PKColumns = append(t.PKColumns, i)
+		}
+		return t
+	}
+	cursor := func(values ...string) []string {
+		var encoded []string
+		for _, v := range values {
+			encoded = append(encoded, hex.EncodeToString([]byte(v)))
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name     string
+		table    *schema.Table
+		progress snapshotProgress
+		want     string
+		wantOK   bool
+	}{
+		{
+			name:     "number",
+			table:    table(id),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"id"}, Cursor: cursor("18446744073709551615")},
+			want:     "(`id`) > (18446744073709551615)",
+			wantOK:   true,
+		},
+		{
+			name:     "text in the column collation",
+			table:    table(code),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"code"}, Cursor: cursor("é")},
+			want:     "(`code`) > (CONVERT(_utf8mb4 X'c3a9' USING latin1) COLLATE latin1_swedish_ci)",
+			wantOK:   true,
+		},
+		{
+			name:     "text without a collation",
+			table:    table(uncollated),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"code"}, Cursor: cursor("a")},
+			want:     "(`code`) > (_utf8mb4 X'61')",
+			wantOK:   true,
+		},
+		{
+			name:     "binary",
+			table:    table(hash),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"hash"}, Cursor: cursor("\x00\xff")},
+			want:     "(`hash`) > (_binary X'00ff')",
+			wantOK:   true,
+		},
+		{
+			name:     "composite",
+			table:    table(id, code),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"id", "code"}, Cursor: cursor("7", "a")},
+			want:     "(`id`, `code`) > (7, CONVERT(_utf8mb4 X'61' USING latin1) COLLATE latin1_swedish_ci)",
+			wantOK:   true,
+		},
+		{
+			name:     "other table",
+			table:    table(id),
+			progress: snapshotProgress{Table: "shop.users", Columns: []string{"id"}, Cursor: cursor("7")},
+		},
+		{
+			name:     "primary key changed",
+			table:    table(code),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"id"}, Cursor: cursor("7")},
+		},
+		{
+			name:     "not a number",
+			table:    table(id),
+			progress: snapshotProgress{Table: "shop.orders", Columns: []string{"id"}, Cursor: cursor("7; DROP TABLE orders")},
+		},
+		{
+			name:     "no cursor",
+			table:    table(id),
+			progress: snapshotProgress{Table: "shop.orders"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.progress.cursorCondition(tt.table, "shop.orders")
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("cursorCondition = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSnapshotResumeAfterCrash(t *testing.T) {
+	mgr, _ := newTestResources(t)
+	opts := []Option{WithResources(mgr), WithDatabase("shop"), WithPositionCache("cache", "position"), WithPositionFlush(0, 1), WithStreamSnapshot(true), WithSnapshotResume(true)}
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "code", Type: schema.TYPE_STRING, RawType: "varchar(16)", Collation: "latin1_swedish_ci"}},
+		PKColumns: []int{0},
+	}
+
+	// The first input reads three rows of the snapshot, of which only the
+	// first is acknowledged before it crashes.
+	m := newTestInput(t, opts...)
+	m.progress = &snapshotProgress{BinlogFile: "mysql-bin.000001", BinlogPos: 400}
+	for _, code := range []string{"a", "b", "c"} {
+		row := []any{code}
+		m.progress = m.progress.advance(table, "shop.orders", row)
+		if err := m.OnRow(&canal.RowsEvent{Table: table, Action: canal.InsertAction, Rows: [][]any{row}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	_, ack, err := m.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ack(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestInput(t, opts...)
+	progress, err := restarted.resumedSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress == nil {
+		t.Fatal("no snapshot progress to resume from")
+	}
+	pos, _, err := restarted.progressPosition(progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != (mysql.Position{Name: "mysql-bin.000001", Pos: 400}) {
+		t.Errorf("streaming continues from %v, want mysql-bin.000001:400", pos)
+	}
+	cond, order := progress.progressQuery(table, "shop.orders")
+	if want := "(`code`) > (CONVERT(_utf8mb4 X'61' USING latin1) COLLATE latin1_swedish_ci)"; cond != want {
+		t.Errorf("resumed condition = %q, want %q", cond, want)
+	}
+	if order != " ORDER BY `code`" {
+		t.Errorf("resumed order = %q, want ORDER BY `code`", order)
+	}
+}
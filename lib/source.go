@@ -0,0 +1,67 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"runtime/debug"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+const (
+	sourceConnector = "benthos-mysql-plugin"
+	modulePath      = "github.com/le-vlad/benthos-mysql-plugin"
+)
+
+// sourceInfo describes the producer of the messages of a connection for
+// include_source_info, and is set as the source metadata field in its JSON
+// form.
+type sourceInfo struct {
+	Connector     string `json:"connector"`
+	Version       string `json:"version"`
+	ServerVersion string `json:"server_version"`
+
+	encoded string
+}
+
+// pluginVersion returns the version of this module the binary was built
+// with, or (devel) when it is not known, such as when built from a checkout.
+func pluginVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		if dep.Version != "" {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// readSourceInfo reads the version of the server on connect when
+// include_source_info is enabled, so that it is not queried per message. A
+// server whose version cannot be read is reported with an empty version.
+func (m *MysqlStreamInput) readSourceInfo(conn *client.Conn) {
+	if !m.includeSourceInfo {
+		return
+	}
+	info := &sourceInfo{Connector: sourceConnector, Version: pluginVersion()}
+	res, err := conn.Execute("SELECT VERSION()")
+	if err == nil && res.RowNumber() > 0 {
+		info.ServerVersion, _ = res.GetString(0, 0)
+	} else if err != nil {
+		m.logger.Warnf("Failed to read the server version for include_source_info: %v", err)
+	}
+	encoded, _ := json.Marshal(info)
+	info.encoded = string(encoded)
+	m.sourceInfo = info
+}
@@ -0,0 +1,361 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+const (
+	spatialFormatWKT     = "wkt"
+	spatialFormatGeoJSON = "geojson"
+	spatialFormatBase64  = "base64"
+)
+
+const (
+	spatialSRIDOmit   = "omit"
+	spatialSRIDEmbed  = "embed"
+	spatialSRIDObject = "object"
+)
+
+var spatialRawTypes = []string{
+	"geometry", "point", "linestring", "polygon",
+	"multipoint", "multilinestring", "multipolygon",
+	"geometrycollection", "geomcollection",
+}
+
+// isSpatial reports whether a column holds a spatial type.
+func isSpatial(col schema.TableColumn) bool {
+	raw := strings.ToLower(col.RawType)
+	for _, t := range spatialRawTypes {
+		if strings.HasPrefix(raw, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertSpatial converts a value in MySQL's internal geometry format, a four
+// byte SRID followed by WKB, into WKT or GeoJSON, carrying the SRID according
+// to spatial_srid. Values of geometry types that are not supported are
+// handled by unknown_type_behavior, and other values that fail to parse are
+// emitted base64 encoded.
+func (m *MysqlStreamInput) convertSpatial(col schema.TableColumn, value any) (any, error) {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return value, nil
+	}
+
+	if m.spatialFormat == spatialFormatBase64 {
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+
+	if len(raw) < 4 {
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+	srid := binary.LittleEndian.Uint32(raw)
+	r := &wkbReader{b: raw[4:]}
+	g, err := r.geometry()
+	var unknown unknownGeometryError
+	if errors.As(err, &unknown) {
+		return m.convertUnknownValue(col, value, fmt.Sprintf("geometry type %d", unknown.kind))
+	}
+	if err != nil {
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+
+	var converted any
+	if m.spatialFormat == spatialFormatGeoJSON {
+		if m.spatialSRID == spatialSRIDEmbed && srid != 0 {
+			g.CRS = &geoJSONCRS{Type: "name", Properties: map[string]string{"name": "EPSG:" + strconv.FormatUint(uint64(srid), 10)}}
+		}
+		converted = g
+	} else {
+		wkt := g.wkt()
+		if m.spatialSRID == spatialSRIDEmbed && srid != 0 {
+			wkt = "SRID=" + strconv.FormatUint(uint64(srid), 10) + ";" + wkt
+		}
+		converted = wkt
+	}
+
+	if m.spatialSRID == spatialSRIDObject {
+		return map[string]any{"srid": srid, "geometry": converted}, nil
+	}
+	return converted, nil
+}
+
+// unknownGeometryError is returned for WKB of a geometry type that is not
+// supported, such as the ISO types with Z or M coordinates.
+type unknownGeometryError struct {
+	kind uint32
+}
+
+func (e unknownGeometryError) Error() string {
+	return fmt.Sprintf("wkb: unsupported geometry type %d", e.kind)
+}
+
+// geoJSONCRS is the named coordinate reference system of a GeoJSON geometry.
+type geoJSONCRS struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+}
+
+// geometry is a parsed spatial value, shaped so that it marshals as GeoJSON.
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates any         `json:"coordinates,omitempty"`
+	Geometries  []geometry  `json:"geometries,omitempty"`
+	CRS         *geoJSONCRS `json:"crs,omitempty"`
+}
+
+type wkbReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+var errShortWKB = errors.New("wkb: unexpected end of data")
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, errShortWKB
+	}
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *wkbReader) point() ([]float64, error) {
+	if len(r.b) < 16 {
+		return nil, errShortWKB
+	}
+	x := math.Float64frombits(r.order.Uint64(r.b))
+	y := math.Float64frombits(r.order.Uint64(r.b[8:]))
+	r.b = r.b[16:]
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return []float64{}, nil
+	}
+	return []float64{x, y}, nil
+}
+
+func (r *wkbReader) points() ([][]float64, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	points := make([][]float64, 0, min(n, uint32(len(r.b)/16)))
+	for i := uint32(0); i < n; i++ {
+		p, err := r.point()
+		if err != nil {
+			return nil, err
+		}
+		if len(p) == 0 {
+			// Only a Point can be empty.
+			return nil, errors.New("wkb: empty point in a line or ring")
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func (r *wkbReader) rings() ([][][]float64, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	rings := make([][][]float64, 0, min(n, uint32(len(r.b)/4)))
+	for i := uint32(0); i < n; i++ {
+		ring, err := r.points()
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
+
+func (r *wkbReader) children() ([]geometry, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	children := make([]geometry, 0, min(n, uint32(len(r.b)/5)))
+	for i := uint32(0); i < n; i++ {
+		g, err := r.geometry()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, g)
+	}
+	return children, nil
+}
+
+func (r *wkbReader) geometry() (geometry, error) {
+	if len(r.b) < 1 {
+		return geometry{}, errShortWKB
+	}
+	switch r.b[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return geometry{}, fmt.Errorf("wkb: invalid byte order %d", r.b[0])
+	}
+	r.b = r.b[1:]
+
+	kind, err := r.uint32()
+	if err != nil {
+		return geometry{}, err
+	}
+
+	switch kind {
+	case 1:
+		p, err := r.point()
+		return geometry{Type: "Point", Coordinates: p}, err
+	case 2:
+		ps, err := r.points()
+		return geometry{Type: "LineString", Coordinates: ps}, err
+	case 3:
+		rings, err := r.rings()
+		return geometry{Type: "Polygon", Coordinates: rings}, err
+	case 4, 5, 6:
+		children, err := r.children()
+		if err != nil {
+			return geometry{}, err
+		}
+		return multiGeometry(kind, children)
+	case 7:
+		children, err := r.children()
+		return geometry{Type: "GeometryCollection", Geometries: children}, err
+	}
+	return geometry{}, unknownGeometryError{kind: kind}
+}
+
+// multiGeometry builds a MultiPoint, MultiLineString or MultiPolygon from its
+// children, which must all be of the matching single geometry type.
+func multiGeometry(kind uint32, children []geometry) (geometry, error) {
+	switch kind {
+	case 4:
+		coords := make([][]float64, 0, len(children))
+		for _, c := range children {
+			p, ok := c.Coordinates.([]float64)
+			if !ok || c.Type != "Point" {
+				return geometry{}, fmt.Errorf("wkb: MultiPoint holds a %s", c.Type)
+			}
+			coords = append(coords, p)
+		}
+		return geometry{Type: "MultiPoint", Coordinates: coords}, nil
+	case 5:
+		coords := make([][][]float64, 0, len(children))
+		for _, c := range children {
+			ps, ok := c.Coordinates.([][]float64)
+			if !ok || c.Type != "LineString" {
+				return geometry{}, fmt.Errorf("wkb: MultiLineString holds a %s", c.Type)
+			}
+			coords = append(coords, ps)
+		}
+		return geometry{Type: "MultiLineString", Coordinates: coords}, nil
+	default:
+		coords := make([][][][]float64, 0, len(children))
+		for _, c := range children {
+			rings, ok := c.Coordinates.([][][]float64)
+			if !ok || c.Type != "Polygon" {
+				return geometry{}, fmt.Errorf("wkb: MultiPolygon holds a %s", c.Type)
+			}
+			coords = append(coords, rings)
+		}
+		return geometry{Type: "MultiPolygon", Coordinates: coords}, nil
+	}
+}
+
+func (g geometry) wkt() string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(g.Type))
+	switch c := g.Coordinates.(type) {
+	case []float64:
+		writeWKTPoint(&b, c)
+	case [][]float64:
+		if g.Type == "MultiPoint" {
+			b.WriteByte('(')
+			for i, p := range c {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				writeWKTPoint(&b, p)
+			}
+			b.WriteByte(')')
+		} else {
+			writeWKTPoints(&b, c)
+		}
+	case [][][]float64:
+		writeWKTRings(&b, c)
+	case [][][][]float64:
+		b.WriteByte('(')
+		for i, polygon := range c {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeWKTRings(&b, polygon)
+		}
+		b.WriteByte(')')
+	default:
+		b.WriteByte('(')
+		for i, child := range g.Geometries {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(child.wkt())
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+func writeWKTCoord(b *strings.Builder, p []float64) {
+	b.WriteString(strconv.FormatFloat(p[0], 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(p[1], 'f', -1, 64))
+}
+
+func writeWKTPoint(b *strings.Builder, p []float64) {
+	if len(p) < 2 {
+		b.WriteString(" EMPTY")
+		return
+	}
+	b.WriteByte('(')
+	writeWKTCoord(b, p)
+	b.WriteByte(')')
+}
+
+func writeWKTPoints(b *strings.Builder, ps [][]float64) {
+	b.WriteByte('(')
+	for i, p := range ps {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeWKTCoord(b, p)
+	}
+	b.WriteByte(')')
+}
+
+func writeWKTRings(b *strings.Builder, rings [][][]float64) {
+	b.WriteByte('(')
+	for i, ring := range rings {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeWKTPoints(b, ring)
+	}
+	b.WriteByte(')')
+}
@@ -0,0 +1,79 @@
+package mongodb_stream_benthos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// wkb builds little endian WKB from a geometry type and its body.
+func wkb(kind uint32, body ...[]byte) []byte {
+	b := []byte{1}
+	b = binary.LittleEndian.AppendUint32(b, kind)
+	for _, part := range body {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func wkbCount(n uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, n)
+}
+
+func wkbPoint(x, y float64) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, math.Float64bits(x))
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(y))
+}
+
+// mysqlGeometry prefixes WKB with an SRID, as MySQL stores spatial values.
+func mysqlGeometry(srid uint32, wkb []byte) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, srid), wkb...)
+}
+
+func TestConvertSpatial(t *testing.T) {
+	col := schema.TableColumn{Name: "shape", Type: schema.TYPE_POINT, RawType: "geometry"}
+	point := mysqlGeometry(4326, wkb(1, wkbPoint(1.5, -2)))
+	line := wkb(2, wkbCount(2), wkbPoint(0, 0), wkbPoint(1, 1))
+	mixed := mysqlGeometry(0, wkb(4, wkbCount(2), wkb(1, wkbPoint(0, 0)), line))
+	multiLine := mysqlGeometry(0, wkb(5, wkbCount(1), line))
+	emptyInLine := mysqlGeometry(0, wkb(2, wkbCount(1), wkbPoint(math.NaN(), math.NaN())))
+	truncated := mysqlGeometry(0, wkb(3, wkbCount(1000000)))
+
+	tests := []struct {
+		name   string
+		format string
+		srid   string
+		value  []byte
+		want   string
+	}{
+		{name: "point wkt", format: spatialFormatWKT, srid: spatialSRIDOmit, value: point, want: `"POINT(1.5 -2)"`},
+		{name: "point wkt srid", format: spatialFormatWKT, srid: spatialSRIDEmbed, value: point, want: `"SRID=4326;POINT(1.5 -2)"`},
+		{name: "point geojson", format: spatialFormatGeoJSON, srid: spatialSRIDOmit, value: point, want: `{"type":"Point","coordinates":[1.5,-2]}`},
+		{name: "point object", format: spatialFormatWKT, srid: spatialSRIDObject, value: point, want: `{"geometry":"POINT(1.5 -2)","srid":4326}`},
+		{name: "multilinestring", format: spatialFormatWKT, srid: spatialSRIDOmit, value: multiLine, want: `"MULTILINESTRING((0 0,1 1))"`},
+		{name: "multipoint holding a line", format: spatialFormatWKT, srid: spatialSRIDOmit, value: mixed, want: `"` + base64.StdEncoding.EncodeToString(mixed) + `"`},
+		{name: "empty point in a line", format: spatialFormatWKT, srid: spatialSRIDOmit, value: emptyInLine, want: `"` + base64.StdEncoding.EncodeToString(emptyInLine) + `"`},
+		{name: "truncated", format: spatialFormatGeoJSON, srid: spatialSRIDOmit, value: truncated, want: `"` + base64.StdEncoding.EncodeToString(truncated) + `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithSpatialFormat(tt.format), WithSpatialSRID(tt.srid))
+			got, err := m.convertSpatial(col, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(encoded, []byte(tt.want)) {
+				t.Errorf("convertSpatial = %s, want %s", encoded, tt.want)
+			}
+		})
+	}
+}
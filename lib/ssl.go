@@ -0,0 +1,107 @@
+package mongodb_stream_benthos
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// SSL modes with the semantics of the MySQL client's --ssl-mode option.
+const (
+	sslModeDisabled       = "DISABLED"
+	sslModePreferred      = "PREFERRED"
+	sslModeRequired       = "REQUIRED"
+	sslModeVerifyCA       = "VERIFY_CA"
+	sslModeVerifyIdentity = "VERIFY_IDENTITY"
+)
+
+// sslTLSConfig returns the TLS config for an SSL mode, or nil when TLS is
+// disabled. Without a CA file the system roots are used for verification.
+func sslTLSConfig(mode, addr, caFile string) (*tls.Config, error) {
+	var roots *x509.CertPool
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ssl_ca: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ssl_ca %s contains no certificates", caFile)
+		}
+	}
+
+	switch mode {
+	case sslModeDisabled:
+		return nil, nil
+	case sslModePreferred, sslModeRequired:
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case sslModeVerifyCA:
+		// The standard verification always checks the host name, so the chain
+		// is verified separately.
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				return verifyCertificateChain(rawCerts, roots)
+			},
+		}, nil
+	case sslModeVerifyIdentity:
+		return &tls.Config{ServerName: serverName(addr), RootCAs: roots}, nil
+	}
+	return nil, fmt.Errorf("invalid ssl_mode: %s", mode)
+}
+
+// serverName returns the host of a host:port address.
+func serverName(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func verifyCertificateChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// negotiatePreferredTLS falls back to an unencrypted connection when the
+// server does not support TLS under the PREFERRED ssl mode.
+func (m *MysqlStreamInput) negotiatePreferredTLS() error {
+	m.tlsConfig = m.preferredTLSConfig
+	conn, err := client.Connect(m.addr, m.user, m.password, "", func(c *client.Conn) error {
+		c.SetTLSConfig(m.tlsConfig)
+		return nil
+	})
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+	if !strings.Contains(err.Error(), "does not support TLS") {
+		return err
+	}
+	m.logger.Warn("Server does not support TLS, connecting without encryption as ssl_mode is PREFERRED")
+	m.tlsConfig = nil
+	return nil
+}
@@ -0,0 +1,137 @@
+package mongodb_stream_benthos
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self signed CA certificate in DER form.
+func newTestCertificate(t *testing.T, name string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestSSLTLSConfig(t *testing.T) {
+	ca := newTestCertificate(t, "ca")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mode           string
+		caFile         string
+		wantTLS        bool
+		wantSkipVerify bool
+		wantVerifyPeer bool
+		wantServerName string
+		wantErr        bool
+	}{
+		{mode: sslModeDisabled},
+		{mode: sslModePreferred, wantTLS: true, wantSkipVerify: true},
+		{mode: sslModeRequired, wantTLS: true, wantSkipVerify: true},
+		{mode: sslModeVerifyCA, caFile: caFile, wantTLS: true, wantSkipVerify: true, wantVerifyPeer: true},
+		{mode: sslModeVerifyIdentity, caFile: caFile, wantTLS: true, wantServerName: "db1.example.com"},
+		{mode: "ALLOWED", wantErr: true},
+		{mode: sslModeVerifyCA, caFile: filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+		{mode: sslModeVerifyCA, caFile: emptyFile, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+filepath.Base(tt.caFile), func(t *testing.T) {
+			cfg, err := sslTLSConfig(tt.mode, "db1.example.com:3306", tt.caFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sslTLSConfig error = %v, want error %v", err, tt.wantErr)
+			}
+			if (cfg != nil) != tt.wantTLS {
+				t.Fatalf("TLS config = %v, want TLS %v", cfg, tt.wantTLS)
+			}
+			if cfg == nil {
+				return
+			}
+			if cfg.InsecureSkipVerify != tt.wantSkipVerify || (cfg.VerifyPeerCertificate != nil) != tt.wantVerifyPeer || cfg.ServerName != tt.wantServerName {
+				t.Errorf("TLS config skips verification %v, verifies peer %v, server name %q, want %v, %v, %q",
+					cfg.InsecureSkipVerify, cfg.VerifyPeerCertificate != nil, cfg.ServerName, tt.wantSkipVerify, tt.wantVerifyPeer, tt.wantServerName)
+			}
+			if tt.mode == sslModeVerifyIdentity && (cfg.RootCAs == nil || !cfg.RootCAs.Equal(rootsOf(t, ca))) {
+				t.Error("VERIFY_IDENTITY does not verify against ssl_ca")
+			}
+			if tt.wantVerifyPeer {
+				if err := cfg.VerifyPeerCertificate([][]byte{ca}, nil); err != nil {
+					t.Errorf("certificate issued by ssl_ca rejected: %v", err)
+				}
+				if err := cfg.VerifyPeerCertificate([][]byte{newTestCertificate(t, "other")}, nil); err == nil {
+					t.Error("certificate of another authority accepted")
+				}
+			}
+		})
+	}
+}
+
+// rootsOf returns a pool holding the DER certificate cert.
+func rootsOf(t *testing.T, cert []byte) *x509.CertPool {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return pool
+}
+
+func TestSSLServerNameOnFailover(t *testing.T) {
+	tests := []struct {
+		mode           string
+		wantServerName string
+	}{
+		{mode: sslModeVerifyIdentity, wantServerName: "db2.example.com"},
+		{mode: sslModeVerifyCA},
+		{mode: sslModeRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			m := newTestInput(t, WithAddr("db1.example.com:3306"), WithSSLMode(tt.mode, ""))
+			before := m.tlsConfig
+			m.useAddr("db2.example.com:3306")
+			if m.tlsConfig.ServerName != tt.wantServerName {
+				t.Errorf("server name after failover = %q, want %q", m.tlsConfig.ServerName, tt.wantServerName)
+			}
+			if m.preferredTLSConfig != m.tlsConfig {
+				t.Error("preferred TLS config not moved to the new server")
+			}
+			if tt.mode == sslModeVerifyIdentity && before.ServerName != "db1.example.com" {
+				t.Errorf("config of the first server changed to server name %q", before.ServerName)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const (
+	startPositionLatest   = "latest"
+	startPositionEarliest = "earliest"
+)
+
+// initialPosition returns the position a stream starts from when it has no
+// position to resume from, along with the GTID set to start from instead
+// when the stream is followed by GTID.
+func (m *MysqlStreamInput) initialPosition(c *canal.Canal) (mysql.Position, mysql.GTIDSet, error) {
+	// The GTID set is read first so that a transaction committed in between
+	// is streamed again rather than skipped.
+	gtidSet := m.failoverGTIDSet(c)
+	if m.startPosition == startPositionEarliest {
+		return m.earliestPosition(c, gtidSet != nil)
+	}
+	pos, err := c.GetMasterPos()
+	return pos, gtidSet, err
+}
+
+// earliestPosition returns the start of the oldest binlog file the server
+// still has, and when gtids is set the GTID set of the transactions purged
+// before it. MariaDB does not track the purged GTIDs, so its streams start
+// from the file position.
+func (m *MysqlStreamInput) earliestPosition(c *canal.Canal, gtids bool) (mysql.Position, mysql.GTIDSet, error) {
+	res, err := c.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return mysql.Position{}, nil, err
+	}
+	if res.RowNumber() == 0 {
+		return mysql.Position{}, nil, errors.New("server has no binary logs to start from")
+	}
+	name, err := res.GetString(0, 0)
+	if err != nil {
+		return mysql.Position{}, nil, err
+	}
+	// Events start after the 4 byte magic number of the file.
+	pos := mysql.Position{Name: name, Pos: 4}
+	if !gtids || m.flavor == mysql.MariaDBFlavor {
+		return pos, nil, nil
+	}
+
+	res, err = c.Execute("SELECT @@GLOBAL.gtid_purged")
+	if err != nil {
+		return mysql.Position{}, nil, err
+	}
+	purged, _ := res.GetString(0, 0)
+	set, err := mysql.ParseGTIDSet(m.flavor, purged)
+	if err != nil {
+		return mysql.Position{}, nil, err
+	}
+	return pos, set, nil
+}
@@ -0,0 +1,130 @@
+package mongodb_stream_benthos
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestStartGTIDSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		flavor  string
+		set     string
+		mode    string
+		want    string
+		wantErr string
+	}{
+		{name: "mysql", flavor: "mysql", set: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{name: "mariadb", flavor: "mariadb", set: "0-1-100", want: "0-1-100"},
+		{name: "invalid for mysql", flavor: "mysql", set: "0-1-100", wantErr: "invalid start_gtid_set for flavor mysql"},
+		{name: "invalid for mariadb", flavor: "mariadb", set: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", wantErr: "invalid start_gtid_set for flavor mariadb"},
+		{name: "snapshot_only", flavor: "mysql", set: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", mode: modeSnapshotOnly, wantErr: "mode snapshot_only cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithFlavor(tt.flavor), WithMode(tt.mode), WithStartGTIDSet(tt.set))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewMysqlStreamInput error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.startGTIDSet == nil || m.startGTIDSet.String() != tt.want {
+				t.Errorf("start GTID set = %v, want %s", m.startGTIDSet, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartPosition(t *testing.T) {
+	const (
+		executed = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-90"
+		purged   = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-40"
+	)
+	result := func(names []string, row ...any) *mysql.Resultset {
+		rs, err := mysql.BuildSimpleTextResultset(names, [][]any{row})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rs
+	}
+	master := result([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, "mysql-bin.000007", uint64(1500), "", "", executed)
+	logs, err := mysql.BuildSimpleTextResultset([]string{"Log_name", "File_size"},
+		[][]any{{"mysql-bin.000005", uint64(2048)}, {"mysql-bin.000006", uint64(4096)}, {"mysql-bin.000007", uint64(1500)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSchemaServer(t, schemaServer{results: map[string]*mysql.Resultset{
+		"SHOW MASTER STATUS":            master,
+		"SHOW BINARY LOG STATUS":        master,
+		"SHOW BINARY LOGS":              logs,
+		"SELECT @@GLOBAL.GTID_EXECUTED": result([]string{"@@GLOBAL.GTID_EXECUTED"}, executed),
+		"SELECT @@GLOBAL.gtid_purged":   result([]string{"@@GLOBAL.gtid_purged"}, purged),
+	}})
+
+	tests := []struct {
+		name     string
+		position string
+		failover bool
+		want     mysql.Position
+		wantGTID string
+	}{
+		{name: "latest", position: startPositionLatest, want: mysql.Position{Name: "mysql-bin.000007", Pos: 1500}},
+		{name: "earliest", position: startPositionEarliest, want: mysql.Position{Name: "mysql-bin.000005", Pos: 4}},
+		// With failover the stream follows GTIDs, which start after the
+		// executed transactions or, for earliest, after the purged ones.
+		{name: "latest with failover", position: startPositionLatest, failover: true, want: mysql.Position{Name: "mysql-bin.000007", Pos: 1500}, wantGTID: executed},
+		{name: "earliest with failover", position: startPositionEarliest, failover: true, want: mysql.Position{Name: "mysql-bin.000005", Pos: 4}, wantGTID: purged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithStartPosition(tt.position)}
+			if tt.failover {
+				opts = append(opts, WithAddrs([]string{addr, "127.0.0.1:3307"}))
+			}
+			m := newTestInput(t, opts...)
+			c := newTestCanal(t)
+			connectCanal(t, c, addr)
+
+			pos, gtidSet, err := m.initialPosition(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pos != tt.want {
+				t.Errorf("position = %v, want %v", pos, tt.want)
+			}
+			gtid := ""
+			if gtidSet != nil {
+				gtid = gtidSet.String()
+			}
+			if gtid != tt.wantGTID {
+				t.Errorf("GTID set = %q, want %q", gtid, tt.wantGTID)
+			}
+		})
+	}
+}
+
+func TestStartPositionInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		position string
+		mode     string
+		wantErr  string
+	}{
+		{name: "earliest with snapshot", position: startPositionEarliest, mode: modeSnapshotAndStream, wantErr: "start_position earliest cannot be combined with a snapshot"},
+		{name: "unknown", position: "oldest", wantErr: "invalid start_position: oldest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithMode(tt.mode), WithStartPosition(tt.position))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewMysqlStreamInput error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// stringValue formats a converted column value for values_as_strings the way
+// JSON output would show it, so that temporal_output, use_decimal and
+// spatial_format still decide the format: binary values are base64 encoded,
+// times parsed by parse_time are formatted as RFC 3339 and GeoJSON and other
+// structured values are encoded as JSON text. NULL is emitted as the
+// configured sentinel, or kept as null when there is none.
+func (m *MysqlStreamInput) stringValue(v any) any {
+	switch v := v.(type) {
+	case nil:
+		if m.valuesAsStringsNull == "" {
+			return nil
+		}
+		return m.valuesAsStringsNull
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case decimal.Decimal:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
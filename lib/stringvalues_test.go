@@ -0,0 +1,68 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/shopspring/decimal"
+)
+
+func TestStringValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		null  string
+		value any
+		want  any
+	}{
+		{name: "null", value: nil, want: nil},
+		{name: "null sentinel", null: `\N`, value: nil, want: `\N`},
+		{name: "string", value: "paid", want: "paid"},
+		{name: "bytes", value: []byte{0xde, 0xad}, want: "3q0="},
+		{name: "bool", value: true, want: "true"},
+		{name: "int", value: int64(-42), want: "-42"},
+		{name: "uint", value: uint64(18446744073709551615), want: "18446744073709551615"},
+		{name: "large float", value: 1e21, want: "1000000000000000000000"},
+		{name: "float32", value: float32(0.1), want: "0.1"},
+		{name: "decimal", value: decimal.RequireFromString("12.3400"), want: "12.34"},
+		{name: "time", value: time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC), want: "2024-01-02T03:04:05.000006Z"},
+		{name: "geojson", value: map[string]any{"type": "Point", "coordinates": []float64{1, 2}}, want: `{"coordinates":[1,2],"type":"Point"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithValuesAsStrings(true, tt.null))
+			if got := m.stringValue(tt.value); got != tt.want {
+				t.Errorf("stringValue(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValuesAsStrings(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithValuesAsStrings(true, ""), WithStructuredMessages(true))
+	table := &schema.Table{
+		Schema:    "shop",
+		Name:      "orders",
+		Columns:   []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}, {Name: "qty", Type: schema.TYPE_NUMBER}, {Name: "note", Type: schema.TYPE_STRING}},
+		PKColumns: []int{0},
+	}
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(7), int64(2), nil}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, body := readStructured(t, m)
+	want := map[string]any{"id": "7", "qty": "2", "note": nil}
+	for column, value := range want {
+		if v, ok := body[column]; !ok || v != value {
+			t.Errorf("%s = %#v, want %#v", column, v, value)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+)
+
+// tableRef identifies a table by schema and name.
+type tableRef struct {
+	schema string
+	name   string
+}
+
+func (t tableRef) key() string {
+	return t.schema + "." + t.name
+}
+
+// parseTableRefs resolves entries of the tables list, which are either bare
+// table names in the default database or qualified as db.table.
+func parseTableRefs(defaultDB string, tables []string) []tableRef {
+	refs := make([]tableRef, 0, len(tables))
+	for _, table := range tables {
+		if db, name, ok := strings.Cut(table, "."); ok {
+			refs = append(refs, tableRef{schema: db, name: name})
+		} else {
+			refs = append(refs, tableRef{schema: defaultDB, name: table})
+		}
+	}
+	return refs
+}
+
+// validateTables checks that every entry of the tables list names a table,
+// so that a mistyped entry such as "shop." fails instead of matching no
+// table.
+func validateTables(defaultDB string, tables []string) error {
+	for i, ref := range parseTableRefs(defaultDB, tables) {
+		if ref.schema == "" || ref.name == "" {
+			return fmt.Errorf("tables: entry %q does not name a table, want a table of database or db.table", tables[i])
+		}
+	}
+	return nil
+}
+
+// configureTables keeps canal from running mysqldump and narrows its binlog
+// table filter to the configured tables, so that row events of other tables
+// are skipped before their schema is fetched. Tables updated through
+// tables_cache are only filtered by isTableStreamed.
+func (m *MysqlStreamInput) configureTables(cfg *canal.Config) {
+	// The snapshot is read with SELECT statements rather than mysqldump, and
+	// runSnapshot reads its binlog position itself, so canal never runs
+	// mysqldump and the master data of a dump is never needed.
+	cfg.Dump.ExecutionPath = ""
+	cfg.Dump.SkipMasterData = true
+
+	if m.tablesCache != "" {
+		return
+	}
+	for _, ref := range m.tableRefs {
+		cfg.IncludeTableRegex = append(cfg.IncludeTableRegex,
+			"^"+regexp.QuoteMeta(ref.schema)+`\.`+regexp.QuoteMeta(ref.name)+"$")
+	}
+}
+
+const (
+	missingTableError = "error"
+	missingTableWarn  = "warn"
+	missingTableSkip  = "skip"
+)
+
+const (
+	onViewError   = "error"
+	onViewResolve = "resolve"
+)
+
+// isTableStreamed reports whether row changes for the given table should be
+// emitted. Without a tables list every table in the database is streamed.
+func (m *MysqlStreamInput) isTableStreamed(schema, table string) bool {
+	if len(m.tableRefs) == 0 {
+		return schema == m.database
+	}
+	_, ok := m.tableSet[schema+"."+table]
+	return ok
+}
@@ -0,0 +1,68 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+)
+
+func TestConfigureTables(t *testing.T) {
+	tests := []struct {
+		name        string
+		tables      []string
+		tablesCache bool
+		want        []string
+	}{
+		{name: "database"},
+		{name: "single database", tables: []string{"orders"}, want: []string{`^shop\.orders$`}},
+		{name: "several databases", tables: []string{"orders", "billing.invoices"}, want: []string{`^shop\.orders$`, `^billing\.invoices$`}},
+		// Tables read from tables_cache can change while connected.
+		{name: "tables_cache", tables: []string{"orders"}, tablesCache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithDatabase("shop"), WithTables(tt.tables...)}
+			if tt.tablesCache {
+				mgr, _ := newTestResources(t)
+				opts = append(opts, WithResources(mgr), WithTablesCache("cache", "tables", time.Minute, false))
+			}
+			m := newTestInput(t, opts...)
+			cfg := canal.NewDefaultConfig()
+			m.configureTables(cfg)
+			// Snapshots never depend on a mysqldump being installed, or on
+			// the format of its output.
+			if cfg.Dump.ExecutionPath != "" || !cfg.Dump.SkipMasterData {
+				t.Errorf("dump execution path %q skipping master data %v, want no mysqldump", cfg.Dump.ExecutionPath, cfg.Dump.SkipMasterData)
+			}
+			if !reflect.DeepEqual(cfg.IncludeTableRegex, tt.want) {
+				t.Errorf("table filter = %q, want %q", cfg.IncludeTableRegex, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		database string
+		tables   []string
+		wantErr  bool
+	}{
+		{name: "bare and qualified", database: "shop", tables: []string{"orders", "billing.invoices"}},
+		{name: "qualified without database", tables: []string{"shop.orders"}},
+		{name: "bare without database", tables: []string{"orders"}, wantErr: true},
+		{name: "empty", database: "shop", tables: []string{""}, wantErr: true},
+		{name: "no table", database: "shop", tables: []string{"shop."}, wantErr: true},
+		{name: "no database", database: "shop", tables: []string{".orders"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase(tt.database), WithTables(tt.tables...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMysqlStreamInput error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// SetTables replaces the set of streamed tables, named as in the tables field,
+// while the binlog stream stays connected. The new set takes effect at the
+// next transaction boundary of the stream, where tables no longer in it stop
+// being emitted and, with snapshot_added_tables, tables added to it are
+// snapshotted the way Resnapshot does. on_missing_table and on_view are not
+// applied to the new set: tables that do not exist yet are streamed once
+// created. When the configured tables span several databases and
+// tables_cache is not set, the replication client itself filters out tables
+// that were not configured, so tables added in that case are only streamed
+// after a reconnect.
+func (m *MysqlStreamInput) SetTables(tables ...string) error {
+	if len(tables) == 0 {
+		return errors.New("set tables requires at least one table")
+	}
+	if err := validateTables(m.database, tables); err != nil {
+		return err
+	}
+	refs := parseTableRefs(m.database, tables)
+
+	// Only the latest set matters, so a pending one is replaced.
+	select {
+	case <-m.tableUpdates:
+	default:
+	}
+	select {
+	case m.tableUpdates <- refs:
+		m.logger.Infof("Queued update of the streamed tables to %d tables", len(refs))
+		return nil
+	default:
+		return errors.New("a concurrent update of the streamed tables is pending")
+	}
+}
+
+// applyTableUpdates switches to a set of streamed tables queued by SetTables.
+// It must only be called from the binlog event handlers, between
+// transactions, with the event lock held.
+func (m *MysqlStreamInput) applyTableUpdates() error {
+	var refs []tableRef
+	select {
+	case refs = <-m.tableUpdates:
+	default:
+		return nil
+	}
+
+	tableSet := make(map[string]struct{}, len(refs))
+	var added []tableRef
+	for _, ref := range refs {
+		tableSet[ref.key()] = struct{}{}
+		if !m.isTableStreamed(ref.schema, ref.name) {
+			added = append(added, ref)
+		}
+	}
+	for _, key := range m.StreamedTables() {
+		if _, ok := tableSet[key]; !ok {
+			m.markTableInactive(key)
+		}
+	}
+	m.tableRefs, m.tableSet = refs, tableSet
+	m.logger.Infof("Streaming %d tables, %d of them added", len(refs), len(added))
+
+	if !m.snapshotAddedTables || len(added) == 0 {
+		return nil
+	}
+	existing := added[:0]
+	for _, ref := range added {
+		if _, err := m.canal.GetTable(ref.schema, ref.name); errors.Is(err, schema.ErrTableNotExist) {
+			m.logger.Warnf("Not snapshotting added table %s as it does not exist", ref.key())
+			continue
+		}
+		existing = append(existing, ref)
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	// The added tables are snapshotted alongside the stream from right here,
+	// so that their changes from now on are held back until their rows are
+	// sent.
+	if run := m.resnapshotting; run != nil {
+		m.eventMu.Unlock()
+		<-run.done
+		m.eventMu.Lock()
+	}
+	m.startResnapshot(existing)
+	return nil
+}
+
+// pollTableUpdates periodically reads a comma separated list of tables from
+// the tables cache, and updates the streamed tables whenever it changes. The
+// key is kept, as it holds the tables to stream rather than a request.
+func (m *MysqlStreamInput) pollTableUpdates(ctx context.Context) {
+	ticker := time.NewTicker(m.tablesPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var value []byte
+		var cacheErr error
+		err := m.resources.AccessCache(ctx, m.tablesCache, func(c service.Cache) {
+			value, cacheErr = c.Get(ctx, m.tablesKey)
+		})
+		if err == nil {
+			err = cacheErr
+		}
+		if errors.Is(err, service.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			m.logger.Warnf("Failed to read the streamed tables: %v", err)
+			continue
+		}
+		if string(value) == m.tablesValue {
+			continue
+		}
+
+		var tables []string
+		for _, table := range strings.Split(string(value), ",") {
+			if table = strings.TrimSpace(table); table != "" {
+				tables = append(tables, table)
+			}
+		}
+		if err := m.SetTables(tables...); err != nil {
+			m.logger.Errorf("Ignoring streamed tables %q: %v", value, err)
+		}
+		m.tablesValue = string(value)
+	}
+}
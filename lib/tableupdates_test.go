@@ -0,0 +1,79 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestSetTablesAtTransactionBoundary(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithTables("orders"))
+	h := EventHandler{m: m}
+	m.binlogFile = "mysql-bin.000001"
+	tables := map[string]*schema.Table{}
+	for _, name := range []string{"orders", "users"} {
+		tables[name] = &schema.Table{Schema: "shop", Name: name, Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	}
+
+	pos := uint32(100)
+	// transaction writes a row to each table and commits, returning the
+	// tables of the changes emitted.
+	transaction := func() []string {
+		t.Helper()
+		for _, name := range []string{"orders", "users"} {
+			pos += 100
+			err := h.OnRow(&canal.RowsEvent{
+				Table:  tables[name],
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(pos)}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		pos += 100
+		header := &replication.EventHeader{Timestamp: 1, LogPos: pos, EventType: replication.XID_EVENT}
+		if err := h.OnXID(header, mysql.Position{Name: "mysql-bin.000001", Pos: pos}); err != nil {
+			t.Fatal(err)
+		}
+		var emitted []string
+		for len(m.stream) > 0 {
+			if msg := <-m.stream; msg.table != nil {
+				emitted = append(emitted, msg.table.Name)
+			}
+		}
+		return emitted
+	}
+
+	steps := []struct {
+		name   string
+		tables []string
+		want   []string
+	}{
+		// The new set takes effect once the transaction in progress commits.
+		{name: "table added", tables: []string{"orders", "users"}, want: []string{"orders"}},
+		{name: "after the added table commits", want: []string{"orders", "users"}},
+		{name: "table removed", tables: []string{"users"}, want: []string{"orders", "users"}},
+		{name: "after the removed table commits", want: []string{"users"}},
+	}
+	for _, step := range steps {
+		if step.tables != nil {
+			if err := m.SetTables(step.tables...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got := transaction()
+		if len(got) != len(step.want) {
+			t.Fatalf("%s: emitted changes to %v, want %v", step.name, got, step.want)
+		}
+		for i := range got {
+			if got[i] != step.want[i] {
+				t.Fatalf("%s: emitted changes to %v, want %v", step.name, got, step.want)
+			}
+		}
+	}
+}
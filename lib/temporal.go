@@ -0,0 +1,127 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+const (
+	temporalNative      = "native"
+	temporalRFC3339     = "rfc3339"
+	temporalUnixMillis  = "unix_millis"
+	temporalMySQLString = "mysql_string"
+)
+
+// mysqlDateTimeLayout parses DATETIME and TIMESTAMP values as MySQL formats
+// them, with up to microsecond fractions.
+const mysqlDateTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// convertTemporal converts a DATE, DATETIME, TIMESTAMP, TIME or YEAR value,
+// already normalized and with zero dates resolved, to temporal_output. With
+// any output but native the binlog and snapshot hand over every such value as
+// the string MySQL formats it as, with TIMESTAMP values in UTC.
+func (m *MysqlStreamInput) convertTemporal(col schema.TableColumn, value interface{}) (interface{}, error) {
+	if m.temporalOutput == temporalNative || value == nil {
+		return value, nil
+	}
+
+	if isYear(col) {
+		year, ok := value.(int64)
+		if ok && m.temporalOutput == temporalMySQLString {
+			return fmt.Sprintf("%04d", year), nil
+		}
+		return value, nil
+	}
+
+	s, ok := temporalString(value)
+	if !ok {
+		return value, nil
+	}
+	if col.Type == schema.TYPE_TIME {
+		if m.temporalOutput != temporalUnixMillis {
+			return s, nil
+		}
+		ms, err := timeMillis(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse column %s: %w", col.Name, err)
+		}
+		return ms, nil
+	}
+
+	// Zero dates kept by zero_date_behavior string have no time to convert.
+	if m.temporalOutput == temporalMySQLString || strings.HasPrefix(s, "0000-00-00") {
+		return s, nil
+	}
+	layout := mysqlDateTimeLayout
+	if col.Type == schema.TYPE_DATE {
+		layout = mysqlDateFormat
+	}
+	t, err := time.ParseInLocation(layout, s, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parse column %s: %w", col.Name, err)
+	}
+
+	if m.temporalOutput == temporalUnixMillis {
+		return t.UnixMilli(), nil
+	}
+	if col.Type == schema.TYPE_DATE {
+		return t.Format(mysqlDateFormat), nil
+	}
+	return t.Format(rfc3339Layout(timePrecision(col.RawType))), nil
+}
+
+func temporalString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
+
+// rfc3339Layout returns the RFC 3339 layout of UTC times with precision
+// fractional second digits, which unlike time.RFC3339Nano keeps trailing
+// zeros so that every value of a column has the same length.
+func rfc3339Layout(precision int) string {
+	if precision == 0 {
+		return "2006-01-02T15:04:05Z07:00"
+	}
+	return "2006-01-02T15:04:05." + strings.Repeat("0", precision) + "Z07:00"
+}
+
+// timeMillis converts a TIME value formatted as [-]HH:MM:SS[.fraction] to a
+// signed number of milliseconds.
+func timeMillis(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	hms, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	parts := strings.Split(hms, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	var ms int64
+	for i, unit := range []int64{3600000, 60000, 1000} {
+		n, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		ms += n * unit
+	}
+	if frac != "" {
+		frac = (frac + "00")[:3]
+		n, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		ms += n
+	}
+	if negative {
+		ms = -ms
+	}
+	return ms, nil
+}
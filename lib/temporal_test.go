@@ -0,0 +1,45 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestConvertTemporal(t *testing.T) {
+	datetime := schema.TableColumn{Name: "created_at", Type: schema.TYPE_DATETIME, RawType: "datetime(3)"}
+	date := schema.TableColumn{Name: "birthday", Type: schema.TYPE_DATE, RawType: "date"}
+	duration := schema.TableColumn{Name: "opens", Type: schema.TYPE_TIME, RawType: "time"}
+	year := schema.TableColumn{Name: "built", Type: schema.TYPE_NUMBER, RawType: "year"}
+
+	tests := []struct {
+		output string
+		col    schema.TableColumn
+		value  any
+		want   any
+	}{
+		{output: temporalNative, col: datetime, value: "2024-05-01 10:00:00.250", want: "2024-05-01 10:00:00.250"},
+		{output: temporalRFC3339, col: datetime, value: "2024-05-01 10:00:00.25", want: "2024-05-01T10:00:00.250Z"},
+		{output: temporalRFC3339, col: date, value: "2024-05-01", want: "2024-05-01"},
+		{output: temporalRFC3339, col: duration, value: "-01:30:00", want: "-01:30:00"},
+		{output: temporalRFC3339, col: datetime, value: "0000-00-00 00:00:00", want: "0000-00-00 00:00:00"},
+		{output: temporalUnixMillis, col: datetime, value: "2024-05-01 10:00:00.25", want: int64(1714557600250)},
+		{output: temporalUnixMillis, col: date, value: []byte("1970-01-02"), want: int64(86400000)},
+		{output: temporalUnixMillis, col: duration, value: "-01:30:00", want: int64(-5400000)},
+		{output: temporalUnixMillis, col: year, value: int64(2024), want: int64(2024)},
+		{output: temporalMySQLString, col: datetime, value: "2024-05-01 10:00:00.250", want: "2024-05-01 10:00:00.250"},
+		{output: temporalMySQLString, col: year, value: int64(24), want: "0024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output+" "+tt.col.RawType, func(t *testing.T) {
+			m := newTestInput(t, WithTemporalOutput(tt.output))
+			got, err := m.convertValue(tt.col, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("convertValue(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
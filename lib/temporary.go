@@ -0,0 +1,65 @@
+package mongodb_stream_benthos
+
+import (
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// temporaryTables tracks the temporary tables created by statements in the
+// binlog, by db.table and the id of the session that created them. Temporary
+// tables only exist in their session, where they shadow any persistent table
+// of the same name, and are only written to the binlog when statements are
+// logged as statements, so their DDL must not be mistaken for changes of
+// the persistent tables.
+type temporaryTables map[string]map[uint32]struct{}
+
+// temporaryDDL reports whether a statement acts on a temporary table, and
+// tracks the temporary tables it creates and drops. Statements naming a
+// table the same session created as a temporary table act on it, as do
+// statements with the TEMPORARY keyword.
+func (m *MysqlStreamInput) temporaryDDL(stmt ast.StmtNode, queryEvent *replication.QueryEvent) bool {
+	session := queryEvent.SlaveProxyID
+	switch t := stmt.(type) {
+	case *ast.CreateTableStmt:
+		if t.TemporaryKeyword == ast.TemporaryNone {
+			return m.temporaryTables.has(ddlTableRef(t.Table, queryEvent), session)
+		}
+		key := ddlTableRef(t.Table, queryEvent).key()
+		if m.temporaryTables[key] == nil {
+			m.temporaryTables[key] = map[uint32]struct{}{}
+		}
+		m.temporaryTables[key][session] = struct{}{}
+		return true
+	case *ast.DropTableStmt:
+		temporary := t.TemporaryKeyword != ast.TemporaryNone
+		for _, name := range t.Tables {
+			ref := ddlTableRef(name, queryEvent)
+			if !m.temporaryTables.has(ref, session) {
+				continue
+			}
+			// A DROP TABLE drops the temporary table of the session rather
+			// than the persistent table it shadows.
+			temporary = true
+			delete(m.temporaryTables[ref.key()], session)
+			if len(m.temporaryTables[ref.key()]) == 0 {
+				delete(m.temporaryTables, ref.key())
+			}
+		}
+		return temporary
+	}
+
+	for _, ref := range ddlTables(stmt, queryEvent) {
+		if m.temporaryTables.has(ref, session) {
+			return true
+		}
+	}
+	if t, ok := stmt.(*ast.TruncateTableStmt); ok {
+		return m.temporaryTables.has(ddlTableRef(t.Table, queryEvent), session)
+	}
+	return false
+}
+
+func (t temporaryTables) has(ref tableRef, session uint32) bool {
+	_, ok := t[ref.key()][session]
+	return ok
+}
@@ -0,0 +1,40 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+func TestTemporaryTableStatementsSkipped(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithTruncateEvents(true))
+	m.binlogFile = "mysql-bin.000002"
+
+	steps := []struct {
+		name    string
+		session uint32
+		query   string
+		want    bool
+	}{
+		{name: "create temporary", session: 7, query: "CREATE TEMPORARY TABLE orders (id INT)"},
+		{name: "truncate in the creating session", session: 7, query: "TRUNCATE TABLE orders"},
+		{name: "truncate in another session", session: 8, query: "TRUNCATE TABLE orders", want: true},
+		{name: "drop in the creating session", session: 7, query: "DROP TABLE orders"},
+		{name: "truncate after the drop", session: 7, query: "TRUNCATE TABLE orders", want: true},
+	}
+	for i, step := range steps {
+		pos := uint32(100 * (i + 1))
+		query := &replication.QueryEvent{SlaveProxyID: step.session, Schema: []byte("shop"), Query: []byte(step.query)}
+		err := m.OnDDL(&replication.EventHeader{Timestamp: 1, LogPos: pos}, mysql.Position{Name: m.binlogFile, Pos: pos}, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emitted := len(m.stream) == 1; emitted != step.want {
+			t.Fatalf("%s: emitted = %v, want %v", step.name, emitted, step.want)
+		}
+		if step.want {
+			<-m.stream
+		}
+	}
+}
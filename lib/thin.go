@@ -0,0 +1,74 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// isThin reports whether a table is one of thin_tables, whose row changes
+// carry only their primary key.
+func (m *MysqlStreamInput) isThin(table *schema.Table) bool {
+	_, ok := m.thinTables[tableRef{schema: table.Schema, name: table.Name}.key()]
+	return ok
+}
+
+// emitThin emits the row at index i of e with only its primary key columns,
+// without converting any other value. An update that changed the primary key
+// carries the key it had before in its before section, where absent tells
+// the columns its images left out.
+func (m *MysqlStreamInput) emitThin(e *canal.RowsEvent, absent absentColumns, i, row int, position mysql.Position, seq uint64) error {
+	data, err := m.primaryKeyData(e.Table, e.Rows[i])
+	if err != nil {
+		return m.rowError(e, i, position, err)
+	}
+	var previous map[string]any
+	if e.Action == canal.UpdateAction && pkChanged(e.Table, e.Rows[i-1], e.Rows[i], absent, i) {
+		if previous, err = m.primaryKeyData(e.Table, e.Rows[i-1]); err != nil {
+			return m.rowError(e, i, position, err)
+		}
+	}
+
+	key, err := m.messageKey(e.Table, e.Rows[i])
+	if err != nil {
+		return m.rowError(e, i, position, err)
+	}
+
+	return m.emit(e, StreamMessage{
+		Table:           e.Table.Name,
+		Event:           e.Action,
+		Data:            data,
+		Previous:        previous,
+		table:           e.Table,
+		traceparent:     m.upstreamTraceparent,
+		idempotencyKey:  m.idempotencyKey(e, e.Rows[i], row),
+		position:        position,
+		globalSeq:       seq,
+		autoIncrementID: autoIncrementID(e, e.Rows[i]),
+		partition:       m.partition(e.Table, e.Rows[i]),
+		key:             key,
+	})
+}
+
+// primaryKeyData returns the converted primary key values of a row image,
+// which is empty for tables without a primary key.
+func (m *MysqlStreamInput) primaryKeyData(table *schema.Table, row []any) (map[string]any, error) {
+	data := make(map[string]any, len(table.PKColumns))
+	for _, i := range table.PKColumns {
+		if i >= len(row) || i >= len(table.Columns) {
+			return nil, fmt.Errorf("row of %s has %d values but its schema has %d columns", table, len(row), len(table.Columns))
+		}
+		col := table.Columns[i]
+		v, err := m.convertValue(col, row[i])
+		if err != nil {
+			return nil, err
+		}
+		if m.valuesAsStrings {
+			v = m.stringValue(v)
+		}
+		data[col.Name] = v
+	}
+	return data, nil
+}
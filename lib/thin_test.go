@@ -0,0 +1,57 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestThinTables(t *testing.T) {
+	table := &schema.Table{
+		Schema: "shop",
+		Name:   "sessions",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "token", Type: schema.TYPE_STRING},
+		},
+		PKColumns: []int{0},
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		rows   [][]any
+		want   map[string]any
+	}{
+		{name: "insert", action: canal.InsertAction, rows: [][]any{{int64(1), "secret"}}, want: map[string]any{"id": int64(1)}},
+		{name: "update", action: canal.UpdateAction, rows: [][]any{{int64(1), "old"}, {int64(1), "new"}}, want: map[string]any{"id": int64(1)}},
+		{
+			name:   "primary key update",
+			action: canal.UpdateAction,
+			rows:   [][]any{{int64(1), "old"}, {int64(2), "old"}},
+			want:   map[string]any{"id": int64(2), "_before": map[string]any{"id": int64(1)}},
+		},
+		{name: "delete", action: canal.DeleteAction, rows: [][]any{{int64(1), "secret"}}, want: map[string]any{"id": int64(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithThinTables([]string{"sessions"}), WithStructuredMessages(true))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: tt.action,
+				Rows:   tt.rows,
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, body := readStructured(t, m)
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+		})
+	}
+}
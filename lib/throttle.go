@@ -0,0 +1,86 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+)
+
+// snapshotMaxPause bounds how long a table read is paused at a time, as the
+// server drops a streaming read whose client does not read for longer than
+// its net_write_timeout, 60 seconds by default.
+const snapshotMaxPause = 30 * time.Second
+
+// snapshotThrottle pauses the snapshot while the server has more than
+// snapshot_max_source_threads threads running, checked at most once per
+// interval on a control connection.
+type snapshotThrottle struct {
+	lastCheck time.Time
+}
+
+// throttleSnapshot is called between snapshot rows. When the server is over
+// the limit it waits in steps of snapshot_throttle_interval until it is not,
+// for at most snapshotMaxPause before rows are read again until the next
+// check.
+func (m *MysqlStreamInput) throttleSnapshot() error {
+	if m.snapshotMaxSourceThreads <= 0 || time.Since(m.throttle.lastCheck) < m.snapshotThrottleInterval {
+		return nil
+	}
+
+	var paused time.Duration
+	for {
+		m.throttle.lastCheck = time.Now()
+		running, err := m.threadsRunning()
+		if err != nil {
+			m.logger.Warnf("Failed to check Threads_running, not throttling the snapshot: %v", err)
+			return nil
+		}
+		if running <= int64(m.snapshotMaxSourceThreads) {
+			if paused > 0 {
+				m.logger.Debugf("Resuming the snapshot after pausing for %v", paused)
+				m.metrics.snapshotThrottled.Timing(paused.Nanoseconds())
+			}
+			return nil
+		}
+		if paused >= snapshotMaxPause {
+			m.logger.Warnf("Resuming the snapshot after pausing for %v with %d threads running", paused, running)
+			m.metrics.snapshotThrottled.Timing(paused.Nanoseconds())
+			return nil
+		}
+		if paused == 0 {
+			m.logger.Debugf("Pausing the snapshot with %d threads running, more than snapshot_max_source_threads %d", running, m.snapshotMaxSourceThreads)
+			m.metrics.snapshotThrottles.Incr(1)
+		}
+
+		if !m.snapshotDeadline.IsZero() && time.Now().Add(m.snapshotThrottleInterval).After(m.snapshotDeadline) {
+			return errSnapshotTimeout
+		}
+		select {
+		case <-time.After(m.snapshotThrottleInterval):
+		case <-m.closed:
+			return nil
+		}
+		paused += m.snapshotThrottleInterval
+	}
+}
+
+// threadsRunning returns the Threads_running status of the server.
+func (m *MysqlStreamInput) threadsRunning() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var running int64
+	err := m.withMetadataConn(ctx, func(conn *client.Conn) error {
+		res, err := conn.Execute("SHOW GLOBAL STATUS LIKE 'Threads_running'")
+		if err != nil {
+			return err
+		}
+		if res.RowNumber() == 0 {
+			return nil
+		}
+		running, err = res.GetIntByName(0, "Value")
+		return err
+	})
+	return running, err
+}
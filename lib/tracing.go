@@ -0,0 +1,60 @@
+package mongodb_stream_benthos
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/Jeffail/benthos/v3/public/service"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/opentracing/opentracing-go"
+)
+
+// sqlcommenter style trace context, e.g. /*traceparent='00-...-01'*/
+var traceparentComment = regexp.MustCompile(`traceparent='([^']+)'`)
+
+// OnRowsQueryEvent captures trace context left by instrumented clients in the
+// original statement so it can be attached to the row events that follow.
+// Requires binlog_rows_query_log_events=ON on the source.
+func (m *MysqlStreamInput) OnRowsQueryEvent(e *replication.RowsQueryEvent) error {
+	m.upstreamTraceparent = ""
+	if match := traceparentComment.FindSubmatch(e.Query); match != nil {
+		m.upstreamTraceparent = string(match[1])
+	}
+	return nil
+}
+
+// startSpan creates a span for a message read from the stream, continuing the
+// originating write's trace when one was captured, and attaches it to the
+// message so that downstream Benthos components trace as its children.
+func (m *MysqlStreamInput) startSpan(ctx context.Context, streamMessage StreamMessage, msg *service.Message) *service.Message {
+	if streamMessage.traceparent != "" {
+		msg.MetaSet("traceparent", streamMessage.traceparent)
+	}
+	if !m.tracing {
+		return msg
+	}
+
+	tracer := opentracing.GlobalTracer()
+
+	var opts []opentracing.StartSpanOption
+	if streamMessage.traceparent != "" {
+		carrier := opentracing.TextMapCarrier{"traceparent": streamMessage.traceparent}
+		if parent, err := tracer.Extract(opentracing.TextMap, carrier); err == nil {
+			opts = append(opts, opentracing.ChildOf(parent))
+		}
+	}
+
+	span := tracer.StartSpan("mysql_stream_read", opts...)
+	span.SetTag("db.instance", m.database)
+	span.SetTag("mysql.table", streamMessage.Table)
+	span.SetTag("mysql.event", streamMessage.Event)
+
+	carrier := opentracing.TextMapCarrier{}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, carrier); err == nil {
+		for k, v := range carrier {
+			msg.MetaSet(k, v)
+		}
+	}
+
+	return msg.WithContext(opentracing.ContextWithSpan(ctx, span))
+}
@@ -0,0 +1,268 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+const (
+	outputFormatRow         = "row"
+	outputFormatTransaction = "transaction"
+
+	largeTransactionError = "error"
+	largeTransactionSplit = "split"
+
+	transactionEvent = "transaction"
+	emptyTxEvent     = "empty_tx"
+)
+
+// emit sends a row change downstream, or buffers it until commit when
+// messages are grouped per transaction or committed_only is enabled. Snapshot
+// rows carry no binlog header and are never part of a transaction, so they
+// are always sent directly.
+func (m *MysqlStreamInput) emit(e *canal.RowsEvent, msg StreamMessage) error {
+	if name, ok := m.actionNames[msg.Event]; ok {
+		msg.Event = name
+	}
+	if e.Header == nil {
+		return m.emitSnapshotRow(msg)
+	}
+
+	m.txEmitted++
+	if m.includeCommitTimestamp {
+		msg.commitTime = m.txCommitTime(e.Header)
+	}
+	msg.op = actionOp(e.Action)
+	msg.snapshot = snapshotFalse
+	msg.timestamp = e.Header.Timestamp
+	if m.includeServerIdentity {
+		msg.serverID = e.Header.ServerID
+		msg.originServerUUID = gtidSourceUUID(m.gtid)
+	}
+	if msg.version = msg.globalSeq; msg.version == 0 {
+		msg.version = m.compressedVersion(msg.position)
+	}
+	if m.outputFormat != outputFormatTransaction && !m.committedOnly {
+		return m.send(msg)
+	}
+
+	if len(m.txBuffer) >= m.maxTransactionRows {
+		// Splitting a transaction buffered by committed_only would emit
+		// changes before their commit, so it always fails the stream.
+		if m.committedOnly || m.onLargeTransaction != largeTransactionSplit {
+			return fmt.Errorf("transaction exceeded max_transaction_rows (%d)", m.maxTransactionRows)
+		}
+		if err := m.flushTransaction(e.Header, nil, true); err != nil {
+			return err
+		}
+	}
+
+	m.txBuffer = append(m.txBuffer, msg)
+	return nil
+}
+
+// flushTransaction emits all buffered row changes as a single message. A
+// transaction split by on_large_transaction is emitted as partial messages
+// followed by a final one, all but the first marked as a continuation.
+func (m *MysqlStreamInput) flushTransaction(header *replication.EventHeader, pos *mysql.Position, partial bool) error {
+	if len(m.txBuffer) == 0 {
+		return nil
+	}
+
+	data := map[string]any{
+		"changes":      m.txBuffer,
+		"timestamp":    header.Timestamp,
+		"partial":      partial,
+		"continuation": m.txParts > 0,
+	}
+	if partial {
+		m.txParts++
+	} else {
+		m.txParts = 0
+	}
+	if pos != nil {
+		data["binlog_file"] = pos.Name
+		data["binlog_pos"] = pos.Pos
+	}
+
+	msg := StreamMessage{
+		Event: transactionEvent,
+		Data:  data,
+	}
+	if m.includeCommitTimestamp {
+		msg.commitTime = m.txCommitTime(header)
+	}
+	if pos != nil && pos.Pos != 0 {
+		msg.position = *pos
+		msg.globalSeq = globalSeq(*pos, 0, 1)
+	}
+
+	m.txBuffer = nil
+	return m.send(msg)
+}
+
+// releaseTransaction sends the row changes buffered by committed_only once
+// their transaction has committed.
+func (m *MysqlStreamInput) releaseTransaction() error {
+	for _, msg := range m.txBuffer {
+		if err := m.send(msg); err != nil {
+			return err
+		}
+	}
+	m.txBuffer = nil
+	return nil
+}
+
+// beginTransaction records that the stream is inside a transaction, between
+// its GTID or first rows event and its commit.
+//
+// A transaction is only resumable from its start. When the replication
+// client reconnects by itself in the middle of a transaction it resumes by
+// GTID from the start of the transaction, so the part of it read before is
+// read again, and by file and position right after the last event read,
+// announcing the position with a rotate event. The synced position is not
+// moved to such a position, and a stream that stops inside a transaction
+// resumes from the last synced position, so that a transaction is always
+// read again from its start and never emitted in part.
+func (m *MysqlStreamInput) beginTransaction() {
+	m.inTransaction = true
+}
+
+// restartTransaction drops the changes buffered for a transaction that is
+// read again from its start.
+func (m *MysqlStreamInput) restartTransaction() {
+	if !m.inTransaction {
+		return
+	}
+	if len(m.txBuffer) > 0 || m.txParts > 0 {
+		m.logger.Warnf("Transaction is read again from its start after a reconnect, dropping the %d changes buffered for it", len(m.txBuffer))
+	}
+	m.txBuffer, m.txParts, m.txEmitted = nil, 0, 0
+	m.inTransaction = false
+}
+
+func (m *MysqlStreamInput) OnXID(header *replication.EventHeader, nextPos mysql.Position) error {
+	m.inTransaction = false
+	if nextPos.Pos == 0 {
+		// The XID event of a compressed transaction has no position.
+		nextPos = m.txEnd
+	}
+	m.txEnd, m.txVersion = mysql.Position{}, mysql.Position{}
+	gtid := m.gtid
+	m.upstreamTraceparent = ""
+	m.gtid, m.txRowsEvents = "", 0
+	if m.committedOnly && m.outputFormat != outputFormatTransaction {
+		// The changes are released before the synced position moves past
+		// their transaction, as they are only resumable from its start.
+		if err := m.releaseTransaction(); err != nil {
+			return err
+		}
+	}
+	m.trackSyncedPosition(nextPos)
+	if m.outputFormat == outputFormatTransaction {
+		if err := m.flushTransaction(header, &nextPos, false); err != nil {
+			return err
+		}
+	}
+	if m.emitEmptyTxMarkers && m.txEmitted == 0 {
+		if err := m.emitEmptyTx(header, nextPos, gtid); err != nil {
+			return err
+		}
+	}
+	m.txEmitted = 0
+	m.commitTime = time.Time{}
+	return m.applyTableUpdates()
+}
+
+// OnPosSynced ends the transactions on non-transactional tables, such as
+// MyISAM, which end with a COMMIT or ROLLBACK query rather than an XID event,
+// and emits the caught_up event once the stream reaches the end of the
+// binlog.
+func (m *MysqlStreamInput) OnPosSynced(header *replication.EventHeader, pos mysql.Position, set mysql.GTIDSet, force bool) error {
+	if header != nil && header.EventType == replication.QUERY_EVENT {
+		m.inTransaction = false
+		if m.committedOnly && m.outputFormat != outputFormatTransaction {
+			if err := m.endQueryTransaction(header, pos); err != nil {
+				return err
+			}
+		}
+	}
+	if pos.Pos == 0 {
+		pos = m.syncedPosition
+	}
+	return m.checkCaughtUp(pos)
+}
+
+// endQueryTransaction releases the row changes buffered by committed_only
+// when the query event ending at pos commits their transaction, and drops
+// them when it rolls it back. Canal syncs the position after every query it
+// parses, such as the BEGIN of a transaction, which leaves them buffered.
+// Canal does not pass these queries to its handler, so the query is read back
+// from the server; when that fails the stream fails rather than release
+// changes that may have been rolled back.
+func (m *MysqlStreamInput) endQueryTransaction(header *replication.EventHeader, pos mysql.Position) error {
+	if len(m.txBuffer) == 0 {
+		return nil
+	}
+	query, err := m.binlogQuery(mysql.Position{Name: pos.Name, Pos: header.LogPos - header.EventSize})
+	if err != nil {
+		return fmt.Errorf("committed_only: reading the query event at %s: %w", pos, err)
+	}
+	stmts, _, err := m.txParser.Parse(query, "", "")
+	if err != nil {
+		// Transaction control statements always parse.
+		return nil
+	}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.CommitStmt:
+			return m.releaseTransaction()
+		case *ast.RollbackStmt:
+			// A rollback to a savepoint does not end the transaction.
+			if stmt.SavepointName == "" {
+				m.txBuffer = nil
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// binlogQuery returns the query of the query event starting at pos, as SHOW
+// BINLOG EVENTS shows it, which the replication privileges allow.
+func (m *MysqlStreamInput) binlogQuery(pos mysql.Position) (string, error) {
+	res, err := m.canal.Execute(fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' FROM %d LIMIT 1", mysql.Escape(pos.Name), pos.Pos))
+	if err != nil {
+		return "", err
+	}
+	if res.RowNumber() == 0 {
+		return "", errors.New("no event at that position")
+	}
+	return res.GetStringByName(0, "Info")
+}
+
+// emitEmptyTx marks the commit of a transaction that produced no row changes
+// for the streamed tables, so that downstream watermarks can advance past it.
+func (m *MysqlStreamInput) emitEmptyTx(header *replication.EventHeader, pos mysql.Position, gtid string) error {
+	data := map[string]any{
+		"binlog_file": pos.Name,
+		"binlog_pos":  pos.Pos,
+		"timestamp":   header.Timestamp,
+	}
+	if gtid != "" {
+		data["gtid"] = gtid
+	}
+
+	msg := StreamMessage{Event: emptyTxEvent, Data: data}
+	if pos.Pos != 0 {
+		msg.position = pos
+		msg.globalSeq = globalSeq(pos, 0, 1)
+	}
+	return m.send(msg)
+}
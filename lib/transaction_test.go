@@ -0,0 +1,443 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestTransactionReadAgainAfterReconnect(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(outputFormatTransaction))
+	m.binlogFile = "mysql-bin.000001"
+	start := mysql.Position{Name: "mysql-bin.000001", Pos: 1000}
+	m.trackSyncedPosition(start)
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	gtid := func() {
+		t.Helper()
+		header := &replication.EventHeader{Timestamp: 1, EventType: replication.GTID_EVENT, LogPos: 1079, EventSize: 79}
+		if err := m.OnGTID(header, &replication.GTIDEvent{SID: make([]byte, 16), GNO: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert := func(id int64, pos uint32) {
+		t.Helper()
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: canal.InsertAction,
+			Rows:   [][]any{{id}},
+			Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gtid()
+	insert(1, 1200)
+	// The replication client reconnects and announces the position right
+	// after the last event it read.
+	err := m.OnRotate(&replication.EventHeader{Timestamp: 1, EventType: replication.ROTATE_EVENT},
+		&replication.RotateEvent{NextLogName: []byte("mysql-bin.000001"), Position: 1200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.syncedPosition != start {
+		t.Errorf("synced position after a rotate inside the transaction = %v, want %v", m.syncedPosition, start)
+	}
+
+	// Resuming by GTID reads the whole transaction again.
+	gtid()
+	if len(m.txBuffer) != 0 {
+		t.Fatalf("%d changes still buffered after the transaction started again, want 0", len(m.txBuffer))
+	}
+	insert(1, 1200)
+	insert(2, 1300)
+	commit := mysql.Position{Name: "mysql-bin.000001", Pos: 1400}
+	if err := m.OnXID(&replication.EventHeader{Timestamp: 1, LogPos: 1400, EventType: replication.XID_EVENT}, commit); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.stream) != 1 {
+		t.Fatalf("%d messages sent, want the one transaction", len(m.stream))
+	}
+	msg := <-m.stream
+	changes, _ := msg.Data["changes"].([]StreamMessage)
+	if len(changes) != 2 || changes[0].Data["id"] != int64(1) || changes[1].Data["id"] != int64(2) {
+		t.Errorf("transaction changes = %v, want ids 1 and 2 once each", changes)
+	}
+	if m.inTransaction {
+		t.Error("still inside a transaction after its commit")
+	}
+}
+
+// binlogEvents serves the query event ending at end with query, as SHOW
+// BINLOG EVENTS shows it, from a server c is connected to.
+func binlogEvents(t *testing.T, c *canal.Canal, end mysql.Position, size uint32, query string) {
+	t.Helper()
+	rs, err := mysql.BuildSimpleTextResultset([]string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"},
+		[][]any{{end.Name, uint64(end.Pos - size), "Query", uint64(1), uint64(end.Pos), query}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSchemaServer(t, schemaServer{results: map[string]*mysql.Resultset{
+		fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' FROM %d LIMIT 1", end.Name, end.Pos-size): rs,
+	}})
+	connectCanal(t, c, addr)
+}
+
+func TestCommittedOnly(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	end := mysql.Position{Name: "mysql-bin.000001", Pos: 1400}
+
+	xid := func(t *testing.T, m *MysqlStreamInput) error {
+		return m.OnXID(&replication.EventHeader{Timestamp: 1, LogPos: end.Pos, EventType: replication.XID_EVENT}, end)
+	}
+	query := func(q string) func(t *testing.T, m *MysqlStreamInput) error {
+		return func(t *testing.T, m *MysqlStreamInput) error {
+			binlogEvents(t, m.canal, end, 60, q)
+			return m.OnPosSynced(&replication.EventHeader{Timestamp: 1, LogPos: end.Pos, EventSize: 60, EventType: replication.QUERY_EVENT}, end, nil, false)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		end          func(t *testing.T, m *MysqlStreamInput) error
+		wantSent     int
+		wantBuffered int
+	}{
+		{name: "xid", end: xid, wantSent: 2},
+		{name: "commit", end: query("COMMIT"), wantSent: 2},
+		{name: "rollback", end: query("ROLLBACK"), wantSent: 0},
+		{name: "rollback to savepoint", end: query("ROLLBACK TO sp"), wantBuffered: 2},
+		{name: "begin", end: query("BEGIN"), wantBuffered: 2},
+		{name: "use and commit", end: query("use `shop`; COMMIT"), wantSent: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithCommittedOnly(true))
+			m.canal = newTestCanal(t, table)
+			m.binlogFile = "mysql-bin.000001"
+			for i, pos := range []uint32{1200, 1300} {
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(i + 1)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(m.stream) != 0 {
+				t.Fatalf("%d changes sent before the transaction ended, want 0", len(m.stream))
+			}
+
+			if err := tt.end(t, m); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.stream) != tt.wantSent {
+				t.Fatalf("%d changes sent, want %d", len(m.stream), tt.wantSent)
+			}
+			for i := 0; i < tt.wantSent; i++ {
+				if msg := <-m.stream; msg.Data["id"] != int64(i+1) {
+					t.Errorf("change %d has id %v, want %d", i, msg.Data["id"], i+1)
+				}
+			}
+			if len(m.txBuffer) != tt.wantBuffered {
+				t.Errorf("%d changes still buffered, want %d", len(m.txBuffer), tt.wantBuffered)
+			}
+		})
+	}
+}
+
+func TestCommittedOnlyUnreadableQuery(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	m := newTestInput(t, WithDatabase("shop"), WithCommittedOnly(true))
+	m.canal = newTestCanal(t, table)
+	connectCanal(t, m.canal, startSchemaServer(t, schemaServer{}))
+	m.binlogFile = "mysql-bin.000001"
+	err := m.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: canal.InsertAction,
+		Rows:   [][]any{{int64(1)}},
+		Header: &replication.EventHeader{Timestamp: 1, LogPos: 1200},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A query that cannot be told from a ROLLBACK does not release the
+	// changes.
+	end := mysql.Position{Name: "mysql-bin.000001", Pos: 1400}
+	if err := m.OnPosSynced(&replication.EventHeader{Timestamp: 1, LogPos: end.Pos, EventSize: 60, EventType: replication.QUERY_EVENT}, end, nil, false); err == nil {
+		t.Fatal("unreadable query event did not fail the stream")
+	}
+	if len(m.stream) != 0 {
+		t.Errorf("%d changes sent, want 0", len(m.stream))
+	}
+}
+
+func TestCommittedOnlyOverflow(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	for _, policy := range []string{largeTransactionSplit, largeTransactionError} {
+		t.Run(policy, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithCommittedOnly(true), WithMaxTransactionRows(2), WithOnLargeTransaction(policy))
+			var err error
+			for i := 0; i < 3 && err == nil; i++ {
+				err = m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(i + 1)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: uint32(1200 + 100*i)},
+				})
+			}
+			if err == nil {
+				t.Fatal("transaction over max_transaction_rows did not fail the stream")
+			}
+			// No change is emitted before its commit, even when split.
+			if len(m.stream) != 0 {
+				t.Errorf("%d uncommitted changes sent, want 0", len(m.stream))
+			}
+		})
+	}
+}
+
+func TestTransactionEnvelope(t *testing.T) {
+	m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(outputFormatTransaction))
+	m.binlogFile = "mysql-bin.000003"
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	for i, action := range []string{canal.InsertAction, canal.DeleteAction} {
+		err := m.OnRow(&canal.RowsEvent{
+			Table:  table,
+			Action: action,
+			Rows:   [][]any{{int64(i + 1)}},
+			Header: &replication.EventHeader{Timestamp: 1700000000, LogPos: uint32(1200 + 100*i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(m.stream) != 0 {
+		t.Fatalf("%d messages sent before the commit, want 0", len(m.stream))
+	}
+	commit := mysql.Position{Name: "mysql-bin.000003", Pos: 1400}
+	if err := m.OnXID(&replication.EventHeader{Timestamp: 1700000001, LogPos: 1400, EventType: replication.XID_EVENT}, commit); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.stream) != 1 {
+		t.Fatalf("%d messages sent, want the one transaction", len(m.stream))
+	}
+	msg := <-m.stream
+	if msg.Event != transactionEvent {
+		t.Errorf("event = %q, want %q", msg.Event, transactionEvent)
+	}
+	changes, _ := msg.Data["changes"].([]StreamMessage)
+	if len(changes) != 2 || changes[0].Event != canal.InsertAction || changes[1].Event != canal.DeleteAction {
+		t.Errorf("transaction changes = %v, want the insert and the delete", changes)
+	}
+	want := map[string]any{
+		"binlog_file":  "mysql-bin.000003",
+		"binlog_pos":   uint32(1400),
+		"timestamp":    uint32(1700000001),
+		"partial":      false,
+		"continuation": false,
+	}
+	for field, v := range want {
+		if msg.Data[field] != v {
+			t.Errorf("%s = %v (%T), want %v (%T)", field, msg.Data[field], msg.Data[field], v, v)
+		}
+	}
+	if wantSeq := uint64(3)<<32 | 1400; msg.globalSeq != wantSeq {
+		t.Errorf("global_seq = %d, want %d", msg.globalSeq, wantSeq)
+	}
+	if msg.position != commit {
+		t.Errorf("position = %v, want %v", msg.position, commit)
+	}
+}
+
+func TestLargeTransaction(t *testing.T) {
+	const limit = 4
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	// insert reads a transaction of 2.5 times the limit, returning the first
+	// error.
+	insert := func(m *MysqlStreamInput) error {
+		for i := 0; i < limit*5/2; i++ {
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(i + 1)}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: uint32(1000 + 100*i)},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	commit := func(m *MysqlStreamInput, pos uint32) error {
+		return m.OnXID(&replication.EventHeader{Timestamp: 1, LogPos: pos, EventType: replication.XID_EVENT}, mysql.Position{Name: "mysql-bin.000001", Pos: pos})
+	}
+
+	t.Run(largeTransactionSplit, func(t *testing.T) {
+		m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(outputFormatTransaction), WithMaxTransactionRows(limit), WithOnLargeTransaction(largeTransactionSplit))
+		m.binlogFile = "mysql-bin.000001"
+		if err := insert(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := commit(m, 2100); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []struct {
+			changes             int
+			partial, continuing bool
+		}{
+			{changes: limit, partial: true},
+			{changes: limit, partial: true, continuing: true},
+			{changes: limit / 2, continuing: true},
+		}
+		if len(m.stream) != len(want) {
+			t.Fatalf("%d messages sent, want %d", len(m.stream), len(want))
+		}
+		id := int64(1)
+		for i, w := range want {
+			msg := <-m.stream
+			changes, _ := msg.Data["changes"].([]StreamMessage)
+			if len(changes) != w.changes || msg.Data["partial"] != w.partial || msg.Data["continuation"] != w.continuing {
+				t.Errorf("part %d has %d changes, partial %v, continuation %v, want %d, %v, %v",
+					i, len(changes), msg.Data["partial"], msg.Data["continuation"], w.changes, w.partial, w.continuing)
+			}
+			for _, change := range changes {
+				if change.Data["id"] != id {
+					t.Errorf("part %d holds id %v, want %d", i, change.Data["id"], id)
+				}
+				id++
+			}
+		}
+		if m.txParts != 0 {
+			t.Errorf("%d parts counted after the commit, want 0", m.txParts)
+		}
+
+		// The next transaction starts over.
+		if err := insert(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := commit(m, 3100); err != nil {
+			t.Fatal(err)
+		}
+		if msg := <-m.stream; msg.Data["continuation"] != false {
+			t.Error("first part of the next transaction is marked as a continuation")
+		}
+	})
+
+	t.Run(largeTransactionError, func(t *testing.T) {
+		m := newTestInput(t, WithDatabase("shop"), WithOutputFormat(outputFormatTransaction), WithMaxTransactionRows(limit), WithOnLargeTransaction(largeTransactionError))
+		m.binlogFile = "mysql-bin.000001"
+		if err := insert(m); err == nil {
+			t.Fatal("transaction over max_transaction_rows did not fail the stream")
+		}
+		if len(m.stream) != 0 {
+			t.Errorf("%d messages sent, want 0", len(m.stream))
+		}
+	})
+}
+
+func TestActionNames(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}, {Name: "qty"}}, PKColumns: []int{0}}
+	m := newTestInput(t, WithDatabase("shop"), WithActionNames(map[string]string{canal.InsertAction: "created", canal.DeleteAction: "removed"}))
+
+	events := []*canal.RowsEvent{
+		{Action: canal.InsertAction, Rows: [][]any{{int64(1), int64(1)}}},
+		{Action: canal.UpdateAction, Rows: [][]any{{int64(1), int64(1)}, {int64(1), int64(2)}}},
+		{Action: canal.DeleteAction, Rows: [][]any{{int64(1), int64(2)}}},
+	}
+	for i, e := range events {
+		e.Table = table
+		e.Header = &replication.EventHeader{Timestamp: 1, LogPos: uint32(100 * (i + 1))}
+		if err := m.OnRow(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Actions without a name keep theirs, and the op codes follow the
+	// actions rather than their names.
+	want := []struct{ event, op string }{{"created", opCreate}, {canal.UpdateAction, opUpdate}, {"removed", opDelete}}
+	if len(m.stream) != len(want) {
+		t.Fatalf("%d messages sent, want %d", len(m.stream), len(want))
+	}
+	for _, w := range want {
+		if msg := <-m.stream; msg.Event != w.event || msg.op != w.op {
+			t.Errorf("event %q with op %q, want %q with op %q", msg.Event, msg.op, w.event, w.op)
+		}
+	}
+}
+
+func TestEmitEmptyTxMarkers(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+	commit := mysql.Position{Name: "mysql-bin.000001", Pos: 1400}
+
+	tests := []struct {
+		name      string
+		rows      bool
+		wantEvent string
+	}{
+		{name: "no changes", wantEvent: emptyTxEvent},
+		{name: "changes", rows: true, wantEvent: canal.InsertAction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithEmptyTxMarkers(true))
+			m.binlogFile = "mysql-bin.000001"
+			header := &replication.EventHeader{Timestamp: 1, EventType: replication.GTID_EVENT, LogPos: 1079, EventSize: 79}
+			if err := m.OnGTID(header, &replication.GTIDEvent{SID: make([]byte, 16), GNO: 5}); err != nil {
+				t.Fatal(err)
+			}
+			gtid := m.gtid
+			if tt.rows {
+				err := m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: canal.InsertAction,
+					Rows:   [][]any{{int64(1)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: 1200},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.OnXID(&replication.EventHeader{Timestamp: 1700000000, LogPos: 1400, EventType: replication.XID_EVENT}, commit); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(m.stream) != 1 {
+				t.Fatalf("%d messages sent, want 1", len(m.stream))
+			}
+			msg := <-m.stream
+			if msg.Event != tt.wantEvent {
+				t.Fatalf("event = %q, want %q", msg.Event, tt.wantEvent)
+			}
+			if !tt.rows {
+				want := map[string]any{"binlog_file": commit.Name, "binlog_pos": commit.Pos, "timestamp": uint32(1700000000), "gtid": gtid}
+				for field, v := range want {
+					if msg.Data[field] != v {
+						t.Errorf("%s = %v, want %v", field, msg.Data[field], v)
+					}
+				}
+				if gtid == "" || msg.position != commit || msg.globalSeq != uint64(1)<<32|1400 {
+					t.Errorf("marker at %v with global_seq %d and gtid %q, want %v", msg.position, msg.globalSeq, gtid, commit)
+				}
+			}
+			if m.txEmitted != 0 {
+				t.Errorf("%d changes counted after the commit, want 0", m.txEmitted)
+			}
+		})
+	}
+}
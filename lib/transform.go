@@ -0,0 +1,69 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// columnTransform converts a single non-NULL column value.
+type columnTransform func(any) any
+
+var columnTransformFuncs = map[string]columnTransform{
+	"lowercase":       transformText(strings.ToLower),
+	"uppercase":       transformText(strings.ToUpper),
+	"trim":            transformText(strings.TrimSpace),
+	"to_epoch_millis": transformEpochMillis,
+}
+
+// parseColumnTransforms resolves the column_transforms config into a lookup
+// of table key to column name to transform.
+func parseColumnTransforms(database string, transforms map[string]string) (map[string]map[string]columnTransform, error) {
+	parsed := make(map[string]map[string]columnTransform, len(transforms))
+	for key, name := range transforms {
+		i := strings.LastIndexByte(key, '.')
+		if i <= 0 || i == len(key)-1 {
+			return nil, fmt.Errorf("column_transforms: key %s must be in the form table.column", key)
+		}
+		fn, ok := columnTransformFuncs[name]
+		if !ok {
+			return nil, fmt.Errorf("column_transforms: unknown transform %s for %s", name, key)
+		}
+
+		table := parseTableRefs(database, []string{key[:i]})[0].key()
+		if parsed[table] == nil {
+			parsed[table] = map[string]columnTransform{}
+		}
+		parsed[table][key[i+1:]] = fn
+	}
+	return parsed, nil
+}
+
+func transformText(fn func(string) string) columnTransform {
+	return func(v any) any {
+		switch v := v.(type) {
+		case string:
+			return fn(v)
+		case []byte:
+			return fn(string(v))
+		}
+		return v
+	}
+}
+
+func transformEpochMillis(v any) any {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UnixMilli()
+	case string:
+		if parsed, err := time.ParseInLocation(mysql.TimeFormat, t, time.Local); err == nil {
+			return parsed.UnixMilli()
+		}
+		if parsed, err := time.Parse(mysqlDateFormat, t); err == nil {
+			return parsed.UnixMilli()
+		}
+	}
+	return v
+}
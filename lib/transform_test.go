@@ -0,0 +1,69 @@
+package mongodb_stream_benthos
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestColumnTransforms(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "users", Columns: []schema.TableColumn{{Name: "id"}, {Name: "value"}}, PKColumns: []int{0}}
+	created := time.Date(2024, 5, 1, 13, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		transform string
+		value     any
+		want      any
+	}{
+		{name: "lowercase", transform: "lowercase", value: "Ann@Example.COM", want: "ann@example.com"},
+		{name: "lowercase bytes", transform: "lowercase", value: []byte("ANN"), want: "ann"},
+		{name: "uppercase", transform: "uppercase", value: "eu", want: "EU"},
+		{name: "trim", transform: "trim", value: "  paid \n", want: "paid"},
+		{name: "to_epoch_millis time", transform: "to_epoch_millis", value: created, want: created.UnixMilli()},
+		{name: "to_epoch_millis date", transform: "to_epoch_millis", value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).UnixMilli()},
+		{name: "to_epoch_millis zero date", transform: "to_epoch_millis", value: "0000-00-00", want: "0000-00-00"},
+		{name: "null", transform: "lowercase", value: nil, want: nil},
+		{name: "number", transform: "trim", value: int64(5), want: int64(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithColumnTransforms(map[string]string{"users.value": tt.transform}))
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(1), tt.value}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 500},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg := <-m.stream; msg.Data["value"] != tt.want {
+				t.Errorf("value = %v (%T), want %v (%T)", msg.Data["value"], msg.Data["value"], tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnTransformsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		transforms map[string]string
+		wantErr    string
+	}{
+		{name: "unknown transform", transforms: map[string]string{"users.email": "reverse"}, wantErr: "unknown transform reverse"},
+		{name: "no column", transforms: map[string]string{"users": "lowercase"}, wantErr: "table.column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMysqlStreamInput(WithAddr("127.0.0.1:3306"), WithDatabase("shop"), WithColumnTransforms(tt.transforms))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewMysqlStreamInput error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
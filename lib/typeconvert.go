@@ -1,7 +1,11 @@
 package mongodb_stream_benthos
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -87,3 +91,284 @@ func convertData(col schema.TableColumn, value interface{}) interface{} {
 
 	return value
 }
+
+const (
+	zeroDateNull   = "null"
+	zeroDateEpoch  = "epoch"
+	zeroDateString = "string"
+	zeroDateError  = "error"
+)
+
+// isTemporal reports whether a column holds a date or a date and time.
+func isTemporal(col schema.TableColumn) bool {
+	switch col.Type {
+	case schema.TYPE_DATE, schema.TYPE_DATETIME, schema.TYPE_TIMESTAMP:
+		return true
+	}
+	return false
+}
+
+// convertZeroDate applies the zero date behavior to values such as
+// 0000-00-00 or 0000-00-00 00:00:00, which have no time.Time equivalent.
+// Other values are returned unchanged.
+func convertZeroDate(col schema.TableColumn, value interface{}, behavior string) (interface{}, error) {
+	v, ok := value.(string)
+	if !ok || !strings.HasPrefix(v, "0000-00-00") {
+		return value, nil
+	}
+
+	switch behavior {
+	case zeroDateNull:
+		return nil, nil
+	case zeroDateEpoch:
+		epoch := time.Unix(0, 0).UTC()
+		if col.Type == schema.TYPE_DATE {
+			return epoch.Format(mysqlDateFormat), nil
+		}
+		return epoch.Format(mysql.TimeFormat), nil
+	case zeroDateError:
+		return nil, fmt.Errorf("zero date in column %s", col.Name)
+	}
+	return value, nil
+}
+
+const (
+	unknownTypeRawBytes = "raw_bytes"
+	unknownTypeBase64   = "base64"
+	unknownTypeString   = "string"
+	unknownTypeSkip     = "skip"
+	unknownTypeError    = "error"
+)
+
+// errSkipColumn is returned by convertValue when a column should be left out
+// of the emitted message.
+var errSkipColumn = errors.New("skip column")
+
+// knownStringRawTypes are the column types that schema.TYPE_STRING is meant
+// for. The schema package also falls back to TYPE_STRING for any type it does
+// not recognize.
+var knownStringRawTypes = []string{
+	"char", "varchar",
+	"tinytext", "text", "mediumtext", "longtext",
+	"tinyblob", "blob", "mediumblob", "longblob",
+}
+
+// isUnknownType reports whether a column's type is not recognized.
+func isUnknownType(col schema.TableColumn) bool {
+	if col.Type != schema.TYPE_STRING || isSpatial(col) {
+		return false
+	}
+	raw := strings.ToLower(col.RawType)
+	for _, t := range knownStringRawTypes {
+		if strings.HasPrefix(raw, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// convertUnknownType applies the unknown type behavior, logging each unknown
+// type once.
+func (m *MysqlStreamInput) convertUnknownType(col schema.TableColumn, value interface{}) (interface{}, error) {
+	return m.convertUnknownValue(col, value, col.RawType)
+}
+
+// convertUnknownValue applies unknown_type_behavior to a value of the named
+// unknown type, which is either the type of its column or, for spatial
+// columns, the geometry type of the value.
+func (m *MysqlStreamInput) convertUnknownValue(col schema.TableColumn, value interface{}, unknownType string) (interface{}, error) {
+	if _, ok := m.unknownTypesLogged[unknownType]; !ok {
+		m.unknownTypesLogged[unknownType] = struct{}{}
+		m.logger.Warnf("Column %s has unknown type %s, applying unknown_type_behavior %s", col.Name, unknownType, m.unknownTypeBehavior)
+	}
+
+	switch m.unknownTypeBehavior {
+	case unknownTypeSkip:
+		return nil, errSkipColumn
+	case unknownTypeError:
+		return nil, fmt.Errorf("column %s has unknown type %s", col.Name, unknownType)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	switch m.unknownTypeBehavior {
+	case unknownTypeBase64:
+		switch v := value.(type) {
+		case []byte:
+			return base64.StdEncoding.EncodeToString(v), nil
+		case string:
+			return base64.StdEncoding.EncodeToString([]byte(v)), nil
+		}
+	case unknownTypeString:
+		switch v := value.(type) {
+		case []byte:
+			return string(v), nil
+		case string:
+			return v, nil
+		}
+		return fmt.Sprint(value), nil
+	}
+	return value, nil
+}
+
+// convertValue normalizes a column value before it is emitted.
+func (m *MysqlStreamInput) convertValue(col schema.TableColumn, value interface{}) (interface{}, error) {
+	if isUnknownType(col) {
+		return m.convertUnknownType(col, value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	if isTemporal(col) {
+		v, err := convertZeroDate(col, value, m.zeroDateBehavior)
+		if err != nil {
+			return nil, err
+		}
+		return m.convertTemporal(col, v)
+	}
+	if isSpatial(col) {
+		return m.convertSpatial(col, value)
+	}
+	if col.Type == schema.TYPE_TIME {
+		return m.convertTemporal(col, convertTime(col, value))
+	}
+	if isYear(col) {
+		v, err := convertZeroYear(col, convertYear(value), m.zeroDateBehavior)
+		if err != nil {
+			return nil, err
+		}
+		return m.convertTemporal(col, v)
+	}
+	if m.tinyint1AsBool && isTinyint1(col) {
+		return convertBool(value), nil
+	}
+	if s, ok := value.(string); ok && isBinary(col) {
+		// The binlog carries BINARY and VARBINARY values as strings but BLOB
+		// values as bytes, which snapshots read for every binary column.
+		return []byte(s), nil
+	}
+	return value, nil
+}
+
+// isBinary reports whether a column holds binary strings: BINARY, VARBINARY
+// or one of the BLOB types.
+func isBinary(col schema.TableColumn) bool {
+	return col.Type == schema.TYPE_BINARY || strings.Contains(strings.ToLower(col.RawType), "blob")
+}
+
+// isYear reports whether a column is a YEAR, which the schema package treats
+// as a number.
+func isYear(col schema.TableColumn) bool {
+	return strings.HasPrefix(strings.ToLower(col.RawType), "year")
+}
+
+// convertYear converts a YEAR value, an int from the binlog or an integer or
+// string from snapshot queries, to an int64 such as 2024, or 0 for the zero
+// year. Values of unexpected types are returned unchanged.
+func convertYear(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case []byte:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// convertZeroYear applies the zero date behavior to the zero year, which is
+// emitted as 0 by default and as 1970 for the epoch.
+func convertZeroYear(col schema.TableColumn, value interface{}, behavior string) (interface{}, error) {
+	if value != int64(0) {
+		return value, nil
+	}
+	switch behavior {
+	case zeroDateNull:
+		return nil, nil
+	case zeroDateEpoch:
+		return int64(1970), nil
+	case zeroDateError:
+		return nil, fmt.Errorf("zero year in column %s", col.Name)
+	}
+	return value, nil
+}
+
+// convertTime formats a TIME value as [-]HH:MM:SS, followed by as many
+// fractional second digits as the column's precision. The binlog leaves out
+// fractional seconds that are zero, while snapshot queries always include
+// them, so this keeps both consistent. Values of unexpected types are returned
+// unchanged.
+func convertTime(col schema.TableColumn, value interface{}) interface{} {
+	var v string
+	switch value := value.(type) {
+	case string:
+		v = value
+	case []byte:
+		v = string(value)
+	default:
+		return value
+	}
+
+	hms, frac, _ := strings.Cut(v, ".")
+	precision := timePrecision(col.RawType)
+	if precision == 0 {
+		return hms
+	}
+	if len(frac) > precision {
+		frac = frac[:precision]
+	}
+	return hms + "." + frac + strings.Repeat("0", precision-len(frac))
+}
+
+// timePrecision returns the fractional seconds precision of a column type
+// such as time(3).
+func timePrecision(rawType string) int {
+	_, rest, ok := strings.Cut(rawType, "(")
+	if !ok {
+		return 0
+	}
+	digits, _, _ := strings.Cut(rest, ")")
+	precision, err := strconv.Atoi(digits)
+	if err != nil || precision < 0 || precision > 6 {
+		return 0
+	}
+	return precision
+}
+
+// isTinyint1 reports whether a column is a TINYINT(1), which is what MySQL
+// stores BOOLEAN columns as.
+func isTinyint1(col schema.TableColumn) bool {
+	return strings.HasPrefix(strings.ToLower(col.RawType), "tinyint(1)")
+}
+
+// convertBool converts a TINYINT(1) value to a boolean. Values of unexpected
+// types are returned unchanged.
+func convertBool(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int8:
+		return v != 0
+	case uint8:
+		return v != 0
+	case int64:
+		return v != 0
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n != 0
+		}
+	case []byte:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return n != 0
+		}
+	}
+	return value
+}
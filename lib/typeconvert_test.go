@@ -0,0 +1,138 @@
+package mongodb_stream_benthos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestConvertValueTinyint1(t *testing.T) {
+	flag := schema.TableColumn{Name: "active", Type: schema.TYPE_NUMBER, RawType: "tinyint(1)"}
+	unsignedFlag := schema.TableColumn{Name: "active", Type: schema.TYPE_NUMBER, RawType: "tinyint(1) unsigned", IsUnsigned: true}
+	small := schema.TableColumn{Name: "level", Type: schema.TYPE_NUMBER, RawType: "tinyint(4)"}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		col     schema.TableColumn
+		value   any
+		want    any
+	}{
+		{name: "bool true", enabled: true, col: flag, value: int8(1), want: true},
+		{name: "bool false", enabled: true, col: flag, value: int8(0), want: false},
+		{name: "bool out of range", enabled: true, col: flag, value: int8(-3), want: true},
+		{name: "unsigned bool", enabled: true, col: unsignedFlag, value: uint8(1), want: true},
+		{name: "snapshot text", enabled: true, col: flag, value: []byte("0"), want: false},
+		{name: "bool null", enabled: true, col: flag, value: nil, want: nil},
+		{name: "numeric tinyint", enabled: true, col: small, value: int8(3), want: int8(3)},
+		{name: "disabled", col: flag, value: int8(1), want: int8(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithTinyint1AsBool(tt.enabled))
+			got, err := m.convertValue(tt.col, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("convertValue(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertValueYearAndTime(t *testing.T) {
+	year := schema.TableColumn{Name: "built", Type: schema.TYPE_NUMBER, RawType: "year"}
+	seconds := schema.TableColumn{Name: "opens", Type: schema.TYPE_TIME, RawType: "time"}
+	millis := schema.TableColumn{Name: "opens", Type: schema.TYPE_TIME, RawType: "time(3)"}
+
+	tests := []struct {
+		name     string
+		behavior string
+		col      schema.TableColumn
+		value    any
+		want     any
+		wantErr  bool
+	}{
+		{name: "binlog year", col: year, value: int(2024), want: int64(2024)},
+		{name: "snapshot year", col: year, value: []byte("1999"), want: int64(1999)},
+		{name: "zero year", behavior: zeroDateString, col: year, value: int(0), want: int64(0)},
+		{name: "zero year null", behavior: zeroDateNull, col: year, value: int(0), want: nil},
+		{name: "zero year epoch", behavior: zeroDateEpoch, col: year, value: int(0), want: int64(1970)},
+		{name: "zero year error", behavior: zeroDateError, col: year, value: int(0), wantErr: true},
+		{name: "time", col: seconds, value: "12:30:00", want: "12:30:00"},
+		{name: "negative time", col: seconds, value: "-838:59:59", want: "-838:59:59"},
+		{name: "binlog fractional time", col: millis, value: "12:30:00.5", want: "12:30:00.500"},
+		{name: "binlog whole second time", col: millis, value: "12:30:00", want: "12:30:00.000"},
+		{name: "snapshot fractional time", col: millis, value: []byte("12:30:00.250"), want: "12:30:00.250"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			behavior := tt.behavior
+			if behavior == "" {
+				behavior = zeroDateString
+			}
+			m := newTestInput(t, WithZeroDateBehavior(behavior))
+			got, err := m.convertValue(tt.col, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("convertValue(%v) = %#v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("convertValue(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownTypeBehavior(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "items", PKColumns: []int{0}, Columns: []schema.TableColumn{
+		{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int"},
+		// The schema package falls back to TYPE_STRING for types it does not
+		// recognize.
+		{Name: "embedding", Type: schema.TYPE_STRING, RawType: "vector(2)"},
+	}}
+	raw := []byte{0x00, 0x00, 0x80, 0x3f}
+
+	tests := []struct {
+		behavior string
+		value    any
+		want     any
+		wantSkip bool
+		wantErr  bool
+	}{
+		{behavior: unknownTypeRawBytes, value: raw, want: raw},
+		{behavior: unknownTypeBase64, value: raw, want: "AACAPw=="},
+		{behavior: unknownTypeString, value: []byte("[1,2]"), want: "[1,2]"},
+		{behavior: unknownTypeSkip, value: raw, wantSkip: true},
+		{behavior: unknownTypeError, value: raw, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			m := newTestInput(t, WithUnknownTypeBehavior(tt.behavior))
+			data, err := m.rowData(table, []any{int64(1), tt.value}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rowData error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, ok := data["embedding"]
+			if ok == tt.wantSkip {
+				t.Fatalf("embedding present = %v, want %v", ok, !tt.wantSkip)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("embedding = %#v, want %#v", got, tt.want)
+			}
+			if data["id"] != int64(1) {
+				t.Errorf("id = %v, want 1", data["id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mongodb_stream_benthos
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+// typeHintCacheSize bounds the number of tables whose type hints are kept.
+const typeHintCacheSize = 1024
+
+type typeHints struct {
+	table *schema.Table
+	json  string
+}
+
+// defaultTypeHint maps a column to a generic type name that downstream
+// systems can map to their own types.
+func defaultTypeHint(col schema.TableColumn) string {
+	if isSpatial(col) {
+		return "geography"
+	}
+	switch col.Type {
+	case schema.TYPE_NUMBER, schema.TYPE_MEDIUM_INT, schema.TYPE_BIT:
+		return "integer"
+	case schema.TYPE_FLOAT:
+		return "float"
+	case schema.TYPE_DECIMAL:
+		return "numeric"
+	case schema.TYPE_DATETIME, schema.TYPE_TIMESTAMP:
+		return "timestamp"
+	case schema.TYPE_DATE:
+		return "date"
+	case schema.TYPE_TIME:
+		return "time"
+	case schema.TYPE_JSON:
+		return "json"
+	case schema.TYPE_POINT:
+		return "bytes"
+	}
+	if isBinary(col) {
+		return "bytes"
+	}
+	return "string"
+}
+
+// typeHint returns the type_mapping override for a column, matched against
+// its full type such as tinyint(1) before its base type such as tinyint, or
+// the default hint when there is none.
+func (m *MysqlStreamInput) typeHint(col schema.TableColumn) string {
+	raw := strings.ToLower(col.RawType)
+	if hint, ok := m.typeMapping[raw]; ok {
+		return hint
+	}
+	base := raw
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	if hint, ok := m.typeMapping[base]; ok {
+		return hint
+	}
+	if m.tinyint1AsBool && isTinyint1(col) {
+		return "boolean"
+	}
+	return defaultTypeHint(col)
+}
+
+// typeHintsFor returns the JSON encoded column to type hint mapping of a
+// table. Hints are cached per table and recomputed when its layout changes.
+func (m *MysqlStreamInput) typeHintsFor(table *schema.Table) (string, error) {
+	key := tableRef{schema: table.Schema, name: table.Name}.key()
+	if cached, ok := m.typeHintCache.get(key); ok && cached.table == table {
+		return cached.json, nil
+	}
+
+	hints := make(map[string]string, len(table.Columns))
+	for _, col := range table.Columns {
+		hints[col.Name] = m.typeHint(col)
+	}
+	b, err := json.Marshal(hints)
+	if err != nil {
+		return "", err
+	}
+	m.typeHintCache.put(key, typeHints{table: table, json: string(b)})
+	return string(b), nil
+}
@@ -0,0 +1,28 @@
+package mongodb_stream_benthos
+
+import (
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+)
+
+const (
+	unknownActionSkip  = "skip"
+	unknownActionError = "error"
+)
+
+// unknownAction applies on_unknown_action to a rows event whose action is not
+// an insert, update or delete, which canal does not produce today but may in
+// later versions. Skipped events are counted and logged once per action.
+func (m *MysqlStreamInput) unknownAction(e *canal.RowsEvent) error {
+	if m.onUnknownAction == unknownActionError {
+		return fmt.Errorf("invalid rows action %q on %s", e.Action, e.Table)
+	}
+
+	m.metrics.unknownActionsSkipped.Incr(1, e.Action)
+	if _, ok := m.unknownActionLogged[e.Action]; !ok {
+		m.unknownActionLogged[e.Action] = struct{}{}
+		m.logger.Warnf("Skipping rows events with unknown action %q, first seen on %s", e.Action, e.Table)
+	}
+	return nil
+}
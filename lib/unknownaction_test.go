@@ -0,0 +1,52 @@
+package mongodb_stream_benthos
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+func TestOnUnknownAction(t *testing.T) {
+	table := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id"}}, PKColumns: []int{0}}
+
+	tests := []struct {
+		policy  string
+		wantErr bool
+	}{
+		{policy: unknownActionSkip},
+		{policy: unknownActionError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithOnUnknownAction(tt.policy))
+			rows := func(action string, pos uint32) error {
+				return m.OnRow(&canal.RowsEvent{
+					Table:  table,
+					Action: action,
+					Rows:   [][]any{{int64(pos)}},
+					Header: &replication.EventHeader{Timestamp: 1, LogPos: pos},
+				})
+			}
+
+			err := rows("upsert", 100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OnRow error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(m.stream) != 0 {
+				t.Fatalf("sent %d messages for an unknown action", len(m.stream))
+			}
+			if tt.wantErr {
+				return
+			}
+			// The stream carries on with the changes after it.
+			if err := rows(canal.InsertAction, 200); err != nil {
+				t.Fatal(err)
+			}
+			if msg := <-m.stream; msg.Event != canal.InsertAction {
+				t.Errorf("sent %s, want the insert after the skipped action", msg.Event)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/schema"
+)
+
+const (
+	unresolvedSchemaError      = "error"
+	unresolvedSchemaPositional = "positional"
+)
+
+// unresolvedTable is the placeholder cached for a table whose schema could
+// not be resolved, and when resolving it was last attempted.
+type unresolvedTable struct {
+	table *schema.Table
+	tried time.Time
+}
+
+// degradeSchema caches a placeholder without columns for a table whose schema
+// could not be resolved when on_unresolved_schema is positional, so that
+// canal hands its rows events to OnRow instead of failing the stream.
+func (m *MysqlStreamInput) degradeSchema(c *canal.Canal, db, table string, err error) {
+	if m.onUnresolvedSchema != unresolvedSchemaPositional || err == nil ||
+		errors.Is(err, schema.ErrTableNotExist) || errors.Is(err, canal.ErrExcludedTable) {
+		return
+	}
+	if !m.isTableStreamed(db, table) {
+		return
+	}
+
+	key := tableRef{schema: db, name: table}.key()
+	if _, ok := m.unresolvedTables[key]; !ok {
+		m.logger.Warnf("Emitting rows of %s with positional columns as its schema could not be resolved: %v", key, err)
+	}
+	placeholder := &schema.Table{Schema: db, Name: table}
+	c.SetTableCache([]byte(db), []byte(table), placeholder)
+	m.unresolvedTables[key] = unresolvedTable{table: placeholder, tried: time.Now()}
+}
+
+// resolveConfiguredSchemas resolves the schemas of the configured tables
+// before streaming starts on a new canal, so that tables whose schema cannot
+// be resolved are streamed with positional columns rather than failing their
+// first event.
+func (m *MysqlStreamInput) resolveConfiguredSchemas(c *canal.Canal) {
+	if m.onUnresolvedSchema != unresolvedSchemaPositional {
+		return
+	}
+	m.unresolvedTables = map[string]unresolvedTable{}
+	for _, ref := range m.tableRefs {
+		_, err := c.GetTable(ref.schema, ref.name)
+		m.degradeSchema(c, ref.schema, ref.name, err)
+	}
+}
+
+// resolvePlaceholder retries resolving the schema of a table streamed with
+// positional columns, and returns the resolved table once it matches the
+// column count of the event.
+func (m *MysqlStreamInput) resolvePlaceholder(e *canal.RowsEvent) (*schema.Table, bool) {
+	key := e.Table.String()
+	unresolved, ok := m.unresolvedTables[key]
+	if !ok || unresolved.table != e.Table {
+		return e.Table, false
+	}
+	if time.Since(unresolved.tried) < canal.UnknownTableRetryPeriod {
+		return e.Table, true
+	}
+
+	m.canal.ClearTableCache([]byte(e.Table.Schema), []byte(e.Table.Name))
+	table, err := m.canal.GetTable(e.Table.Schema, e.Table.Name)
+	if err != nil || (len(e.Rows) > 0 && len(e.Rows[0]) != len(table.Columns)) {
+		if err == nil {
+			err = fmt.Errorf("schema has %d columns but rows have %d", len(table.Columns), len(e.Rows[0]))
+		}
+		m.degradeSchema(m.canal, e.Table.Schema, e.Table.Name, err)
+		return m.unresolvedTables[key].table, true
+	}
+	delete(m.unresolvedTables, key)
+	m.logger.Infof("Resolved the schema of %s, emitting its rows with column names again", key)
+	return table, false
+}
+
+// hasWiderRows reports whether an event has rows with more values than its
+// table has columns, as rows written after an ALTER TABLE ... ADD COLUMN do
+// while the cached schema still lacks the new columns.
+func hasWiderRows(e *canal.RowsEvent) bool {
+	for _, row := range e.Rows {
+		if len(row) > len(e.Table.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshStaleSchema reads the schema of a table again for an event whose rows
+// have more values than its cached schema has columns, and returns it once it
+// fits the rows. When the current schema does not fit them either, such as
+// after further DDL, the rows are emitted with positional columns under
+// on_unresolved_schema positional and fail the stream otherwise.
+func (m *MysqlStreamInput) refreshStaleSchema(e *canal.RowsEvent) (*schema.Table, bool, error) {
+	key := e.Table.String()
+	m.logger.Warnf("Rows of %s have more values than its schema has columns, reading its schema again", key)
+	m.canal.ClearTableCache([]byte(e.Table.Schema), []byte(e.Table.Name))
+	table, err := m.canal.GetTable(e.Table.Schema, e.Table.Name)
+	if err == nil && rowsFit(table, e.Rows) {
+		m.logger.Infof("Refreshed the stale schema of %s", key)
+		return table, false, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("rows have %d values but its current schema has %d columns", len(e.Rows[0]), len(table.Columns))
+	}
+	m.degradeSchema(m.canal, e.Table.Schema, e.Table.Name, err)
+	unresolved, ok := m.unresolvedTables[key]
+	if !ok {
+		return nil, false, fmt.Errorf("schema of %s does not match its rows: %w", key, err)
+	}
+	return unresolved.table, true, nil
+}
+
+// rowsFit reports whether every row has a value per column of table, or per
+// column but the virtual generated ones that the server may leave out.
+func rowsFit(table *schema.Table, rows [][]any) bool {
+	virtual := 0
+	for _, col := range table.Columns {
+		if col.IsVirtual {
+			virtual++
+		}
+	}
+	for _, row := range rows {
+		if len(row) != len(table.Columns) && len(row) != len(table.Columns)-virtual {
+			return false
+		}
+	}
+	return true
+}
+
+// positionalData returns the values of a row image keyed by their position,
+// col_0, col_1 and so on, for rows of tables whose schema is unresolved.
+func positionalData(row []any) map[string]any {
+	data := make(map[string]any, len(row))
+	for i, v := range row {
+		data[fmt.Sprintf("col_%d", i)] = v
+	}
+	return data
+}
+
+// emitPositional emits the rows of an event on a table whose schema could
+// not be resolved with positional column names and the schema_unresolved
+// metadata field set.
+func (m *MysqlStreamInput) emitPositional(e *canal.RowsEvent, params ProcessEventParams) error {
+	var position mysql.Position
+	switch {
+	case isCompressed(e.Header):
+		position = mysql.Position{Name: m.binlogFile, Pos: m.syncedPosition.Pos}
+	case e.Header != nil:
+		position = mysql.Position{Name: m.binlogFile, Pos: e.Header.LogPos}
+	}
+
+	rows := len(e.Rows) / params.incrementValue
+	for i := params.initValue; i < len(e.Rows); i += params.incrementValue {
+		row := (i - params.initValue) / params.incrementValue
+		var seq uint64
+		if !isCompressed(e.Header) {
+			seq = globalSeq(position, row, rows)
+		}
+		data := positionalData(e.Rows[i])
+		var previous map[string]any
+		if e.Action == canal.UpdateAction {
+			before := positionalData(e.Rows[i-1])
+			previous = m.previousValues(before, changedFields(before, data))
+		}
+		err := m.emit(e, StreamMessage{
+			Table:            e.Table.Name,
+			Event:            e.Action,
+			Data:             data,
+			Previous:         previous,
+			table:            e.Table,
+			traceparent:      m.upstreamTraceparent,
+			idempotencyKey:   m.idempotencyKey(e, e.Rows[i], row),
+			position:         position,
+			globalSeq:        seq,
+			schemaUnresolved: true,
+			partition:        m.partition(e.Table, e.Rows[i]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
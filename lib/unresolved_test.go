@@ -0,0 +1,252 @@
+package mongodb_stream_benthos
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/go-mysql-org/go-mysql/canal"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/schema"
+	"github.com/go-mysql-org/go-mysql/server"
+)
+
+func TestUnresolvedSchemaPositional(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		wantCached bool
+	}{
+		{name: "positional", policy: unresolvedSchemaPositional, wantCached: true},
+		{name: "error", policy: unresolvedSchemaError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithOnUnresolvedSchema(tt.policy))
+			m.canal = newTestCanal(t)
+			m.unresolvedTables = map[string]unresolvedTable{}
+
+			m.degradeSchema(m.canal, "shop", "orders", errors.New("schema query failed"))
+			unresolved, cached := m.unresolvedTables["shop.orders"]
+			if cached != tt.wantCached {
+				t.Fatalf("placeholder cached = %v, want %v", cached, tt.wantCached)
+			}
+			if !cached {
+				return
+			}
+			// Canal hands the rows of the table to OnRow with the placeholder.
+			table, err := m.canal.GetTable("shop", "orders")
+			if err != nil || table != unresolved.table {
+				t.Fatalf("canal resolved %v, %v, want the placeholder", table, err)
+			}
+
+			err = m.OnRow(&canal.RowsEvent{
+				Table:  table,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(7), "paid"}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, body := readStructured(t, m)
+			if v, _ := msg.MetaGet("schema_unresolved"); v != "true" {
+				t.Errorf("schema_unresolved = %q, want true", v)
+			}
+			if fmt.Sprint(body["col_0"]) != "7" || body["col_1"] != "paid" {
+				t.Errorf("body = %v, want col_0 7 and col_1 paid", body)
+			}
+		})
+	}
+}
+
+// schemaServer is a MySQL server answering the schema queries of canal with
+// the columns and primary key of tables. Other queries are answered from
+// results by their exact text, and the session and locking statements of a
+// snapshot succeed. When queries is set, it records every query received.
+type schemaServer struct {
+	server.EmptyHandler
+	tables  map[string]*schema.Table
+	results map[string]*mysql.Resultset
+	queries *queryLog
+}
+
+var schemaQuery = regexp.MustCompile("^show (full columns|index) from `([^`]+)`\\.`([^`]+)`$")
+
+func (s schemaServer) HandleQuery(query string) (*mysql.Result, error) {
+	if s.queries != nil {
+		s.queries.add(query)
+	}
+	match := schemaQuery.FindStringSubmatch(query)
+	if match == nil {
+		if rs, ok := s.results[query]; ok {
+			return &mysql.Result{Resultset: rs}, nil
+		}
+		for _, statement := range []string{"SET ", "FLUSH ", "START ", "LOCK ", "UNLOCK "} {
+			if strings.HasPrefix(query, statement) {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	table, ok := s.tables[match[2]+"."+match[3]]
+	if !ok {
+		return nil, mysql.NewError(mysql.ER_NO_SUCH_TABLE, "no such table")
+	}
+	var names []string
+	var rows [][]any
+	if match[1] == "full columns" {
+		names = []string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"}
+		for _, col := range table.Columns {
+			rows = append(rows, []any{col.Name, col.RawType, "", "YES", "", nil, "", "", ""})
+		}
+	} else {
+		names = []string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Collation", "Cardinality"}
+		for i, pk := range table.PKColumns {
+			rows = append(rows, []any{table.Name, uint64(0), "PRIMARY", uint64(i + 1), table.Columns[pk].Name, "A", uint64(0)})
+		}
+	}
+	rs, err := mysql.BuildSimpleTextResultset(names, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+// queryLog holds the queries a schemaServer received, in order.
+type queryLog struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (l *queryLog) add(query string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = append(l.queries, query)
+}
+
+// take returns the queries received since the last call.
+func (l *queryLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	queries := l.queries
+	l.queries = nil
+	return queries
+}
+
+// connectSchemaServer connects c to a schemaServer serving tables, so that it
+// reads the schemas of tables missing from its table cache from them.
+func connectSchemaServer(t *testing.T, c *canal.Canal, tables ...*schema.Table) {
+	t.Helper()
+	h := schemaServer{tables: map[string]*schema.Table{}}
+	for _, table := range tables {
+		h.tables[table.Schema+"."+table.Name] = table
+	}
+	connectCanal(t, c, startSchemaServer(t, h))
+}
+
+// startSchemaServer serves h to user root without a password until the test
+// ends, and returns its address.
+func startSchemaServer(t *testing.T, h schemaServer) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn, err := server.NewConn(nc, "root", "", h)
+				if err != nil {
+					return
+				}
+				for conn.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// connectCanal connects c to the server at addr for its queries.
+func connectCanal(t *testing.T, c *canal.Canal, addr string) {
+	t.Helper()
+	conn, err := client.Connect(addr, "root", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	field := reflect.ValueOf(c).Elem().FieldByName("conn")
+	if !field.IsValid() || field.Type() != reflect.TypeOf(conn) {
+		t.Fatal("canal.Canal has no conn *client.Conn field")
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(conn))
+}
+
+func TestStaleSchemaRefresh(t *testing.T) {
+	stale := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id", RawType: "int"}}, PKColumns: []int{0}}
+	altered := &schema.Table{Schema: "shop", Name: "orders", Columns: []schema.TableColumn{{Name: "id", RawType: "int"}, {Name: "note", RawType: "varchar(16)"}}, PKColumns: []int{0}}
+
+	tests := []struct {
+		name           string
+		policy         string
+		current        *schema.Table
+		wantErr        bool
+		wantUnresolved bool
+	}{
+		{name: "column added", policy: unresolvedSchemaError, current: altered},
+		{name: "still wider", policy: unresolvedSchemaPositional, current: stale, wantUnresolved: true},
+		{name: "still wider error", policy: unresolvedSchemaError, current: stale, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestInput(t, WithDatabase("shop"), WithOnUnresolvedSchema(tt.policy))
+			m.canal = newTestCanal(t, stale)
+			m.unresolvedTables = map[string]unresolvedTable{}
+			connectSchemaServer(t, m.canal, tt.current)
+
+			// The row is written right after ALTER TABLE orders ADD COLUMN
+			// note, which the cached schema does not reflect yet.
+			err := m.OnRow(&canal.RowsEvent{
+				Table:  stale,
+				Action: canal.InsertAction,
+				Rows:   [][]any{{int64(7), "gift"}},
+				Header: &replication.EventHeader{Timestamp: 1, LogPos: 100},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OnRow error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			msg, body := readStructured(t, m)
+			_, unresolved := msg.MetaGet("schema_unresolved")
+			if unresolved != tt.wantUnresolved {
+				t.Fatalf("schema_unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+			}
+			want := map[string]string{"id": "7", "note": "gift"}
+			if unresolved {
+				want = map[string]string{"col_0": "7", "col_1": "gift"}
+			}
+			for column, value := range want {
+				if fmt.Sprint(body[column]) != value {
+					t.Errorf("%s = %v, want %s", column, body[column], value)
+				}
+			}
+		})
+	}
+}